@@ -112,5 +112,48 @@ func (c *AdminClient) SetIngestionAccountFilter(filter hProtocol.AccountFilterCo
 	return c.sendHTTPRequest(req, nil)
 }
 
+func (c *AdminClient) GetIngestionTransactionFilter() (hProtocol.TransactionFilterConfig, error) {
+	var filter hProtocol.TransactionFilterConfig
+	err := c.sendGetRequest(c.getIngestionFiltersURL("transaction"), &filter)
+	return filter, err
+}
+
+func (c *AdminClient) SetIngestionTransactionFilter(filter hProtocol.TransactionFilterConfig) error {
+	buf := bytes.NewBuffer(nil)
+	err := json.NewEncoder(buf).Encode(filter)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPut, c.getIngestionFiltersURL("transaction"), buf)
+	if err != nil {
+		return errors.Wrap(err, "error creating HTTP request")
+	}
+	req.Header.Add("Content-Type", "application/json")
+	return c.sendHTTPRequest(req, nil)
+}
+
+// GetIngestionFilterMode returns how ingestion filters are combined.
+func (c *AdminClient) GetIngestionFilterMode() (hProtocol.FilterModeConfig, error) {
+	var mode hProtocol.FilterModeConfig
+	err := c.sendGetRequest(c.getIngestionFiltersURL("mode"), &mode)
+	return mode, err
+}
+
+// SetIngestionFilterMode sets how ingestion filters are combined, "all" or
+// "any".
+func (c *AdminClient) SetIngestionFilterMode(mode hProtocol.FilterModeConfig) error {
+	buf := bytes.NewBuffer(nil)
+	err := json.NewEncoder(buf).Encode(mode)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPut, c.getIngestionFiltersURL("mode"), buf)
+	if err != nil {
+		return errors.Wrap(err, "error creating HTTP request")
+	}
+	req.Header.Add("Content-Type", "application/json")
+	return c.sendHTTPRequest(req, nil)
+}
+
 // ensure that the horizon admin client implements AdminClientInterface
 var _ AdminClientInterface = &AdminClient{}
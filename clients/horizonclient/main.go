@@ -170,6 +170,10 @@ type AdminClientInterface interface {
 	GetIngestionAssetFilter() (hProtocol.AssetFilterConfig, error)
 	SetIngestionAccountFilter(hProtocol.AccountFilterConfig) error
 	SetIngestionAssetFilter(hProtocol.AssetFilterConfig) error
+	GetIngestionTransactionFilter() (hProtocol.TransactionFilterConfig, error)
+	SetIngestionTransactionFilter(hProtocol.TransactionFilterConfig) error
+	GetIngestionFilterMode() (hProtocol.FilterModeConfig, error)
+	SetIngestionFilterMode(hProtocol.FilterModeConfig) error
 }
 
 // ClientInterface contains methods implemented by the horizon client
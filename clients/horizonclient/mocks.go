@@ -373,6 +373,26 @@ func (m *MockAdminClient) SetIngestionAssetFilter(resource hProtocol.AssetFilter
 	return a.Error(0)
 }
 
+func (m *MockAdminClient) GetIngestionTransactionFilter() (hProtocol.TransactionFilterConfig, error) {
+	a := m.Called()
+	return a.Get(0).(hProtocol.TransactionFilterConfig), a.Error(1)
+}
+
+func (m *MockAdminClient) SetIngestionTransactionFilter(resource hProtocol.TransactionFilterConfig) error {
+	a := m.Called(resource)
+	return a.Error(0)
+}
+
+func (m *MockAdminClient) GetIngestionFilterMode() (hProtocol.FilterModeConfig, error) {
+	a := m.Called()
+	return a.Get(0).(hProtocol.FilterModeConfig), a.Error(1)
+}
+
+func (m *MockAdminClient) SetIngestionFilterMode(resource hProtocol.FilterModeConfig) error {
+	a := m.Called(resource)
+	return a.Error(0)
+}
+
 // ensure that the MockClient implements ClientInterface
 var _ ClientInterface = &MockClient{}
 
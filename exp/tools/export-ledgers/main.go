@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/stellar/go/historyarchive"
+	"github.com/stellar/go/ingest/ledgerbackend"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/support/log"
+)
+
+// export-ledgers runs Captive Stellar-Core and exports the ledgers it streams
+// to files that can be read by ledgerbackend.FileBackend (for example by
+// `horizon db reingest range --ledger-files-url=...`).
+//
+// When -to is not set ledgers are exported continuously until the process is
+// interrupted. When the storage already contains ledgers -from is ignored and
+// the export continues after the latest exported ledger.
+func main() {
+	storageURL := flag.String("storage-url", "", "url of the storage to write ledger files to (ex. file:///data/ledgers)")
+	from := flag.Uint("from", 2, "first ledger to export")
+	to := flag.Uint("to", 0, "last ledger to export, 0 to export continuously")
+	ledgersPerFile := flag.Uint("ledgers-per-file", ledgerbackend.DefaultLedgersPerFile, "number of ledgers in a single file")
+	binaryPath := flag.String("stellar-core-binary-path", "stellar-core", "path to the stellar-core binary")
+	configPath := flag.String("captive-core-config-path", "", "path to the captive core configuration file")
+	archiveURLs := flag.String("history-archive-urls", "", "comma separated list of history archive urls")
+	testnet := flag.Bool("testnet", false, "connect to the Stellar test network")
+	flag.Parse()
+
+	if *storageURL == "" || *configPath == "" || *archiveURLs == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	networkPassphrase := network.PublicNetworkPassphrase
+	if *testnet {
+		networkPassphrase = network.TestNetworkPassphrase
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	historyArchiveURLs := strings.Split(*archiveURLs, ",")
+	toml, err := ledgerbackend.NewCaptiveCoreTomlFromFile(*configPath, ledgerbackend.CaptiveCoreTomlParams{
+		NetworkPassphrase:  networkPassphrase,
+		HistoryArchiveURLs: historyArchiveURLs,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	core, err := ledgerbackend.NewCaptive(ledgerbackend.CaptiveCoreConfig{
+		BinaryPath:         *binaryPath,
+		NetworkPassphrase:  networkPassphrase,
+		HistoryArchiveURLs: historyArchiveURLs,
+		Toml:               toml,
+		Context:            ctx,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer core.Close()
+
+	storage, err := historyarchive.ConnectBackend(*storageURL, historyarchive.ConnectOptions{Context: ctx})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	writer, err := ledgerbackend.NewLedgerFileWriter(storage, uint32(*ledgersPerFile))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	start := uint32(*from)
+	if latest := writer.LatestLedger(); latest != 0 {
+		start = latest + 1
+		log.Infof("Storage contains ledgers up to %d, continuing from %d", latest, start)
+	}
+
+	ledgerRange := ledgerbackend.UnboundedRange(start)
+	if *to != 0 {
+		ledgerRange = ledgerbackend.BoundedRange(start, uint32(*to))
+	}
+
+	log.Infof("Exporting ledgers %s", ledgerRange)
+	exportErr := ledgerbackend.ExportLedgers(ctx, core, writer, ledgerRange)
+	if err = writer.Close(); err != nil {
+		log.Fatal(err)
+	}
+	if exportErr != nil && ctx.Err() == nil {
+		log.Fatal(exportErr)
+	}
+	log.Infof("Exported ledgers up to %d", writer.LatestLedger())
+}
@@ -17,6 +17,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	log "github.com/sirupsen/logrus"
 
@@ -35,6 +36,9 @@ type CommandOptions struct {
 	Verify       bool
 	Thorough     bool
 	SkipOptional bool
+	// StateFile is the file in which Mirror records its progress so that an
+	// interrupted mirror can be resumed. Progress is not recorded when empty.
+	StateFile string
 }
 
 type ConnectOptions struct {
@@ -46,11 +50,35 @@ type ConnectOptions struct {
 	S3Region          string
 	S3Endpoint        string
 	UnsignedRequests  bool
+	// GCSEndpoint is the endpoint of the Google Cloud Storage JSON API (ex.
+	// "http://localhost:4443/storage/v1/" for fake-gcs-server). Requests to a
+	// custom endpoint are not authenticated. If unset, STORAGE_EMULATOR_HOST
+	// environment variable is checked and if it's also unset the default
+	// endpoint with Application Default Credentials is used.
+	GCSEndpoint string
+	// AzureAccount is the name of the Azure storage account. If unset,
+	// AZURE_STORAGE_ACCOUNT environment variable is used.
+	AzureAccount string
+	// AzureKey is the shared key of the Azure storage account. If unset,
+	// AZURE_STORAGE_KEY environment variable is used.
+	AzureKey string
+	// AzureSASToken is the SAS token used when AzureKey is not set. If unset,
+	// AZURE_STORAGE_SAS_TOKEN environment variable is used.
+	AzureSASToken string
+	// AzureEndpoint is the Blob service endpoint (ex.
+	// "http://127.0.0.1:10000/devstoreaccount1" for Azurite). Defaults to
+	// https://<AzureAccount>.blob.core.windows.net.
+	AzureEndpoint string
 	// CheckpointFrequency is the number of ledgers between checkpoints
 	// if unset, DefaultCheckpointFrequency will be used
 	CheckpointFrequency uint32
 	// UserAgent is the value of `User-Agent` header. Applicable only for HTTP client.
 	UserAgent string
+	// CacheConfig configures the (optional) on-disk cache of archive files,
+	// see CachingArchiveBackend. Used only by Connect. In NewArchivePool and
+	// NewFailoverArchivePool every archive is cached in a subdirectory of
+	// Path and MaxSize is split between the archives.
+	CacheConfig CacheOptions
 }
 
 type Ledger struct {
@@ -64,6 +92,7 @@ type ArchiveBackend interface {
 	Size(path string) (int64, error)
 	GetFile(path string) (io.ReadCloser, error)
 	PutFile(path string, in io.ReadCloser) error
+	DeleteFile(path string) error
 	ListFiles(path string) (chan string, chan error)
 	CanListFiles() bool
 }
@@ -106,15 +135,31 @@ type Archive struct {
 	expectTxResultSetHashes map[uint32]Hash
 	actualTxResultSetHashes map[uint32]Hash
 
-	invalidBuckets int
-
-	invalidLedgers      int
-	invalidTxSets       int
-	invalidTxResultSets int
+	invalidBuckets map[Hash]bool
+	// listedAllBuckets is set when allBuckets contains all buckets in the
+	// archive (and not only the referenced ones).
+	listedAllBuckets bool
 
 	checkpointManager CheckpointManager
 
 	backend ArchiveBackend
+
+	// bytesRead and bytesWritten count bytes transferred by copy commands
+	// (mirror and repair).
+	bytesRead    atomic.Int64
+	bytesWritten atomic.Int64
+}
+
+// BytesRead returns the number of bytes read from the archive by copy
+// commands (mirror and repair).
+func (a *Archive) BytesRead() int64 {
+	return a.bytesRead.Load()
+}
+
+// BytesWritten returns the number of bytes written to the archive by copy
+// commands (mirror and repair).
+func (a *Archive) BytesWritten() int64 {
+	return a.bytesWritten.Load()
 }
 
 func (arch *Archive) GetCheckpointManager() CheckpointManager {
@@ -384,6 +429,7 @@ func Connect(u string, opts ConnectOptions) (*Archive, error) {
 		checkpointFiles:         make(map[string](map[uint32]bool)),
 		allBuckets:              make(map[Hash]bool),
 		referencedBuckets:       make(map[Hash]bool),
+		invalidBuckets:          make(map[Hash]bool),
 		expectLedgerHashes:      make(map[uint32]Hash),
 		actualLedgerHashes:      make(map[uint32]Hash),
 		expectTxSetHashes:       make(map[uint32]Hash),
@@ -400,33 +446,63 @@ func Connect(u string, opts ConnectOptions) (*Archive, error) {
 		return &arch, errors.New("URL is empty")
 	}
 
-	parsed, err := url.Parse(u)
+	var err error
+	arch.backend, err = ConnectBackend(u, opts)
 	if err != nil {
 		return &arch, err
 	}
+	if opts.CacheConfig.Path != "" {
+		arch.backend, err = NewCachingBackend(arch.backend, opts.CacheConfig)
+	}
+	return &arch, err
+}
+
+// ConnectBackend returns the ArchiveBackend for the given URL without
+// wrapping it in an Archive. It understands the same URL schemes as Connect
+// and is useful for storing non-archive files using the same storage layers.
+func ConnectBackend(u string, opts ConnectOptions) (ArchiveBackend, error) {
+	if u == "" {
+		return nil, errors.New("URL is empty")
+	}
+
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return nil, err
+	}
 
 	if opts.Context == nil {
 		opts.Context = context.Background()
 	}
 
+	var backend ArchiveBackend
 	pth := parsed.Path
 	if parsed.Scheme == "s3" {
 		// Inside s3, all paths start _without_ the leading /
 		if len(pth) > 0 && pth[0] == '/' {
 			pth = pth[1:]
 		}
-		arch.backend, err = makeS3Backend(parsed.Host, pth, opts)
+		backend, err = makeS3Backend(parsed.Host, pth, opts)
+	} else if parsed.Scheme == "gcs" || parsed.Scheme == "azblob" {
+		// Object names don't start with / either
+		if len(pth) > 0 && pth[0] == '/' {
+			pth = pth[1:]
+		}
+		if parsed.Scheme == "gcs" {
+			backend, err = makeGCSBackend(parsed.Host, pth, opts)
+		} else {
+			backend, err = makeAzureBlobBackend(parsed.Host, pth, opts)
+		}
 	} else if parsed.Scheme == "file" {
 		pth = path.Join(parsed.Host, pth)
-		arch.backend = makeFsBackend(pth, opts)
+		backend = makeFsBackend(pth, opts)
 	} else if parsed.Scheme == "http" || parsed.Scheme == "https" {
-		arch.backend = makeHttpBackend(parsed, opts)
+		backend = makeHttpBackend(parsed, opts)
 	} else if parsed.Scheme == "mock" {
-		arch.backend = makeMockBackend(opts)
+		backend = makeMockBackend(opts)
 	} else {
 		err = errors.New("unknown URL scheme: '" + parsed.Scheme + "'")
 	}
-	return &arch, err
+	return backend, err
 }
 
 func MustConnect(u string, opts ConnectOptions) *Archive {
@@ -5,14 +5,18 @@
 package historyarchive
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"math/rand"
+	"path/filepath"
 
 	"github.com/stellar/go/support/errors"
 	"github.com/stellar/go/xdr"
 )
 
 // A ArchivePool is just a collection of `ArchiveInterface`s so that we can
-// distribute requests fairly throughout the pool.
+// distribute requests fairly throughout the pool. See FailoverArchivePool for
+// a pool retrying failed requests using other archives.
 type ArchivePool []ArchiveInterface
 
 // NewArchivePool tries connecting to each of the provided history archive URLs,
@@ -22,14 +26,25 @@ type ArchivePool []ArchiveInterface
 // failed archive. Note that the errors for each individual archive are hard to
 // track if there's success overall.
 func NewArchivePool(archiveURLs []string, config ConnectOptions) (ArchivePool, error) {
+	archives, _, err := connectArchives(archiveURLs, config)
+	if err != nil {
+		return nil, err
+	}
+	return ArchivePool(archives), nil
+}
+
+// connectArchives connects to each of the history archive URLs, returning the
+// valid archives and their URLs.
+func connectArchives(archiveURLs []string, config ConnectOptions) ([]ArchiveInterface, []string, error) {
 	if len(archiveURLs) <= 0 {
-		return nil, errors.New("No history archives provided")
+		return nil, nil, errors.New("No history archives provided")
 	}
 
 	var lastErr error = nil
 
 	// Try connecting to all of the listed archives, but only store valid ones.
-	var validArchives ArchivePool
+	var validArchives []ArchiveInterface
+	var validURLs []string
 	for _, url := range archiveURLs {
 		archive, err := Connect(
 			url,
@@ -37,6 +52,7 @@ func NewArchivePool(archiveURLs []string, config ConnectOptions) (ArchivePool, e
 				NetworkPassphrase:   config.NetworkPassphrase,
 				CheckpointFrequency: config.CheckpointFrequency,
 				Context:             config.Context,
+				CacheConfig:         poolCacheConfig(config.CacheConfig, url, len(archiveURLs)),
 			},
 		)
 
@@ -46,13 +62,35 @@ func NewArchivePool(archiveURLs []string, config ConnectOptions) (ArchivePool, e
 		}
 
 		validArchives = append(validArchives, archive)
+		validURLs = append(validURLs, url)
 	}
 
 	if len(validArchives) == 0 {
-		return nil, lastErr
+		return nil, nil, lastErr
 	}
 
-	return validArchives, nil
+	return validArchives, validURLs, nil
+}
+
+// poolCacheConfig returns the cache config of a single archive in a pool of
+// archiveCount archives. Files with the same path can differ between archives
+// (ex. scp files) so each archive is cached in a separate directory and the
+// maximum size of the cache is split between archives.
+func poolCacheConfig(config CacheOptions, url string, archiveCount int) CacheOptions {
+	if config.Path == "" || archiveCount <= 1 {
+		return config
+	}
+
+	urlHash := sha256.Sum256([]byte(url))
+	config.Path = filepath.Join(config.Path, hex.EncodeToString(urlHash[:8]))
+	if config.MaxSize > 0 {
+		config.MaxSize /= int64(archiveCount)
+		if config.MaxSize == 0 {
+			// 0 means no limit
+			config.MaxSize = 1
+		}
+	}
+	return config
 }
 
 // Ensure the pool conforms to the ArchiveInterface
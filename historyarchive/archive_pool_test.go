@@ -0,0 +1,28 @@
+// Copyright 2021 Stellar Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+
+package historyarchive
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoolCacheConfig(t *testing.T) {
+	config := CacheOptions{Path: "/cache", MaxSize: 300}
+	assert.Equal(t, config, poolCacheConfig(config, "http://a", 1))
+
+	a := poolCacheConfig(config, "http://a", 3)
+	b := poolCacheConfig(config, "http://b", 3)
+	assert.Equal(t, int64(100), a.MaxSize)
+	assert.Equal(t, int64(100), b.MaxSize)
+	assert.NotEqual(t, a.Path, b.Path)
+	assert.Equal(t, "/cache", filepath.Dir(a.Path))
+
+	// No limit stays unlimited
+	assert.Equal(t, int64(0), poolCacheConfig(CacheOptions{Path: "/cache"}, "http://a", 3).MaxSize)
+	assert.Equal(t, CacheOptions{}, poolCacheConfig(CacheOptions{}, "http://a", 3))
+}
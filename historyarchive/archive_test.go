@@ -7,6 +7,7 @@ package historyarchive
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"crypto/rand"
 	"crypto/sha256"
 	"fmt"
@@ -14,11 +15,14 @@ import (
 	"io/ioutil"
 	"math/big"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stellar/go/xdr"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func GetTestS3Archive() *Archive {
@@ -240,6 +244,39 @@ func TestScanSlowMissing(t *testing.T) {
 	assert.Equal(t, 5, n)
 }
 
+func TestScanReport(t *testing.T) {
+	defer cleanup()
+	opts := testOptions()
+	arch := GetRandomPopulatedArchiveWithGapAt(0x1bf)
+
+	// Reference a bucket which doesn't exist, the replaced bucket becomes
+	// orphaned.
+	has, err := arch.GetCheckpointHAS(0x13f)
+	require.NoError(t, err)
+	replaced := has.CurrentBuckets[0].Curr
+	var missing Hash
+	_, err = rand.Read(missing[:])
+	require.NoError(t, err)
+	has.CurrentBuckets[0].Curr = missing.String()
+	require.NoError(t, arch.PutCheckpointHAS(0x13f, has, &CommandOptions{Force: true}))
+	orphaned, err := arch.AddRandomBucket()
+	require.NoError(t, err)
+
+	require.NoError(t, arch.Scan(opts))
+	report := arch.Report(opts)
+	assert.Equal(t, testRange(), report.Range)
+	for _, cat := range Categories() {
+		assert.Equal(t, []Range{{Low: 0x1bf, High: 0x1bf}}, report.MissingCheckpoints[cat])
+		assert.Equal(t, 14, report.CheckpointFiles[cat])
+	}
+	assert.Equal(t, []string{missing.String()}, report.MissingBuckets)
+	assert.True(t, report.OrphanedBucketsKnown)
+	assert.ElementsMatch(t, []string{replaced, orphaned.String()}, report.OrphanedBuckets)
+	assert.True(t, report.HasMissing())
+	assert.False(t, report.Verified)
+	assert.Equal(t, 0, report.InvalidCount())
+}
+
 func TestMirror(t *testing.T) {
 	defer cleanup()
 	opts := testOptions()
@@ -344,6 +381,127 @@ func TestMirrorSubsetNoPointerUpdate(t *testing.T) {
 	assert.Equal(t, oldHigh, dst.MustGetRootHAS().CurrentLedger)
 }
 
+func TestMirrorStateFile(t *testing.T) {
+	defer cleanup()
+	dir, err := ioutil.TempDir("", "mirror-state")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	opts := testOptions()
+	opts.StateFile = filepath.Join(dir, "state.json")
+	src := GetRandomPopulatedArchive()
+	dst := GetTestArchive()
+	require.NoError(t, Mirror(src, dst, opts))
+	assert.Equal(t, 0, countMissing(dst, opts))
+
+	state, err := ReadMirrorState(opts.StateFile)
+	require.NoError(t, err)
+	assert.Equal(t, []Range{testRange()}, state.Completed)
+	assert.Greater(t, dst.BytesWritten(), int64(0))
+	assert.Equal(t, dst.BytesWritten(), state.BytesWritten)
+	assert.Equal(t, src.BytesRead(), state.BytesRead)
+	// Only buckets of the latest checkpoint are kept
+	has, err := src.GetCheckpointHAS(testRange().High)
+	require.NoError(t, err)
+	buckets, err := has.Buckets()
+	require.NoError(t, err)
+	assert.Len(t, state.Buckets, len(buckets))
+
+	// Completed checkpoints are not copied again
+	resumed := GetTestArchive()
+	opts = testOptions()
+	opts.StateFile = filepath.Join(dir, "state.json")
+	require.NoError(t, Mirror(src, resumed, opts))
+	assert.Equal(t, int64(0), resumed.BytesWritten())
+
+	// New checkpoints are copied and merged into the completed range
+	next := src.checkpointManager.NextCheckpoint(testRange().High)
+	require.NoError(t, src.AddRandomCheckpoint(next))
+	opts.Range.High = next
+	require.NoError(t, Mirror(src, dst, opts))
+	assert.Equal(t, next, dst.MustGetRootHAS().CurrentLedger)
+	state, err = ReadMirrorState(opts.StateFile)
+	require.NoError(t, err)
+	assert.Equal(t, []Range{{Low: testRange().Low, High: next}}, state.Completed)
+}
+
+func TestMirrorFollow(t *testing.T) {
+	defer cleanup()
+	src := GetRandomPopulatedArchive()
+	dst := GetTestArchive()
+
+	// Returns once the high ledger is mirrored
+	require.NoError(t, MirrorFollow(context.Background(), src, dst, testOptions(), time.Millisecond, testRange().High))
+	assert.Equal(t, testRange().High, dst.MustGetRootHAS().CurrentLedger)
+
+	// The bounded starting range (ex. --last N) is extended with checkpoints
+	// published later.
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- MirrorFollow(ctx, src, dst, testOptions(), 10*time.Millisecond, 0)
+	}()
+
+	next := src.checkpointManager.NextCheckpoint(testRange().High)
+	require.NoError(t, src.AddRandomCheckpoint(next))
+	assert.Eventually(t, func() bool {
+		for _, cat := range Categories() {
+			if exists, err := dst.CategoryCheckpointExists(cat, next); err != nil || !exists {
+				return false
+			}
+		}
+		has, err := dst.GetRootHAS()
+		return err == nil && has.CurrentLedger == next
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+	assert.Equal(t, 0, countMissing(dst, &CommandOptions{Range: Range{Low: 63, High: next}, Concurrency: 16}))
+}
+
+func TestPrune(t *testing.T) {
+	defer cleanup()
+	arch := GetRandomPopulatedArchive()
+	orphaned, err := arch.AddRandomBucket()
+	require.NoError(t, err)
+	oldHAS, err := arch.GetCheckpointHAS(0x7f)
+	require.NoError(t, err)
+	oldBuckets, err := oldHAS.Buckets()
+	require.NoError(t, err)
+
+	opts := testOptions()
+	opts.Range.Low = 0x1bf
+	opts.DryRun = true
+	require.NoError(t, arch.Prune(opts))
+	exists, err := arch.BucketExists(orphaned)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = arch.CategoryCheckpointExists("ledger", 0x7f)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	opts.DryRun = false
+	require.NoError(t, arch.Prune(opts))
+	exists, err = arch.BucketExists(orphaned)
+	require.NoError(t, err)
+	assert.False(t, exists)
+	for _, cat := range Categories() {
+		exists, err = arch.CategoryCheckpointExists(cat, 0x7f)
+		require.NoError(t, err)
+		assert.False(t, exists)
+	}
+	for _, bucket := range oldBuckets {
+		exists, err = arch.BucketExists(bucket)
+		require.NoError(t, err)
+		assert.False(t, exists)
+	}
+
+	// Kept checkpoints are complete
+	assert.Equal(t, 0, countMissing(arch, &CommandOptions{
+		Range:       Range{Low: 0x1bf, High: testRange().High},
+		Concurrency: 16,
+	}))
+}
+
 func TestDryRunNoRepair(t *testing.T) {
 	defer cleanup()
 	opts := testOptions()
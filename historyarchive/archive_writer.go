@@ -0,0 +1,170 @@
+// Copyright 2021 Stellar Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+
+package historyarchive
+
+import (
+	"bytes"
+	"io"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/xdr"
+)
+
+// ArchiveLedger is a closed ledger written to a history archive by
+// ArchiveWriter.
+type ArchiveLedger struct {
+	Header xdr.LedgerHeaderHistoryEntry
+	// Transactions and Results are written only when the ledger has
+	// transactions (Results are not empty).
+	Transactions xdr.TransactionHistoryEntry
+	Results      xdr.TransactionHistoryResultEntry
+	// ScpInfo is optional.
+	ScpInfo []xdr.ScpHistoryEntry
+	// InitEntries, LiveEntries and DeadEntries are ledger entries created,
+	// updated and removed in the ledger.
+	InitEntries []xdr.LedgerEntry
+	LiveEntries []xdr.LedgerEntry
+	DeadEntries []xdr.LedgerKey
+}
+
+type ArchiveWriterOptions struct {
+	// NetworkPassphrase is written to HAS files.
+	NetworkPassphrase string
+	// SkipBucketListHashCheck disables checking that the bucket list hash
+	// after each ledger matches the ledger header, ex. when writing ledgers of
+	// a synthetic network.
+	SkipBucketListHashCheck bool
+}
+
+// ArchiveWriter writes history archive files (checkpoint files, buckets and
+// HAS files) of closed ledgers, like stellar-core does when publishing. Every
+// checkpoint is written when its last ledger is added.
+//
+// The bucket list is updated by ArchiveWriter, see BucketList for its
+// limitations. The writer can't be used after AddLedger returns an error.
+type ArchiveWriter struct {
+	archive    *Archive
+	bucketList *BucketList
+	opts       ArchiveWriterOptions
+
+	ledger       uint32
+	previousHash *xdr.Hash
+	// files contains uncompressed checkpoint files of the current checkpoint.
+	files map[string]*bytes.Buffer
+}
+
+// NewArchiveWriter returns an ArchiveWriter which writes ledgers following
+// the given checkpoint ledger. The bucket list must be the bucket list after
+// closing the checkpoint ledger (ex. loaded by LoadBucketList from its HAS).
+func NewArchiveWriter(
+	archive *Archive,
+	bucketList *BucketList,
+	checkpoint uint32,
+	opts ArchiveWriterOptions,
+) (*ArchiveWriter, error) {
+	if !archive.checkpointManager.IsCheckpoint(checkpoint) {
+		return nil, errors.Errorf("%d is not a checkpoint ledger", checkpoint)
+	}
+	w := &ArchiveWriter{
+		archive:    archive,
+		bucketList: bucketList,
+		opts:       opts,
+		ledger:     checkpoint,
+	}
+	w.reset()
+	return w, nil
+}
+
+func (w *ArchiveWriter) reset() {
+	w.files = map[string]*bytes.Buffer{}
+	for _, cat := range Categories() {
+		if cat != "history" {
+			w.files[cat] = &bytes.Buffer{}
+		}
+	}
+}
+
+// AddLedger adds the next ledger and writes the checkpoint if the ledger is a
+// checkpoint ledger.
+func (w *ArchiveWriter) AddLedger(ledger ArchiveLedger) error {
+	header := ledger.Header.Header
+	sequence := uint32(header.LedgerSeq)
+	if sequence != w.ledger+1 {
+		return errors.Errorf("expected ledger %d, got %d", w.ledger+1, sequence)
+	}
+	if w.previousHash != nil && header.PreviousLedgerHash != *w.previousHash {
+		return errors.Errorf("previous ledger hash of ledger %d does not match", sequence)
+	}
+
+	err := w.bucketList.AddBatch(
+		sequence,
+		uint32(header.LedgerVersion),
+		ledger.InitEntries,
+		ledger.LiveEntries,
+		ledger.DeadEntries,
+	)
+	if err != nil {
+		return errors.Wrapf(err, "error adding ledger %d to bucket list", sequence)
+	}
+	if !w.opts.SkipBucketListHashCheck && w.bucketList.Hash() != header.BucketListHash {
+		return errors.Errorf("bucket list hash of ledger %d does not match", sequence)
+	}
+
+	if err = xdr.MarshalFramed(w.files["ledger"], ledger.Header); err != nil {
+		return err
+	}
+	if len(ledger.Results.TxResultSet.Results) > 0 {
+		if err = xdr.MarshalFramed(w.files["transactions"], ledger.Transactions); err != nil {
+			return err
+		}
+		if err = xdr.MarshalFramed(w.files["results"], ledger.Results); err != nil {
+			return err
+		}
+	}
+	for _, scp := range ledger.ScpInfo {
+		if err = xdr.MarshalFramed(w.files["scp"], scp); err != nil {
+			return err
+		}
+	}
+
+	w.ledger = sequence
+	w.previousHash = &ledger.Header.Hash
+	if w.archive.checkpointManager.IsCheckpoint(sequence) {
+		return w.writeCheckpoint(sequence)
+	}
+	return nil
+}
+
+// writeCheckpoint writes buckets and checkpoint files first so that the HAS
+// files, written last, only reference existing files.
+func (w *ArchiveWriter) writeCheckpoint(checkpoint uint32) error {
+	if err := w.archive.putBuckets(w.bucketList); err != nil {
+		return err
+	}
+	for cat, data := range w.files {
+		pth := CategoryCheckpointPath(cat, checkpoint)
+		err := w.archive.putXdrGzFile(pth, func(out io.Writer) error {
+			_, err := data.WriteTo(out)
+			return err
+		})
+		if err != nil {
+			return errors.Wrapf(err, "error writing %s", pth)
+		}
+	}
+
+	has := w.bucketList.HistoryArchiveState(checkpoint, w.opts.NetworkPassphrase)
+	opts := &CommandOptions{Force: true}
+	if err := w.archive.PutCheckpointHAS(checkpoint, has, opts); err != nil {
+		return errors.Wrap(err, "error writing checkpoint HAS")
+	}
+	if err := w.archive.PutRootHAS(has, opts); err != nil {
+		return errors.Wrap(err, "error writing root HAS")
+	}
+	log.Printf("Wrote checkpoint 0x%8.8x", checkpoint)
+	w.reset()
+	return nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2021 Stellar Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+
+package historyarchive
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stellar/go/xdr"
+)
+
+func TestArchiveWriter(t *testing.T) {
+	defer cleanup()
+	arch := GetTestArchive()
+
+	_, err := NewArchiveWriter(arch, NewBucketList(), 64, ArchiveWriterOptions{})
+	assert.EqualError(t, err, "64 is not a checkpoint ledger")
+
+	writer, err := NewArchiveWriter(arch, NewBucketList(), 63, ArchiveWriterOptions{
+		NetworkPassphrase: "test",
+	})
+	require.NoError(t, err)
+
+	// expected is used to calculate bucket list hashes of ledger headers
+	expected := NewBucketList()
+	var previousHash xdr.Hash
+	var headers []xdr.LedgerHeaderHistoryEntry
+	for seq := uint32(64); seq <= 191; seq++ {
+		init, live, dead := testLedgerChanges(seq)
+		require.NoError(t, expected.AddBatch(seq, 18, init, live, dead))
+		header := xdr.LedgerHeaderHistoryEntry{
+			Header: xdr.LedgerHeader{
+				LedgerVersion:      18,
+				LedgerSeq:          xdr.Uint32(seq),
+				PreviousLedgerHash: previousHash,
+				BucketListHash:     expected.Hash(),
+			},
+		}
+		hash, err := HashXdr(header.Header)
+		require.NoError(t, err)
+		header.Hash = xdr.Hash(hash)
+		previousHash = header.Hash
+		headers = append(headers, header)
+
+		ledger := ArchiveLedger{
+			Header:      header,
+			InitEntries: init,
+			LiveEntries: live,
+			DeadEntries: dead,
+		}
+		if seq == 100 {
+			ledger.Transactions = xdr.TransactionHistoryEntry{LedgerSeq: xdr.Uint32(seq)}
+			ledger.Results = xdr.TransactionHistoryResultEntry{
+				LedgerSeq: xdr.Uint32(seq),
+				TxResultSet: xdr.TransactionResultSet{
+					Results: []xdr.TransactionResultPair{{
+						Result: xdr.TransactionResult{
+							Result: xdr.TransactionResultResult{Code: xdr.TransactionResultCodeTxBadSeq},
+						},
+					}},
+				},
+			}
+		}
+		require.NoError(t, writer.AddLedger(ledger))
+	}
+
+	assert.EqualError(t, writer.AddLedger(ArchiveLedger{Header: headers[0]}), "expected ledger 192, got 64")
+
+	root, err := arch.GetRootHAS()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(191), root.CurrentLedger)
+	assert.Equal(t, "test", root.NetworkPassphrase)
+	hash, err := root.BucketListHash()
+	require.NoError(t, err)
+	assert.Equal(t, headers[len(headers)-1].Header.BucketListHash, hash)
+
+	header, err := arch.GetLedgerHeader(100)
+	require.NoError(t, err)
+	assert.Equal(t, headers[100-64].Hash, header.Hash)
+	ledgers, err := arch.GetLedgers(64, 127)
+	require.NoError(t, err)
+	assert.Len(t, ledgers, 64)
+	assert.Len(t, ledgers[100].TransactionResult.TxResultSet.Results, 1)
+
+	opts := testOptions()
+	opts.Range = Range{Low: 127, High: 191}
+	require.NoError(t, arch.Scan(opts))
+	assert.False(t, arch.Report(opts).HasMissing())
+
+	loaded, err := LoadBucketList(arch, root)
+	require.NoError(t, err)
+	assert.Equal(t, expected.Hash(), loaded.Hash())
+
+	// Ledgers with unexpected bucket list hashes are rejected
+	writer, err = NewArchiveWriter(arch, loaded, 191, ArchiveWriterOptions{})
+	require.NoError(t, err)
+	header = xdr.LedgerHeaderHistoryEntry{
+		Header: xdr.LedgerHeader{LedgerVersion: 18, LedgerSeq: 192},
+	}
+	assert.EqualError(t, writer.AddLedger(ArchiveLedger{Header: header}), "bucket list hash of ledger 192 does not match")
+}
@@ -0,0 +1,352 @@
+// Copyright 2021 Stellar Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+
+package historyarchive
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/stellar/go/support/errors"
+)
+
+const (
+	azureStorageVersion = "2020-04-08"
+
+	azureAccountEnv  = "AZURE_STORAGE_ACCOUNT"
+	azureKeyEnv      = "AZURE_STORAGE_KEY"
+	azureSASTokenEnv = "AZURE_STORAGE_SAS_TOKEN"
+)
+
+// AzureBlobArchiveBackend is an ArchiveBackend storing files in a container
+// of Azure Blob Storage. It talks directly to the Blob service REST API and
+// authenticates requests using a shared key or a SAS token (or doesn't
+// authenticate at all for public containers).
+type AzureBlobArchiveBackend struct {
+	ctx       context.Context
+	client    http.Client
+	base      url.URL
+	account   string
+	key       []byte
+	sasToken  url.Values
+	container string
+	prefix    string
+	userAgent string
+}
+
+type azureListBlobsResult struct {
+	Blobs []struct {
+		Name string `xml:"Name"`
+	} `xml:"Blobs>Blob"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+func (b *AzureBlobArchiveBackend) url(blob string, query url.Values) *url.URL {
+	u := b.base
+	u.Path = path.Join(u.Path, b.container, blob)
+	values := url.Values{}
+	for k, v := range b.sasToken {
+		values[k] = v
+	}
+	for k, v := range query {
+		values[k] = v
+	}
+	u.RawQuery = values.Encode()
+	return &u
+}
+
+// sign adds SharedKey authorization to the request, see:
+// https://docs.microsoft.com/en-us/rest/api/storageservices/authorize-with-shared-key
+func (b *AzureBlobArchiveBackend) sign(req *http.Request) {
+	contentLength := ""
+	if req.ContentLength > 0 {
+		contentLength = strconv.FormatInt(req.ContentLength, 10)
+	}
+
+	var msHeaders []string
+	for name := range req.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-ms-") {
+			msHeaders = append(msHeaders, name)
+		}
+	}
+	sort.Strings(msHeaders)
+	var canonicalizedHeaders strings.Builder
+	for _, name := range msHeaders {
+		canonicalizedHeaders.WriteString(name + ":" + strings.TrimSpace(req.Header.Get(name)) + "\n")
+	}
+
+	var canonicalizedResource strings.Builder
+	canonicalizedResource.WriteString("/" + b.account + req.URL.EscapedPath())
+	query := req.URL.Query()
+	var params []string
+	for name := range query {
+		params = append(params, name)
+	}
+	sort.Strings(params)
+	for _, name := range params {
+		values := query[name]
+		sort.Strings(values)
+		canonicalizedResource.WriteString("\n" + strings.ToLower(name) + ":" + strings.Join(values, ","))
+	}
+
+	stringToSign := strings.Join([]string{
+		req.Method,
+		req.Header.Get("Content-Encoding"),
+		req.Header.Get("Content-Language"),
+		contentLength,
+		req.Header.Get("Content-MD5"),
+		req.Header.Get("Content-Type"),
+		"", // Date, x-ms-date is used instead
+		req.Header.Get("If-Modified-Since"),
+		req.Header.Get("If-Match"),
+		req.Header.Get("If-None-Match"),
+		req.Header.Get("If-Unmodified-Since"),
+		req.Header.Get("Range"),
+		canonicalizedHeaders.String() + canonicalizedResource.String(),
+	}, "\n")
+
+	mac := hmac.New(sha256.New, b.key)
+	mac.Write([]byte(stringToSign))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	req.Header.Set("Authorization", "SharedKey "+b.account+":"+signature)
+}
+
+func (b *AzureBlobArchiveBackend) makeSendRequest(method string, u *url.URL, body []byte, headers http.Header) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, u.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(b.ctx)
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+	req.Header.Set("x-ms-version", azureStorageVersion)
+	req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+	if b.userAgent != "" {
+		req.Header.Set("User-Agent", b.userAgent)
+	}
+	if len(b.key) > 0 {
+		b.sign(req)
+	}
+	logReq(req)
+	resp, err := b.client.Do(req)
+	logResp(resp)
+	return resp, err
+}
+
+func (b *AzureBlobArchiveBackend) GetFile(pth string) (io.ReadCloser, error) {
+	blob := path.Join(b.prefix, pth)
+	resp, err := b.makeSendRequest("GET", b.url(blob, nil), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkResp(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (b *AzureBlobArchiveBackend) Head(pth string) (*http.Response, error) {
+	blob := path.Join(b.prefix, pth)
+	resp, err := b.makeSendRequest("HEAD", b.url(blob, nil), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+func (b *AzureBlobArchiveBackend) Exists(pth string) (bool, error) {
+	resp, err := b.Head(pth)
+	if err != nil {
+		return false, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 400 {
+		return true, nil
+	} else if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	} else {
+		return false, errors.Errorf("Unknown status code=%d", resp.StatusCode)
+	}
+}
+
+func (b *AzureBlobArchiveBackend) Size(pth string) (int64, error) {
+	resp, err := b.Head(pth)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 400 {
+		return resp.ContentLength, nil
+	} else if resp.StatusCode == http.StatusNotFound {
+		return 0, nil
+	} else {
+		return 0, errors.Errorf("Unknown status code=%d", resp.StatusCode)
+	}
+}
+
+func (b *AzureBlobArchiveBackend) PutFile(pth string, in io.ReadCloser) error {
+	var buf bytes.Buffer
+	_, err := buf.ReadFrom(in)
+	in.Close()
+	if err != nil {
+		return err
+	}
+	blob := path.Join(b.prefix, pth)
+	headers := http.Header{}
+	headers.Set("x-ms-blob-type", "BlockBlob")
+	resp, err := b.makeSendRequest("PUT", b.url(blob, nil), buf.Bytes(), headers)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return checkResp(resp)
+}
+
+func (b *AzureBlobArchiveBackend) DeleteFile(pth string) error {
+	blob := path.Join(b.prefix, pth)
+	resp, err := b.makeSendRequest("DELETE", b.url(blob, nil), nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	return checkResp(resp)
+}
+
+func (b *AzureBlobArchiveBackend) listPage(prefix, marker string) (azureListBlobsResult, error) {
+	var result azureListBlobsResult
+	query := url.Values{}
+	query.Set("restype", "container")
+	query.Set("comp", "list")
+	query.Set("prefix", prefix)
+	if marker != "" {
+		query.Set("marker", marker)
+	}
+	resp, err := b.makeSendRequest("GET", b.url("", query), nil, nil)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+	if err = checkResp(resp); err != nil {
+		return result, err
+	}
+	if err = xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return result, errors.Wrap(err, "error decoding list blobs response")
+	}
+	return result, nil
+}
+
+func (b *AzureBlobArchiveBackend) ListFiles(pth string) (chan string, chan error) {
+	prefix := path.Join(b.prefix, pth)
+	ch := make(chan string)
+	errs := make(chan error)
+
+	go func() {
+		defer close(ch)
+		defer close(errs)
+		marker := ""
+		for {
+			result, err := b.listPage(prefix, marker)
+			if err != nil {
+				errs <- err
+				return
+			}
+			for _, blob := range result.Blobs {
+				log.WithField("key", blob.Name).Trace("azblob: ListFiles")
+				ch <- blob.Name
+			}
+			if result.NextMarker == "" {
+				return
+			}
+			marker = result.NextMarker
+		}
+	}()
+	return ch, errs
+}
+
+func (b *AzureBlobArchiveBackend) CanListFiles() bool {
+	return true
+}
+
+func makeAzureBlobBackend(container string, prefix string, opts ConnectOptions) (ArchiveBackend, error) {
+	account := opts.AzureAccount
+	if account == "" {
+		account = os.Getenv(azureAccountEnv)
+	}
+	key := opts.AzureKey
+	if key == "" {
+		key = os.Getenv(azureKeyEnv)
+	}
+	sasToken := opts.AzureSASToken
+	if sasToken == "" {
+		sasToken = os.Getenv(azureSASTokenEnv)
+	}
+
+	endpoint := opts.AzureEndpoint
+	if endpoint == "" {
+		if account == "" {
+			return nil, errors.New("azure storage account is not set")
+		}
+		endpoint = fmt.Sprintf("https://%s.blob.core.windows.net", account)
+	}
+	base, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid azure endpoint")
+	}
+
+	log.WithFields(log.Fields{"container": container,
+		"prefix":   prefix,
+		"account":  account,
+		"endpoint": base.String()}).Debug("azblob: making backend")
+
+	backend := AzureBlobArchiveBackend{
+		ctx:       opts.Context,
+		base:      *base,
+		account:   account,
+		container: container,
+		prefix:    prefix,
+		userAgent: opts.UserAgent,
+	}
+
+	if key != "" {
+		if account == "" {
+			return nil, errors.New("azure storage account is required when using a shared key")
+		}
+		backend.key, err = base64.StdEncoding.DecodeString(key)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid azure storage key")
+		}
+	} else if sasToken != "" {
+		backend.sasToken, err = url.ParseQuery(strings.TrimPrefix(sasToken, "?"))
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid azure SAS token")
+		}
+	}
+
+	return &backend, nil
+}
@@ -0,0 +1,219 @@
+// Copyright 2021 Stellar Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+
+package historyarchive
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testAzureAccount = "devstoreaccount1"
+	// testAzureKey is the well-known key of Azurite's development account.
+	testAzureKey = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+)
+
+// fakeAzureBlob implements the subset of the Blob service REST API used by
+// AzureBlobArchiveBackend. Paths are in the emulator (path-style) format:
+// /<account>/<container>/<blob>.
+type fakeAzureBlob struct {
+	lock      sync.Mutex
+	verifier  *AzureBlobArchiveBackend
+	container string
+	blobs     map[string][]byte
+}
+
+func (f *fakeAzureBlob) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	// Check the signature by signing the same request again.
+	authorization := r.Header.Get("Authorization")
+	f.verifier.sign(r)
+	if authorization == "" || authorization != r.Header.Get("Authorization") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	containerPath := "/" + testAzureAccount + "/" + f.container
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == containerPath:
+		f.list(w, r)
+	case strings.HasPrefix(r.URL.Path, containerPath+"/"):
+		name := strings.TrimPrefix(r.URL.Path, containerPath+"/")
+		switch r.Method {
+		case http.MethodPut:
+			if r.Header.Get("x-ms-blob-type") != "BlockBlob" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			data, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			f.blobs[name] = data
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodGet, http.MethodHead:
+			data, ok := f.blobs[name]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			w.Write(data)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (f *fakeAzureBlob) list(w http.ResponseWriter, r *http.Request) {
+	const pageSize = 2
+	query := r.URL.Query()
+	if query.Get("restype") != "container" || query.Get("comp") != "list" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var names []string
+	for name := range f.blobs {
+		if strings.HasPrefix(name, query.Get("prefix")) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	start := 0
+	if marker := query.Get("marker"); marker != "" {
+		start, _ = strconv.Atoi(marker)
+	}
+	end := start + pageSize
+	var result azureListBlobsResult
+	if end < len(names) {
+		result.NextMarker = strconv.Itoa(end)
+	} else {
+		end = len(names)
+	}
+	for _, name := range names[start:end] {
+		result.Blobs = append(result.Blobs, struct {
+			Name string `xml:"Name"`
+		}{name})
+	}
+	type enumerationResults struct {
+		XMLName xml.Name `xml:"EnumerationResults"`
+		azureListBlobsResult
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(enumerationResults{azureListBlobsResult: result})
+}
+
+func TestAzureBlobArchiveBackend(t *testing.T) {
+	key, err := base64.StdEncoding.DecodeString(testAzureKey)
+	require.NoError(t, err)
+	fake := &fakeAzureBlob{
+		verifier:  &AzureBlobArchiveBackend{account: testAzureAccount, key: key},
+		container: "archive",
+		blobs:     map[string][]byte{},
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	backend, err := ConnectBackend("azblob://archive/testnet", ConnectOptions{
+		AzureAccount:  testAzureAccount,
+		AzureKey:      testAzureKey,
+		AzureEndpoint: server.URL + "/" + testAzureAccount,
+	})
+	require.NoError(t, err)
+	assert.True(t, backend.CanListFiles())
+
+	exists, err := backend.Exists("history/00/00/00/history-0000003f.json")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	for _, file := range []string{
+		"history/00/00/00/history-0000003f.json",
+		"history/00/00/00/history-0000007f.json",
+		"history/00/00/00/history-000000bf.json",
+		"ledger/00/00/00/ledger-0000003f.xdr.gz",
+	} {
+		require.NoError(t, backend.PutFile(file, ioutil.NopCloser(strings.NewReader(file))))
+	}
+	assert.Contains(t, fake.blobs, "testnet/history/00/00/00/history-0000003f.json")
+
+	exists, err = backend.Exists("history/00/00/00/history-0000003f.json")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	size, err := backend.Size("ledger/00/00/00/ledger-0000003f.xdr.gz")
+	require.NoError(t, err)
+	assert.Equal(t, int64(len("ledger/00/00/00/ledger-0000003f.xdr.gz")), size)
+
+	reader, err := backend.GetFile("history/00/00/00/history-0000007f.json")
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	assert.Equal(t, "history/00/00/00/history-0000007f.json", string(data))
+
+	_, err = backend.GetFile("history/00/00/00/history-000000ff.json")
+	assert.Error(t, err)
+
+	ch, errs := backend.ListFiles("history")
+	var files []string
+	for file := range ch {
+		files = append(files, file)
+	}
+	require.NoError(t, <-errs)
+	assert.Equal(t, []string{
+		"testnet/history/00/00/00/history-0000003f.json",
+		"testnet/history/00/00/00/history-0000007f.json",
+		"testnet/history/00/00/00/history-000000bf.json",
+	}, files)
+
+	// Requests with invalid signatures are rejected
+	backend, err = ConnectBackend("azblob://archive/testnet", ConnectOptions{
+		AzureAccount:  testAzureAccount,
+		AzureKey:      base64.StdEncoding.EncodeToString([]byte("invalid")),
+		AzureEndpoint: server.URL + "/" + testAzureAccount,
+	})
+	require.NoError(t, err)
+	_, err = backend.Exists("history/00/00/00/history-0000003f.json")
+	assert.EqualError(t, err, "Unknown status code=403")
+}
+
+func TestAzureBlobArchiveBackendSASToken(t *testing.T) {
+	var query url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		assert.Empty(t, r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	backend, err := ConnectBackend("azblob://archive", ConnectOptions{
+		AzureSASToken: "?sv=2020-04-08&sig=c2lnbmF0dXJl",
+		AzureEndpoint: server.URL,
+	})
+	require.NoError(t, err)
+	exists, err := backend.Exists("history/00/00/00/history-0000003f.json")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	assert.Equal(t, "2020-04-08", query.Get("sv"))
+	assert.Equal(t, "c2lnbmF0dXJl", query.Get("sig"))
+}
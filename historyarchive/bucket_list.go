@@ -0,0 +1,511 @@
+// Copyright 2021 Stellar Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+
+package historyarchive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"io"
+	"io/ioutil"
+	"sort"
+
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/xdr"
+)
+
+const (
+	// firstProtocolSupportingInitEntryAndMetaEntry is the first protocol
+	// version with METAENTRY and INITENTRY bucket entries.
+	firstProtocolSupportingInitEntryAndMetaEntry = 11
+	// firstProtocolShadowsRemoved is the first protocol version which doesn't
+	// use shadows when merging buckets.
+	firstProtocolShadowsRemoved = 12
+)
+
+// bucket is an immutable, sorted list of bucket entries. The METAENTRY is not
+// stored in entries, it's generated from protocolVersion.
+type bucket struct {
+	hash            Hash
+	protocolVersion uint32
+	entries         []xdr.BucketEntry
+}
+
+var emptyBucket = &bucket{}
+
+func (b *bucket) isEmpty() bool {
+	return b.hash.IsZero()
+}
+
+func newBucket(protocolVersion uint32, entries []xdr.BucketEntry) (*bucket, error) {
+	if len(entries) == 0 && protocolVersion < firstProtocolSupportingInitEntryAndMetaEntry {
+		return emptyBucket, nil
+	}
+	b := &bucket{protocolVersion: protocolVersion, entries: entries}
+	hasher := sha256.New()
+	if err := b.writeTo(hasher); err != nil {
+		return nil, err
+	}
+	copy(b.hash[:], hasher.Sum(nil))
+	return b, nil
+}
+
+// writeTo writes the uncompressed contents of the bucket file: framed XDR
+// entries starting with the METAENTRY in protocol 11 and later.
+func (b *bucket) writeTo(w io.Writer) error {
+	if b.protocolVersion >= firstProtocolSupportingInitEntryAndMetaEntry {
+		meta := xdr.BucketEntry{
+			Type: xdr.BucketEntryTypeMetaentry,
+			MetaEntry: &xdr.BucketMetadata{
+				LedgerVersion: xdr.Uint32(b.protocolVersion),
+			},
+		}
+		if err := xdr.MarshalFramed(w, meta); err != nil {
+			return err
+		}
+	}
+	for _, entry := range b.entries {
+		if err := xdr.MarshalFramed(w, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bucketEntryKey returns the ledger key of a LIVEENTRY, INITENTRY or
+// DEADENTRY.
+func bucketEntryKey(entry xdr.BucketEntry) xdr.LedgerKey {
+	if entry.Type == xdr.BucketEntryTypeDeadentry {
+		return *entry.DeadEntry
+	}
+	return entry.LiveEntry.LedgerKey()
+}
+
+// ledgerKeySortKey returns a byte string which orders ledger keys the way
+// stellar-core does. The XDR encoding of keys preserves the order of all
+// fields (offer IDs are never negative) except data names, which XDR prefixes
+// with their length, so data names are appended without the prefix.
+func ledgerKeySortKey(key xdr.LedgerKey) ([]byte, error) {
+	if key.Type != xdr.LedgerEntryTypeData {
+		return key.MarshalBinary()
+	}
+	data := key.MustData()
+	typ, err := key.Type.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	account, err := data.AccountId.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	sortKey := append(typ, account...)
+	return append(sortKey, []byte(data.DataName)...), nil
+}
+
+// freshBucket creates a bucket with the changes of a single ledger.
+func freshBucket(
+	protocolVersion uint32,
+	initEntries, liveEntries []xdr.LedgerEntry,
+	deadEntries []xdr.LedgerKey,
+) (*bucket, error) {
+	entries := make([]xdr.BucketEntry, 0, len(initEntries)+len(liveEntries)+len(deadEntries))
+	for i := range initEntries {
+		entries = append(entries, xdr.BucketEntry{
+			Type:      xdr.BucketEntryTypeInitentry,
+			LiveEntry: &initEntries[i],
+		})
+	}
+	for i := range liveEntries {
+		entries = append(entries, xdr.BucketEntry{
+			Type:      xdr.BucketEntryTypeLiveentry,
+			LiveEntry: &liveEntries[i],
+		})
+	}
+	for i := range deadEntries {
+		entries = append(entries, xdr.BucketEntry{
+			Type:      xdr.BucketEntryTypeDeadentry,
+			DeadEntry: &deadEntries[i],
+		})
+	}
+
+	sortKeys := make([][]byte, len(entries))
+	for i, entry := range entries {
+		sortKey, err := ledgerKeySortKey(bucketEntryKey(entry))
+		if err != nil {
+			return nil, errors.Wrap(err, "error encoding ledger key")
+		}
+		sortKeys[i] = sortKey
+	}
+	sort.Sort(bucketEntriesByKey{entries, sortKeys})
+	for i := 1; i < len(sortKeys); i++ {
+		if bytes.Equal(sortKeys[i-1], sortKeys[i]) {
+			return nil, errors.New("duplicate ledger key in ledger changes")
+		}
+	}
+	return newBucket(protocolVersion, entries)
+}
+
+type bucketEntriesByKey struct {
+	entries  []xdr.BucketEntry
+	sortKeys [][]byte
+}
+
+func (s bucketEntriesByKey) Len() int {
+	return len(s.entries)
+}
+
+func (s bucketEntriesByKey) Less(i, j int) bool {
+	return bytes.Compare(s.sortKeys[i], s.sortKeys[j]) < 0
+}
+
+func (s bucketEntriesByKey) Swap(i, j int) {
+	s.entries[i], s.entries[j] = s.entries[j], s.entries[i]
+	s.sortKeys[i], s.sortKeys[j] = s.sortKeys[j], s.sortKeys[i]
+}
+
+// mergeBuckets merges two buckets like stellar-core does without shadows
+// (protocol 12 and later). Entries of newer replace entries of older with the
+// same key, taking INITENTRY/DEADENTRY lifecycles into account. DEADENTRYs
+// are dropped when keepDeadEntries is false (the bottom level).
+func mergeBuckets(older, newer *bucket, keepDeadEntries bool) (*bucket, error) {
+	protocolVersion := older.protocolVersion
+	if newer.protocolVersion > protocolVersion {
+		protocolVersion = newer.protocolVersion
+	}
+	lifecycles := protocolVersion >= firstProtocolSupportingInitEntryAndMetaEntry
+
+	merged := make([]xdr.BucketEntry, 0, len(older.entries)+len(newer.entries))
+	put := func(entry xdr.BucketEntry) {
+		if !keepDeadEntries && entry.Type == xdr.BucketEntryTypeDeadentry {
+			return
+		}
+		merged = append(merged, entry)
+	}
+
+	i, j := 0, 0
+	var olderKey, newerKey []byte
+	var err error
+	for i < len(older.entries) || j < len(newer.entries) {
+		if j == len(newer.entries) {
+			put(older.entries[i])
+			i++
+			continue
+		}
+		if i == len(older.entries) {
+			put(newer.entries[j])
+			j++
+			continue
+		}
+
+		if olderKey == nil {
+			if olderKey, err = ledgerKeySortKey(bucketEntryKey(older.entries[i])); err != nil {
+				return nil, errors.Wrap(err, "error encoding ledger key")
+			}
+		}
+		if newerKey == nil {
+			if newerKey, err = ledgerKeySortKey(bucketEntryKey(newer.entries[j])); err != nil {
+				return nil, errors.Wrap(err, "error encoding ledger key")
+			}
+		}
+
+		switch c := bytes.Compare(olderKey, newerKey); {
+		case c < 0:
+			put(older.entries[i])
+			i, olderKey = i+1, nil
+		case c > 0:
+			put(newer.entries[j])
+			j, newerKey = j+1, nil
+		default:
+			if !lifecycles {
+				put(newer.entries[j])
+			} else if entry, ok, err := mergeEqualKeys(older.entries[i], newer.entries[j]); err != nil {
+				return nil, err
+			} else if ok {
+				put(entry)
+			}
+			i, olderKey = i+1, nil
+			j, newerKey = j+1, nil
+		}
+	}
+	return newBucket(protocolVersion, merged)
+}
+
+// mergeEqualKeys merges two entries with the same key. It returns false if
+// the entries annihilate each other.
+func mergeEqualKeys(older, newer xdr.BucketEntry) (xdr.BucketEntry, bool, error) {
+	switch {
+	case newer.Type == xdr.BucketEntryTypeInitentry:
+		// An entry can only be created again after it was removed.
+		if older.Type != xdr.BucketEntryTypeDeadentry {
+			return xdr.BucketEntry{}, false, errors.New("malformed bucket: INITENTRY of an existing entry")
+		}
+		return xdr.BucketEntry{Type: xdr.BucketEntryTypeLiveentry, LiveEntry: newer.LiveEntry}, true, nil
+	case older.Type == xdr.BucketEntryTypeInitentry && newer.Type == xdr.BucketEntryTypeLiveentry:
+		return xdr.BucketEntry{Type: xdr.BucketEntryTypeInitentry, LiveEntry: newer.LiveEntry}, true, nil
+	case older.Type == xdr.BucketEntryTypeInitentry && newer.Type == xdr.BucketEntryTypeDeadentry:
+		return xdr.BucketEntry{}, false, nil
+	default:
+		return newer, true, nil
+	}
+}
+
+// levelSize returns the number of ledgers whose changes are stored in a
+// level (in curr and snap).
+func levelSize(level int) uint32 {
+	return 1 << (2 * (level + 1))
+}
+
+func levelHalf(level int) uint32 {
+	return levelSize(level) >> 1
+}
+
+func roundDown(v, m uint32) uint32 {
+	return v &^ (m - 1)
+}
+
+// levelShouldSpill returns true if curr of the level becomes its snap (and
+// the previous snap is merged into the next level) in the given ledger.
+func levelShouldSpill(ledger uint32, level int) bool {
+	if level == NumLevels-1 {
+		return false
+	}
+	return ledger == roundDown(ledger, levelHalf(level)) ||
+		ledger == roundDown(ledger, levelSize(level))
+}
+
+// shouldMergeWithEmptyCurr returns true if the merge prepared in the level in
+// the given ledger must not include curr because curr will spill before the
+// merge is committed.
+func shouldMergeWithEmptyCurr(ledger uint32, level int) bool {
+	if level == 0 {
+		return false
+	}
+	mergeStartLedger := roundDown(ledger, levelHalf(level-1))
+	nextChangeLedger := mergeStartLedger + levelHalf(level-1)
+	return levelShouldSpill(nextChangeLedger, level)
+}
+
+type bucketLevel struct {
+	curr, snap *bucket
+	// next is the result of the pending merge or nil.
+	next *bucket
+}
+
+// BucketList is an in-memory implementation of the stellar-core bucket list
+// which can be used to build history archives of networks in protocol 12 and
+// later (see ArchiveWriter). All buckets are kept in memory so it's only
+// suitable for small (ex. private or test) networks.
+type BucketList struct {
+	levels [NumLevels]bucketLevel
+}
+
+// NewBucketList returns an empty bucket list.
+func NewBucketList() *BucketList {
+	bl := &BucketList{}
+	for i := range bl.levels {
+		bl.levels[i] = bucketLevel{curr: emptyBucket, snap: emptyBucket}
+	}
+	return bl
+}
+
+// LoadBucketList loads the bucket list of the given HAS from the archive.
+// Pending merges without an output hash are computed again.
+func LoadBucketList(archive ArchiveInterface, has HistoryArchiveState) (*BucketList, error) {
+	bl := NewBucketList()
+	loaded := map[string]*bucket{}
+	load := func(hash string) (*bucket, error) {
+		if b, ok := loaded[hash]; ok {
+			return b, nil
+		}
+		b, err := readBucket(archive, hash)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error reading bucket %s", hash)
+		}
+		loaded[hash] = b
+		return b, nil
+	}
+
+	var err error
+	for i, level := range has.CurrentBuckets {
+		if bl.levels[i].curr, err = load(level.Curr); err != nil {
+			return nil, err
+		}
+		if bl.levels[i].snap, err = load(level.Snap); err != nil {
+			return nil, err
+		}
+	}
+	for i, level := range has.CurrentBuckets {
+		switch {
+		case level.Next.State == 0:
+		case level.Next.Output != "":
+			if bl.levels[i].next, err = load(level.Next.Output); err != nil {
+				return nil, err
+			}
+		case i == 0:
+			return nil, errors.New("unexpected pending merge in level 0")
+		default:
+			// The merge was prepared when the previous level spilled.
+			prepared := roundDown(has.CurrentLedger, levelHalf(i-1))
+			if bl.levels[i].next, err = bl.levels[i].merge(prepared, i, bl.levels[i-1].snap); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return bl, nil
+}
+
+func readBucket(archive ArchiveInterface, hash string) (*bucket, error) {
+	h, err := DecodeHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	if h.IsZero() {
+		return emptyBucket, nil
+	}
+
+	stream, err := archive.GetXdrStreamForHash(h)
+	if err != nil {
+		return nil, err
+	}
+	stream.SetExpectedHash(h)
+
+	b := &bucket{hash: h}
+	for {
+		var entry xdr.BucketEntry
+		if err = stream.ReadOne(&entry); err == io.EOF {
+			break
+		} else if err != nil {
+			stream.Close()
+			return nil, err
+		}
+		if entry.Type == xdr.BucketEntryTypeMetaentry {
+			b.protocolVersion = uint32(entry.MustMetaEntry().LedgerVersion)
+			continue
+		}
+		b.entries = append(b.entries, entry)
+	}
+	if err = stream.Close(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (l *bucketLevel) merge(ledger uint32, level int, snap *bucket) (*bucket, error) {
+	curr := l.curr
+	if shouldMergeWithEmptyCurr(ledger, level) {
+		curr = emptyBucket
+	}
+	return mergeBuckets(curr, snap, level < NumLevels-1)
+}
+
+// AddBatch adds the changes of the given ledger to the bucket list. Ledgers
+// must be added in order and the protocol version must be 12 or later.
+func (bl *BucketList) AddBatch(
+	ledger, protocolVersion uint32,
+	initEntries, liveEntries []xdr.LedgerEntry,
+	deadEntries []xdr.LedgerKey,
+) error {
+	if protocolVersion < firstProtocolShadowsRemoved {
+		return errors.Errorf("protocol version %d is not supported", protocolVersion)
+	}
+
+	var err error
+	for i := NumLevels - 1; i > 0; i-- {
+		if !levelShouldSpill(ledger, i-1) {
+			continue
+		}
+		prev, level := &bl.levels[i-1], &bl.levels[i]
+		prev.snap, prev.curr = prev.curr, emptyBucket
+		if level.next != nil {
+			level.curr, level.next = level.next, nil
+		}
+		if level.next, err = level.merge(ledger, i, prev.snap); err != nil {
+			return errors.Wrapf(err, "error merging buckets in level %d", i)
+		}
+	}
+
+	fresh, err := freshBucket(protocolVersion, initEntries, liveEntries, deadEntries)
+	if err != nil {
+		return err
+	}
+	if bl.levels[0].curr, err = bl.levels[0].merge(ledger, 0, fresh); err != nil {
+		return errors.Wrap(err, "error merging buckets in level 0")
+	}
+	return nil
+}
+
+// Hash returns the bucket list hash stored in ledger headers.
+func (bl *BucketList) Hash() xdr.Hash {
+	total := make([]byte, 0, NumLevels*sha256.Size)
+	for _, level := range bl.levels {
+		levelHash := sha256.Sum256(append(level.curr.hash[:], level.snap.hash[:]...))
+		total = append(total, levelHash[:]...)
+	}
+	return sha256.Sum256(total)
+}
+
+// HistoryArchiveState returns the HAS of the bucket list after closing the
+// given ledger.
+func (bl *BucketList) HistoryArchiveState(ledger uint32, networkPassphrase string) HistoryArchiveState {
+	has := HistoryArchiveState{
+		Version:           1,
+		Server:            "stellar/go historyarchive",
+		CurrentLedger:     ledger,
+		NetworkPassphrase: networkPassphrase,
+	}
+	for i, level := range bl.levels {
+		has.CurrentBuckets[i].Curr = level.curr.hash.String()
+		has.CurrentBuckets[i].Snap = level.snap.hash.String()
+		if level.next != nil {
+			has.CurrentBuckets[i].Next.State = 1
+			has.CurrentBuckets[i].Next.Output = level.next.hash.String()
+		}
+	}
+	return has
+}
+
+// buckets returns the non-empty buckets of the bucket list.
+func (bl *BucketList) buckets() []*bucket {
+	var buckets []*bucket
+	for _, level := range bl.levels {
+		for _, b := range []*bucket{level.curr, level.snap, level.next} {
+			if b != nil && !b.isEmpty() {
+				buckets = append(buckets, b)
+			}
+		}
+	}
+	return buckets
+}
+
+// putXdrGzFile gzips the data written by write and stores it in the archive.
+func (a *Archive) putXdrGzFile(pth string, write func(w io.Writer) error) error {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if err := write(gz); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return a.backend.PutFile(pth, ioutil.NopCloser(&buf))
+}
+
+// putBuckets uploads buckets of the bucket list which are not in the archive
+// yet.
+func (a *Archive) putBuckets(bl *BucketList) error {
+	for _, b := range bl.buckets() {
+		exists, err := a.BucketExists(b.hash)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if err = a.putXdrGzFile(BucketPath(b.hash), b.writeTo); err != nil {
+			return errors.Wrapf(err, "error writing bucket %s", b.hash)
+		}
+	}
+	return nil
+}
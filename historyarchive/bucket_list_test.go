@@ -0,0 +1,151 @@
+// Copyright 2021 Stellar Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+
+package historyarchive
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stellar/go/xdr"
+)
+
+func testAccountEntry(id uint32, balance int64) xdr.LedgerEntry {
+	var key xdr.Uint256
+	binary.BigEndian.PutUint32(key[:], id)
+	accountID, err := xdr.NewAccountId(xdr.PublicKeyTypePublicKeyTypeEd25519, key)
+	if err != nil {
+		panic(err)
+	}
+	return xdr.LedgerEntry{
+		Data: xdr.LedgerEntryData{
+			Type: xdr.LedgerEntryTypeAccount,
+			Account: &xdr.AccountEntry{
+				AccountId: accountID,
+				Balance:   xdr.Int64(balance),
+			},
+		},
+	}
+}
+
+// testLedgerChanges returns changes of the ledger: account seq is created,
+// account seq-1 updated and in even ledgers account seq-2 removed.
+func testLedgerChanges(seq uint32) ([]xdr.LedgerEntry, []xdr.LedgerEntry, []xdr.LedgerKey) {
+	init := []xdr.LedgerEntry{testAccountEntry(seq, 1)}
+	var live []xdr.LedgerEntry
+	var dead []xdr.LedgerKey
+	if seq > 1 {
+		live = append(live, testAccountEntry(seq-1, 2))
+	}
+	if seq > 2 && seq%2 == 0 {
+		entry := testAccountEntry(seq-2, 0)
+		dead = append(dead, entry.LedgerKey())
+	}
+	return init, live, dead
+}
+
+func TestMergeBuckets(t *testing.T) {
+	a, b, c := testAccountEntry(1, 100), testAccountEntry(2, 100), testAccountEntry(3, 100)
+	older, err := freshBucket(18, []xdr.LedgerEntry{a, b}, nil, []xdr.LedgerKey{c.LedgerKey()})
+	require.NoError(t, err)
+	updatedA := testAccountEntry(1, 200)
+	newer, err := freshBucket(18, []xdr.LedgerEntry{c}, []xdr.LedgerEntry{updatedA}, []xdr.LedgerKey{b.LedgerKey()})
+	require.NoError(t, err)
+
+	merged, err := mergeBuckets(older, newer, true)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(18), merged.protocolVersion)
+	require.Len(t, merged.entries, 2)
+	// Updated INITENTRY stays INITENTRY, INITENTRY and DEADENTRY annihilate
+	// and created DEADENTRY becomes LIVEENTRY.
+	assert.Equal(t, xdr.BucketEntryTypeInitentry, merged.entries[0].Type)
+	assert.Equal(t, xdr.Int64(200), merged.entries[0].LiveEntry.Data.Account.Balance)
+	assert.Equal(t, xdr.BucketEntryTypeLiveentry, merged.entries[1].Type)
+	assert.Equal(t, c.LedgerKey(), merged.entries[1].LiveEntry.LedgerKey())
+
+	// DEADENTRYs are dropped in the bottom level
+	bottom, err := mergeBuckets(emptyBucket, older, false)
+	require.NoError(t, err)
+	assert.Len(t, bottom.entries, 2)
+
+	// An existing entry can't be created again
+	invalid, err := freshBucket(18, []xdr.LedgerEntry{a}, nil, nil)
+	require.NoError(t, err)
+	_, err = mergeBuckets(older, invalid, true)
+	assert.EqualError(t, err, "malformed bucket: INITENTRY of an existing entry")
+}
+
+func TestBucketHashes(t *testing.T) {
+	// Merging empty buckets gives an empty bucket but buckets with only
+	// METAENTRY are not empty.
+	merged, err := mergeBuckets(emptyBucket, emptyBucket, true)
+	require.NoError(t, err)
+	assert.True(t, merged.isEmpty())
+
+	fresh, err := freshBucket(18, nil, nil, nil)
+	require.NoError(t, err)
+	assert.False(t, fresh.isEmpty())
+
+	// Entries are sorted so the hash doesn't depend on the order of changes
+	a, b := testAccountEntry(1, 100), testAccountEntry(2, 100)
+	first, err := freshBucket(18, nil, []xdr.LedgerEntry{a, b}, nil)
+	require.NoError(t, err)
+	second, err := freshBucket(18, nil, []xdr.LedgerEntry{b, a}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, first.hash, second.hash)
+	third, err := freshBucket(18, []xdr.LedgerEntry{a}, []xdr.LedgerEntry{b}, nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, first.hash, third.hash)
+
+	_, err = freshBucket(18, []xdr.LedgerEntry{a}, []xdr.LedgerEntry{a}, nil)
+	assert.EqualError(t, err, "duplicate ledger key in ledger changes")
+}
+
+func TestLevelShouldSpill(t *testing.T) {
+	assert.True(t, levelShouldSpill(2, 0))
+	assert.False(t, levelShouldSpill(3, 0))
+	assert.True(t, levelShouldSpill(8, 1))
+	assert.False(t, levelShouldSpill(10, 1))
+	assert.False(t, levelShouldSpill(0, NumLevels-1))
+}
+
+func TestLoadBucketList(t *testing.T) {
+	defer cleanup()
+	arch := GetTestArchive()
+
+	bl := NewBucketList()
+	for seq := uint32(1); seq <= 1000; seq++ {
+		init, live, dead := testLedgerChanges(seq)
+		require.NoError(t, bl.AddBatch(seq, 18, init, live, dead))
+	}
+	has := bl.HistoryArchiveState(1000, "test")
+	hash, err := has.BucketListHash()
+	require.NoError(t, err)
+	assert.Equal(t, bl.Hash(), hash)
+	require.NoError(t, arch.putBuckets(bl))
+
+	loaded, err := LoadBucketList(arch, has)
+	require.NoError(t, err)
+	assert.Equal(t, has, loaded.HistoryArchiveState(1000, "test"))
+
+	// Pending merges without outputs are merged again
+	for i := range has.CurrentBuckets {
+		has.CurrentBuckets[i].Next.Output = ""
+	}
+	recomputed, err := LoadBucketList(arch, has)
+	require.NoError(t, err)
+	assert.Equal(t, bl.HistoryArchiveState(1000, "test"), recomputed.HistoryArchiveState(1000, "test"))
+
+	for seq := uint32(1001); seq <= 1100; seq++ {
+		init, live, dead := testLedgerChanges(seq)
+		require.NoError(t, bl.AddBatch(seq, 18, init, live, dead))
+		require.NoError(t, recomputed.AddBatch(seq, 18, init, live, dead))
+	}
+	assert.Equal(t, bl.Hash(), recomputed.Hash())
+
+	assert.EqualError(t, bl.AddBatch(1101, 11, nil, nil, nil), "protocol version 11 is not supported")
+}
@@ -0,0 +1,384 @@
+// Copyright 2021 Stellar Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+
+package historyarchive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/stellar/go/support/errors"
+)
+
+const (
+	cacheTempFilePrefix = ".download-"
+	staleTempFileAge    = time.Hour
+)
+
+// cacheablePathRegex matches files which never change once published: buckets
+// and checkpoint files. The root HAS file is updated on every checkpoint so
+// it's never cached.
+var cacheablePathRegex = regexp.MustCompile(
+	`^(bucket|history|ledger|transactions|results|scp)` + hexPrefixPat,
+)
+
+var bucketPathRegex = regexp.MustCompile(`bucket-([0-9a-f]{64})\.xdr\.gz$`)
+
+// CacheOptions configures the on-disk cache of history archive files.
+type CacheOptions struct {
+	// Path is the local directory in which files are cached. Caching is
+	// disabled when it's empty.
+	Path string
+	// MaxSize is the maximum total size (in bytes) of cached files. When it's
+	// exceeded the least recently used files are removed. 0 means no limit.
+	MaxSize int64
+}
+
+type cacheEntry struct {
+	path string
+	size int64
+}
+
+// CachingArchiveBackend wraps an ArchiveBackend and stores files read from
+// it in a local directory so that subsequent reads (also after a restart)
+// don't hit the network. Only buckets and checkpoint files (which never
+// change) are cached. Buckets are verified against their hash before they are
+// added to the cache.
+type CachingArchiveBackend struct {
+	backend ArchiveBackend
+	dir     string
+	maxSize int64
+
+	lock sync.Mutex
+	// lru contains *cacheEntry values, the most recently used in front.
+	lru     *list.List
+	entries map[string]*list.Element
+	size    int64
+}
+
+// NewCachingBackend returns a CachingArchiveBackend wrapping the given backend.
+// Files already present in opts.Path (from previous runs) are reused.
+func NewCachingBackend(backend ArchiveBackend, opts CacheOptions) (*CachingArchiveBackend, error) {
+	if opts.Path == "" {
+		return nil, errors.New("cache path is empty")
+	}
+	if err := os.MkdirAll(opts.Path, 0755); err != nil {
+		return nil, errors.Wrap(err, "error creating cache directory")
+	}
+
+	b := &CachingArchiveBackend{
+		backend: backend,
+		dir:     opts.Path,
+		maxSize: opts.MaxSize,
+		lru:     list.New(),
+		entries: map[string]*list.Element{},
+	}
+	if err := b.load(); err != nil {
+		return nil, errors.Wrap(err, "error loading cache directory")
+	}
+	return b, nil
+}
+
+// load builds the LRU list from the files in the cache directory using their
+// modification times (which are updated on every cache hit).
+func (b *CachingArchiveBackend) load() error {
+	type cachedFile struct {
+		cacheEntry
+		modTime time.Time
+	}
+	var files []cachedFile
+	err := filepath.Walk(b.dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		if strings.HasPrefix(info.Name(), cacheTempFilePrefix) {
+			// Leftover of an interrupted download (recent files can be still
+			// written by another process sharing the directory).
+			if time.Since(info.ModTime()) > staleTempFileAge {
+				return os.Remove(p)
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(b.dir, p)
+		if err != nil {
+			return err
+		}
+		files = append(files, cachedFile{
+			cacheEntry: cacheEntry{path: filepath.ToSlash(rel), size: info.Size()},
+			modTime:    info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for _, file := range files {
+		entry := file.cacheEntry
+		b.entries[entry.path] = b.lru.PushFront(&entry)
+		b.size += entry.size
+	}
+	b.evict()
+	log.WithFields(log.Fields{"files": len(b.entries), "size": b.size}).
+		Debug("cache: loaded cache directory")
+	return nil
+}
+
+func (b *CachingArchiveBackend) localPath(pth string) string {
+	return filepath.Join(b.dir, filepath.FromSlash(pth))
+}
+
+// evict removes the least recently used files until the cache size is within
+// the limit. Must be called with the lock held.
+func (b *CachingArchiveBackend) evict() {
+	for b.maxSize > 0 && b.size > b.maxSize && b.lru.Len() > 0 {
+		b.removeElement(b.lru.Back())
+	}
+}
+
+// removeElement removes the file from the cache. Must be called with the lock
+// held.
+func (b *CachingArchiveBackend) removeElement(element *list.Element) {
+	entry := element.Value.(*cacheEntry)
+	b.lru.Remove(element)
+	delete(b.entries, entry.path)
+	b.size -= entry.size
+	if err := os.Remove(b.localPath(entry.path)); err != nil && !os.IsNotExist(err) {
+		log.WithField("path", entry.path).WithError(err).Warn("cache: error removing file")
+	}
+}
+
+func (b *CachingArchiveBackend) remove(pth string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if element, ok := b.entries[pth]; ok {
+		b.removeElement(element)
+	}
+}
+
+// lookup returns the cache entry of the file (if cached) and marks it as
+// recently used.
+func (b *CachingArchiveBackend) lookup(pth string) (cacheEntry, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	element, ok := b.entries[pth]
+	if !ok {
+		return cacheEntry{}, false
+	}
+	b.lru.MoveToFront(element)
+	now := time.Now()
+	// Persist the LRU order between restarts, errors are not important.
+	_ = os.Chtimes(b.localPath(pth), now, now)
+	return *element.Value.(*cacheEntry), true
+}
+
+func (b *CachingArchiveBackend) open(pth string) (*os.File, bool) {
+	if _, ok := b.lookup(pth); !ok {
+		return nil, false
+	}
+	f, err := os.Open(b.localPath(pth))
+	if err != nil {
+		// The file was removed from the directory by someone else.
+		log.WithField("path", pth).WithError(err).Warn("cache: error opening cached file")
+		b.remove(pth)
+		return nil, false
+	}
+	return f, true
+}
+
+// commit moves a downloaded file into the cache.
+func (b *CachingArchiveBackend) commit(pth, tempPath string, size int64) error {
+	if match := bucketPathRegex.FindStringSubmatch(pth); match != nil {
+		if err := verifyBucketFile(tempPath, match[1]); err != nil {
+			return err
+		}
+	}
+
+	localPath := b.localPath(pth)
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(tempPath, localPath); err != nil {
+		return err
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if element, ok := b.entries[pth]; ok {
+		// Downloaded concurrently by another reader
+		b.size -= element.Value.(*cacheEntry).size
+		b.lru.Remove(element)
+	}
+	b.entries[pth] = b.lru.PushFront(&cacheEntry{path: pth, size: size})
+	b.size += size
+	b.evict()
+	return nil
+}
+
+// verifyBucketFile checks if the hash of the decompressed contents of the
+// given file matches the expected hash.
+func verifyBucketFile(pth, expectedHash string) error {
+	f, err := os.Open(pth)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	rdr, err := gzip.NewReader(f)
+	if err != nil {
+		return errors.Wrap(err, "error decompressing bucket")
+	}
+	defer rdr.Close()
+	hasher := sha256.New()
+	if _, err = io.Copy(hasher, rdr); err != nil {
+		return errors.Wrap(err, "error decompressing bucket")
+	}
+	expected, err := hex.DecodeString(expectedHash)
+	if err != nil {
+		return err
+	}
+	if actual := hasher.Sum(nil); !bytes.Equal(expected, actual) {
+		return errors.Errorf(
+			"bucket hash mismatch (expected=%s actual=%s)",
+			expectedHash, hex.EncodeToString(actual),
+		)
+	}
+	return nil
+}
+
+// cachingReader copies everything read from the remote file into a temporary
+// file which is added to the cache once the remote file is read completely.
+type cachingReader struct {
+	backend  *CachingArchiveBackend
+	path     string
+	rdr      io.ReadCloser
+	temp     *os.File
+	size     int64
+	complete bool
+	failed   bool
+}
+
+func (r *cachingReader) Read(p []byte) (int, error) {
+	n, err := r.rdr.Read(p)
+	if n > 0 && !r.failed {
+		if _, werr := r.temp.Write(p[:n]); werr != nil {
+			log.WithField("path", r.path).WithError(werr).Warn("cache: error writing file")
+			r.failed = true
+		}
+		r.size += int64(n)
+	}
+	if err == io.EOF {
+		r.complete = true
+	}
+	return n, err
+}
+
+func (r *cachingReader) Close() error {
+	err := r.rdr.Close()
+	if cerr := r.temp.Close(); cerr != nil {
+		r.failed = true
+	}
+	if !r.complete || r.failed {
+		os.Remove(r.temp.Name())
+		return err
+	}
+	if cerr := r.backend.commit(r.path, r.temp.Name(), r.size); cerr != nil {
+		log.WithField("path", r.path).WithError(cerr).Warn("cache: file not added to cache")
+		os.Remove(r.temp.Name())
+	}
+	return err
+}
+
+func (b *CachingArchiveBackend) GetFile(pth string) (io.ReadCloser, error) {
+	if !cacheablePathRegex.MatchString(pth) {
+		return b.backend.GetFile(pth)
+	}
+	if f, ok := b.open(pth); ok {
+		log.WithField("path", pth).Trace("cache: hit")
+		return f, nil
+	}
+
+	log.WithField("path", pth).Trace("cache: miss")
+	rdr, err := b.backend.GetFile(pth)
+	if err != nil {
+		return nil, err
+	}
+	temp, err := ioutil.TempFile(b.dir, cacheTempFilePrefix)
+	if err != nil {
+		log.WithError(err).Warn("cache: error creating temporary file")
+		return rdr, nil
+	}
+	return &cachingReader{
+		backend: b,
+		path:    pth,
+		rdr:     rdr,
+		temp:    temp,
+	}, nil
+}
+
+func (b *CachingArchiveBackend) Exists(pth string) (bool, error) {
+	if cacheablePathRegex.MatchString(pth) {
+		if _, ok := b.lookup(pth); ok {
+			return true, nil
+		}
+	}
+	return b.backend.Exists(pth)
+}
+
+func (b *CachingArchiveBackend) Size(pth string) (int64, error) {
+	if cacheablePathRegex.MatchString(pth) {
+		if entry, ok := b.lookup(pth); ok {
+			return entry.size, nil
+		}
+	}
+	return b.backend.Size(pth)
+}
+
+func (b *CachingArchiveBackend) PutFile(pth string, in io.ReadCloser) error {
+	// The file can be overwritten (ex. `stellar-archivist repair --force`)
+	b.remove(pth)
+	return b.backend.PutFile(pth, in)
+}
+
+func (b *CachingArchiveBackend) DeleteFile(pth string) error {
+	b.remove(pth)
+	return b.backend.DeleteFile(pth)
+}
+
+func (b *CachingArchiveBackend) ListFiles(pth string) (chan string, chan error) {
+	return b.backend.ListFiles(pth)
+}
+
+func (b *CachingArchiveBackend) CanListFiles() bool {
+	return b.backend.CanListFiles()
+}
+
+// CachedSize returns the total size (in bytes) of the cached files.
+func (b *CachingArchiveBackend) CachedSize() int64 {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.size
+}
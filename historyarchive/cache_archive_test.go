@@ -0,0 +1,169 @@
+// Copyright 2021 Stellar Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+
+package historyarchive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha256"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingBackend counts GetFile calls of the wrapped backend.
+type countingBackend struct {
+	ArchiveBackend
+	gets map[string]int
+}
+
+func (b *countingBackend) GetFile(pth string) (io.ReadCloser, error) {
+	b.gets[pth]++
+	return b.ArchiveBackend.GetFile(pth)
+}
+
+func randomBucket(t *testing.T, size int) (string, []byte) {
+	contents := make([]byte, size)
+	_, err := rand.Read(contents)
+	require.NoError(t, err)
+
+	var gzipped bytes.Buffer
+	w := gzip.NewWriter(&gzipped)
+	_, err = w.Write(contents)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return BucketPath(Hash(sha256.Sum256(contents))), gzipped.Bytes()
+}
+
+func readFile(t *testing.T, backend ArchiveBackend, pth string) []byte {
+	rdr, err := backend.GetFile(pth)
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(rdr)
+	require.NoError(t, err)
+	require.NoError(t, rdr.Close())
+	return data
+}
+
+func newTestCachingBackend(t *testing.T, maxSize int64) (*CachingArchiveBackend, *countingBackend, string) {
+	dir, err := ioutil.TempDir("", "archive-cache")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	remote := &countingBackend{
+		ArchiveBackend: makeMockBackend(ConnectOptions{}),
+		gets:           map[string]int{},
+	}
+	cache, err := NewCachingBackend(remote, CacheOptions{Path: dir, MaxSize: maxSize})
+	require.NoError(t, err)
+	return cache, remote, dir
+}
+
+func TestCachingBackendCachesImmutableFiles(t *testing.T) {
+	cache, remote, dir := newTestCachingBackend(t, 0)
+
+	bucketPath, bucket := randomBucket(t, 1024)
+	ledgerPath := CategoryCheckpointPath("ledger", 63)
+	for pth, data := range map[string][]byte{
+		bucketPath:  bucket,
+		ledgerPath:  []byte("ledger"),
+		rootHASPath: []byte("has"),
+	} {
+		require.NoError(t, remote.PutFile(pth, ioutil.NopCloser(bytes.NewReader(data))))
+	}
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, bucket, readFile(t, cache, bucketPath))
+		assert.Equal(t, []byte("ledger"), readFile(t, cache, ledgerPath))
+		assert.Equal(t, []byte("has"), readFile(t, cache, rootHASPath))
+	}
+	assert.Equal(t, 1, remote.gets[bucketPath])
+	assert.Equal(t, 1, remote.gets[ledgerPath])
+	// The root HAS changes so it's never cached
+	assert.Equal(t, 3, remote.gets[rootHASPath])
+
+	size, err := cache.Size(bucketPath)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(bucket)), size)
+	assert.Equal(t, int64(len(bucket)+len("ledger")), cache.CachedSize())
+
+	// Files are reused after a restart
+	restarted, err := NewCachingBackend(remote, CacheOptions{Path: dir})
+	require.NoError(t, err)
+	assert.Equal(t, bucket, readFile(t, restarted, bucketPath))
+	assert.Equal(t, 1, remote.gets[bucketPath])
+	exists, err := restarted.Exists(ledgerPath)
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestCachingBackendPartialRead(t *testing.T) {
+	cache, remote, _ := newTestCachingBackend(t, 0)
+	bucketPath, bucket := randomBucket(t, 1024)
+	require.NoError(t, remote.PutFile(bucketPath, ioutil.NopCloser(bytes.NewReader(bucket))))
+
+	rdr, err := cache.GetFile(bucketPath)
+	require.NoError(t, err)
+	_, err = rdr.Read(make([]byte, 10))
+	require.NoError(t, err)
+	require.NoError(t, rdr.Close())
+	assert.Equal(t, int64(0), cache.CachedSize())
+
+	assert.Equal(t, bucket, readFile(t, cache, bucketPath))
+	assert.Equal(t, bucket, readFile(t, cache, bucketPath))
+	assert.Equal(t, 2, remote.gets[bucketPath])
+}
+
+func TestCachingBackendInvalidBucket(t *testing.T) {
+	cache, remote, dir := newTestCachingBackend(t, 0)
+	bucketPath, _ := randomBucket(t, 1024)
+	_, otherBucket := randomBucket(t, 1024)
+	require.NoError(t, remote.PutFile(bucketPath, ioutil.NopCloser(bytes.NewReader(otherBucket))))
+
+	readFile(t, cache, bucketPath)
+	readFile(t, cache, bucketPath)
+	assert.Equal(t, 2, remote.gets[bucketPath])
+	assert.Equal(t, int64(0), cache.CachedSize())
+	_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(bucketPath)))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestCachingBackendEviction(t *testing.T) {
+	cache, remote, dir := newTestCachingBackend(t, 2500)
+	var paths []string
+	for i := 0; i < 3; i++ {
+		bucketPath, bucket := randomBucket(t, 1024)
+		require.NoError(t, remote.PutFile(bucketPath, ioutil.NopCloser(bytes.NewReader(bucket))))
+		paths = append(paths, bucketPath)
+	}
+	sizes := map[string]int64{}
+	for _, pth := range paths {
+		size, err := remote.Size(pth)
+		require.NoError(t, err)
+		sizes[pth] = size
+	}
+	require.Less(t, sizes[paths[0]]+sizes[paths[1]], int64(2500))
+	require.Greater(t, sizes[paths[0]]+sizes[paths[1]]+sizes[paths[2]], int64(2500))
+
+	readFile(t, cache, paths[0])
+	readFile(t, cache, paths[1])
+	// paths[0] becomes the most recently used file
+	readFile(t, cache, paths[0])
+	readFile(t, cache, paths[2])
+
+	assert.Equal(t, sizes[paths[0]]+sizes[paths[2]], cache.CachedSize())
+	_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(paths[1])))
+	assert.True(t, os.IsNotExist(err))
+
+	readFile(t, cache, paths[0])
+	readFile(t, cache, paths[2])
+	assert.Equal(t, 1, remote.gets[paths[0]])
+	assert.Equal(t, 1, remote.gets[paths[2]])
+}
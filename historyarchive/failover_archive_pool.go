@@ -0,0 +1,393 @@
+// Copyright 2021 Stellar Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+
+package historyarchive
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/xdr"
+)
+
+const (
+	defaultPoolErrorThreshold    = 3
+	defaultPoolBlacklistDuration = time.Minute
+)
+
+// FailoverArchivePoolOptions configures the failover behaviour of a
+// FailoverArchivePool.
+type FailoverArchivePoolOptions struct {
+	// MaxRetries is the maximum number of times a failed call is retried
+	// using a different archive. If unset, every archive in the pool is tried
+	// once. Set to a negative value to disable retries.
+	MaxRetries int
+	// ErrorThreshold is the number of consecutive failed calls after which an
+	// archive is blacklisted, 3 if unset.
+	ErrorThreshold int
+	// BlacklistDuration is the time for which an unhealthy archive is not
+	// used (unless all archives are blacklisted), 1 minute if unset.
+	BlacklistDuration time.Duration
+	// MetricsNamespace is the namespace of the metrics registered in
+	// RegisterMetrics.
+	MetricsNamespace string
+}
+
+// archiveHealth describes the health of a single archive in the pool.
+type archiveHealth struct {
+	name              string
+	archive           ArchiveInterface
+	consecutiveErrors int
+	blacklistedUntil  time.Time
+}
+
+type archivePoolMetrics struct {
+	requestsCounter   *prometheus.CounterVec
+	requestsDuration  *prometheus.SummaryVec
+	blacklistedGauge  *prometheus.GaugeVec
+	blacklistsCounter *prometheus.CounterVec
+}
+
+// A FailoverArchivePool is a collection of `ArchiveInterface`s like
+// ArchivePool which also keeps track of failures of each archive: failed
+// calls are retried using a different archive and archives which fail
+// repeatedly are temporarily blacklisted.
+type FailoverArchivePool struct {
+	options FailoverArchivePoolOptions
+	metrics archivePoolMetrics
+
+	lock     sync.Mutex
+	archives []*archiveHealth
+	now      func() time.Time
+}
+
+// NewFailoverArchivePool tries connecting to each of the provided history
+// archive URLs, returning a pool of valid archives (see NewArchivePool).
+func NewFailoverArchivePool(archiveURLs []string, config ConnectOptions, options FailoverArchivePoolOptions) (*FailoverArchivePool, error) {
+	archives, names, err := connectArchives(archiveURLs, config)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := NewFailoverArchivePoolFromArchives(archives, options)
+	for i, name := range names {
+		pool.archives[i].name = name
+	}
+	return pool, nil
+}
+
+// NewFailoverArchivePoolFromArchives returns a pool of the given archives. Archives are
+// identified by their index in metrics and logs.
+func NewFailoverArchivePoolFromArchives(archives []ArchiveInterface, options FailoverArchivePoolOptions) *FailoverArchivePool {
+	if options.MaxRetries == 0 {
+		options.MaxRetries = len(archives) - 1
+	}
+	if options.ErrorThreshold <= 0 {
+		options.ErrorThreshold = defaultPoolErrorThreshold
+	}
+	if options.BlacklistDuration <= 0 {
+		options.BlacklistDuration = defaultPoolBlacklistDuration
+	}
+
+	pool := &FailoverArchivePool{
+		options: options,
+		now:     time.Now,
+		metrics: newArchivePoolMetrics(options.MetricsNamespace),
+	}
+	for i, archive := range archives {
+		pool.archives = append(pool.archives, &archiveHealth{
+			name:    strconv.Itoa(i),
+			archive: archive,
+		})
+	}
+	return pool
+}
+
+func newArchivePoolMetrics(namespace string) archivePoolMetrics {
+	return archivePoolMetrics{
+		requestsCounter: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "history_archive", Name: "requests_total",
+			Help: "number of history archive requests, status = success|error",
+		}, []string{"archive", "method", "status"}),
+		requestsDuration: prometheus.NewSummaryVec(prometheus.SummaryOpts{
+			Namespace: namespace, Subsystem: "history_archive", Name: "request_duration_seconds",
+			Help:       "history archive request durations, sliding window = 10m",
+			Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+		}, []string{"archive", "method"}),
+		blacklistedGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: "history_archive", Name: "blacklisted",
+			Help: "equals 1 if the history archive was blacklisted because of errors and didn't serve a request successfully since, 0 otherwise",
+		}, []string{"archive"}),
+		blacklistsCounter: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "history_archive", Name: "blacklists_total",
+			Help: "number of times the history archive was blacklisted",
+		}, []string{"archive"}),
+	}
+}
+
+// RegisterMetrics registers the metrics of the pool in the given registry.
+func (pa *FailoverArchivePool) RegisterMetrics(registry *prometheus.Registry) {
+	registry.MustRegister(pa.metrics.requestsCounter)
+	registry.MustRegister(pa.metrics.requestsDuration)
+	registry.MustRegister(pa.metrics.blacklistedGauge)
+	registry.MustRegister(pa.metrics.blacklistsCounter)
+}
+
+// pick returns the index of a random healthy archive which wasn't tried yet.
+// If all such archives are blacklisted a random one of them is returned. It
+// returns -1 if all archives were tried.
+func (pa *FailoverArchivePool) pick(tried map[int]bool) int {
+	pa.lock.Lock()
+	defer pa.lock.Unlock()
+
+	now := pa.now()
+	var healthy, blacklisted []int
+	for i, archive := range pa.archives {
+		if tried[i] {
+			continue
+		}
+		if now.Before(archive.blacklistedUntil) {
+			blacklisted = append(blacklisted, i)
+		} else {
+			healthy = append(healthy, i)
+		}
+	}
+	switch {
+	case len(healthy) > 0:
+		return healthy[rand.Intn(len(healthy))]
+	case len(blacklisted) > 0:
+		return blacklisted[rand.Intn(len(blacklisted))]
+	default:
+		return -1
+	}
+}
+
+func isContextError(err error) bool {
+	cause := errors.Cause(err)
+	return cause == context.Canceled || cause == context.DeadlineExceeded
+}
+
+// record updates the health of the archive after a call.
+func (pa *FailoverArchivePool) record(i int, method string, duration time.Duration, err error) {
+	pa.lock.Lock()
+	defer pa.lock.Unlock()
+
+	archive := pa.archives[i]
+	status := "success"
+	if err != nil {
+		status = "error"
+	}
+	pa.metrics.requestsCounter.With(prometheus.Labels{
+		"archive": archive.name, "method": method, "status": status,
+	}).Inc()
+	pa.metrics.requestsDuration.With(prometheus.Labels{
+		"archive": archive.name, "method": method,
+	}).Observe(duration.Seconds())
+
+	if err == nil {
+		archive.consecutiveErrors = 0
+		if !archive.blacklistedUntil.IsZero() {
+			archive.blacklistedUntil = time.Time{}
+			pa.metrics.blacklistedGauge.With(prometheus.Labels{"archive": archive.name}).Set(0)
+		}
+		return
+	}
+	if isContextError(err) {
+		// Not a fault of the archive
+		return
+	}
+
+	archive.consecutiveErrors++
+	now := pa.now()
+	if archive.consecutiveErrors >= pa.options.ErrorThreshold && !now.Before(archive.blacklistedUntil) {
+		archive.blacklistedUntil = now.Add(pa.options.BlacklistDuration)
+		pa.metrics.blacklistedGauge.With(prometheus.Labels{"archive": archive.name}).Set(1)
+		pa.metrics.blacklistsCounter.With(prometheus.Labels{"archive": archive.name}).Inc()
+		log.WithFields(log.Fields{
+			"archive":  archive.name,
+			"errors":   archive.consecutiveErrors,
+			"duration": pa.options.BlacklistDuration,
+		}).WithError(err).Warn("history archive blacklisted")
+	}
+}
+
+// call executes f using a healthy archive and retries it using different
+// archives if it fails.
+func (pa *FailoverArchivePool) call(method string, f func(ArchiveInterface) error) error {
+	attempts := 1
+	if pa.options.MaxRetries > 0 {
+		attempts += pa.options.MaxRetries
+	}
+
+	tried := map[int]bool{}
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		i := pa.pick(tried)
+		if i < 0 {
+			break
+		}
+		tried[i] = true
+
+		startTime := time.Now()
+		err = f(pa.archives[i].archive)
+		pa.record(i, method, time.Since(startTime), err)
+		if err == nil || isContextError(err) {
+			return err
+		}
+		log.WithFields(log.Fields{
+			"archive": pa.archives[i].name,
+			"method":  method,
+			"attempt": attempt + 1,
+		}).WithError(err).Debug("history archive request failed")
+	}
+	return err
+}
+
+// Ensure the pool conforms to the ArchiveInterface
+var _ ArchiveInterface = &FailoverArchivePool{}
+
+// Below are the ArchiveInterface method implementations.
+
+// GetAnyArchive returns a random healthy archive.
+func (pa *FailoverArchivePool) GetAnyArchive() ArchiveInterface {
+	return pa.archives[pa.pick(nil)].archive
+}
+
+func (pa *FailoverArchivePool) GetPathHAS(path string) (HistoryArchiveState, error) {
+	var has HistoryArchiveState
+	err := pa.call("GetPathHAS", func(a ArchiveInterface) (err error) {
+		has, err = a.GetPathHAS(path)
+		return
+	})
+	return has, err
+}
+
+func (pa *FailoverArchivePool) PutPathHAS(path string, has HistoryArchiveState, opts *CommandOptions) error {
+	return pa.call("PutPathHAS", func(a ArchiveInterface) error {
+		return a.PutPathHAS(path, has, opts)
+	})
+}
+
+func (pa *FailoverArchivePool) BucketExists(bucket Hash) (bool, error) {
+	var exists bool
+	err := pa.call("BucketExists", func(a ArchiveInterface) (err error) {
+		exists, err = a.BucketExists(bucket)
+		return
+	})
+	return exists, err
+}
+
+func (pa *FailoverArchivePool) BucketSize(bucket Hash) (int64, error) {
+	var size int64
+	err := pa.call("BucketSize", func(a ArchiveInterface) (err error) {
+		size, err = a.BucketSize(bucket)
+		return
+	})
+	return size, err
+}
+
+func (pa *FailoverArchivePool) CategoryCheckpointExists(cat string, chk uint32) (bool, error) {
+	var exists bool
+	err := pa.call("CategoryCheckpointExists", func(a ArchiveInterface) (err error) {
+		exists, err = a.CategoryCheckpointExists(cat, chk)
+		return
+	})
+	return exists, err
+}
+
+func (pa *FailoverArchivePool) GetLedgerHeader(chk uint32) (xdr.LedgerHeaderHistoryEntry, error) {
+	var header xdr.LedgerHeaderHistoryEntry
+	err := pa.call("GetLedgerHeader", func(a ArchiveInterface) (err error) {
+		header, err = a.GetLedgerHeader(chk)
+		return
+	})
+	return header, err
+}
+
+func (pa *FailoverArchivePool) GetRootHAS() (HistoryArchiveState, error) {
+	var has HistoryArchiveState
+	err := pa.call("GetRootHAS", func(a ArchiveInterface) (err error) {
+		has, err = a.GetRootHAS()
+		return
+	})
+	return has, err
+}
+
+func (pa *FailoverArchivePool) GetLedgers(start, end uint32) (map[uint32]*Ledger, error) {
+	var ledgers map[uint32]*Ledger
+	err := pa.call("GetLedgers", func(a ArchiveInterface) (err error) {
+		ledgers, err = a.GetLedgers(start, end)
+		return
+	})
+	return ledgers, err
+}
+
+func (pa *FailoverArchivePool) GetCheckpointHAS(chk uint32) (HistoryArchiveState, error) {
+	var has HistoryArchiveState
+	err := pa.call("GetCheckpointHAS", func(a ArchiveInterface) (err error) {
+		has, err = a.GetCheckpointHAS(chk)
+		return
+	})
+	return has, err
+}
+
+func (pa *FailoverArchivePool) PutCheckpointHAS(chk uint32, has HistoryArchiveState, opts *CommandOptions) error {
+	return pa.call("PutCheckpointHAS", func(a ArchiveInterface) error {
+		return a.PutCheckpointHAS(chk, has, opts)
+	})
+}
+
+func (pa *FailoverArchivePool) PutRootHAS(has HistoryArchiveState, opts *CommandOptions) error {
+	return pa.call("PutRootHAS", func(a ArchiveInterface) error {
+		return a.PutRootHAS(has, opts)
+	})
+}
+
+// The List* methods return channels so errors can't be retried, they only
+// use a healthy archive.
+
+func (pa *FailoverArchivePool) ListBucket(dp DirPrefix) (chan string, chan error) {
+	return pa.GetAnyArchive().ListBucket(dp)
+}
+
+func (pa *FailoverArchivePool) ListAllBuckets() (chan string, chan error) {
+	return pa.GetAnyArchive().ListAllBuckets()
+}
+
+func (pa *FailoverArchivePool) ListAllBucketHashes() (chan Hash, chan error) {
+	return pa.GetAnyArchive().ListAllBucketHashes()
+}
+
+func (pa *FailoverArchivePool) ListCategoryCheckpoints(cat string, pth string) (chan uint32, chan error) {
+	return pa.GetAnyArchive().ListCategoryCheckpoints(cat, pth)
+}
+
+func (pa *FailoverArchivePool) GetXdrStreamForHash(hash Hash) (*XdrStream, error) {
+	var stream *XdrStream
+	err := pa.call("GetXdrStreamForHash", func(a ArchiveInterface) (err error) {
+		stream, err = a.GetXdrStreamForHash(hash)
+		return
+	})
+	return stream, err
+}
+
+func (pa *FailoverArchivePool) GetXdrStream(pth string) (*XdrStream, error) {
+	var stream *XdrStream
+	err := pa.call("GetXdrStream", func(a ArchiveInterface) (err error) {
+		stream, err = a.GetXdrStream(pth)
+		return
+	})
+	return stream, err
+}
+
+func (pa *FailoverArchivePool) GetCheckpointManager() CheckpointManager {
+	return pa.GetAnyArchive().GetCheckpointManager()
+}
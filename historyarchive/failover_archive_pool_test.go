@@ -0,0 +1,119 @@
+// Copyright 2021 Stellar Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+
+package historyarchive
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/xdr"
+)
+
+func getGaugeValue(t *testing.T, gauge prometheus.Gauge) float64 {
+	var value dto.Metric
+	require.NoError(t, gauge.Write(&value))
+	return value.GetGauge().GetValue()
+}
+
+func TestFailoverArchivePoolRetriesFailedCalls(t *testing.T) {
+	broken := &MockArchive{}
+	broken.On("GetRootHAS").Return(HistoryArchiveState{}, errors.New("broken")).Maybe()
+	healthy := &MockArchive{}
+	healthy.On("GetRootHAS").Return(HistoryArchiveState{CurrentLedger: 63}, nil)
+
+	pool := NewFailoverArchivePoolFromArchives([]ArchiveInterface{broken, healthy}, FailoverArchivePoolOptions{})
+	for i := 0; i < 10; i++ {
+		has, err := pool.GetRootHAS()
+		require.NoError(t, err)
+		assert.Equal(t, uint32(63), has.CurrentLedger)
+	}
+	healthy.AssertNumberOfCalls(t, "GetRootHAS", 10)
+}
+
+func TestFailoverArchivePoolBlacklistsUnhealthyArchives(t *testing.T) {
+	broken := &MockArchive{}
+	broken.On("GetLedgerHeader", uint32(63)).Return(xdr.LedgerHeaderHistoryEntry{}, errors.New("broken"))
+	healthy := &MockArchive{}
+	healthy.On("GetLedgerHeader", uint32(63)).Return(xdr.LedgerHeaderHistoryEntry{}, nil)
+
+	pool := NewFailoverArchivePoolFromArchives([]ArchiveInterface{broken, healthy}, FailoverArchivePoolOptions{
+		ErrorThreshold:    2,
+		BlacklistDuration: time.Minute,
+	})
+	now := time.Now()
+	pool.now = func() time.Time { return now }
+
+	for i := 0; i < 20; i++ {
+		_, err := pool.GetLedgerHeader(63)
+		require.NoError(t, err)
+	}
+	// The broken archive is not used after 2 errors
+	broken.AssertNumberOfCalls(t, "GetLedgerHeader", 2)
+	assert.Equal(t, float64(1), getGaugeValue(t, pool.metrics.blacklistedGauge.WithLabelValues("0")))
+	assert.Equal(t, float64(0), getGaugeValue(t, pool.metrics.blacklistedGauge.WithLabelValues("1")))
+
+	// The archive is used again when the blacklisting expires and it's
+	// blacklisted again after the first error.
+	now = now.Add(2 * time.Minute)
+	for i := 0; i < 20; i++ {
+		_, err := pool.GetLedgerHeader(63)
+		require.NoError(t, err)
+	}
+	broken.AssertNumberOfCalls(t, "GetLedgerHeader", 3)
+	assert.Equal(t, float64(1), getGaugeValue(t, pool.metrics.blacklistedGauge.WithLabelValues("0")))
+}
+
+func TestFailoverArchivePoolAllArchivesFail(t *testing.T) {
+	archives := []ArchiveInterface{}
+	for i := 0; i < 3; i++ {
+		archive := &MockArchive{}
+		archive.On("GetCheckpointHAS", uint32(127)).Return(HistoryArchiveState{}, errors.New("not found")).Once()
+		archives = append(archives, archive)
+	}
+
+	pool := NewFailoverArchivePoolFromArchives(archives, FailoverArchivePoolOptions{})
+	_, err := pool.GetCheckpointHAS(127)
+	assert.EqualError(t, err, "not found")
+	for _, archive := range archives {
+		archive.(*MockArchive).AssertExpectations(t)
+	}
+
+	// All archives are blacklisted but requests are still sent to them
+	archive := archives[0].(*MockArchive)
+	archive.On("GetCheckpointHAS", uint32(127)).Return(HistoryArchiveState{CurrentLedger: 127}, nil).Once()
+	pool = NewFailoverArchivePoolFromArchives(archives[:1], FailoverArchivePoolOptions{ErrorThreshold: 1})
+	pool.archives[0].blacklistedUntil = time.Now().Add(time.Hour)
+	has, err := pool.GetCheckpointHAS(127)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(127), has.CurrentLedger)
+}
+
+func TestFailoverArchivePoolContextErrors(t *testing.T) {
+	archives := []ArchiveInterface{}
+	for i := 0; i < 2; i++ {
+		archive := &MockArchive{}
+		archive.On("GetRootHAS").Return(HistoryArchiveState{}, errors.Wrap(context.Canceled, "canceled")).Maybe()
+		archives = append(archives, archive)
+	}
+
+	pool := NewFailoverArchivePoolFromArchives(archives, FailoverArchivePoolOptions{ErrorThreshold: 1})
+	_, err := pool.GetRootHAS()
+	assert.EqualError(t, err, "canceled: context canceled")
+
+	// Context errors are not retried and don't affect the health of archives
+	calls := 0
+	for i, archive := range archives {
+		calls += len(archive.(*MockArchive).Calls)
+		assert.True(t, pool.archives[i].blacklistedUntil.IsZero())
+	}
+	assert.Equal(t, 1, calls)
+}
@@ -86,6 +86,17 @@ func (b *FsArchiveBackend) PutFile(pth string, in io.ReadCloser) error {
 	return e
 }
 
+func (b *FsArchiveBackend) DeleteFile(pth string) error {
+	pth = path.Join(b.prefix, pth)
+	log.WithField("path", pth).Trace("fs: delete file")
+	err := os.Remove(pth)
+	if err != nil && !os.IsNotExist(err) {
+		log.WithField("path", pth).WithError(err).Error("fs: delete file")
+		return err
+	}
+	return nil
+}
+
 func (b *FsArchiveBackend) ListFiles(pth string) (chan string, chan error) {
 	ch := make(chan string)
 	errs := make(chan error)
@@ -0,0 +1,178 @@
+// Copyright 2021 Stellar Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+
+package historyarchive
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	storage "google.golang.org/api/storage/v1"
+
+	"github.com/stellar/go/support/errors"
+)
+
+// gcsEmulatorHostEnv is the environment variable used by Google Cloud SDKs
+// (and emulators like fake-gcs-server) to point clients to a local emulator.
+const gcsEmulatorHostEnv = "STORAGE_EMULATOR_HOST"
+
+type GCSArchiveBackend struct {
+	ctx    context.Context
+	svc    *storage.Service
+	bucket string
+	prefix string
+}
+
+func isGCSNotFound(err error) bool {
+	gerr, ok := err.(*googleapi.Error)
+	return ok && gerr.Code == http.StatusNotFound
+}
+
+func (b *GCSArchiveBackend) GetFile(pth string) (io.ReadCloser, error) {
+	key := path.Join(b.prefix, pth)
+	log.WithField("key", key).Trace("gcs: GetFile")
+	resp, err := b.svc.Objects.Get(b.bucket, key).Context(b.ctx).Download()
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (b *GCSArchiveBackend) attrs(pth string) (*storage.Object, error) {
+	key := path.Join(b.prefix, pth)
+	log.WithField("key", key).Trace("gcs: attrs")
+	obj, err := b.svc.Objects.Get(b.bucket, key).Context(b.ctx).Do()
+	if isGCSNotFound(err) {
+		return nil, nil
+	}
+	return obj, err
+}
+
+func (b *GCSArchiveBackend) Exists(pth string) (bool, error) {
+	obj, err := b.attrs(pth)
+	if err != nil {
+		return false, err
+	}
+	return obj != nil, nil
+}
+
+func (b *GCSArchiveBackend) Size(pth string) (int64, error) {
+	obj, err := b.attrs(pth)
+	if err != nil || obj == nil {
+		return 0, err
+	}
+	return int64(obj.Size), nil
+}
+
+func (b *GCSArchiveBackend) PutFile(pth string, in io.ReadCloser) error {
+	defer in.Close()
+	key := path.Join(b.prefix, pth)
+	log.WithField("key", key).Trace("gcs: PutFile")
+	_, err := b.svc.Objects.Insert(b.bucket, &storage.Object{Name: key}).
+		Media(in).
+		Context(b.ctx).
+		Do()
+	return err
+}
+
+func (b *GCSArchiveBackend) DeleteFile(pth string) error {
+	key := path.Join(b.prefix, pth)
+	log.WithField("key", key).Trace("gcs: DeleteFile")
+	err := b.svc.Objects.Delete(b.bucket, key).Context(b.ctx).Do()
+	if isGCSNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func (b *GCSArchiveBackend) ListFiles(pth string) (chan string, chan error) {
+	prefix := path.Join(b.prefix, pth)
+	ch := make(chan string)
+	errs := make(chan error)
+
+	go func() {
+		defer close(ch)
+		defer close(errs)
+		err := b.svc.Objects.List(b.bucket).
+			Prefix(prefix).
+			Fields("nextPageToken", "items/name").
+			Pages(b.ctx, func(objects *storage.Objects) error {
+				for _, obj := range objects.Items {
+					log.WithField("key", obj.Name).Trace("gcs: ListFiles")
+					ch <- obj.Name
+				}
+				return nil
+			})
+		if err != nil {
+			errs <- err
+		}
+	}()
+	return ch, errs
+}
+
+func (b *GCSArchiveBackend) CanListFiles() bool {
+	return true
+}
+
+// gcsEndpoint returns the endpoint of the GCS JSON API configured in options
+// or in the STORAGE_EMULATOR_HOST environment variable. Empty string means
+// the default (production) endpoint.
+func gcsEndpoint(opts ConnectOptions) string {
+	endpoint := opts.GCSEndpoint
+	if endpoint == "" {
+		host := os.Getenv(gcsEmulatorHostEnv)
+		if host == "" {
+			return ""
+		}
+		if !strings.Contains(host, "://") {
+			host = "http://" + host
+		}
+		endpoint = strings.TrimSuffix(host, "/") + "/storage/v1/"
+	}
+	if !strings.HasSuffix(endpoint, "/") {
+		endpoint += "/"
+	}
+	return endpoint
+}
+
+func makeGCSBackend(bucket string, prefix string, opts ConnectOptions) (ArchiveBackend, error) {
+	endpoint := gcsEndpoint(opts)
+	log.WithFields(log.Fields{"bucket": bucket,
+		"prefix":   prefix,
+		"endpoint": endpoint}).Debug("gcs: making backend")
+
+	var clientOpts []option.ClientOption
+	if endpoint != "" {
+		// Emulators don't support authentication.
+		clientOpts = append(clientOpts,
+			option.WithEndpoint(endpoint),
+			option.WithoutAuthentication(),
+		)
+	} else {
+		clientOpts = append(clientOpts, option.WithScopes(storage.DevstorageReadWriteScope))
+	}
+	if opts.UserAgent != "" {
+		clientOpts = append(clientOpts, option.WithUserAgent(opts.UserAgent))
+	}
+
+	svc, err := storage.NewService(opts.Context, clientOpts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating GCS client")
+	}
+
+	backend := GCSArchiveBackend{
+		ctx:    opts.Context,
+		svc:    svc,
+		bucket: bucket,
+		prefix: prefix,
+	}
+	return &backend, nil
+}
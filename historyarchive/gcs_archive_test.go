@@ -0,0 +1,191 @@
+// Copyright 2021 Stellar Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+
+package historyarchive
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeGCS implements the subset of the GCS JSON API used by
+// GCSArchiveBackend.
+type fakeGCS struct {
+	lock    sync.Mutex
+	bucket  string
+	objects map[string][]byte
+}
+
+func (f *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	bucketPath := "/storage/v1/b/" + f.bucket + "/o"
+	uploadPath := "/upload" + bucketPath
+	escapedPath := r.URL.EscapedPath()
+	switch {
+	case r.Method == http.MethodPost && escapedPath == uploadPath:
+		f.upload(w, r)
+	case r.Method == http.MethodGet && escapedPath == bucketPath:
+		f.list(w, r)
+	case r.Method == http.MethodGet && strings.HasPrefix(escapedPath, bucketPath+"/"):
+		name, err := url.PathUnescape(strings.TrimPrefix(escapedPath, bucketPath+"/"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, ok := f.objects[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":{"code":404,"message":"Not Found"}}`)
+			return
+		}
+		if r.URL.Query().Get("alt") == "media" {
+			w.Write(data)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"name": name,
+			"size": strconv.Itoa(len(data)),
+		})
+	default:
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+	}
+}
+
+func (f *fakeGCS) upload(w http.ResponseWriter, r *http.Request) {
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	reader := multipart.NewReader(r.Body, params["boundary"])
+	metadataPart, err := reader.NextPart()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var metadata struct {
+		Name string `json:"name"`
+	}
+	if err = json.NewDecoder(metadataPart).Decode(&metadata); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	mediaPart, err := reader.NextPart()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, err := ioutil.ReadAll(mediaPart)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.objects[metadata.Name] = data
+	json.NewEncoder(w).Encode(map[string]string{
+		"name": metadata.Name,
+		"size": strconv.Itoa(len(data)),
+	})
+}
+
+func (f *fakeGCS) list(w http.ResponseWriter, r *http.Request) {
+	const pageSize = 2
+	prefix := r.URL.Query().Get("prefix")
+	var names []string
+	for name := range f.objects {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	start := 0
+	if token := r.URL.Query().Get("pageToken"); token != "" {
+		start, _ = strconv.Atoi(token)
+	}
+	end := start + pageSize
+	response := map[string]interface{}{}
+	if end < len(names) {
+		response["nextPageToken"] = strconv.Itoa(end)
+	} else {
+		end = len(names)
+	}
+	var items []map[string]string
+	for _, name := range names[start:end] {
+		items = append(items, map[string]string{"name": name})
+	}
+	response["items"] = items
+	json.NewEncoder(w).Encode(response)
+}
+
+func TestGCSArchiveBackend(t *testing.T) {
+	fake := &fakeGCS{bucket: "archive-bucket", objects: map[string][]byte{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	backend, err := ConnectBackend("gcs://archive-bucket/testnet", ConnectOptions{
+		GCSEndpoint: server.URL + "/storage/v1/",
+	})
+	require.NoError(t, err)
+	assert.True(t, backend.CanListFiles())
+
+	exists, err := backend.Exists("history/00/00/00/history-0000003f.json")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	for _, file := range []string{
+		"history/00/00/00/history-0000003f.json",
+		"history/00/00/00/history-0000007f.json",
+		"history/00/00/00/history-000000bf.json",
+		"ledger/00/00/00/ledger-0000003f.xdr.gz",
+	} {
+		require.NoError(t, backend.PutFile(file, ioutil.NopCloser(strings.NewReader(file))))
+	}
+	assert.Contains(t, fake.objects, "testnet/history/00/00/00/history-0000003f.json")
+
+	exists, err = backend.Exists("history/00/00/00/history-0000003f.json")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	size, err := backend.Size("ledger/00/00/00/ledger-0000003f.xdr.gz")
+	require.NoError(t, err)
+	assert.Equal(t, int64(len("ledger/00/00/00/ledger-0000003f.xdr.gz")), size)
+
+	reader, err := backend.GetFile("history/00/00/00/history-0000007f.json")
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	assert.Equal(t, "history/00/00/00/history-0000007f.json", string(data))
+
+	_, err = backend.GetFile("history/00/00/00/history-000000ff.json")
+	assert.Error(t, err)
+
+	ch, errs := backend.ListFiles("history")
+	var files []string
+	for file := range ch {
+		files = append(files, file)
+	}
+	require.NoError(t, <-errs)
+	assert.Equal(t, []string{
+		"testnet/history/00/00/00/history-0000003f.json",
+		"testnet/history/00/00/00/history-0000007f.json",
+		"testnet/history/00/00/00/history-000000bf.json",
+	}, files)
+}
@@ -114,6 +114,10 @@ func (b *HttpArchiveBackend) PutFile(pth string, in io.ReadCloser) error {
 	return errors.New("PutFile not available over HTTP")
 }
 
+func (b *HttpArchiveBackend) DeleteFile(pth string) error {
+	return errors.New("DeleteFile not available over HTTP")
+}
+
 func (b *HttpArchiveBackend) ListFiles(pth string) (chan string, chan error) {
 	ch := make(chan string)
 	er := make(chan error)
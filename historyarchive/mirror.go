@@ -5,10 +5,13 @@
 package historyarchive
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"math"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/stellar/go/support/errors"
 )
@@ -24,19 +27,27 @@ func Mirror(src *Archive, dst *Archive, opts *CommandOptions) error {
 
 	log.Printf("copying range %s\n", opts.Range)
 
+	var progress *mirrorProgress
+	if opts.StateFile != "" && !opts.DryRun {
+		progress, e = loadMirrorProgress(opts.StateFile, src, dst)
+		if e != nil {
+			return e
+		}
+	}
+
 	// Make a bucket-fetch map that shows which buckets are
 	// already-being-fetched
 	bucketFetch := make(map[Hash]bool)
 	var bucketFetchMutex sync.Mutex
 
-	var errs uint32
+	var errs, skipped uint32
 	tick := makeTicker(func(ticks uint) {
 		bucketFetchMutex.Lock()
 		sz := opts.Range.SizeInCheckPoints(src.checkpointManager)
-		log.Printf("Copied %d/%d checkpoints (%f%%), %d buckets",
+		log.Printf("Copied %d/%d checkpoints (%f%%), %d buckets, %d bytes",
 			ticks, sz,
 			100.0*float64(ticks)/float64(sz),
-			len(bucketFetch))
+			len(bucketFetch), dst.BytesWritten())
 		bucketFetchMutex.Unlock()
 	})
 
@@ -50,6 +61,11 @@ func Mirror(src *Archive, dst *Archive, opts *CommandOptions) error {
 				if !ok {
 					break
 				}
+				if progress != nil && progress.isCheckpointCompleted(ix) {
+					atomic.AddUint32(&skipped, 1)
+					tick <- true
+					continue
+				}
 				has, err := src.GetCheckpointHAS(ix)
 				if err != nil {
 					atomic.AddUint32(&errs, noteError(err))
@@ -61,7 +77,12 @@ func Mirror(src *Archive, dst *Archive, opts *CommandOptions) error {
 					panic(errors.Wrap(err, "error getting buckets"))
 				}
 
+				var checkpointErrs uint32
 				for _, bucket := range buckets {
+					if progress != nil && progress.isBucketCopied(bucket) {
+						progress.bucketCopied(bucket, ix)
+						continue
+					}
 					alreadyFetching := false
 					bucketFetchMutex.Lock()
 					_, alreadyFetching = bucketFetch[bucket]
@@ -72,7 +93,10 @@ func Mirror(src *Archive, dst *Archive, opts *CommandOptions) error {
 					if !alreadyFetching {
 						pth := BucketPath(bucket)
 						err = copyPath(src, dst, pth, opts)
-						atomic.AddUint32(&errs, noteError(err))
+						if err == nil && progress != nil {
+							progress.bucketCopied(bucket, ix)
+						}
+						checkpointErrs += noteError(err)
 					}
 				}
 
@@ -85,7 +109,25 @@ func Mirror(src *Archive, dst *Archive, opts *CommandOptions) error {
 					if err != nil && !categoryRequired(cat) {
 						continue
 					}
-					atomic.AddUint32(&errs, noteError(err))
+					checkpointErrs += noteError(err)
+				}
+				atomic.AddUint32(&errs, checkpointErrs)
+				// A bucket being copied by another worker can be still
+				// incomplete so the checkpoint is marked as completed only if
+				// all its buckets were recorded.
+				if progress != nil && checkpointErrs == 0 {
+					complete := true
+					for _, bucket := range buckets {
+						if !progress.isBucketCopied(bucket) {
+							complete = false
+							break
+						}
+					}
+					if complete {
+						if err = progress.checkpointCompleted(ix, buckets); err != nil {
+							log.Printf("Error saving mirror state: %v", err)
+						}
+					}
 				}
 				tick <- true
 			}
@@ -94,9 +136,14 @@ func Mirror(src *Archive, dst *Archive, opts *CommandOptions) error {
 	}
 
 	wg.Wait()
-	log.Printf("copied %d checkpoints, %d buckets, range %s",
-		opts.Range.SizeInCheckPoints(src.checkpointManager), len(bucketFetch), opts.Range)
+	log.Printf("copied %d checkpoints (%d already mirrored), %d buckets, range %s",
+		opts.Range.SizeInCheckPoints(src.checkpointManager), skipped, len(bucketFetch), opts.Range)
+	log.Printf("transferred %d bytes (read from source), %d bytes (written to destination)",
+		src.BytesRead(), dst.BytesWritten())
 	close(tick)
+	if progress != nil {
+		errs += noteError(progress.close())
+	}
 	if rootHAS.CurrentLedger == opts.Range.High {
 		log.Printf("updating destination archive current-ledger pointer to 0x%8.8x",
 			rootHAS.CurrentLedger)
@@ -116,3 +163,43 @@ func Mirror(src *Archive, dst *Archive, opts *CommandOptions) error {
 	}
 	return nil
 }
+
+// MirrorFollow mirrors opts.Range and then keeps polling the source archive
+// every interval, mirroring new checkpoints as they are published, until the
+// context is canceled or, if high is not 0, the checkpoint containing the high
+// ledger is mirrored. Every round after the first one mirrors the checkpoints
+// from the last mirrored one up to the current ledger of the source archive,
+// so a bounded opts.Range is extended as the source archive grows. Failed
+// rounds are logged and retried in the next round.
+func MirrorFollow(ctx context.Context, src *Archive, dst *Archive, opts *CommandOptions, interval time.Duration, high uint32) error {
+	var mirrored uint32
+	for {
+		rootHAS, err := src.GetRootHAS()
+		if err != nil {
+			log.Printf("error getting source archive state: %v", err)
+		} else if rootHAS.CurrentLedger > mirrored {
+			roundOpts := *opts
+			if mirrored > 0 {
+				roundOpts.Range.Low = mirrored
+				roundOpts.Range.High = math.MaxUint32
+				if high != 0 {
+					roundOpts.Range.High = high
+				}
+			}
+			if err = Mirror(src, dst, &roundOpts); err != nil {
+				log.Printf("error mirroring, retrying in %s: %v", interval, err)
+			} else {
+				mirrored = roundOpts.Range.High
+				if high != 0 && mirrored >= high {
+					return nil
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
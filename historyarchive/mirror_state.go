@@ -0,0 +1,242 @@
+// Copyright 2021 Stellar Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+
+package historyarchive
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/stellar/go/support/errors"
+)
+
+// mirrorStateSaveInterval is the minimum time between two writes of the state
+// file while mirroring.
+const mirrorStateSaveInterval = 10 * time.Second
+
+// MirrorState is the progress of a mirror persisted in a state file (see
+// CommandOptions.StateFile) so that an interrupted mirror can be resumed
+// without copying (or checking) completed checkpoints again.
+type MirrorState struct {
+	// Completed contains merged ranges of checkpoints which were copied
+	// completely: all checkpoint files and all buckets referenced by them.
+	Completed []Range `json:"completed"`
+	// Buckets contains buckets which were copied and can be referenced by
+	// checkpoints which are not completed yet.
+	Buckets []string `json:"buckets"`
+	// BytesRead is the total number of bytes read from the source archive.
+	BytesRead int64 `json:"bytes_read"`
+	// BytesWritten is the total number of bytes written to the destination
+	// archive.
+	BytesWritten int64 `json:"bytes_written"`
+}
+
+// ReadMirrorState reads the mirror state from the given file. It returns an
+// empty state if the file doesn't exist.
+func ReadMirrorState(path string) (MirrorState, error) {
+	var state MirrorState
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	} else if err != nil {
+		return state, errors.Wrap(err, "error reading mirror state file")
+	}
+	if err = json.Unmarshal(data, &state); err != nil {
+		return state, errors.Wrap(err, "error decoding mirror state file")
+	}
+	return state, nil
+}
+
+// Write atomically writes the state to the given file.
+func (s MirrorState) Write(path string) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	temp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return errors.Wrap(err, "error creating temporary state file")
+	}
+	if _, err = temp.Write(data); err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+		return errors.Wrap(err, "error writing temporary state file")
+	}
+	if err = temp.Close(); err != nil {
+		os.Remove(temp.Name())
+		return errors.Wrap(err, "error writing temporary state file")
+	}
+	return os.Rename(temp.Name(), path)
+}
+
+// IsCompleted returns true if the given checkpoint was mirrored completely.
+func (s MirrorState) IsCompleted(checkpoint uint32) bool {
+	for _, r := range s.Completed {
+		if r.InRange(checkpoint) {
+			return true
+		}
+	}
+	return false
+}
+
+// mirrorProgress tracks the progress of a running mirror and periodically
+// saves it in the state file.
+type mirrorProgress struct {
+	path     string
+	src, dst *Archive
+
+	lock      sync.Mutex
+	completed []Range
+	// buckets maps copied buckets to the checkpoint which copied them (0 for
+	// buckets loaded from the state file).
+	buckets map[Hash]uint32
+	// latestBuckets are buckets referenced by the latest completed checkpoint.
+	latestCheckpoint uint32
+	latestBuckets    []Hash
+	// baseBytesRead and baseBytesWritten convert the byte counters of the
+	// archives to the totals since the first run.
+	baseBytesRead    int64
+	baseBytesWritten int64
+	lastSave         time.Time
+}
+
+func loadMirrorProgress(path string, src, dst *Archive) (*mirrorProgress, error) {
+	state, err := ReadMirrorState(path)
+	if err != nil {
+		return nil, err
+	}
+	progress := &mirrorProgress{
+		path:             path,
+		src:              src,
+		dst:              dst,
+		completed:        state.Completed,
+		buckets:          map[Hash]uint32{},
+		baseBytesRead:    state.BytesRead - src.BytesRead(),
+		baseBytesWritten: state.BytesWritten - dst.BytesWritten(),
+		lastSave:         time.Now(),
+	}
+	for _, bucket := range state.Buckets {
+		hash, err := DecodeHash(bucket)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid bucket hash in mirror state file")
+		}
+		progress.buckets[hash] = 0
+		progress.latestBuckets = append(progress.latestBuckets, hash)
+	}
+	return progress, nil
+}
+
+func (p *mirrorProgress) isCheckpointCompleted(checkpoint uint32) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return MirrorState{Completed: p.completed}.IsCompleted(checkpoint)
+}
+
+func (p *mirrorProgress) isBucketCopied(bucket Hash) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	_, ok := p.buckets[bucket]
+	return ok
+}
+
+// bucketCopied records a copied bucket. Buckets loaded from the state file are
+// assigned to the first checkpoint using them.
+func (p *mirrorProgress) bucketCopied(bucket Hash, checkpoint uint32) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if current, ok := p.buckets[bucket]; !ok || current == 0 {
+		p.buckets[bucket] = checkpoint
+	}
+}
+
+// checkpointCompleted marks the checkpoint as completed and saves the state
+// file if it wasn't saved recently.
+func (p *mirrorProgress) checkpointCompleted(checkpoint uint32, buckets []Hash) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.completed = mergeCheckpointRanges(
+		append(p.completed, Range{Low: checkpoint, High: checkpoint}),
+		p.src.checkpointManager,
+	)
+	if checkpoint >= p.latestCheckpoint {
+		p.latestCheckpoint = checkpoint
+		p.latestBuckets = buckets
+	}
+
+	if time.Since(p.lastSave) < mirrorStateSaveInterval {
+		return nil
+	}
+	return p.save()
+}
+
+// save writes the state file. Must be called with the lock held.
+func (p *mirrorProgress) save() error {
+	state := p.state()
+	if err := state.Write(p.path); err != nil {
+		return err
+	}
+	p.lastSave = time.Now()
+	return nil
+}
+
+// state returns the current MirrorState. Only buckets which can be referenced
+// by the remaining checkpoints are kept: buckets of the latest completed
+// checkpoint and buckets copied by checkpoints which are not completed yet.
+// Must be called with the lock held.
+func (p *mirrorProgress) state() MirrorState {
+	state := MirrorState{
+		Completed:    p.completed,
+		BytesRead:    p.baseBytesRead + p.src.BytesRead(),
+		BytesWritten: p.baseBytesWritten + p.dst.BytesWritten(),
+	}
+	keep := map[Hash]bool{}
+	for _, bucket := range p.latestBuckets {
+		keep[bucket] = true
+	}
+	for bucket, checkpoint := range p.buckets {
+		if checkpoint != 0 && !state.IsCompleted(checkpoint) {
+			keep[bucket] = true
+		}
+	}
+	for bucket := range keep {
+		if _, ok := p.buckets[bucket]; ok {
+			state.Buckets = append(state.Buckets, bucket.String())
+		}
+	}
+	sort.Strings(state.Buckets)
+	return state
+}
+
+func (p *mirrorProgress) close() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.save()
+}
+
+// mergeCheckpointRanges sorts the ranges and merges overlapping and adjacent
+// ones.
+func mergeCheckpointRanges(ranges []Range, checkpointManager CheckpointManager) []Range {
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].Low < ranges[j].Low
+	})
+	freq := uint64(checkpointManager.GetCheckpointFrequency())
+	var merged []Range
+	for _, r := range ranges {
+		last := len(merged) - 1
+		if last >= 0 && uint64(r.Low) <= uint64(merged[last].High)+freq {
+			if r.High > merged[last].High {
+				merged[last].High = r.High
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
@@ -0,0 +1,187 @@
+// Copyright 2021 Stellar Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+
+package historyarchive
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/stellar/go/support/errors"
+)
+
+// referencedBucketsInRange returns buckets referenced by the checkpoints in the
+// range. Unlike scanning, it fails if any of the HAS files can't be read
+// because then it's impossible to tell which buckets are still in use.
+func (arch *Archive) referencedBucketsInRange(rng Range, opts *CommandOptions) (map[Hash]bool, error) {
+	referenced := map[Hash]bool{}
+	var lock sync.Mutex
+	var errs uint32
+
+	var wg sync.WaitGroup
+	wg.Add(opts.Concurrency)
+	checkpoints := rng.GenerateCheckpoints(arch.checkpointManager)
+	for i := 0; i < opts.Concurrency; i++ {
+		go func() {
+			defer wg.Done()
+			for chk := range checkpoints {
+				has, err := arch.GetCheckpointHAS(chk)
+				if err != nil {
+					atomic.AddUint32(&errs, noteError(err))
+					continue
+				}
+				buckets, err := has.Buckets()
+				if err != nil {
+					atomic.AddUint32(&errs, noteError(err))
+					continue
+				}
+				lock.Lock()
+				for _, bucket := range buckets {
+					referenced[bucket] = true
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if errs != 0 {
+		return nil, fmt.Errorf("%d errors reading checkpoint states", errs)
+	}
+	return referenced, nil
+}
+
+// deletePaths deletes the paths received from the channel concurrently and
+// returns the number of deleted files and errors.
+func (arch *Archive) deletePaths(paths chan string, opts *CommandOptions) (uint32, uint32) {
+	var deleted, errs uint32
+	var wg sync.WaitGroup
+	wg.Add(opts.Concurrency)
+	for i := 0; i < opts.Concurrency; i++ {
+		go func() {
+			defer wg.Done()
+			for pth := range paths {
+				if opts.DryRun {
+					log.Printf("dryrun skipping delete of %s", pth)
+					atomic.AddUint32(&deleted, 1)
+					continue
+				}
+				err := arch.backend.DeleteFile(pth)
+				if err != nil {
+					atomic.AddUint32(&errs, noteError(err))
+					continue
+				}
+				log.WithField("path", pth).Debug("deleted")
+				atomic.AddUint32(&deleted, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	return deleted, errs
+}
+
+// Prune deletes checkpoint files of checkpoints older than opts.Range.Low and
+// all buckets which are not referenced by the remaining checkpoints. Newer
+// checkpoints (above opts.Range.High) are never deleted. With opts.DryRun
+// files to be deleted are only logged.
+//
+// The archive backend must support listing files. Buckets are found by
+// listing the archive so Prune must not run while the archive is being
+// published to: buckets uploaded before the HAS referencing them would be
+// deleted.
+func (arch *Archive) Prune(opts *CommandOptions) error {
+	if opts.Concurrency == 0 {
+		return errors.New("Zero concurrency")
+	}
+	if !arch.backend.CanListFiles() {
+		return errors.New("archive backend can't list files")
+	}
+
+	rootHAS, err := arch.GetRootHAS()
+	if err != nil {
+		return err
+	}
+	keep := opts.Range.clamp(rootHAS.Range(), arch.checkpointManager)
+	keep.High = rootHAS.CurrentLedger
+	log.Printf("Pruning archive, keeping checkpoints in range %s", keep)
+
+	referenced, err := arch.referencedBucketsInRange(keep, opts)
+	if err != nil {
+		return errors.Wrap(err, "error finding referenced buckets")
+	}
+	log.Printf("Found %d buckets referenced by kept checkpoints", len(referenced))
+
+	var errs uint32
+
+	// Checkpoint files are deleted first so that the archive stays consistent
+	// if pruning is interrupted.
+	paths := make(chan string)
+	go func() {
+		defer close(paths)
+		for _, cat := range Categories() {
+			chks, es := arch.ListCategoryCheckpoints(cat, "")
+			for chk := range chks {
+				if chk < keep.Low {
+					paths <- CategoryCheckpointPath(cat, chk)
+				}
+			}
+			atomic.AddUint32(&errs, drainErrors(es))
+		}
+	}()
+	deletedCheckpointFiles, n := arch.deletePaths(paths, opts)
+	errs += n
+
+	// Listing buckets can take long so buckets of checkpoints published in
+	// the meantime are also kept.
+	buckets := []Hash{}
+	allBuckets, es := arch.ListAllBucketHashes()
+	for bucket := range allBuckets {
+		buckets = append(buckets, bucket)
+	}
+	if n := drainErrors(es); n != 0 {
+		// A partial list of buckets is fine, only unreferenced buckets are
+		// deleted, but report the error.
+		errs += n
+	}
+	if latest, err := arch.GetRootHAS(); err != nil {
+		return errors.Wrap(err, "error getting root HAS")
+	} else if latest.CurrentLedger > rootHAS.CurrentLedger {
+		newer, err := arch.referencedBucketsInRange(Range{
+			Low:  arch.checkpointManager.NextCheckpoint(rootHAS.CurrentLedger),
+			High: latest.CurrentLedger,
+		}, opts)
+		if err != nil {
+			return errors.Wrap(err, "error finding referenced buckets")
+		}
+		for bucket := range newer {
+			referenced[bucket] = true
+		}
+	}
+
+	paths = make(chan string)
+	go func() {
+		defer close(paths)
+		for _, bucket := range buckets {
+			if !referenced[bucket] {
+				paths <- BucketPath(bucket)
+			}
+		}
+	}()
+	deletedBuckets, n := arch.deletePaths(paths, opts)
+	errs += n
+
+	verb := "Deleted"
+	if opts.DryRun {
+		verb = "Would delete"
+	}
+	log.Printf("%s %d checkpoint files older than 0x%8.8x and %d unreferenced buckets (of %d)",
+		verb, deletedCheckpointFiles, keep.Low, deletedBuckets, len(buckets))
+	if errs != 0 {
+		return fmt.Errorf("%d errors while pruning", errs)
+	}
+	return nil
+}
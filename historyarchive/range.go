@@ -15,8 +15,8 @@ import (
 const DefaultCheckpointFrequency = uint32(64)
 
 type Range struct {
-	Low  uint32
-	High uint32
+	Low  uint32 `json:"low"`
+	High uint32 `json:"high"`
 }
 
 type CheckpointManager struct {
@@ -124,26 +124,28 @@ func (r Range) InRange(sequence uint32) bool {
 	return sequence >= r.Low && sequence <= r.High
 }
 
-func fmtRangeList(vs []uint32, cManager CheckpointManager) string {
+// checkpointRanges sorts the checkpoints and merges consecutive ones into
+// ranges.
+func checkpointRanges(vs []uint32, cManager CheckpointManager) []Range {
 	slices.Sort(vs)
 
-	s := make([]string, 0, 10)
-	var curr *Range
-
+	ranges := make([]Range, 0, 10)
 	for _, t := range vs {
-		if curr != nil {
-			if curr.High+cManager.checkpointFreq == t {
-				curr.High = t
-				continue
-			} else {
-				s = append(s, curr.collapsedString())
-				curr = nil
-			}
+		last := len(ranges) - 1
+		if last >= 0 && ranges[last].High+cManager.checkpointFreq == t {
+			ranges[last].High = t
+			continue
 		}
-		curr = &Range{Low: t, High: t}
+		ranges = append(ranges, Range{Low: t, High: t})
 	}
-	if curr != nil {
-		s = append(s, curr.collapsedString())
+	return ranges
+}
+
+func fmtRangeList(vs []uint32, cManager CheckpointManager) string {
+	ranges := checkpointRanges(vs, cManager)
+	s := make([]string, 0, len(ranges))
+	for _, r := range ranges {
+		s = append(s, r.collapsedString())
 	}
 
 	return strings.Join(s, ", ")
@@ -0,0 +1,125 @@
+// Copyright 2021 Stellar Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+
+package historyarchive
+
+import (
+	"sort"
+)
+
+// ScanReport is the result of scanning (and, with CommandOptions.Verify,
+// verifying) an archive. It's built from the state collected by Scan so it
+// should be requested after Scan returns.
+type ScanReport struct {
+	// Range is the range of checkpoints which was checked.
+	Range Range `json:"range"`
+	// CheckpointFiles is the number of checkpoint files found in each
+	// category.
+	CheckpointFiles map[string]int `json:"checkpoint_files"`
+	// MissingCheckpoints contains ranges of checkpoints with missing files in
+	// each category (including optional ones).
+	MissingCheckpoints map[string][]Range `json:"missing_checkpoints"`
+	// ReferencedBuckets is the number of buckets referenced by the scanned
+	// checkpoints.
+	ReferencedBuckets int `json:"referenced_buckets"`
+	// MissingBuckets are buckets referenced by the scanned checkpoints which
+	// are not in the archive.
+	MissingBuckets []string `json:"missing_buckets"`
+	// OrphanedBuckets are buckets in the archive not referenced by any
+	// checkpoint. They are only known when all buckets were listed (a scan of
+	// the entire archive on a backend which can list files), see
+	// OrphanedBucketsKnown.
+	OrphanedBuckets      []string `json:"orphaned_buckets"`
+	OrphanedBucketsKnown bool     `json:"orphaned_buckets_known"`
+	// Verified is true when file contents were verified. The Invalid* fields
+	// are empty otherwise.
+	Verified            bool           `json:"verified"`
+	InvalidBuckets      []string       `json:"invalid_buckets"`
+	InvalidLedgers      []HashMismatch `json:"invalid_ledgers"`
+	InvalidTxSets       []HashMismatch `json:"invalid_tx_sets"`
+	InvalidTxResultSets []HashMismatch `json:"invalid_tx_result_sets"`
+}
+
+// HasMissing returns true if files of required categories or buckets are
+// missing.
+func (r *ScanReport) HasMissing() bool {
+	for cat, missing := range r.MissingCheckpoints {
+		if categoryRequired(cat) && len(missing) > 0 {
+			return true
+		}
+	}
+	return len(r.MissingBuckets) > 0
+}
+
+// InvalidCount returns the number of objects with unexpected hashes.
+func (r *ScanReport) InvalidCount() int {
+	return len(r.InvalidBuckets) + len(r.InvalidLedgers) +
+		len(r.InvalidTxSets) + len(r.InvalidTxResultSets)
+}
+
+func sortedHashStrings(hashes map[Hash]bool) []string {
+	s := make([]string, 0, len(hashes))
+	for h := range hashes {
+		s = append(s, h.String())
+	}
+	sort.Strings(s)
+	return s
+}
+
+// Report returns a ScanReport of the checkpoints in opts.Range.
+func (arch *Archive) Report(opts *CommandOptions) *ScanReport {
+	missingCheckpointFiles := arch.CheckCheckpointFilesMissing(opts)
+	missingBuckets := arch.CheckBucketsMissing()
+
+	report := &ScanReport{
+		Range:              opts.Range,
+		CheckpointFiles:    map[string]int{},
+		MissingCheckpoints: map[string][]Range{},
+		MissingBuckets:     sortedHashStrings(missingBuckets),
+		OrphanedBuckets:    []string{},
+		Verified:           opts.Verify,
+		InvalidBuckets:     []string{},
+	}
+	for cat, missing := range missingCheckpointFiles {
+		if opts.SkipOptional && !categoryRequired(cat) {
+			continue
+		}
+		report.MissingCheckpoints[cat] = checkpointRanges(missing, arch.checkpointManager)
+	}
+
+	arch.mutex.Lock()
+	for cat, files := range arch.checkpointFiles {
+		n := 0
+		for chk, present := range files {
+			if present && opts.Range.InRange(chk) {
+				n++
+			}
+		}
+		report.CheckpointFiles[cat] = n
+	}
+	report.ReferencedBuckets = len(arch.referencedBuckets)
+	if arch.listedAllBuckets {
+		report.OrphanedBucketsKnown = true
+		orphaned := map[Hash]bool{}
+		for bucket := range arch.allBuckets {
+			if !arch.referencedBuckets[bucket] {
+				orphaned[bucket] = true
+			}
+		}
+		report.OrphanedBuckets = sortedHashStrings(orphaned)
+	}
+	if opts.Verify {
+		report.InvalidBuckets = sortedHashStrings(arch.invalidBuckets)
+	}
+	arch.mutex.Unlock()
+
+	if opts.Verify {
+		report.InvalidLedgers, report.InvalidTxSets, report.InvalidTxResultSets = arch.hashMismatches()
+	} else {
+		report.InvalidLedgers = []HashMismatch{}
+		report.InvalidTxSets = []HashMismatch{}
+		report.InvalidTxResultSets = []HashMismatch{}
+	}
+	return report
+}
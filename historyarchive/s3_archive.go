@@ -137,6 +137,23 @@ func (b *S3ArchiveBackend) PutFile(pth string, in io.ReadCloser) error {
 	return err
 }
 
+func (b *S3ArchiveBackend) DeleteFile(pth string) error {
+	key := path.Join(b.prefix, pth)
+	params := &s3.DeleteObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(key),
+	}
+	req, _ := b.svc.DeleteObjectRequest(params)
+	if b.unsignedRequests {
+		req.Handlers.Sign.Clear() // makes this request unsigned
+	}
+	req.SetContext(b.ctx)
+	logReq(req.HTTPRequest)
+	err := req.Send()
+	logResp(req.HTTPResponse)
+	return err
+}
+
 func (b *S3ArchiveBackend) ListFiles(pth string) (chan string, chan error) {
 	prefix := path.Join(b.prefix, pth)
 	ch := make(chan string)
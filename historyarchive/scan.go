@@ -220,7 +220,11 @@ func (arch *Archive) ScanBuckets(opts *CommandOptions) error {
 		log.Print("Continuing and will do an exists-check on each bucket as we go, this will be slower")
 	}
 	if doList {
-		errs += noteError(arch.ScanAllBuckets())
+		e := arch.ScanAllBuckets()
+		errs += noteError(e)
+		arch.mutex.Lock()
+		arch.listedAllBuckets = e == nil
+		arch.mutex.Unlock()
 	} else {
 		log.Printf("Scanning buckets for %d checkpoints", opts.Range.SizeInCheckPoints(arch.checkpointManager))
 	}
@@ -290,7 +294,7 @@ func (arch *Archive) ScanBuckets(opts *CommandOptions) error {
 								atomic.AddUint32(&errs, n)
 								if n != 0 {
 									arch.mutex.Lock()
-									arch.invalidBuckets++
+									arch.invalidBuckets[bucket] = true
 									arch.mutex.Unlock()
 								}
 							}
@@ -320,6 +324,8 @@ func (arch *Archive) ClearCachedInfo() {
 	}
 	arch.allBuckets = make(map[Hash]bool)
 	arch.referencedBuckets = make(map[Hash]bool)
+	arch.invalidBuckets = make(map[Hash]bool)
+	arch.listedAllBuckets = false
 }
 
 func (arch *Archive) ReportCheckpointStats() {
@@ -52,7 +52,12 @@ func copyPath(src *Archive, dst *Archive, pth string, opts *CommandOptions) erro
 		return err
 	}
 	defer rdr.Close()
-	err = dst.backend.PutFile(pth, bufReadCloser(rdr))
+	counter := newCountReader(rdr)
+	err = dst.backend.PutFile(pth, bufReadCloser(counter))
+	src.bytesRead.Add(counter.bytesRead)
+	if err == nil {
+		dst.bytesWritten.Add(counter.bytesRead)
+	}
 	return err
 }
 
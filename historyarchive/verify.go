@@ -251,29 +251,40 @@ func reportValidity(ty string, nbad int, total int) {
 	}
 }
 
-func compareHashMaps(expect map[uint32]Hash, actual map[uint32]Hash, ty string,
-	passOn func(eledger uint32, ehash Hash) bool) int {
-	n := 0
+// HashMismatch describes an object whose hash doesn't match the hash expected
+// by the next ledger header (or the ledger header itself).
+type HashMismatch struct {
+	Ledger   uint32 `json:"ledger"`
+	Expected string `json:"expected"`
+	// Actual is the zero hash when the object was not found.
+	Actual string `json:"actual"`
+}
+
+func compareHashMaps(expect map[uint32]Hash, actual map[uint32]Hash,
+	passOn func(eledger uint32, ehash Hash) bool) []HashMismatch {
+	mismatches := []HashMismatch{}
 	for eledger, ehash := range expect {
 		ahash, ok := actual[eledger]
 		if !ok && passOn(eledger, ehash) {
 			continue
 		}
 		if ahash != ehash {
-			n++
-			log.Errorf("Error: mismatched hash on %s 0x%8.8x: expected %s, got %s",
-				ty, eledger, ehash, ahash)
+			mismatches = append(mismatches, HashMismatch{
+				Ledger:   eledger,
+				Expected: ehash.String(),
+				Actual:   ahash.String(),
+			})
 		}
 	}
-	reportValidity(ty, n, len(expect))
-	return n
+	sort.Slice(mismatches, func(i, j int) bool {
+		return mismatches[i].Ledger < mismatches[j].Ledger
+	})
+	return mismatches
 }
 
-func (arch *Archive) ReportInvalid(opts *CommandOptions) (bool, error) {
-	if !opts.Verify {
-		return false, nil
-	}
-
+// hashMismatches returns ledger headers, transaction sets and transaction
+// result sets with unexpected hashes found by verifying scanned checkpoints.
+func (arch *Archive) hashMismatches() (ledgers, txSets, txResultSets []HashMismatch) {
 	arch.mutex.Lock()
 	defer arch.mutex.Unlock()
 
@@ -284,16 +295,16 @@ func (arch *Archive) ReportInvalid(opts *CommandOptions) (bool, error) {
 		}
 	}
 
-	arch.invalidLedgers = compareHashMaps(arch.expectLedgerHashes,
-		arch.actualLedgerHashes, "ledger header",
+	ledgers = compareHashMaps(arch.expectLedgerHashes,
+		arch.actualLedgerHashes,
 		func(eledger uint32, ehash Hash) bool {
 			// We will never have the lowest expected ledger, because
 			// it's one-before the first checkpoint we scanned.
 			return eledger == lowest
 		})
 
-	arch.invalidTxSets = compareHashMaps(arch.expectTxSetHashes,
-		arch.actualTxSetHashes, "transaction set",
+	txSets = compareHashMaps(arch.expectTxSetHashes,
+		arch.actualTxSetHashes,
 		func(eledger uint32, ehash Hash) bool {
 			// When there was an empty txset, it produces just the hash of
 			// the previous ledger header followed by nothing.
@@ -301,22 +312,39 @@ func (arch *Archive) ReportInvalid(opts *CommandOptions) (bool, error) {
 		})
 
 	emptyXdrArrayHash := EmptyXdrArrayHash()
-	arch.invalidTxResultSets = compareHashMaps(arch.expectTxResultSetHashes,
-		arch.actualTxResultSetHashes, "transaction result set",
+	txResultSets = compareHashMaps(arch.expectTxResultSetHashes,
+		arch.actualTxResultSetHashes,
 		func(eledger uint32, ehash Hash) bool {
 			// When there was an empty txresultset, it produces just the hash of
 			// the 4-zero-byte "0 entries" XDR array.
 			return ehash == emptyXdrArrayHash
 		})
+	return
+}
 
-	reportValidity("bucket", arch.invalidBuckets, len(arch.referencedBuckets))
+func reportMismatches(ty string, mismatches []HashMismatch, total int) {
+	for _, m := range mismatches {
+		log.Errorf("Error: mismatched hash on %s 0x%8.8x: expected %s, got %s",
+			ty, m.Ledger, m.Expected, m.Actual)
+	}
+	reportValidity(ty, len(mismatches), total)
+}
+
+func (arch *Archive) ReportInvalid(opts *CommandOptions) (bool, error) {
+	if !opts.Verify {
+		return false, nil
+	}
 
-	totalInvalid := arch.invalidBuckets
-	totalInvalid += arch.invalidLedgers
-	totalInvalid += arch.invalidTxSets
-	totalInvalid += arch.invalidTxResultSets
+	report := arch.Report(opts)
+
+	arch.mutex.Lock()
+	reportMismatches("ledger header", report.InvalidLedgers, len(arch.expectLedgerHashes))
+	reportMismatches("transaction set", report.InvalidTxSets, len(arch.expectTxSetHashes))
+	reportMismatches("transaction result set", report.InvalidTxResultSets, len(arch.expectTxResultSetHashes))
+	reportValidity("bucket", len(report.InvalidBuckets), len(arch.referencedBuckets))
+	arch.mutex.Unlock()
 
-	if totalInvalid != 0 {
+	if totalInvalid := report.InvalidCount(); totalInvalid != 0 {
 		return true, fmt.Errorf("Detected %d objects with unexpected hashes", totalInvalid)
 	}
 	return false, nil
@@ -149,41 +149,69 @@ func (x *XdrStream) closeReaders() error {
 }
 
 func (x *XdrStream) ReadOne(in xdr.DecoderFrom) error {
+	raw, err := x.ReadOneRaw()
+	if err != nil {
+		return err
+	}
+	return x.decode(in, raw)
+}
+
+// ReadOneFiltered reads records until keep returns true for the XDR encoding
+// of a record and decodes that record. Records which are not kept are not
+// decoded.
+func (x *XdrStream) ReadOneFiltered(in xdr.DecoderFrom, keep func(raw []byte) bool) error {
+	for {
+		raw, err := x.ReadOneRaw()
+		if err != nil {
+			return err
+		}
+		if keep(raw) {
+			return x.decode(in, raw)
+		}
+	}
+}
+
+// ReadOneRaw reads the next record without decoding it. The returned slice is
+// only valid until the next read.
+func (x *XdrStream) ReadOneRaw() ([]byte, error) {
 	var nbytes uint32
 	err := binary.Read(x.rdr, binary.BigEndian, &nbytes)
 	if err != nil {
 		x.rdr.Close()
 		if err == io.EOF {
 			// Do not wrap io.EOF
-			return err
+			return nil, err
 		}
-		return errors.Wrap(err, "binary.Read error")
+		return nil, errors.Wrap(err, "binary.Read error")
 	}
 	nbytes &= 0x7fffffff
 	x.buf.Reset()
 	if nbytes == 0 {
 		x.rdr.Close()
-		return io.EOF
+		return nil, io.EOF
 	}
 	x.buf.Grow(int(nbytes))
 	read, err := x.buf.ReadFrom(io.LimitReader(x.rdr, int64(nbytes)))
 	if err != nil {
 		x.rdr.Close()
-		return err
+		return nil, err
 	}
 	if read != int64(nbytes) {
 		x.rdr.Close()
-		return errors.New("Read wrong number of bytes from XDR")
+		return nil, errors.New("Read wrong number of bytes from XDR")
 	}
+	return x.buf.Bytes(), nil
+}
 
-	readi, err := x.xdrDecoder.DecodeBytes(in, x.buf.Bytes())
+func (x *XdrStream) decode(in xdr.DecoderFrom, raw []byte) error {
+	readi, err := x.xdrDecoder.DecodeBytes(in, raw)
 	if err != nil {
 		x.rdr.Close()
 		return err
 	}
-	if int64(readi) != int64(nbytes) {
+	if readi != len(raw) {
 		return fmt.Errorf("Unmarshalled %d bytes from XDR, expected %d)",
-			readi, nbytes)
+			readi, len(raw))
 	}
 	return nil
 }
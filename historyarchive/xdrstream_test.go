@@ -116,3 +116,44 @@ func TestXdrStreamDiscard(t *testing.T) {
 	assert.NoError(t, discardStream.Close())
 	assert.NoError(t, fullStream.Close())
 }
+
+func TestXdrStreamReadOneFiltered(t *testing.T) {
+	entries := []xdr.BucketEntry{}
+	for _, balance := range []int64{1, 2, 3} {
+		entries = append(entries, xdr.BucketEntry{
+			Type: xdr.BucketEntryTypeLiveentry,
+			LiveEntry: &xdr.LedgerEntry{
+				Data: xdr.LedgerEntryData{
+					Type: xdr.LedgerEntryTypeAccount,
+					Account: &xdr.AccountEntry{
+						AccountId: xdr.MustAddress("GC3C4AKRBQLHOJ45U4XG35ESVWRDECWO5XLDGYADO6DPR3L7KIDVUMML"),
+						Balance:   xdr.Int64(balance),
+					},
+				},
+			},
+		})
+	}
+	stream := CreateXdrStream(entries...)
+	b := &bytes.Buffer{}
+	for _, entry := range entries {
+		require.NoError(t, xdr.MarshalFramed(b, entry))
+	}
+	stream.SetExpectedHash(sha256.Sum256(b.Bytes()))
+
+	// Skip the second entry using its raw encoding
+	second, err := entries[1].MarshalBinary()
+	require.NoError(t, err)
+	keep := func(raw []byte) bool {
+		return !bytes.Equal(raw, second)
+	}
+
+	var readBucketEntry xdr.BucketEntry
+	require.NoError(t, stream.ReadOneFiltered(&readBucketEntry, keep))
+	assert.Equal(t, entries[0], readBucketEntry)
+	require.NoError(t, stream.ReadOneFiltered(&readBucketEntry, keep))
+	assert.Equal(t, entries[2], readBucketEntry)
+	assert.Equal(t, io.EOF, stream.ReadOneFiltered(&readBucketEntry, keep))
+
+	// Skipped entries are included in the hash
+	assert.NoError(t, stream.Close())
+}
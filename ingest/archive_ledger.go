@@ -0,0 +1,79 @@
+package ingest
+
+import (
+	"io"
+
+	"github.com/stellar/go/historyarchive"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/xdr"
+)
+
+// NewArchiveLedgerFromLedgerCloseMeta converts ledger close meta to the data
+// written to a history archive by historyarchive.ArchiveWriter: the ledger
+// header, transactions with their results and the ledger entry changes of the
+// ledger.
+func NewArchiveLedgerFromLedgerCloseMeta(
+	networkPassphrase string,
+	ledger xdr.LedgerCloseMeta,
+) (historyarchive.ArchiveLedger, error) {
+	var meta xdr.LedgerCloseMetaV0
+	switch ledger.V {
+	case 0:
+		meta = ledger.MustV0()
+	default:
+		// The ledger change reader supports only V0 meta.
+		return historyarchive.ArchiveLedger{}, errors.Errorf("unsupported LedgerCloseMeta version: %d", ledger.V)
+	}
+
+	archiveLedger := historyarchive.ArchiveLedger{
+		Header:  meta.LedgerHeader,
+		ScpInfo: meta.ScpInfo,
+	}
+
+	if len(meta.TxProcessing) > 0 {
+		sequence := meta.LedgerHeader.Header.LedgerSeq
+		results := make([]xdr.TransactionResultPair, len(meta.TxProcessing))
+		for i, processing := range meta.TxProcessing {
+			results[i] = processing.Result
+		}
+		archiveLedger.Transactions = xdr.TransactionHistoryEntry{
+			LedgerSeq: sequence,
+			TxSet:     meta.TxSet,
+		}
+		archiveLedger.Results = xdr.TransactionHistoryResultEntry{
+			LedgerSeq:   sequence,
+			TxResultSet: xdr.TransactionResultSet{Results: results},
+		}
+	}
+
+	reader, err := NewLedgerChangeReaderFromLedgerCloseMeta(networkPassphrase, ledger)
+	if err != nil {
+		return archiveLedger, errors.Wrap(err, "error creating ledger change reader")
+	}
+	defer reader.Close()
+
+	compactor := NewChangeCompactor()
+	for {
+		change, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return archiveLedger, errors.Wrap(err, "error reading ledger changes")
+		}
+		if err = compactor.AddChange(change); err != nil {
+			return archiveLedger, errors.Wrap(err, "error compacting ledger changes")
+		}
+	}
+
+	for _, change := range compactor.GetChanges() {
+		switch {
+		case change.Pre == nil:
+			archiveLedger.InitEntries = append(archiveLedger.InitEntries, *change.Post)
+		case change.Post == nil:
+			archiveLedger.DeadEntries = append(archiveLedger.DeadEntries, change.Pre.LedgerKey())
+		default:
+			archiveLedger.LiveEntries = append(archiveLedger.LiveEntries, *change.Post)
+		}
+	}
+	return archiveLedger, nil
+}
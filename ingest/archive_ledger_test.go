@@ -0,0 +1,135 @@
+package ingest
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stellar/go/historyarchive"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/xdr"
+)
+
+func archiveLedgerAccount(address string, balance int64) xdr.LedgerEntry {
+	return xdr.LedgerEntry{
+		Data: xdr.LedgerEntryData{
+			Type: xdr.LedgerEntryTypeAccount,
+			Account: &xdr.AccountEntry{
+				AccountId: xdr.MustAddress(address),
+				Balance:   xdr.Int64(balance),
+			},
+		},
+	}
+}
+
+// archiveLedgerCloseMeta returns ledger close meta in which created is
+// created, updated is updated and removed is removed in a protocol upgrade.
+func archiveLedgerCloseMeta(seq uint32, previousHash xdr.Hash, created, updated, removed *xdr.LedgerEntry) xdr.LedgerCloseMeta {
+	var changes xdr.LedgerEntryChanges
+	if created != nil {
+		changes = append(changes, xdr.LedgerEntryChange{
+			Type:    xdr.LedgerEntryChangeTypeLedgerEntryCreated,
+			Created: created,
+		})
+	}
+	if updated != nil {
+		changes = append(changes,
+			xdr.LedgerEntryChange{Type: xdr.LedgerEntryChangeTypeLedgerEntryState, State: updated},
+			xdr.LedgerEntryChange{Type: xdr.LedgerEntryChangeTypeLedgerEntryUpdated, Updated: updated},
+		)
+	}
+	if removed != nil {
+		key := removed.LedgerKey()
+		changes = append(changes,
+			xdr.LedgerEntryChange{Type: xdr.LedgerEntryChangeTypeLedgerEntryState, State: removed},
+			xdr.LedgerEntryChange{Type: xdr.LedgerEntryChangeTypeLedgerEntryRemoved, Removed: &key},
+		)
+	}
+	return xdr.LedgerCloseMeta{
+		V0: &xdr.LedgerCloseMetaV0{
+			LedgerHeader: xdr.LedgerHeaderHistoryEntry{
+				Hash: xdr.Hash{byte(seq), byte(seq >> 8)},
+				Header: xdr.LedgerHeader{
+					LedgerVersion:      18,
+					LedgerSeq:          xdr.Uint32(seq),
+					PreviousLedgerHash: previousHash,
+				},
+			},
+			UpgradesProcessing: []xdr.UpgradeEntryMeta{{Changes: changes}},
+		},
+	}
+}
+
+func TestNewArchiveLedgerFromLedgerCloseMeta(t *testing.T) {
+	created := archiveLedgerAccount(keypair.MustRandom().Address(), 100)
+	updated := archiveLedgerAccount(keypair.MustRandom().Address(), 200)
+	removed := archiveLedgerAccount(keypair.MustRandom().Address(), 300)
+	meta := archiveLedgerCloseMeta(64, xdr.Hash{}, &created, &updated, &removed)
+
+	ledger, err := NewArchiveLedgerFromLedgerCloseMeta(network.TestNetworkPassphrase, meta)
+	require.NoError(t, err)
+	assert.Equal(t, meta.V0.LedgerHeader, ledger.Header)
+	assert.Empty(t, ledger.Results.TxResultSet.Results)
+	assert.Equal(t, []xdr.LedgerEntry{created}, ledger.InitEntries)
+	assert.Equal(t, []xdr.LedgerEntry{updated}, ledger.LiveEntries)
+	assert.Equal(t, []xdr.LedgerKey{removed.LedgerKey()}, ledger.DeadEntries)
+}
+
+func TestNewArchiveLedgerFromLedgerCloseMetaUnsupportedVersion(t *testing.T) {
+	ledger := xdr.LedgerCloseMeta{
+		V:  1,
+		V1: &xdr.LedgerCloseMetaV1{},
+	}
+	_, err := NewArchiveLedgerFromLedgerCloseMeta(network.TestNetworkPassphrase, ledger)
+	assert.EqualError(t, err, "unsupported LedgerCloseMeta version: 1")
+}
+
+func TestArchiveWriterCheckpointChangeReader(t *testing.T) {
+	archive, err := historyarchive.Connect("mock://test", historyarchive.ConnectOptions{})
+	require.NoError(t, err)
+	writer, err := historyarchive.NewArchiveWriter(archive, historyarchive.NewBucketList(), 63, historyarchive.ArchiveWriterOptions{
+		NetworkPassphrase:       network.TestNetworkPassphrase,
+		SkipBucketListHashCheck: true,
+	})
+	require.NoError(t, err)
+
+	// Every ledger creates an account and removes the account created two
+	// ledgers before in even ledgers.
+	expected := map[string]bool{}
+	var accounts []xdr.LedgerEntry
+	var previousHash xdr.Hash
+	for seq := uint32(64); seq <= 127; seq++ {
+		created := archiveLedgerAccount(keypair.MustRandom().Address(), int64(seq))
+		accounts = append(accounts, created)
+		expected[created.Data.Account.AccountId.Address()] = true
+		var removed *xdr.LedgerEntry
+		if seq%2 == 0 && len(accounts) > 2 {
+			removed = &accounts[len(accounts)-3]
+			delete(expected, removed.Data.Account.AccountId.Address())
+		}
+
+		meta := archiveLedgerCloseMeta(seq, previousHash, &created, nil, removed)
+		previousHash = meta.LedgerHash()
+		ledger, err := NewArchiveLedgerFromLedgerCloseMeta(network.TestNetworkPassphrase, meta)
+		require.NoError(t, err)
+		require.NoError(t, writer.AddLedger(ledger))
+	}
+
+	reader, err := NewCheckpointChangeReader(context.Background(), archive, 127)
+	require.NoError(t, err)
+	defer reader.Close()
+	actual := map[string]bool{}
+	for {
+		change, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		actual[change.Post.Data.Account.AccountId.Address()] = true
+	}
+	assert.Equal(t, expected, actual)
+}
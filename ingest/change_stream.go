@@ -0,0 +1,196 @@
+package ingest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+
+	"github.com/stellar/go/historyarchive"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/xdr"
+)
+
+// Change streams are files containing a sequence of changes written by
+// ChangeStreamWriter and read by ChangeStreamReader. A stream starts with an
+// uncompressed header (changeStreamMagic followed by the format version as a
+// big-endian uint32) followed by a gzip stream of XDR framed records (the
+// same framing as history archive files). Every record is a
+// LedgerEntryChanges representing a single change:
+//
+// - created entry: [CREATED(post)],
+// - updated entry: [STATE(pre), UPDATED(post)],
+// - removed entry: [STATE(pre), REMOVED(key)].
+var changeStreamMagic = [8]byte{'S', 'T', 'L', 'R', 'C', 'H', 'N', 'G'}
+
+// ChangeStreamVersion is the version of the change stream format written by
+// ChangeStreamWriter.
+const ChangeStreamVersion uint32 = 1
+
+// ChangeStreamWriter writes changes to a change stream which can be read
+// using ChangeStreamReader.
+//
+// ChangeStreamWriter is not thread-safe.
+type ChangeStreamWriter struct {
+	gzipWriter *gzip.Writer
+	written    int
+}
+
+// NewChangeStreamWriter writes the change stream header to w and returns a
+// ChangeStreamWriter writing changes to w. Close must be called to flush
+// the stream, it doesn't close w.
+func NewChangeStreamWriter(w io.Writer) (*ChangeStreamWriter, error) {
+	var header bytes.Buffer
+	header.Write(changeStreamMagic[:])
+	if err := binary.Write(&header, binary.BigEndian, ChangeStreamVersion); err != nil {
+		return nil, errors.Wrap(err, "error encoding change stream header")
+	}
+	if _, err := header.WriteTo(w); err != nil {
+		return nil, errors.Wrap(err, "error writing change stream header")
+	}
+
+	return &ChangeStreamWriter{gzipWriter: gzip.NewWriter(w)}, nil
+}
+
+// Write appends the change to the stream.
+func (w *ChangeStreamWriter) Write(change Change) error {
+	if change.Pre == nil && change.Post == nil {
+		return errors.Errorf("change %d has neither Pre nor Post entry", w.written)
+	}
+
+	var record xdr.LedgerEntryChanges
+	switch change.LedgerEntryChangeType() {
+	case xdr.LedgerEntryChangeTypeLedgerEntryCreated:
+		record = xdr.LedgerEntryChanges{
+			{Type: xdr.LedgerEntryChangeTypeLedgerEntryCreated, Created: change.Post},
+		}
+	case xdr.LedgerEntryChangeTypeLedgerEntryUpdated:
+		record = xdr.LedgerEntryChanges{
+			{Type: xdr.LedgerEntryChangeTypeLedgerEntryState, State: change.Pre},
+			{Type: xdr.LedgerEntryChangeTypeLedgerEntryUpdated, Updated: change.Post},
+		}
+	case xdr.LedgerEntryChangeTypeLedgerEntryRemoved:
+		key := change.Pre.LedgerKey()
+		record = xdr.LedgerEntryChanges{
+			{Type: xdr.LedgerEntryChangeTypeLedgerEntryState, State: change.Pre},
+			{Type: xdr.LedgerEntryChangeTypeLedgerEntryRemoved, Removed: &key},
+		}
+	}
+
+	if err := xdr.MarshalFramed(w.gzipWriter, record); err != nil {
+		return errors.Wrapf(err, "error marshaling change %d", w.written)
+	}
+	w.written++
+	return nil
+}
+
+// WriteAll writes all changes returned by reader to the stream and returns
+// the number of written changes. The reader is not closed.
+func (w *ChangeStreamWriter) WriteAll(reader ChangeReader) (int, error) {
+	count := 0
+	for {
+		change, err := reader.Read()
+		if err == io.EOF {
+			return count, nil
+		} else if err != nil {
+			return count, errors.Wrap(err, "error reading change")
+		}
+		if err = w.Write(change); err != nil {
+			return count, err
+		}
+		count++
+	}
+}
+
+// Close flushes the stream. It doesn't close the underlying writer.
+func (w *ChangeStreamWriter) Close() error {
+	if err := w.gzipWriter.Close(); err != nil {
+		return errors.Wrap(err, "error closing gzip writer")
+	}
+	return nil
+}
+
+// ChangeStreamReader is a ChangeReader returning changes from a change stream
+// written by ChangeStreamWriter.
+type ChangeStreamReader struct {
+	stream *historyarchive.XdrStream
+	read   int
+}
+
+// Ensure ChangeStreamReader implements ChangeReader
+var _ ChangeReader = &ChangeStreamReader{}
+
+// NewChangeStreamReader reads the change stream header from r and returns a
+// ChangeStreamReader reading changes from r. Close closes r if it implements
+// io.Closer.
+func NewChangeStreamReader(r io.Reader) (*ChangeStreamReader, error) {
+	var magic [len(changeStreamMagic)]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil {
+		return nil, errors.Wrap(err, "error reading change stream header")
+	}
+	if magic != changeStreamMagic {
+		return nil, errors.New("not a change stream")
+	}
+	var version uint32
+	if err := binary.Read(r, binary.BigEndian, &version); err != nil {
+		return nil, errors.Wrap(err, "error reading change stream header")
+	}
+	if version != ChangeStreamVersion {
+		return nil, errors.Errorf("unsupported change stream version: %d", version)
+	}
+
+	rc, ok := r.(io.ReadCloser)
+	if !ok {
+		rc = ioutil.NopCloser(r)
+	}
+	stream, err := historyarchive.NewXdrGzStream(rc)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating gzip reader")
+	}
+	return &ChangeStreamReader{stream: stream}, nil
+}
+
+// Read returns the next change in the stream. It returns io.EOF when there
+// are no more changes.
+func (r *ChangeStreamReader) Read() (Change, error) {
+	var record xdr.LedgerEntryChanges
+	if err := r.stream.ReadOne(&record); err == io.EOF {
+		return Change{}, io.EOF
+	} else if err != nil {
+		return Change{}, errors.Wrapf(err, "error reading change %d", r.read)
+	}
+
+	change, err := changeFromRecord(record)
+	if err != nil {
+		return Change{}, errors.Wrapf(err, "invalid change %d", r.read)
+	}
+	r.read++
+	return change, nil
+}
+
+func changeFromRecord(record xdr.LedgerEntryChanges) (Change, error) {
+	switch len(record) {
+	case 1:
+		if record[0].Type != xdr.LedgerEntryChangeTypeLedgerEntryCreated {
+			return Change{}, errors.Errorf("unexpected change type: %s", record[0].Type)
+		}
+	case 2:
+		if record[0].Type != xdr.LedgerEntryChangeTypeLedgerEntryState {
+			return Change{}, errors.Errorf("unexpected change type: %s", record[0].Type)
+		}
+		switch record[1].Type {
+		case xdr.LedgerEntryChangeTypeLedgerEntryUpdated, xdr.LedgerEntryChangeTypeLedgerEntryRemoved:
+		default:
+			return Change{}, errors.Errorf("unexpected change type: %s", record[1].Type)
+		}
+	default:
+		return Change{}, errors.Errorf("unexpected number of entry changes: %d", len(record))
+	}
+	return GetChangesFromLedgerEntryChanges(record)[0], nil
+}
+
+// Close closes the stream.
+func (r *ChangeStreamReader) Close() error {
+	return r.stream.Close()
+}
@@ -0,0 +1,92 @@
+package ingest
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stellar/go/xdr"
+)
+
+func TestChangeStreamRoundTrip(t *testing.T) {
+	created := archiveLedgerAccount(testSourceAddress, 100)
+	pre := archiveLedgerAccount(testDestinationAddress, 100)
+	post := archiveLedgerAccount(testDestinationAddress, 200)
+	removed := archiveLedgerAccount(testSellerAddress, 300)
+	changes := []Change{
+		{Type: xdr.LedgerEntryTypeAccount, Post: &created},
+		{Type: xdr.LedgerEntryTypeAccount, Pre: &pre, Post: &post},
+		{Type: xdr.LedgerEntryTypeAccount, Pre: &removed},
+	}
+
+	mockReader := &MockChangeReader{}
+	for _, change := range changes {
+		mockReader.On("Read").Return(change, nil).Once()
+	}
+	mockReader.On("Read").Return(Change{}, io.EOF).Once()
+
+	var buf bytes.Buffer
+	writer, err := NewChangeStreamWriter(&buf)
+	require.NoError(t, err)
+	count, err := writer.WriteAll(mockReader)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+	assert.EqualError(t, writer.Write(Change{}), "change 3 has neither Pre nor Post entry")
+	require.NoError(t, writer.Close())
+	mockReader.AssertExpectations(t)
+
+	reader, err := NewChangeStreamReader(&buf)
+	require.NoError(t, err)
+	for _, expected := range changes {
+		change, err := reader.Read()
+		require.NoError(t, err)
+		assert.Equal(t, expected, change)
+	}
+	_, err = reader.Read()
+	assert.Equal(t, io.EOF, err)
+	require.NoError(t, reader.Close())
+}
+
+func TestChangeStreamEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	writer, err := NewChangeStreamWriter(&buf)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	reader, err := NewChangeStreamReader(&buf)
+	require.NoError(t, err)
+	_, err = reader.Read()
+	assert.Equal(t, io.EOF, err)
+	require.NoError(t, reader.Close())
+}
+
+func TestChangeStreamInvalidHeader(t *testing.T) {
+	_, err := NewChangeStreamReader(bytes.NewReader([]byte("STLRCHNG")))
+	assert.EqualError(t, err, "error reading change stream header: EOF")
+
+	_, err = NewChangeStreamReader(bytes.NewReader([]byte("not a stream")))
+	assert.EqualError(t, err, "not a change stream")
+
+	_, err = NewChangeStreamReader(bytes.NewReader([]byte("STLRCHNG\x00\x00\x00\x02")))
+	assert.EqualError(t, err, "unsupported change stream version: 2")
+}
+
+func TestChangeStreamInvalidRecord(t *testing.T) {
+	updated := archiveLedgerAccount(testSourceAddress, 100)
+	var buf bytes.Buffer
+	writer, err := NewChangeStreamWriter(&buf)
+	require.NoError(t, err)
+	// UPDATED must be preceded by STATE
+	require.NoError(t, xdr.MarshalFramed(writer.gzipWriter, xdr.LedgerEntryChanges{
+		{Type: xdr.LedgerEntryChangeTypeLedgerEntryUpdated, Updated: &updated},
+	}))
+	require.NoError(t, writer.Close())
+
+	reader, err := NewChangeStreamReader(&buf)
+	require.NoError(t, err)
+	_, err = reader.Read()
+	assert.EqualError(t, err, "invalid change 0: unexpected change type: LedgerEntryChangeTypeLedgerEntryUpdated")
+}
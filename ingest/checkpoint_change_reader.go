@@ -36,6 +36,11 @@ type CheckpointChangeReader struct {
 	totalSize      int64
 
 	encodingBuffer *xdr.EncodingBuffer
+	// concurrency is the number of buckets streamed concurrently, see
+	// CheckpointChangeReaderOptions.
+	concurrency int
+	// filter restricts returned changes, nil if all changes are returned.
+	filter *checkpointFilter
 
 	// This should be set to true in tests only
 	disableBucketListHashValidation bool
@@ -73,8 +78,44 @@ const (
 	preloadedEntries = 20000
 
 	sleepDuration = time.Second
+
+	// bucketBatchBufferSize defines how many batches of preloadedEntries
+	// entries are decoded ahead for each bucket streamed concurrently.
+	bucketBatchBufferSize = 4
 )
 
+// CheckpointChangeReaderOptions are options of CheckpointChangeReader.
+type CheckpointChangeReaderOptions struct {
+	// Concurrency is the maximum number of buckets downloaded and decoded at
+	// the same time. Changes are returned in the same order regardless of
+	// concurrency because buckets are still processed from the newest to the
+	// oldest one. Buckets are streamed one by one when Concurrency is 0 or 1.
+	//
+	// Every bucket streamed concurrently buffers up to
+	// bucketBatchBufferSize*preloadedEntries (80000) decoded entries.
+	Concurrency int
+	// TempSetDir makes the reader keep ledger keys of processed entries (see
+	// streamBuckets) in sorted files in a temporary directory created in
+	// TempSetDir instead of memory, which requires gigabytes of RAM on
+	// pubnet. The directory is removed when all buckets are streamed.
+	TempSetDir string
+	// TempSetMemoryKeys is the number of keys kept in memory before they're
+	// written to a file when TempSetDir is set. Defaults to 1000000.
+	TempSetMemoryKeys int
+	// EntryTypes restricts returned changes to ledger entries of the given
+	// types. Bucket entries of other types are skipped without decoding.
+	EntryTypes []xdr.LedgerEntryType
+	// Accounts restricts returned changes to accounts, trustlines, offers and
+	// data entries of the given accounts. Bucket entries of other accounts
+	// are skipped without decoding.
+	Accounts []string
+	// Assets restricts returned changes to ledger entries holding or trading
+	// one of the given assets: accounts (native asset), trustlines, offers
+	// (selling or buying), claimable balances and liquidity pools. Entries
+	// are filtered by assets after decoding.
+	Assets []xdr.Asset
+}
+
 // NewCheckpointChangeReader constructs a new CheckpointChangeReader instance.
 //
 // The ledger sequence must be a checkpoint ledger. By default (see
@@ -85,6 +126,17 @@ func NewCheckpointChangeReader(
 	ctx context.Context,
 	archive historyarchive.ArchiveInterface,
 	sequence uint32,
+) (*CheckpointChangeReader, error) {
+	return NewCheckpointChangeReaderWithOptions(ctx, archive, sequence, CheckpointChangeReaderOptions{})
+}
+
+// NewCheckpointChangeReaderWithOptions constructs a new CheckpointChangeReader
+// instance like NewCheckpointChangeReader using the given options.
+func NewCheckpointChangeReaderWithOptions(
+	ctx context.Context,
+	archive historyarchive.ArchiveInterface,
+	sequence uint32,
+	opts CheckpointChangeReaderOptions,
 ) (*CheckpointChangeReader, error) {
 	manager := archive.GetCheckpointManager()
 
@@ -104,7 +156,15 @@ func NewCheckpointChangeReader(
 		return nil, errors.Wrapf(err, "unable to get checkpoint HAS at ledger sequence %d", sequence)
 	}
 
-	tempStore := &memoryTempSet{}
+	filter, err := newCheckpointFilter(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var tempStore tempSet = &memoryTempSet{}
+	if opts.TempSetDir != "" {
+		tempStore = newDiskTempSet(opts.TempSetDir, opts.TempSetMemoryKeys)
+	}
 	err = tempStore.Open()
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to get open temp store")
@@ -121,6 +181,8 @@ func NewCheckpointChangeReader(
 		closeOnce:      sync.Once{},
 		done:           make(chan bool),
 		encodingBuffer: xdr.NewEncodingBuffer(),
+		concurrency:    opts.Concurrency,
+		filter:         filter,
 		sleep:          time.Sleep,
 	}, nil
 }
@@ -224,6 +286,11 @@ func (r *CheckpointChangeReader) streamBuckets() {
 		r.readBytesMutex.Unlock()
 	}
 
+	if r.concurrency > 1 {
+		r.streamBucketsConcurrently(buckets)
+		return
+	}
+
 	for i, hash := range buckets {
 		oldestBucket := i == len(buckets)-1
 		if shouldContinue := r.streamBucketContents(hash, oldestBucket); !shouldContinue {
@@ -251,7 +318,11 @@ func (r *CheckpointChangeReader) readBucketEntry(stream *historyarchive.XdrStrea
 			break
 		}
 		if err == nil {
-			err = stream.ReadOne(&entry)
+			if r.filter != nil {
+				err = stream.ReadOneFiltered(&entry, r.filter.keepRaw)
+			} else {
+				err = stream.ReadOne(&entry)
+			}
 			if err == nil || err == io.EOF {
 				r.readBytesMutex.Lock()
 				r.totalRead += stream.GzipBytesRead() - gzipCurrentPosition
@@ -298,6 +369,65 @@ func (r *CheckpointChangeReader) newXDRStream(hash historyarchive.Hash) (
 	return rdr, e
 }
 
+// bucketBatch is a batch of entries read from a bucket together with their
+// compressed ledger keys (empty for METAENTRY).
+type bucketBatch struct {
+	entries []xdr.BucketEntry
+	keys    []string
+	// last is true when the end of the bucket was reached.
+	last bool
+	err  error
+}
+
+// readBucketBatch reads up to preloadedEntries entries from the stream. n is
+// the number of the last entry read from the bucket, used in errors.
+func (r *CheckpointChangeReader) readBucketBatch(
+	rdr *historyarchive.XdrStream,
+	hash historyarchive.Hash,
+	encodingBuffer *xdr.EncodingBuffer,
+	n int,
+) bucketBatch {
+	var batch bucketBatch
+	for i := 0; i < preloadedEntries; i++ {
+		entry, e := r.readBucketEntry(rdr, hash)
+		if e != nil {
+			if e == io.EOF {
+				batch.last = true
+				return batch
+			}
+			batch.err = errors.Wrapf(e, "Error on XDR record %d of hash '%s'", n, hash.String())
+			return batch
+		}
+
+		batch.entries = append(batch.entries, entry)
+
+		// Generate a key
+		var key xdr.LedgerKey
+
+		switch entry.Type {
+		case xdr.BucketEntryTypeLiveentry, xdr.BucketEntryTypeInitentry:
+			liveEntry := entry.MustLiveEntry()
+			key = liveEntry.LedgerKey()
+		case xdr.BucketEntryTypeDeadentry:
+			key = entry.MustDeadEntry()
+		default:
+			// No ledger key associated with this entry, continue to the next one.
+			batch.keys = append(batch.keys, "")
+			continue
+		}
+
+		// We're using compressed keys here
+		// safe, since we are converting to string right away
+		keyBytes, e := encodingBuffer.LedgerKeyUnsafeMarshalBinaryCompress(key)
+		if e != nil {
+			batch.err = errors.Wrapf(e, "Error marshaling XDR record %d of hash '%s'", n, hash.String())
+			return batch
+		}
+		batch.keys = append(batch.keys, string(keyBytes))
+	}
+	return batch
+}
+
 // streamBucketContents pushes value onto the read channel, returning false when the channel needs to be closed otherwise true
 func (r *CheckpointChangeReader) streamBucketContents(hash historyarchive.Hash, oldestBucket bool) bool {
 	rdr, e := r.newXDRStream(hash)
@@ -317,72 +447,56 @@ func (r *CheckpointChangeReader) streamBucketContents(hash historyarchive.Hash,
 		}
 	}()
 
+	n := -1
+	return r.processBucketBatches(hash, oldestBucket, func() bucketBatch {
+		batch := r.readBucketBatch(rdr, hash, r.encodingBuffer, n)
+		n += len(batch.entries)
+		return batch
+	})
+}
+
+// processBucketBatches pushes entries of the batches returned by nextBatch
+// onto the read channel, returning false when the channel needs to be closed
+// otherwise true.
+func (r *CheckpointChangeReader) processBucketBatches(
+	hash historyarchive.Hash,
+	oldestBucket bool,
+	nextBatch func() bucketBatch,
+) bool {
 	// bucketProtocolVersion is a protocol version read from METAENTRY or 0 when no METAENTRY.
 	// No METAENTRY means that bucket originates from before protocol version 11.
 	bucketProtocolVersion := uint32(0)
 
 	n := -1
-	var batch []xdr.BucketEntry
+	var entries []xdr.BucketEntry
+	var keys []string
 	lastBatch := false
 
-LoopBucketEntry:
 	for {
 		// Preload entries for faster retrieve from temp store.
-		if len(batch) == 0 {
+		if len(entries) == 0 {
 			if lastBatch {
 				return true
 			}
 
-			preloadKeys := []string{}
-
-			for i := 0; i < preloadedEntries; i++ {
-				var entry xdr.BucketEntry
-				entry, e = r.readBucketEntry(rdr, hash)
-				if e != nil {
-					if e == io.EOF {
-						if len(batch) == 0 {
-							// No entries loaded for this batch, nothing more to process
-							return true
-						}
-						lastBatch = true
-						break
-					}
-					r.readChan <- r.error(
-						errors.Wrapf(e, "Error on XDR record %d of hash '%s'", n, hash.String()),
-					)
-					return false
-				}
-
-				batch = append(batch, entry)
-
-				// Generate a key
-				var key xdr.LedgerKey
-
-				switch entry.Type {
-				case xdr.BucketEntryTypeLiveentry, xdr.BucketEntryTypeInitentry:
-					liveEntry := entry.MustLiveEntry()
-					key = liveEntry.LedgerKey()
-				case xdr.BucketEntryTypeDeadentry:
-					key = entry.MustDeadEntry()
-				default:
-					// No ledger key associated with this entry, continue to the next one.
-					continue
-				}
+			batch := nextBatch()
+			if batch.err != nil {
+				r.readChan <- r.error(batch.err)
+				return false
+			}
+			if len(batch.entries) == 0 {
+				// No entries loaded for this batch, nothing more to process
+				return true
+			}
+			lastBatch = batch.last
+			entries, keys = batch.entries, batch.keys
 
-				// We're using compressed keys here
-				// safe, since we are converting to string right away
-				keyBytes, e := r.encodingBuffer.LedgerKeyUnsafeMarshalBinaryCompress(key)
-				if e != nil {
-					r.readChan <- r.error(
-						errors.Wrapf(e, "Error marshaling XDR record %d of hash '%s'", n, hash.String()),
-					)
-					return false
+			preloadKeys := make([]string, 0, len(keys))
+			for _, key := range keys {
+				if key != "" {
+					preloadKeys = append(preloadKeys, key)
 				}
-
-				h := string(keyBytes)
-				preloadKeys = append(preloadKeys, h)
 			}
-
 			err := r.tempStore.Preload(preloadKeys)
 			if err != nil {
 				r.readChan <- r.error(errors.Wrap(err, "Error preloading keys"))
@@ -391,12 +505,12 @@ LoopBucketEntry:
 		}
 
 		var entry xdr.BucketEntry
-		entry, batch = batch[0], batch[1:]
+		var h string
+		entry, entries = entries[0], entries[1:]
+		h, keys = keys[0], keys[1:]
 
 		n++
 
-		var key xdr.LedgerKey
-
 		switch entry.Type {
 		case xdr.BucketEntryTypeMetaentry:
 			if n != 0 {
@@ -411,34 +525,7 @@ LoopBucketEntry:
 			// We can't use MustMetaEntry() here. Check:
 			// https://github.com/golang/go/issues/32560
 			bucketProtocolVersion = uint32(entry.MetaEntry.LedgerVersion)
-			continue LoopBucketEntry
-		case xdr.BucketEntryTypeLiveentry, xdr.BucketEntryTypeInitentry:
-			liveEntry := entry.MustLiveEntry()
-			key = liveEntry.LedgerKey()
-		case xdr.BucketEntryTypeDeadentry:
-			key = entry.MustDeadEntry()
-		default:
-			r.readChan <- r.error(
-				errors.Errorf("Unknown BucketEntryType=%d: %d@%s", entry.Type, n, hash.String()),
-			)
-			return false
-		}
-
-		// We're using compressed keys here
-		// Safe, since we are converting to string right away
-		keyBytes, e := r.encodingBuffer.LedgerKeyUnsafeMarshalBinaryCompress(key)
-		if e != nil {
-			r.readChan <- r.error(
-				errors.Wrapf(
-					e, "Error marshaling XDR record %d of hash '%s'", n, hash.String(),
-				),
-			)
-			return false
-		}
-
-		h := string(keyBytes)
-
-		switch entry.Type {
+			continue
 		case xdr.BucketEntryTypeLiveentry, xdr.BucketEntryTypeInitentry:
 			if entry.Type == xdr.BucketEntryTypeInitentry && bucketProtocolVersion < 11 {
 				r.readChan <- r.error(
@@ -456,11 +543,13 @@ LoopBucketEntry:
 			if !seen {
 				// Return LEDGER_ENTRY_STATE changes only now.
 				liveEntry := entry.MustLiveEntry()
-				entryChange := xdr.LedgerEntryChange{
-					Type:  xdr.LedgerEntryChangeTypeLedgerEntryState,
-					State: &liveEntry,
+				if r.filter == nil || r.filter.matches(&liveEntry) {
+					entryChange := xdr.LedgerEntryChange{
+						Type:  xdr.LedgerEntryChangeTypeLedgerEntryState,
+						State: &liveEntry,
+					}
+					r.readChan <- readResult{entryChange, nil}
 				}
-				r.readChan <- readResult{entryChange, nil}
 
 				// We don't update `tempStore` for INITENTRY because CAP-20 says:
 				// > a bucket entry marked INITENTRY implies that either no entry
@@ -490,7 +579,7 @@ LoopBucketEntry:
 			}
 		default:
 			r.readChan <- r.error(
-				errors.Errorf("Unexpected entry type %d: %d@%s", entry.Type, n, hash.String()),
+				errors.Errorf("Unknown BucketEntryType=%d: %d@%s", entry.Type, n, hash.String()),
 			)
 			return false
 		}
@@ -503,8 +592,102 @@ LoopBucketEntry:
 			continue
 		}
 	}
+}
 
-	panic("Shouldn't happen")
+// streamBucketsConcurrently streams the buckets like streamBucketContents
+// but up to r.concurrency buckets are downloaded and decoded at the same time
+// by separate goroutines. Entries are still processed from the newest to the
+// oldest bucket so the output is the same as when streaming sequentially.
+func (r *CheckpointChangeReader) streamBucketsConcurrently(buckets []historyarchive.Hash) {
+	stop := make(chan struct{})
+	defer close(stop)
+
+	batches := make([]chan bucketBatch, len(buckets))
+	for i := range batches {
+		batches[i] = make(chan bucketBatch, bucketBatchBufferSize)
+	}
+	go func() {
+		slots := make(chan struct{}, r.concurrency)
+		for i, hash := range buckets {
+			select {
+			case slots <- struct{}{}:
+			case <-stop:
+				return
+			}
+			go func(hash historyarchive.Hash, out chan<- bucketBatch) {
+				defer func() { <-slots }()
+				r.readBucketBatches(hash, out, stop)
+			}(hash, batches[i])
+		}
+	}()
+
+	for i, hash := range buckets {
+		oldestBucket := i == len(buckets)-1
+		in := batches[i]
+		shouldContinue := r.processBucketBatches(hash, oldestBucket, func() bucketBatch {
+			batch, ok := <-in
+			if !ok {
+				return bucketBatch{last: true}
+			}
+			return batch
+		})
+		if !shouldContinue {
+			break
+		}
+	}
+}
+
+// readBucketBatches reads batches of bucket entries and sends them to out
+// until the end of the bucket, an error or until stop is closed. Errors,
+// including errors closing the stream, are sent in the last batch.
+func (r *CheckpointChangeReader) readBucketBatches(
+	hash historyarchive.Hash,
+	out chan<- bucketBatch,
+	stop <-chan struct{},
+) {
+	defer close(out)
+	send := func(batch bucketBatch) bool {
+		select {
+		case out <- batch:
+			return true
+		case <-stop:
+			return false
+		}
+	}
+
+	rdr, e := r.newXDRStream(hash)
+	if e != nil {
+		send(bucketBatch{
+			err: errors.Wrapf(e, "cannot get xdr stream for hash '%s'", hash.String()),
+		})
+		return
+	}
+
+	encodingBuffer := xdr.NewEncodingBuffer()
+	n := -1
+	for {
+		batch := r.readBucketBatch(rdr, hash, encodingBuffer, n)
+		n += len(batch.entries)
+		if batch.err != nil {
+			rdr.Close()
+			send(batch)
+			return
+		}
+		if batch.last {
+			// The stream is closed before sending the last batch because
+			// processBucketBatches doesn't read batches after it, an error
+			// closing the stream (ex. hash mismatch) replaces the last batch.
+			if err := rdr.Close(); err != nil {
+				batch = bucketBatch{err: errors.Wrap(err, "Error closing xdr stream")}
+			}
+			send(batch)
+			return
+		}
+		if !send(batch) {
+			rdr.Close()
+			return
+		}
+	}
 }
 
 // Read returns a new ledger entry change on each call, returning io.EOF when the stream ends.
@@ -547,5 +730,12 @@ func (r *CheckpointChangeReader) Progress() float64 {
 // Close should be called when reading is finished.
 func (r *CheckpointChangeReader) Close() error {
 	r.closeOnce.Do(r.close)
-	return nil
+	// If streaming hasn't started the temp store is closed here (ex. to
+	// remove files of a disk temp store) and Read returns io.EOF.
+	var err error
+	r.streamOnce.Do(func() {
+		err = r.tempStore.Close()
+		close(r.readChan)
+	})
+	return err
 }
@@ -263,6 +263,211 @@ func (s *SingleLedgerStateReaderTestSuite) TestMalformedProtocol11BucketNoMeta()
 	s.Assert().Equal("Error while reading from buckets: Read INITENTRY from version <11 bucket: 0@517bea4c6627a688a8ce501febd8c562e737e3d86b29689d9956217640f3c74b", err.Error())
 }
 
+// TestConcurrentBuckets tests if entries are shadowed and returned in order
+// when buckets are streamed concurrently.
+func (s *SingleLedgerStateReaderTestSuite) TestConcurrentBuckets() {
+	s.reader.concurrency = 4
+
+	curr1 := createXdrStream(
+		metaEntry(11),
+		entryAccount(xdr.BucketEntryTypeDeadentry, "GC3C4AKRBQLHOJ45U4XG35ESVWRDECWO5XLDGYADO6DPR3L7KIDVUMML", 1),
+		entryAccount(xdr.BucketEntryTypeLiveentry, "GCMNSW2UZMSH3ZFRLWP6TW2TG4UX4HLSYO5HNIKUSFMLN2KFSF26JKWF", 2),
+	)
+
+	snap1 := createXdrStream(
+		metaEntry(11),
+		entryAccount(xdr.BucketEntryTypeLiveentry, "GC3C4AKRBQLHOJ45U4XG35ESVWRDECWO5XLDGYADO6DPR3L7KIDVUMML", 1),
+		entryAccount(xdr.BucketEntryTypeLiveentry, "GCMNSW2UZMSH3ZFRLWP6TW2TG4UX4HLSYO5HNIKUSFMLN2KFSF26JKWF", 1),
+		entryAccount(xdr.BucketEntryTypeInitentry, "GB6IPC7LIOSRY26MXHQ3QJ32MTELYAA6YFIRBXZVVGTU7AOI4KUFOQ54", 1),
+	)
+
+	nextBucket := s.getNextBucketChannel()
+
+	// Return curr1 and snap1 stream for the first two bucket...
+	s.mockArchive.
+		On("GetXdrStreamForHash", <-nextBucket).
+		Return(curr1, nil).Once()
+
+	s.mockArchive.
+		On("GetXdrStreamForHash", <-nextBucket).
+		Return(snap1, nil).Once()
+
+	// ...and streams with a single entry for the rest of the buckets.
+	for hash := range nextBucket {
+		s.mockArchive.
+			On("GetXdrStreamForHash", hash).
+			Return(createXdrStream(
+				entryAccount(xdr.BucketEntryTypeLiveentry, "GC3C4AKRBQLHOJ45U4XG35ESVWRDECWO5XLDGYADO6DPR3L7KIDVUMML", 3),
+			), nil).Once()
+	}
+
+	expected := []struct {
+		address string
+		balance xdr.Int64
+	}{
+		{"GCMNSW2UZMSH3ZFRLWP6TW2TG4UX4HLSYO5HNIKUSFMLN2KFSF26JKWF", 2},
+		{"GB6IPC7LIOSRY26MXHQ3QJ32MTELYAA6YFIRBXZVVGTU7AOI4KUFOQ54", 1},
+	}
+	for _, e := range expected {
+		change, err := s.reader.Read()
+		s.Require().NoError(err)
+		s.Assert().Equal(e.address, change.Post.Data.Account.AccountId.Address())
+		s.Assert().Equal(e.balance, change.Post.Data.Account.Balance)
+	}
+
+	_, err := s.reader.Read()
+	s.Require().Equal(err, io.EOF)
+}
+
+// TestDiskTempSet tests reading buckets with keys stored on disk.
+func (s *SingleLedgerStateReaderTestSuite) TestDiskTempSet() {
+	s.Require().NoError(s.reader.tempStore.Close())
+	s.reader.tempStore = newDiskTempSet(s.T().TempDir(), 1)
+	s.Require().NoError(s.reader.tempStore.Open())
+
+	curr1 := createXdrStream(
+		entryAccount(xdr.BucketEntryTypeDeadentry, "GC3C4AKRBQLHOJ45U4XG35ESVWRDECWO5XLDGYADO6DPR3L7KIDVUMML", 1),
+		entryAccount(xdr.BucketEntryTypeLiveentry, "GCMNSW2UZMSH3ZFRLWP6TW2TG4UX4HLSYO5HNIKUSFMLN2KFSF26JKWF", 2),
+	)
+
+	snap1 := createXdrStream(
+		entryAccount(xdr.BucketEntryTypeLiveentry, "GC3C4AKRBQLHOJ45U4XG35ESVWRDECWO5XLDGYADO6DPR3L7KIDVUMML", 1),
+		entryAccount(xdr.BucketEntryTypeLiveentry, "GCMNSW2UZMSH3ZFRLWP6TW2TG4UX4HLSYO5HNIKUSFMLN2KFSF26JKWF", 1),
+		entryAccount(xdr.BucketEntryTypeLiveentry, "GB6IPC7LIOSRY26MXHQ3QJ32MTELYAA6YFIRBXZVVGTU7AOI4KUFOQ54", 1),
+	)
+
+	nextBucket := s.getNextBucketChannel()
+
+	s.mockArchive.
+		On("GetXdrStreamForHash", <-nextBucket).
+		Return(curr1, nil).Once()
+
+	s.mockArchive.
+		On("GetXdrStreamForHash", <-nextBucket).
+		Return(snap1, nil).Once()
+
+	for hash := range nextBucket {
+		s.mockArchive.
+			On("GetXdrStreamForHash", hash).
+			Return(createXdrStream(), nil).Once()
+	}
+
+	change, err := s.reader.Read()
+	s.Require().NoError(err)
+	s.Assert().Equal("GCMNSW2UZMSH3ZFRLWP6TW2TG4UX4HLSYO5HNIKUSFMLN2KFSF26JKWF", change.Post.Data.Account.AccountId.Address())
+	s.Assert().Equal(xdr.Int64(2), change.Post.Data.Account.Balance)
+
+	change, err = s.reader.Read()
+	s.Require().NoError(err)
+	s.Assert().Equal("GB6IPC7LIOSRY26MXHQ3QJ32MTELYAA6YFIRBXZVVGTU7AOI4KUFOQ54", change.Post.Data.Account.AccountId.Address())
+
+	_, err = s.reader.Read()
+	s.Require().Equal(err, io.EOF)
+}
+
+// TestAccountsFilter tests if entries of other accounts are skipped and
+// removed entries of filtered accounts are not returned.
+func (s *SingleLedgerStateReaderTestSuite) TestAccountsFilter() {
+	var err error
+	s.reader.filter, err = newCheckpointFilter(CheckpointChangeReaderOptions{
+		Accounts: []string{
+			"GC3C4AKRBQLHOJ45U4XG35ESVWRDECWO5XLDGYADO6DPR3L7KIDVUMML",
+			"GB6IPC7LIOSRY26MXHQ3QJ32MTELYAA6YFIRBXZVVGTU7AOI4KUFOQ54",
+		},
+	})
+	s.Require().NoError(err)
+
+	curr1 := createXdrStream(
+		metaEntry(11),
+		entryAccount(xdr.BucketEntryTypeDeadentry, "GC3C4AKRBQLHOJ45U4XG35ESVWRDECWO5XLDGYADO6DPR3L7KIDVUMML", 1),
+		entryAccount(xdr.BucketEntryTypeLiveentry, "GCMNSW2UZMSH3ZFRLWP6TW2TG4UX4HLSYO5HNIKUSFMLN2KFSF26JKWF", 2),
+	)
+
+	snap1 := createXdrStream(
+		metaEntry(11),
+		entryAccount(xdr.BucketEntryTypeLiveentry, "GC3C4AKRBQLHOJ45U4XG35ESVWRDECWO5XLDGYADO6DPR3L7KIDVUMML", 1),
+		entryAccount(xdr.BucketEntryTypeLiveentry, "GCMNSW2UZMSH3ZFRLWP6TW2TG4UX4HLSYO5HNIKUSFMLN2KFSF26JKWF", 1),
+		entryAccount(xdr.BucketEntryTypeLiveentry, "GB6IPC7LIOSRY26MXHQ3QJ32MTELYAA6YFIRBXZVVGTU7AOI4KUFOQ54", 1),
+	)
+
+	nextBucket := s.getNextBucketChannel()
+
+	s.mockArchive.
+		On("GetXdrStreamForHash", <-nextBucket).
+		Return(curr1, nil).Once()
+
+	s.mockArchive.
+		On("GetXdrStreamForHash", <-nextBucket).
+		Return(snap1, nil).Once()
+
+	for hash := range nextBucket {
+		s.mockArchive.
+			On("GetXdrStreamForHash", hash).
+			Return(createXdrStream(), nil).Once()
+	}
+
+	change, err := s.reader.Read()
+	s.Require().NoError(err)
+	s.Assert().Equal("GB6IPC7LIOSRY26MXHQ3QJ32MTELYAA6YFIRBXZVVGTU7AOI4KUFOQ54", change.Post.Data.Account.AccountId.Address())
+
+	_, err = s.reader.Read()
+	s.Require().Equal(err, io.EOF)
+}
+
+// TestConcurrentBucketsError tests if errors of buckets streamed concurrently
+// are returned.
+func (s *SingleLedgerStateReaderTestSuite) TestConcurrentBucketsError() {
+	s.reader.concurrency = 4
+
+	nextBucket := s.getNextBucketChannel()
+	first := <-nextBucket
+	s.mockArchive.
+		On("GetXdrStreamForHash", first).
+		Return(createXdrStream(), errors.New("broken")).Once()
+
+	// The rest of the buckets may be requested before the error is read.
+	for hash := range nextBucket {
+		s.mockArchive.
+			On("GetXdrStreamForHash", hash).
+			Return(createXdrStream(), nil).Maybe()
+	}
+
+	_, err := s.reader.Read()
+	s.Require().EqualError(err, "Error while reading from buckets: cannot get xdr stream for hash '"+first.String()+"': broken")
+
+	_, err = s.reader.Read()
+	s.Require().Equal(err, io.EOF)
+}
+
+// TestConcurrentBucketsHashMismatch tests if hash validation errors of
+// buckets streamed concurrently are returned.
+func (s *SingleLedgerStateReaderTestSuite) TestConcurrentBucketsHashMismatch() {
+	s.reader.concurrency = 2
+	s.reader.disableBucketListHashValidation = false
+
+	nextBucket := s.getNextBucketChannel()
+	first := <-nextBucket
+	s.mockArchive.
+		On("GetXdrStreamForHash", first).
+		Return(createXdrStream(
+			metaEntry(11),
+			entryAccount(xdr.BucketEntryTypeLiveentry, "GC3C4AKRBQLHOJ45U4XG35ESVWRDECWO5XLDGYADO6DPR3L7KIDVUMML", 1),
+		), nil).Once()
+
+	// The rest of the buckets may be requested before the error is read.
+	for hash := range nextBucket {
+		s.mockArchive.
+			On("GetXdrStreamForHash", hash).
+			Return(createXdrStream(), nil).Maybe()
+	}
+
+	var err error
+	for err == nil {
+		_, err = s.reader.Read()
+	}
+	s.Require().EqualError(err, "Error while reading from buckets: Error closing xdr stream: Stream hash does not match expected hash!")
+}
+
 func TestBucketExistsTestSuite(t *testing.T) {
 	suite.Run(t, new(BucketExistsTestSuite))
 }
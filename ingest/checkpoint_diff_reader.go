@@ -0,0 +1,316 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sort"
+
+	"github.com/stellar/go/historyarchive"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/xdr"
+)
+
+// CheckpointDiffReader is a ChangeReader which returns changes between the
+// ledger state of two checkpoint ledgers: created entries (`Pre` is nil),
+// updated entries and removed entries (`Post` is nil). Entries which didn't
+// change are not returned.
+//
+// Instead of replaying ledgers between the checkpoints it compares bucket
+// lists of both checkpoints. Buckets present in both bucket lists are not
+// compared (they can't contain changes) and are read only if they contain the
+// previous or current value of an entry changed in other buckets. Entries of
+// buckets present in only one of the bucket lists are kept in memory so
+// CheckpointDiffReader is intended for checkpoints close to each other (the
+// closer the checkpoints the fewer buckets differ).
+//
+// Changes are returned ordered by the ledger key.
+type CheckpointDiffReader struct {
+	ctx          context.Context
+	archive      historyarchive.ArchiveInterface
+	fromSequence uint32
+	toSequence   uint32
+	fromBuckets  []historyarchive.Hash
+	toBuckets    []historyarchive.Hash
+
+	disableBucketListHashValidation bool
+
+	computed bool
+	changes  []Change
+}
+
+// Ensure CheckpointDiffReader implements ChangeReader
+var _ ChangeReader = &CheckpointDiffReader{}
+
+// NewCheckpointDiffReader constructs a new CheckpointDiffReader instance
+// returning changes between fromSequence and toSequence checkpoint ledgers.
+// fromSequence must be lower than toSequence.
+func NewCheckpointDiffReader(
+	ctx context.Context,
+	archive historyarchive.ArchiveInterface,
+	fromSequence, toSequence uint32,
+) (*CheckpointDiffReader, error) {
+	if fromSequence >= toSequence {
+		return nil, errors.Errorf(
+			"from ledger (%d) must be lower than to ledger (%d)", fromSequence, toSequence,
+		)
+	}
+
+	manager := archive.GetCheckpointManager()
+	r := &CheckpointDiffReader{
+		ctx:          ctx,
+		archive:      archive,
+		fromSequence: fromSequence,
+		toSequence:   toSequence,
+	}
+	for _, target := range []struct {
+		sequence uint32
+		buckets  *[]historyarchive.Hash
+	}{
+		{fromSequence, &r.fromBuckets},
+		{toSequence, &r.toBuckets},
+	} {
+		if !manager.IsCheckpoint(target.sequence) {
+			return nil, errors.Errorf("%d is not a checkpoint ledger", target.sequence)
+		}
+		has, err := archive.GetCheckpointHAS(target.sequence)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to get checkpoint HAS at ledger sequence %d", target.sequence)
+		}
+		*target.buckets, err = bucketListHashes(has)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// bucketListHashes returns non-empty buckets of the HAS ordered from the
+// newest to the oldest.
+func bucketListHashes(has historyarchive.HistoryArchiveState) ([]historyarchive.Hash, error) {
+	var buckets []historyarchive.Hash
+	for _, level := range has.CurrentBuckets {
+		for _, hashString := range []string{level.Curr, level.Snap} {
+			hash, err := historyarchive.DecodeHash(hashString)
+			if err != nil {
+				return nil, errors.Wrap(err, "Error decoding bucket hash")
+			}
+			if !hash.IsZero() {
+				buckets = append(buckets, hash)
+			}
+		}
+	}
+	return buckets, nil
+}
+
+// Read returns the next change between the checkpoints. The first call
+// downloads and compares buckets so it can take a long time. It returns
+// io.EOF when there are no more changes.
+func (r *CheckpointDiffReader) Read() (Change, error) {
+	if !r.computed {
+		changes, err := r.diff()
+		if err != nil {
+			return Change{}, err
+		}
+		r.changes = changes
+		r.computed = true
+	}
+
+	if len(r.changes) == 0 {
+		return Change{}, io.EOF
+	}
+	change := r.changes[0]
+	r.changes = r.changes[1:]
+	return change, nil
+}
+
+// Close releases changes which were not read.
+func (r *CheckpointDiffReader) Close() error {
+	r.changes = nil
+	r.computed = true
+	return nil
+}
+
+// diffBucket contains entries of a bucket indexed by the ledger key. DEADENTRY
+// values are nil.
+type diffBucket map[string]*xdr.LedgerEntry
+
+func (r *CheckpointDiffReader) diff() ([]Change, error) {
+	inFrom := map[historyarchive.Hash]bool{}
+	for _, hash := range r.fromBuckets {
+		inFrom[hash] = true
+	}
+	inTo := map[historyarchive.Hash]bool{}
+	for _, hash := range r.toBuckets {
+		inTo[hash] = true
+	}
+
+	// Read buckets present in only one of the bucket lists. Keys of all
+	// their entries are the keys which could have changed.
+	buckets := map[historyarchive.Hash]diffBucket{}
+	candidates := map[string]bool{}
+	for _, list := range [][]historyarchive.Hash{r.fromBuckets, r.toBuckets} {
+		for _, hash := range list {
+			if (inFrom[hash] && inTo[hash]) || buckets[hash] != nil {
+				continue
+			}
+			bucket, err := r.readBucket(hash, nil)
+			if err != nil {
+				return nil, err
+			}
+			buckets[hash] = bucket
+			for key := range bucket {
+				candidates[key] = true
+			}
+		}
+	}
+
+	fromEntries, err := r.resolve(r.fromBuckets, buckets, candidates)
+	if err != nil {
+		return nil, err
+	}
+	toEntries, err := r.resolve(r.toBuckets, buckets, candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(candidates))
+	for key := range candidates {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var changes []Change
+	for _, key := range keys {
+		pre, post := fromEntries[key], toEntries[key]
+		if pre == nil && post == nil {
+			continue
+		}
+		if pre != nil && post != nil {
+			equal, err := ledgerEntriesEqual(pre, post)
+			if err != nil {
+				return nil, err
+			}
+			if equal {
+				continue
+			}
+		}
+
+		var entryType xdr.LedgerEntryType
+		if pre != nil {
+			entryType = pre.Data.Type
+		} else {
+			entryType = post.Data.Type
+		}
+		changes = append(changes, Change{
+			Type: entryType,
+			Pre:  pre,
+			Post: post,
+		})
+	}
+	return changes, nil
+}
+
+// resolve returns the values of candidate keys in the bucket list (nil if an
+// entry doesn't exist). Buckets present in both bucket lists are read (and
+// added to buckets) only if they can contain unresolved keys.
+func (r *CheckpointDiffReader) resolve(
+	list []historyarchive.Hash,
+	buckets map[historyarchive.Hash]diffBucket,
+	candidates map[string]bool,
+) (map[string]*xdr.LedgerEntry, error) {
+	resolved := map[string]bool{}
+	values := map[string]*xdr.LedgerEntry{}
+	for _, hash := range list {
+		if len(resolved) == len(candidates) {
+			break
+		}
+		bucket, ok := buckets[hash]
+		if !ok {
+			var err error
+			if bucket, err = r.readBucket(hash, candidates); err != nil {
+				return nil, err
+			}
+			buckets[hash] = bucket
+		}
+		for key, entry := range bucket {
+			if !resolved[key] {
+				resolved[key] = true
+				values[key] = entry
+			}
+		}
+	}
+	return values, nil
+}
+
+// readBucket reads entries of the bucket. If keys is not nil only entries
+// with the given keys are returned.
+func (r *CheckpointDiffReader) readBucket(hash historyarchive.Hash, keys map[string]bool) (diffBucket, error) {
+	stream, err := r.archive.GetXdrStreamForHash(hash)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot get xdr stream for hash '%s'", hash.String())
+	}
+	if !r.disableBucketListHashValidation {
+		stream.SetExpectedHash(hash)
+	}
+
+	bucket := diffBucket{}
+	encodingBuffer := xdr.NewEncodingBuffer()
+	for n := 0; ; n++ {
+		if err = r.ctx.Err(); err != nil {
+			stream.Close()
+			return nil, err
+		}
+
+		var entry xdr.BucketEntry
+		if err = stream.ReadOne(&entry); err == io.EOF {
+			break
+		} else if err != nil {
+			stream.Close()
+			return nil, errors.Wrapf(err, "Error on XDR record %d of hash '%s'", n, hash.String())
+		}
+
+		var key xdr.LedgerKey
+		var value *xdr.LedgerEntry
+		switch entry.Type {
+		case xdr.BucketEntryTypeLiveentry, xdr.BucketEntryTypeInitentry:
+			liveEntry := entry.MustLiveEntry()
+			key = liveEntry.LedgerKey()
+			value = &liveEntry
+		case xdr.BucketEntryTypeDeadentry:
+			key = entry.MustDeadEntry()
+		default:
+			// No ledger key associated with this entry
+			continue
+		}
+
+		var keyBytes []byte
+		keyBytes, err = encodingBuffer.LedgerKeyUnsafeMarshalBinaryCompress(key)
+		if err != nil {
+			stream.Close()
+			return nil, errors.Wrapf(err, "Error marshaling XDR record %d of hash '%s'", n, hash.String())
+		}
+		if keys != nil && !keys[string(keyBytes)] {
+			continue
+		}
+		bucket[string(keyBytes)] = value
+	}
+
+	if err = stream.Close(); err != nil {
+		return nil, errors.Wrapf(err, "Error closing bucket '%s'", hash.String())
+	}
+	return bucket, nil
+}
+
+func ledgerEntriesEqual(a, b *xdr.LedgerEntry) (bool, error) {
+	aBytes, err := a.MarshalBinary()
+	if err != nil {
+		return false, err
+	}
+	bBytes, err := b.MarshalBinary()
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(aBytes, bBytes), nil
+}
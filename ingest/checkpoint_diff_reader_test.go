@@ -0,0 +1,116 @@
+package ingest
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stellar/go/historyarchive"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/xdr"
+)
+
+// checkpointBalances returns balances of all accounts at the checkpoint.
+func checkpointBalances(t *testing.T, archive historyarchive.ArchiveInterface, checkpoint uint32) map[string]xdr.Int64 {
+	reader, err := NewCheckpointChangeReader(context.Background(), archive, checkpoint)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	balances := map[string]xdr.Int64{}
+	for {
+		change, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		account := change.Post.Data.MustAccount()
+		balances[account.AccountId.Address()] = account.Balance
+	}
+	return balances
+}
+
+func TestCheckpointDiffReader(t *testing.T) {
+	archive, err := historyarchive.Connect("mock://test", historyarchive.ConnectOptions{})
+	require.NoError(t, err)
+	writer, err := historyarchive.NewArchiveWriter(archive, historyarchive.NewBucketList(), 63, historyarchive.ArchiveWriterOptions{
+		NetworkPassphrase:       network.TestNetworkPassphrase,
+		SkipBucketListHashCheck: true,
+	})
+	require.NoError(t, err)
+
+	// Every ledger creates an account, every third ledger updates one of the
+	// existing accounts and every fifth ledger removes one.
+	var accounts []xdr.LedgerEntry
+	var previousHash xdr.Hash
+	for seq := uint32(64); seq <= 319; seq++ {
+		created := archiveLedgerAccount(keypair.MustRandom().Address(), int64(seq))
+		var updated, removed *xdr.LedgerEntry
+		if seq%3 == 0 && len(accounts) > 1 {
+			i := int(seq) % (len(accounts) - 1)
+			entry := archiveLedgerAccount(accounts[i].Data.Account.AccountId.Address(), int64(seq)*10)
+			accounts[i] = entry
+			updated = &entry
+		}
+		if seq%5 == 0 && len(accounts) > 1 {
+			entry := accounts[len(accounts)-1]
+			removed = &entry
+			accounts = accounts[:len(accounts)-1]
+		}
+		accounts = append(accounts, created)
+
+		meta := archiveLedgerCloseMeta(seq, previousHash, &created, updated, removed)
+		previousHash = meta.LedgerHash()
+		ledger, err := NewArchiveLedgerFromLedgerCloseMeta(network.TestNetworkPassphrase, meta)
+		require.NoError(t, err)
+		require.NoError(t, writer.AddLedger(ledger))
+	}
+
+	for _, checkpoints := range [][2]uint32{{127, 191}, {127, 319}, {255, 319}} {
+		from, to := checkpoints[0], checkpoints[1]
+		fromBalances := checkpointBalances(t, archive, from)
+		toBalances := checkpointBalances(t, archive, to)
+
+		reader, err := NewCheckpointDiffReader(context.Background(), archive, from, to)
+		require.NoError(t, err)
+
+		// Applying the changes to the state of the first checkpoint must
+		// result in the state of the second one.
+		changes := 0
+		for {
+			change, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			changes++
+
+			switch {
+			case change.Pre == nil:
+				account := change.Post.Data.MustAccount()
+				assert.NotContains(t, fromBalances, account.AccountId.Address())
+				fromBalances[account.AccountId.Address()] = account.Balance
+			case change.Post == nil:
+				account := change.Pre.Data.MustAccount()
+				assert.Equal(t, fromBalances[account.AccountId.Address()], account.Balance)
+				delete(fromBalances, account.AccountId.Address())
+			default:
+				pre, post := change.Pre.Data.MustAccount(), change.Post.Data.MustAccount()
+				assert.Equal(t, fromBalances[pre.AccountId.Address()], pre.Balance)
+				assert.NotEqual(t, pre.Balance, post.Balance)
+				fromBalances[post.AccountId.Address()] = post.Balance
+			}
+		}
+		require.NoError(t, reader.Close())
+		assert.Equal(t, toBalances, fromBalances)
+		assert.Greater(t, changes, 0)
+	}
+
+	_, err = NewCheckpointDiffReader(context.Background(), archive, 191, 127)
+	assert.EqualError(t, err, "from ledger (191) must be lower than to ledger (127)")
+	_, err = NewCheckpointDiffReader(context.Background(), archive, 127, 128)
+	assert.EqualError(t, err, "128 is not a checkpoint ledger")
+}
@@ -0,0 +1,140 @@
+package ingest
+
+import (
+	"encoding/binary"
+
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/xdr"
+)
+
+// accountIDSize is the size of an encoded xdr.AccountId (ed25519 public key).
+const accountIDSize = 36
+
+// checkpointFilter restricts changes returned by CheckpointChangeReader, see
+// CheckpointChangeReaderOptions.
+type checkpointFilter struct {
+	// entryTypes, accounts (encoded account IDs) and assets are nil when
+	// entries are not filtered by them.
+	entryTypes map[xdr.LedgerEntryType]bool
+	accounts   map[string]bool
+	assets     []xdr.Asset
+}
+
+// newCheckpointFilter returns nil if the options don't filter entries.
+func newCheckpointFilter(opts CheckpointChangeReaderOptions) (*checkpointFilter, error) {
+	if len(opts.EntryTypes) == 0 && len(opts.Accounts) == 0 && len(opts.Assets) == 0 {
+		return nil, nil
+	}
+
+	f := &checkpointFilter{}
+	if len(opts.EntryTypes) > 0 {
+		f.entryTypes = map[xdr.LedgerEntryType]bool{}
+		for _, entryType := range opts.EntryTypes {
+			f.entryTypes[entryType] = true
+		}
+	}
+	if len(opts.Accounts) > 0 {
+		f.accounts = map[string]bool{}
+		for _, address := range opts.Accounts {
+			var accountID xdr.AccountId
+			if err := accountID.SetAddress(address); err != nil {
+				return nil, errors.Wrapf(err, "invalid account %s", address)
+			}
+			encoded, err := accountID.MarshalBinary()
+			if err != nil {
+				return nil, err
+			}
+			f.accounts[string(encoded)] = true
+		}
+	}
+	if len(opts.Assets) > 0 {
+		f.assets = opts.Assets
+	}
+	return f, nil
+}
+
+// keepRaw checks the ledger entry type and account of an encoded bucket
+// entry so that other entries are skipped without decoding them. Entry types
+// and accounts are at fixed offsets: the LedgerEntryData (or LedgerKey) type
+// follows the BucketEntry type (and lastModifiedLedgerSeq) and the account ID
+// is the first field of accounts, trustlines, offers and data entries and
+// their keys. Entries which can't be checked (ex. METAENTRY) are kept.
+func (f *checkpointFilter) keepRaw(raw []byte) bool {
+	if len(raw) < 4 {
+		return true
+	}
+
+	var typeOffset int
+	switch xdr.BucketEntryType(int32(binary.BigEndian.Uint32(raw))) {
+	case xdr.BucketEntryTypeLiveentry, xdr.BucketEntryTypeInitentry:
+		typeOffset = 8
+	case xdr.BucketEntryTypeDeadentry:
+		typeOffset = 4
+	default:
+		return true
+	}
+	if len(raw) < typeOffset+4 {
+		return true
+	}
+
+	entryType := xdr.LedgerEntryType(int32(binary.BigEndian.Uint32(raw[typeOffset:])))
+	if f.entryTypes != nil && !f.entryTypes[entryType] {
+		return false
+	}
+	if f.accounts == nil {
+		return true
+	}
+	switch entryType {
+	case xdr.LedgerEntryTypeAccount, xdr.LedgerEntryTypeTrustline,
+		xdr.LedgerEntryTypeOffer, xdr.LedgerEntryTypeData:
+		start := typeOffset + 4
+		if len(raw) < start+accountIDSize {
+			return true
+		}
+		return f.accounts[string(raw[start:start+accountIDSize])]
+	default:
+		return false
+	}
+}
+
+// matches checks the assets of a decoded ledger entry. Other filters are
+// applied by keepRaw.
+func (f *checkpointFilter) matches(entry *xdr.LedgerEntry) bool {
+	if f.assets == nil {
+		return true
+	}
+
+	switch entry.Data.Type {
+	case xdr.LedgerEntryTypeAccount:
+		return f.hasAsset(xdr.MustNewNativeAsset())
+	case xdr.LedgerEntryTypeTrustline:
+		asset := entry.Data.MustTrustLine().Asset
+		if asset.Type == xdr.AssetTypeAssetTypePoolShare {
+			return false
+		}
+		return f.hasAsset(asset.ToAsset())
+	case xdr.LedgerEntryTypeOffer:
+		offer := entry.Data.MustOffer()
+		return f.hasAsset(offer.Selling) || f.hasAsset(offer.Buying)
+	case xdr.LedgerEntryTypeClaimableBalance:
+		return f.hasAsset(entry.Data.MustClaimableBalance().Asset)
+	case xdr.LedgerEntryTypeLiquidityPool:
+		body := entry.Data.MustLiquidityPool().Body
+		if body.Type != xdr.LiquidityPoolTypeLiquidityPoolConstantProduct {
+			return false
+		}
+		params := body.MustConstantProduct().Params
+		return f.hasAsset(params.AssetA) || f.hasAsset(params.AssetB)
+	default:
+		return false
+	}
+}
+
+func (f *checkpointFilter) hasAsset(asset xdr.Asset) bool {
+	for _, a := range f.assets {
+		if a.Equals(asset) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,199 @@
+package ingest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stellar/go/xdr"
+)
+
+func TestCheckpointFilterNoOptions(t *testing.T) {
+	filter, err := newCheckpointFilter(CheckpointChangeReaderOptions{Concurrency: 2})
+	require.NoError(t, err)
+	assert.Nil(t, filter)
+
+	_, err = newCheckpointFilter(CheckpointChangeReaderOptions{Accounts: []string{"GABC"}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid account GABC")
+}
+
+func TestCheckpointFilterKeepRaw(t *testing.T) {
+	const (
+		account      = "GC3C4AKRBQLHOJ45U4XG35ESVWRDECWO5XLDGYADO6DPR3L7KIDVUMML"
+		otherAccount = "GB6IPC7LIOSRY26MXHQ3QJ32MTELYAA6YFIRBXZVVGTU7AOI4KUFOQ54"
+	)
+	trustLine := xdr.BucketEntry{
+		Type: xdr.BucketEntryTypeInitentry,
+		LiveEntry: &xdr.LedgerEntry{
+			LastModifiedLedgerSeq: 10,
+			Data: xdr.LedgerEntryData{
+				Type: xdr.LedgerEntryTypeTrustline,
+				TrustLine: &xdr.TrustLineEntry{
+					AccountId: xdr.MustAddress(account),
+					Asset:     xdr.MustNewCreditAsset("USD", otherAccount).ToTrustLineAsset(),
+				},
+			},
+		},
+	}
+	claimableBalance := xdr.BucketEntry{
+		Type: xdr.BucketEntryTypeLiveentry,
+		LiveEntry: &xdr.LedgerEntry{
+			Data: xdr.LedgerEntryData{
+				Type: xdr.LedgerEntryTypeClaimableBalance,
+				ClaimableBalance: &xdr.ClaimableBalanceEntry{
+					BalanceId: xdr.ClaimableBalanceId{
+						Type: xdr.ClaimableBalanceIdTypeClaimableBalanceIdTypeV0,
+						V0:   &xdr.Hash{1},
+					},
+					Asset: xdr.MustNewNativeAsset(),
+				},
+			},
+		},
+	}
+
+	for _, testCase := range []struct {
+		name     string
+		opts     CheckpointChangeReaderOptions
+		entry    xdr.BucketEntry
+		expected bool
+	}{
+		{
+			"meta entry",
+			CheckpointChangeReaderOptions{EntryTypes: []xdr.LedgerEntryType{xdr.LedgerEntryTypeOffer}},
+			metaEntry(18),
+			true,
+		},
+		{
+			"entry type",
+			CheckpointChangeReaderOptions{EntryTypes: []xdr.LedgerEntryType{xdr.LedgerEntryTypeAccount}},
+			entryAccount(xdr.BucketEntryTypeLiveentry, account, 1),
+			true,
+		},
+		{
+			"other entry type",
+			CheckpointChangeReaderOptions{EntryTypes: []xdr.LedgerEntryType{xdr.LedgerEntryTypeAccount}},
+			trustLine,
+			false,
+		},
+		{
+			"dead entry type",
+			CheckpointChangeReaderOptions{EntryTypes: []xdr.LedgerEntryType{xdr.LedgerEntryTypeAccount}},
+			entryAccount(xdr.BucketEntryTypeDeadentry, account, 1),
+			true,
+		},
+		{
+			"account",
+			CheckpointChangeReaderOptions{Accounts: []string{account}},
+			trustLine,
+			true,
+		},
+		{
+			"other account",
+			CheckpointChangeReaderOptions{Accounts: []string{otherAccount}},
+			trustLine,
+			false,
+		},
+		{
+			"dead entry account",
+			CheckpointChangeReaderOptions{Accounts: []string{account}},
+			entryAccount(xdr.BucketEntryTypeDeadentry, account, 1),
+			true,
+		},
+		{
+			"dead entry other account",
+			CheckpointChangeReaderOptions{Accounts: []string{otherAccount}},
+			entryAccount(xdr.BucketEntryTypeDeadentry, account, 1),
+			false,
+		},
+		{
+			"entry without account",
+			CheckpointChangeReaderOptions{Accounts: []string{account}},
+			claimableBalance,
+			false,
+		},
+		{
+			"assets only",
+			CheckpointChangeReaderOptions{Assets: []xdr.Asset{xdr.MustNewCreditAsset("EUR", otherAccount)}},
+			trustLine,
+			true,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			filter, err := newCheckpointFilter(testCase.opts)
+			require.NoError(t, err)
+			raw, err := testCase.entry.MarshalBinary()
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expected, filter.keepRaw(raw))
+		})
+	}
+}
+
+func TestCheckpointFilterMatches(t *testing.T) {
+	issuer := "GB6IPC7LIOSRY26MXHQ3QJ32MTELYAA6YFIRBXZVVGTU7AOI4KUFOQ54"
+	usd := xdr.MustNewCreditAsset("USD", issuer)
+	eur := xdr.MustNewCreditAsset("EUR", issuer)
+	filter, err := newCheckpointFilter(CheckpointChangeReaderOptions{
+		Assets: []xdr.Asset{usd},
+	})
+	require.NoError(t, err)
+
+	account := entryAccount(xdr.BucketEntryTypeLiveentry, issuer, 1)
+	assert.False(t, filter.matches(account.LiveEntry))
+
+	trustLine := func(asset xdr.Asset) *xdr.LedgerEntry {
+		return &xdr.LedgerEntry{
+			Data: xdr.LedgerEntryData{
+				Type: xdr.LedgerEntryTypeTrustline,
+				TrustLine: &xdr.TrustLineEntry{
+					AccountId: xdr.MustAddress(issuer),
+					Asset:     asset.ToTrustLineAsset(),
+				},
+			},
+		}
+	}
+	assert.True(t, filter.matches(trustLine(usd)))
+	assert.False(t, filter.matches(trustLine(eur)))
+
+	offer := func(selling, buying xdr.Asset) *xdr.LedgerEntry {
+		return &xdr.LedgerEntry{
+			Data: xdr.LedgerEntryData{
+				Type: xdr.LedgerEntryTypeOffer,
+				Offer: &xdr.OfferEntry{
+					SellerId: xdr.MustAddress(issuer),
+					Selling:  selling,
+					Buying:   buying,
+				},
+			},
+		}
+	}
+	assert.True(t, filter.matches(offer(usd, eur)))
+	assert.True(t, filter.matches(offer(xdr.MustNewNativeAsset(), usd)))
+	assert.False(t, filter.matches(offer(xdr.MustNewNativeAsset(), eur)))
+
+	liquidityPool := &xdr.LedgerEntry{
+		Data: xdr.LedgerEntryData{
+			Type: xdr.LedgerEntryTypeLiquidityPool,
+			LiquidityPool: &xdr.LiquidityPoolEntry{
+				Body: xdr.LiquidityPoolEntryBody{
+					Type: xdr.LiquidityPoolTypeLiquidityPoolConstantProduct,
+					ConstantProduct: &xdr.LiquidityPoolEntryConstantProduct{
+						Params: xdr.LiquidityPoolConstantProductParameters{
+							AssetA: eur,
+							AssetB: usd,
+						},
+					},
+				},
+			},
+		},
+	}
+	assert.True(t, filter.matches(liquidityPool))
+
+	filter, err = newCheckpointFilter(CheckpointChangeReaderOptions{
+		Assets: []xdr.Asset{xdr.MustNewNativeAsset()},
+	})
+	require.NoError(t, err)
+	assert.True(t, filter.matches(account.LiveEntry))
+	assert.False(t, filter.matches(liquidityPool))
+}
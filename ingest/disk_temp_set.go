@@ -0,0 +1,435 @@
+package ingest
+
+import (
+	"bufio"
+	"encoding/binary"
+	"hash/maphash"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/stellar/go/support/errors"
+)
+
+const (
+	// defaultDiskTempSetMemoryKeys is the default number of keys kept in
+	// memory by diskTempSet before they're written to a file.
+	defaultDiskTempSetMemoryKeys = 1000000
+	// diskTempSetMaxRuns is the number of files after which all files are
+	// merged into one.
+	diskTempSetMaxRuns = 8
+	// diskTempSetIndexInterval is the number of keys in a block of a file.
+	// The first key of each block is kept in memory.
+	diskTempSetIndexInterval = 128
+	// diskTempSetFilterBitsPerKey and diskTempSetFilterHashes configure bloom
+	// filters of files (~1% false positives).
+	diskTempSetFilterBitsPerKey = 10
+	diskTempSetFilterHashes     = 7
+)
+
+// diskTempSet is an implementation of tempSet interface which keeps up to
+// memoryKeys keys in memory and then writes them to a sorted file (run) in a
+// temporary directory. Only the first key of every block of
+// diskTempSetIndexInterval keys and a bloom filter of each run are kept in
+// memory so checking a key which was written to disk usually requires at most
+// one read per run. Runs are merged when there are more than
+// diskTempSetMaxRuns of them. The temporary directory is removed when the set
+// is closed.
+type diskTempSet struct {
+	dir        string
+	memoryKeys int
+
+	tempDir string
+	seed    maphash.Seed
+	memory  map[string]struct{}
+	runs    []*tempSetRun
+	// preloaded contains keys loaded by Preload. It's cleared when keys are
+	// written to a new run.
+	preloaded map[string]bool
+}
+
+func newDiskTempSet(dir string, memoryKeys int) *diskTempSet {
+	if memoryKeys <= 0 {
+		memoryKeys = defaultDiskTempSetMemoryKeys
+	}
+	return &diskTempSet{dir: dir, memoryKeys: memoryKeys}
+}
+
+// Open creates the temporary directory.
+func (s *diskTempSet) Open() error {
+	tempDir, err := ioutil.TempDir(s.dir, "checkpoint-change-reader")
+	if err != nil {
+		return errors.Wrap(err, "error creating temp set directory")
+	}
+	s.tempDir = tempDir
+	s.seed = maphash.MakeSeed()
+	s.memory = map[string]struct{}{}
+	s.runs = nil
+	s.preloaded = nil
+	return nil
+}
+
+// Add adds a key to the set and writes keys kept in memory to disk if there
+// are memoryKeys of them.
+func (s *diskTempSet) Add(key string) error {
+	s.memory[key] = struct{}{}
+	if len(s.memory) < s.memoryKeys {
+		return nil
+	}
+
+	keys := make([]string, 0, len(s.memory))
+	for key := range s.memory {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	w, err := s.newRunWriter(len(keys))
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err = w.add(key); err != nil {
+			w.abort()
+			return err
+		}
+	}
+	run, err := w.finish()
+	if err != nil {
+		return err
+	}
+	s.runs = append(s.runs, run)
+	s.memory = map[string]struct{}{}
+	s.preloaded = nil
+
+	if len(s.runs) > diskTempSetMaxRuns {
+		return s.mergeRuns()
+	}
+	return nil
+}
+
+// Preload checks which of the keys were written to disk reading every block
+// of a run at most once.
+func (s *diskTempSet) Preload(keys []string) error {
+	pending := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if _, ok := s.memory[key]; !ok {
+			pending = append(pending, key)
+		}
+	}
+	sort.Strings(pending)
+
+	s.preloaded = make(map[string]bool, len(pending))
+	for _, run := range s.runs {
+		blockIndex := -1
+		var block []byte
+		for _, key := range pending {
+			if s.preloaded[key] || !run.filter.mayContain(s.hash(key)) {
+				continue
+			}
+			i := run.blockOf(key)
+			if i < 0 {
+				continue
+			}
+			if i != blockIndex {
+				var err error
+				if block, err = run.readBlock(i); err != nil {
+					return err
+				}
+				blockIndex = i
+			}
+			if blockContains(block, key) {
+				s.preloaded[key] = true
+			}
+		}
+	}
+	for _, key := range pending {
+		if !s.preloaded[key] {
+			s.preloaded[key] = false
+		}
+	}
+	return nil
+}
+
+// Exist checks if the key is in the set.
+func (s *diskTempSet) Exist(key string) (bool, error) {
+	if _, ok := s.memory[key]; ok {
+		return true, nil
+	}
+	if exists, ok := s.preloaded[key]; ok {
+		return exists, nil
+	}
+	for _, run := range s.runs {
+		if !run.filter.mayContain(s.hash(key)) {
+			continue
+		}
+		i := run.blockOf(key)
+		if i < 0 {
+			continue
+		}
+		block, err := run.readBlock(i)
+		if err != nil {
+			return false, err
+		}
+		if blockContains(block, key) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Close closes and removes all files.
+func (s *diskTempSet) Close() error {
+	for _, run := range s.runs {
+		run.file.Close()
+	}
+	s.runs = nil
+	s.memory = nil
+	s.preloaded = nil
+	if s.tempDir == "" {
+		return nil
+	}
+	err := os.RemoveAll(s.tempDir)
+	s.tempDir = ""
+	return err
+}
+
+func (s *diskTempSet) hash(key string) uint64 {
+	var h maphash.Hash
+	h.SetSeed(s.seed)
+	h.WriteString(key)
+	return h.Sum64()
+}
+
+// mergeRuns merges all runs into a single run.
+func (s *diskTempSet) mergeRuns() error {
+	count := 0
+	iterators := make([]*tempSetRunIterator, 0, len(s.runs))
+	for _, run := range s.runs {
+		count += run.count
+		it, err := newTempSetRunIterator(run)
+		if err != nil {
+			return err
+		}
+		iterators = append(iterators, it)
+	}
+
+	w, err := s.newRunWriter(count)
+	if err != nil {
+		return err
+	}
+	for {
+		// Runs are few so finding the smallest key linearly is fine.
+		var next *tempSetRunIterator
+		for _, it := range iterators {
+			if it.done {
+				continue
+			}
+			if next == nil || it.key < next.key {
+				next = it
+			}
+		}
+		if next == nil {
+			break
+		}
+		if err = w.add(next.key); err != nil {
+			w.abort()
+			return err
+		}
+		for _, it := range iterators {
+			if !it.done && it.key == w.last {
+				if err = it.next(); err != nil {
+					w.abort()
+					return err
+				}
+			}
+		}
+	}
+	run, err := w.finish()
+	if err != nil {
+		return err
+	}
+
+	for _, old := range s.runs {
+		old.file.Close()
+		os.Remove(old.file.Name())
+	}
+	s.runs = []*tempSetRun{run}
+	return nil
+}
+
+type tempSetIndexEntry struct {
+	key    string
+	offset int64
+}
+
+// tempSetRun is a file with sorted, length prefixed keys.
+type tempSetRun struct {
+	file   *os.File
+	size   int64
+	count  int
+	index  []tempSetIndexEntry
+	filter bloomFilter
+}
+
+// blockOf returns the index of the block which can contain the key or -1.
+func (r *tempSetRun) blockOf(key string) int {
+	return sort.Search(len(r.index), func(i int) bool {
+		return r.index[i].key > key
+	}) - 1
+}
+
+func (r *tempSetRun) readBlock(i int) ([]byte, error) {
+	end := r.size
+	if i+1 < len(r.index) {
+		end = r.index[i+1].offset
+	}
+	block := make([]byte, end-r.index[i].offset)
+	if _, err := r.file.ReadAt(block, r.index[i].offset); err != nil {
+		return nil, errors.Wrap(err, "error reading temp set file")
+	}
+	return block, nil
+}
+
+// blockContains checks if the sorted block contains the key.
+func blockContains(block []byte, key string) bool {
+	for len(block) > 0 {
+		length, n := binary.Uvarint(block)
+		if n <= 0 || uint64(len(block)-n) < length {
+			return false
+		}
+		current := string(block[n : n+int(length)])
+		if current == key {
+			return true
+		}
+		if current > key {
+			return false
+		}
+		block = block[n+int(length):]
+	}
+	return false
+}
+
+type tempSetRunWriter struct {
+	set    *diskTempSet
+	file   *os.File
+	w      *bufio.Writer
+	run    *tempSetRun
+	offset int64
+	last   string
+	buf    [binary.MaxVarintLen64]byte
+}
+
+func (s *diskTempSet) newRunWriter(expectedKeys int) (*tempSetRunWriter, error) {
+	file, err := ioutil.TempFile(s.tempDir, "run")
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating temp set file")
+	}
+	return &tempSetRunWriter{
+		set:  s,
+		file: file,
+		w:    bufio.NewWriter(file),
+		run: &tempSetRun{
+			file:   file,
+			filter: newBloomFilter(expectedKeys),
+		},
+	}, nil
+}
+
+// add writes the key. Keys must be added in order, duplicates are skipped.
+func (w *tempSetRunWriter) add(key string) error {
+	if w.run.count > 0 && key == w.last {
+		return nil
+	}
+	if w.run.count%diskTempSetIndexInterval == 0 {
+		w.run.index = append(w.run.index, tempSetIndexEntry{key: key, offset: w.offset})
+	}
+	n := binary.PutUvarint(w.buf[:], uint64(len(key)))
+	if _, err := w.w.Write(w.buf[:n]); err != nil {
+		return errors.Wrap(err, "error writing temp set file")
+	}
+	if _, err := w.w.WriteString(key); err != nil {
+		return errors.Wrap(err, "error writing temp set file")
+	}
+	w.offset += int64(n + len(key))
+	w.run.filter.add(w.set.hash(key))
+	w.run.count++
+	w.last = key
+	return nil
+}
+
+func (w *tempSetRunWriter) finish() (*tempSetRun, error) {
+	if err := w.w.Flush(); err != nil {
+		w.abort()
+		return nil, errors.Wrap(err, "error writing temp set file")
+	}
+	w.run.size = w.offset
+	return w.run, nil
+}
+
+func (w *tempSetRunWriter) abort() {
+	w.file.Close()
+	os.Remove(w.file.Name())
+}
+
+// tempSetRunIterator reads keys of a run in order.
+type tempSetRunIterator struct {
+	r    *bufio.Reader
+	key  string
+	done bool
+}
+
+func newTempSetRunIterator(run *tempSetRun) (*tempSetRunIterator, error) {
+	it := &tempSetRunIterator{
+		r: bufio.NewReader(io.NewSectionReader(run.file, 0, run.size)),
+	}
+	return it, it.next()
+}
+
+func (it *tempSetRunIterator) next() error {
+	length, err := binary.ReadUvarint(it.r)
+	if err == io.EOF {
+		it.done = true
+		return nil
+	} else if err != nil {
+		return errors.Wrap(err, "error reading temp set file")
+	}
+	key := make([]byte, length)
+	if _, err = io.ReadFull(it.r, key); err != nil {
+		return errors.Wrap(err, "error reading temp set file")
+	}
+	it.key = string(key)
+	return nil
+}
+
+// bloomFilter is a bloom filter of key hashes using double hashing.
+type bloomFilter []uint64
+
+func newBloomFilter(expectedKeys int) bloomFilter {
+	bits := expectedKeys * diskTempSetFilterBitsPerKey
+	return make(bloomFilter, bits/64+1)
+}
+
+func (f bloomFilter) positions(hash uint64, fn func(bit uint64) bool) bool {
+	h1, h2 := hash, hash>>32|1
+	bits := uint64(len(f)) * 64
+	for i := uint64(0); i < diskTempSetFilterHashes; i++ {
+		if !fn((h1 + i*h2) % bits) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f bloomFilter) add(hash uint64) {
+	f.positions(hash, func(bit uint64) bool {
+		f[bit/64] |= 1 << (bit % 64)
+		return true
+	})
+}
+
+func (f bloomFilter) mayContain(hash uint64) bool {
+	return f.positions(hash, func(bit uint64) bool {
+		return f[bit/64]&(1<<(bit%64)) != 0
+	})
+}
@@ -0,0 +1,59 @@
+package ingest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiskTempSet(t *testing.T) {
+	dir := t.TempDir()
+	s := newDiskTempSet(dir, 10)
+	require.NoError(t, s.Open())
+
+	// 1000 keys are written to 100 runs which are merged when there are
+	// more than diskTempSetMaxRuns of them.
+	for i := 0; i < 1000; i += 2 {
+		require.NoError(t, s.Add(fmt.Sprintf("key-%d", i)))
+		require.NoError(t, s.Add(fmt.Sprintf("key-%d", i+1)))
+		// Duplicates are ignored
+		require.NoError(t, s.Add(fmt.Sprintf("key-%d", i)))
+	}
+	assert.LessOrEqual(t, len(s.runs), diskTempSetMaxRuns)
+	assert.Greater(t, len(s.runs), 0)
+
+	for i := 0; i < 1000; i++ {
+		v, err := s.Exist(fmt.Sprintf("key-%d", i))
+		require.NoError(t, err)
+		assert.True(t, v)
+	}
+	for _, key := range []string{"a", "key-", "key-1000", "key-5000", "z"} {
+		v, err := s.Exist(key)
+		require.NoError(t, err)
+		assert.False(t, v)
+	}
+
+	keys := []string{"key-1", "key-500", "key-999", "key-1000", "a"}
+	require.NoError(t, s.Preload(keys))
+	for i, key := range keys {
+		v, err := s.Exist(key)
+		require.NoError(t, err)
+		assert.Equal(t, i < 3, v, key)
+	}
+
+	// Keys added after preloading are found even if they're written to disk
+	for i := 1000; i < 1020; i++ {
+		require.NoError(t, s.Add(fmt.Sprintf("key-%d", i)))
+	}
+	v, err := s.Exist("key-1000")
+	require.NoError(t, err)
+	assert.True(t, v)
+
+	require.NoError(t, s.Close())
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
@@ -34,6 +34,15 @@ Currently there are three types of readers:
 Warning: Readers stream BOTH successful and failed transactions; check
 transactions status in your application if required.
 
+# Processors
+
+ChangeProcessor and LedgerTransactionProcessor are interfaces of objects
+processing the data returned by readers. Pipeline wires a LedgerBackend and
+a CheckpointChangeReader to processors: it builds the initial state at
+a checkpoint ledger, processes every following ledger, commits processors
+implementing ProcessorCommitter and stores the last processed ledger in
+a CursorStore so it can be resumed.
+
 # Tutorial
 
 Refer to the examples below for simple use cases, or check out the README (and
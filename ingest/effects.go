@@ -0,0 +1,1134 @@
+package ingest
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/stellar/go/amount"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/protocols/horizon/base"
+	"github.com/stellar/go/protocols/horizon/effects"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/xdr"
+)
+
+// Effect is a change of the ledger state caused by an operation, as
+// presented by Horizon's /effects endpoints. Details contain the same fields
+// as Horizon effects of the given type.
+type Effect struct {
+	// Address is the account the effect belongs to.
+	Address string
+	// AddressMuxed is the muxed address of the account or an empty string if
+	// the effect doesn't belong to a muxed account.
+	AddressMuxed string
+	// OperationIndex is the 0-based index of the operation in the
+	// transaction.
+	OperationIndex uint32
+	// Order is the 1-based order of the effect within the operation.
+	Order   uint32
+	Type    effects.EffectType
+	Details map[string]interface{}
+}
+
+// Effects returns effects of all operations of the transaction. Failed
+// transactions don't have effects.
+func (t *LedgerTransaction) Effects() ([]Effect, error) {
+	var all []Effect
+	for _, operation := range t.Operations() {
+		operationEffects, err := operation.Effects()
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading operation %d effects", operation.Index)
+		}
+		all = append(all, operationEffects...)
+	}
+	return all, nil
+}
+
+// Effects returns the effects of the operation. Operations of failed
+// transactions don't have effects.
+func (o LedgerOperation) Effects() ([]Effect, error) {
+	if !o.Transaction.Successful() {
+		return []Effect{}, nil
+	}
+
+	changes, err := o.Changes()
+	if err != nil {
+		return nil, err
+	}
+
+	wrapper := &effectsWrapper{
+		effects:   []Effect{},
+		operation: o,
+	}
+
+	switch o.Type() {
+	case xdr.OperationTypeCreateAccount:
+		wrapper.addAccountCreatedEffects()
+	case xdr.OperationTypePayment:
+		wrapper.addPaymentEffects()
+	case xdr.OperationTypePathPaymentStrictReceive, xdr.OperationTypePathPaymentStrictSend:
+		err = wrapper.addPathPaymentEffects()
+	case xdr.OperationTypeManageSellOffer, xdr.OperationTypeManageBuyOffer, xdr.OperationTypeCreatePassiveSellOffer:
+		source := o.SourceAccount()
+		err = wrapper.addIngestTradeEffects(source, o.ClaimAtoms())
+	case xdr.OperationTypeSetOptions:
+		err = wrapper.addSetOptionsEffects(changes)
+	case xdr.OperationTypeChangeTrust:
+		err = wrapper.addChangeTrustEffects(changes)
+	case xdr.OperationTypeAllowTrust:
+		err = wrapper.addAllowTrustEffects()
+	case xdr.OperationTypeAccountMerge:
+		wrapper.addAccountMergeEffects()
+	case xdr.OperationTypeInflation:
+		wrapper.addInflationEffects()
+	case xdr.OperationTypeManageData:
+		wrapper.addManageDataEffects(changes)
+	case xdr.OperationTypeBumpSequence:
+		wrapper.addBumpSequenceEffects(changes)
+	case xdr.OperationTypeCreateClaimableBalance:
+		err = wrapper.addCreateClaimableBalanceEffects(changes)
+	case xdr.OperationTypeClaimClaimableBalance:
+		err = wrapper.addClaimClaimableBalanceEffects(changes)
+	case xdr.OperationTypeBeginSponsoringFutureReserves, xdr.OperationTypeEndSponsoringFutureReserves, xdr.OperationTypeRevokeSponsorship:
+		// The effects of these operations are obtained indirectly from the
+		// ledger entries
+	case xdr.OperationTypeClawback:
+		wrapper.addClawbackEffects()
+	case xdr.OperationTypeClawbackClaimableBalance:
+		err = wrapper.addClawbackClaimableBalanceEffects(changes)
+	case xdr.OperationTypeSetTrustLineFlags:
+		err = wrapper.addSetTrustLineFlagsEffects()
+	case xdr.OperationTypeLiquidityPoolDeposit:
+		err = wrapper.addLiquidityPoolDepositEffect()
+	case xdr.OperationTypeLiquidityPoolWithdraw:
+		err = wrapper.addLiquidityPoolWithdrawEffect()
+	default:
+		return nil, fmt.Errorf("Unknown operation type: %s", o.Type())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Effects generated for multiple operations. Keep the effect categories
+	// separated so they are "together" in case of different order or meta
+	// changes generate by core (unordered_map).
+
+	// Sponsorships
+	for _, change := range changes {
+		if err = wrapper.addLedgerEntrySponsorshipEffects(change); err != nil {
+			return nil, err
+		}
+		wrapper.addSignerSponsorshipEffects(change)
+	}
+
+	// Liquidity pools
+	for _, change := range changes {
+		// Effects caused by ChangeTrust (creation), AllowTrust and
+		// SetTrustlineFlags (removal through revocation)
+		wrapper.addLedgerEntryLiquidityPoolEffects(change)
+	}
+
+	return wrapper.effects, nil
+}
+
+type effectsWrapper struct {
+	effects   []Effect
+	operation LedgerOperation
+}
+
+func (e *effectsWrapper) add(address, addressMuxed string, effectType effects.EffectType, details map[string]interface{}) {
+	e.effects = append(e.effects, Effect{
+		Address:        address,
+		AddressMuxed:   addressMuxed,
+		OperationIndex: e.operation.Index,
+		Order:          uint32(len(e.effects) + 1),
+		Type:           effectType,
+		Details:        details,
+	})
+}
+
+func (e *effectsWrapper) addUnmuxed(address xdr.AccountId, effectType effects.EffectType, details map[string]interface{}) {
+	e.add(address.Address(), "", effectType, details)
+}
+
+func (e *effectsWrapper) addMuxed(address xdr.MuxedAccount, effectType effects.EffectType, details map[string]interface{}) {
+	var addressMuxed string
+	if address.Type == xdr.CryptoKeyTypeKeyTypeMuxedEd25519 {
+		addressMuxed = address.Address()
+	}
+	accountID := address.ToAccountId()
+	e.add(accountID.Address(), addressMuxed, effectType, details)
+}
+
+var sponsoringEffectsTable = map[xdr.LedgerEntryType]struct {
+	created, updated, removed effects.EffectType
+}{
+	xdr.LedgerEntryTypeAccount: {
+		created: effects.EffectAccountSponsorshipCreated,
+		updated: effects.EffectAccountSponsorshipUpdated,
+		removed: effects.EffectAccountSponsorshipRemoved,
+	},
+	xdr.LedgerEntryTypeTrustline: {
+		created: effects.EffectTrustlineSponsorshipCreated,
+		updated: effects.EffectTrustlineSponsorshipUpdated,
+		removed: effects.EffectTrustlineSponsorshipRemoved,
+	},
+	xdr.LedgerEntryTypeData: {
+		created: effects.EffectDataSponsorshipCreated,
+		updated: effects.EffectDataSponsorshipUpdated,
+		removed: effects.EffectDataSponsorshipRemoved,
+	},
+	xdr.LedgerEntryTypeClaimableBalance: {
+		created: effects.EffectClaimableBalanceSponsorshipCreated,
+		updated: effects.EffectClaimableBalanceSponsorshipUpdated,
+		removed: effects.EffectClaimableBalanceSponsorshipRemoved,
+	},
+
+	// We intentionally don't have Sponsoring effects for Offer
+	// entries because we don't generate creation effects for them.
+}
+
+func (e *effectsWrapper) addSignerSponsorshipEffects(change Change) {
+	if change.Type != xdr.LedgerEntryTypeAccount {
+		return
+	}
+
+	preSigners := map[string]xdr.AccountId{}
+	postSigners := map[string]xdr.AccountId{}
+	if change.Pre != nil {
+		account := change.Pre.Data.MustAccount()
+		preSigners = account.SponsorPerSigner()
+	}
+	if change.Post != nil {
+		account := change.Post.Data.MustAccount()
+		postSigners = account.SponsorPerSigner()
+	}
+
+	var all []string
+	for signer := range preSigners {
+		all = append(all, signer)
+	}
+	for signer := range postSigners {
+		if _, ok := preSigners[signer]; ok {
+			continue
+		}
+		all = append(all, signer)
+	}
+	sort.Strings(all)
+
+	for _, signer := range all {
+		pre, foundPre := preSigners[signer]
+		post, foundPost := postSigners[signer]
+
+		switch {
+		case !foundPre && foundPost:
+			e.addUnmuxed(change.Post.Data.MustAccount().AccountId, effects.EffectSignerSponsorshipCreated,
+				map[string]interface{}{
+					"sponsor": post.Address(),
+					"signer":  signer,
+				},
+			)
+		case foundPre && !foundPost:
+			e.addUnmuxed(change.Pre.Data.MustAccount().AccountId, effects.EffectSignerSponsorshipRemoved,
+				map[string]interface{}{
+					"former_sponsor": pre.Address(),
+					"signer":         signer,
+				},
+			)
+		case foundPre && foundPost:
+			formerSponsor := pre.Address()
+			newSponsor := post.Address()
+			if formerSponsor == newSponsor {
+				continue
+			}
+			e.addUnmuxed(change.Post.Data.MustAccount().AccountId, effects.EffectSignerSponsorshipUpdated,
+				map[string]interface{}{
+					"former_sponsor": formerSponsor,
+					"new_sponsor":    newSponsor,
+					"signer":         signer,
+				},
+			)
+		}
+	}
+}
+
+func (e *effectsWrapper) addLedgerEntrySponsorshipEffects(change Change) error {
+	effectsForEntryType, found := sponsoringEffectsTable[change.Type]
+	if !found {
+		return nil
+	}
+
+	details := map[string]interface{}{}
+	var effectType effects.EffectType
+
+	switch {
+	case (change.Pre == nil || change.Pre.SponsoringID() == nil) &&
+		(change.Post != nil && change.Post.SponsoringID() != nil):
+		effectType = effectsForEntryType.created
+		details["sponsor"] = (*change.Post.SponsoringID()).Address()
+	case (change.Pre != nil && change.Pre.SponsoringID() != nil) &&
+		(change.Post == nil || change.Post.SponsoringID() == nil):
+		effectType = effectsForEntryType.removed
+		details["former_sponsor"] = (*change.Pre.SponsoringID()).Address()
+	case (change.Pre != nil && change.Pre.SponsoringID() != nil) &&
+		(change.Post != nil && change.Post.SponsoringID() != nil):
+		preSponsor := (*change.Pre.SponsoringID()).Address()
+		postSponsor := (*change.Post.SponsoringID()).Address()
+		if preSponsor == postSponsor {
+			return nil
+		}
+		effectType = effectsForEntryType.updated
+		details["new_sponsor"] = postSponsor
+		details["former_sponsor"] = preSponsor
+	default:
+		return nil
+	}
+
+	var data xdr.LedgerEntryData
+	if change.Post != nil {
+		data = change.Post.Data
+	} else {
+		data = change.Pre.Data
+	}
+
+	switch change.Type {
+	case xdr.LedgerEntryTypeAccount:
+		e.addUnmuxed(data.MustAccount().AccountId, effectType, details)
+	case xdr.LedgerEntryTypeTrustline:
+		tl := data.MustTrustLine()
+		if tl.Asset.Type == xdr.AssetTypeAssetTypePoolShare {
+			details["asset_type"] = "liquidity_pool"
+			details["liquidity_pool_id"] = poolIDToString(*tl.Asset.LiquidityPoolId)
+		} else {
+			details["asset"] = tl.Asset.ToAsset().StringCanonical()
+		}
+		e.addUnmuxed(tl.AccountId, effectType, details)
+	case xdr.LedgerEntryTypeData:
+		details["data_name"] = data.MustData().DataName
+		e.addMuxed(e.operation.SourceAccount(), effectType, details)
+	case xdr.LedgerEntryTypeClaimableBalance:
+		var err error
+		details["balance_id"], err = xdr.MarshalHex(data.MustClaimableBalance().BalanceId)
+		if err != nil {
+			return errors.Wrapf(err, "Invalid balanceId in change from op %d", e.operation.Index)
+		}
+		e.addMuxed(e.operation.SourceAccount(), effectType, details)
+	default:
+		// liquidity pools cannot be sponsored
+		return errors.Errorf("invalid sponsorship ledger entry type %v", change.Type.String())
+	}
+
+	return nil
+}
+
+func (e *effectsWrapper) addLedgerEntryLiquidityPoolEffects(change Change) {
+	if change.Type != xdr.LedgerEntryTypeLiquidityPool {
+		return
+	}
+
+	switch {
+	case change.Pre == nil && change.Post != nil:
+		e.addMuxed(e.operation.SourceAccount(), effects.EffectLiquidityPoolCreated,
+			map[string]interface{}{
+				"liquidity_pool": liquidityPoolDetails(change.Post.Data.LiquidityPool),
+			},
+		)
+	case change.Pre != nil && change.Post == nil:
+		e.addMuxed(e.operation.SourceAccount(), effects.EffectLiquidityPoolRemoved,
+			map[string]interface{}{
+				"liquidity_pool_id": poolIDToString(change.Pre.Data.LiquidityPool.LiquidityPoolId),
+			},
+		)
+	}
+}
+
+func (e *effectsWrapper) addAccountCreatedEffects() {
+	op := e.operation.Operation.Body.MustCreateAccountOp()
+
+	e.addUnmuxed(op.Destination, effects.EffectAccountCreated,
+		map[string]interface{}{
+			"starting_balance": amount.String(op.StartingBalance),
+		},
+	)
+	e.addMuxed(e.operation.SourceAccount(), effects.EffectAccountDebited,
+		map[string]interface{}{
+			"asset_type": "native",
+			"amount":     amount.String(op.StartingBalance),
+		},
+	)
+	e.addUnmuxed(op.Destination, effects.EffectSignerCreated,
+		map[string]interface{}{
+			"public_key": op.Destination.Address(),
+			"weight":     keypair.DefaultSignerWeight,
+		},
+	)
+}
+
+func (e *effectsWrapper) addPaymentEffects() {
+	op := e.operation.Operation.Body.MustPaymentOp()
+
+	details := map[string]interface{}{"amount": amount.String(op.Amount)}
+	addAssetDetails(details, op.Asset, "")
+
+	e.addMuxed(op.Destination, effects.EffectAccountCredited, details)
+	e.addMuxed(e.operation.SourceAccount(), effects.EffectAccountDebited, details)
+}
+
+func (e *effectsWrapper) addPathPaymentEffects() error {
+	result, ok := e.operation.PathPaymentResult()
+	if !ok {
+		return errors.New("path payment result not found")
+	}
+	source := e.operation.SourceAccount()
+
+	details := map[string]interface{}{"amount": amount.String(result.DestAmount)}
+	addAssetDetails(details, result.DestAsset, "")
+	e.addMuxed(result.Destination, effects.EffectAccountCredited, details)
+
+	details = map[string]interface{}{"amount": amount.String(result.SendAmount)}
+	addAssetDetails(details, result.SendAsset, "")
+	e.addMuxed(source, effects.EffectAccountDebited, details)
+
+	return e.addIngestTradeEffects(source, e.operation.ClaimAtoms())
+}
+
+func (e *effectsWrapper) addSetOptionsEffects(changes []Change) error {
+	source := e.operation.SourceAccount()
+	op := e.operation.Operation.Body.MustSetOptionsOp()
+
+	if op.HomeDomain != nil {
+		e.addMuxed(source, effects.EffectAccountHomeDomainUpdated,
+			map[string]interface{}{
+				"home_domain": string(*op.HomeDomain),
+			},
+		)
+	}
+
+	thresholdDetails := map[string]interface{}{}
+
+	if op.LowThreshold != nil {
+		thresholdDetails["low_threshold"] = *op.LowThreshold
+	}
+
+	if op.MedThreshold != nil {
+		thresholdDetails["med_threshold"] = *op.MedThreshold
+	}
+
+	if op.HighThreshold != nil {
+		thresholdDetails["high_threshold"] = *op.HighThreshold
+	}
+
+	if len(thresholdDetails) > 0 {
+		e.addMuxed(source, effects.EffectAccountThresholdsUpdated, thresholdDetails)
+	}
+
+	flagDetails := map[string]interface{}{}
+	if op.SetFlags != nil {
+		setAuthFlagDetails(flagDetails, xdr.AccountFlags(*op.SetFlags), true)
+	}
+	if op.ClearFlags != nil {
+		setAuthFlagDetails(flagDetails, xdr.AccountFlags(*op.ClearFlags), false)
+	}
+
+	if len(flagDetails) > 0 {
+		e.addMuxed(source, effects.EffectAccountFlagsUpdated, flagDetails)
+	}
+
+	if op.InflationDest != nil {
+		e.addMuxed(source, effects.EffectAccountInflationDestinationUpdated,
+			map[string]interface{}{
+				"inflation_destination": op.InflationDest.Address(),
+			},
+		)
+	}
+
+	for _, change := range changes {
+		if change.Type != xdr.LedgerEntryTypeAccount || change.Pre == nil || change.Post == nil {
+			continue
+		}
+
+		beforeAccount := change.Pre.Data.MustAccount()
+		afterAccount := change.Post.Data.MustAccount()
+
+		before := beforeAccount.SignerSummary()
+		after := afterAccount.SignerSummary()
+
+		// if before and after are the same, the signers have not changed
+		if reflect.DeepEqual(before, after) {
+			continue
+		}
+
+		beforeSortedSigners := []string{}
+		for signer := range before {
+			beforeSortedSigners = append(beforeSortedSigners, signer)
+		}
+		sort.Strings(beforeSortedSigners)
+
+		for _, addy := range beforeSortedSigners {
+			weight, ok := after[addy]
+			if !ok {
+				e.addMuxed(source, effects.EffectSignerRemoved, map[string]interface{}{
+					"public_key": addy,
+				})
+				continue
+			}
+
+			if weight != before[addy] {
+				e.addMuxed(source, effects.EffectSignerUpdated, map[string]interface{}{
+					"public_key": addy,
+					"weight":     weight,
+				})
+			}
+		}
+
+		afterSortedSigners := []string{}
+		for signer := range after {
+			afterSortedSigners = append(afterSortedSigners, signer)
+		}
+		sort.Strings(afterSortedSigners)
+
+		// Add the "created" effects
+		for _, addy := range afterSortedSigners {
+			// if `addy` is in before, the previous for loop should have
+			// recorded the update, so skip this key
+			if _, ok := before[addy]; ok {
+				continue
+			}
+
+			e.addMuxed(source, effects.EffectSignerCreated, map[string]interface{}{
+				"public_key": addy,
+				"weight":     after[addy],
+			})
+		}
+	}
+	return nil
+}
+
+func (e *effectsWrapper) addChangeTrustEffects(changes []Change) error {
+	source := e.operation.SourceAccount()
+	op := e.operation.Operation.Body.MustChangeTrustOp()
+
+	// NOTE: when an account trusts itself, the transaction is successful but
+	// no ledger entries are actually modified.
+	for _, change := range changes {
+		if change.Type != xdr.LedgerEntryTypeTrustline {
+			continue
+		}
+
+		var (
+			effectType effects.EffectType
+			trustLine  xdr.TrustLineEntry
+		)
+
+		switch {
+		case change.Pre == nil && change.Post != nil:
+			effectType = effects.EffectTrustlineCreated
+			trustLine = *change.Post.Data.TrustLine
+		case change.Pre != nil && change.Post == nil:
+			effectType = effects.EffectTrustlineRemoved
+			trustLine = *change.Pre.Data.TrustLine
+		case change.Pre != nil && change.Post != nil:
+			effectType = effects.EffectTrustlineUpdated
+			trustLine = *change.Post.Data.TrustLine
+		default:
+			return errors.New("invalid trust line change")
+		}
+
+		// We want to add a single effect for change_trust op. If it's
+		// modifying credit_asset search for credit_asset trustline, otherwise
+		// search for liquidity_pool.
+		if op.Line.Type != trustLine.Asset.Type {
+			continue
+		}
+
+		details := map[string]interface{}{"limit": amount.String(op.Limit)}
+		if trustLine.Asset.Type == xdr.AssetTypeAssetTypePoolShare {
+			// The only change_trust ops that can modify LP are those with
+			// asset=liquidity_pool so *op.Line.LiquidityPool below is
+			// available.
+			if err := addLiquidityPoolAssetDetails(details, *op.Line.LiquidityPool); err != nil {
+				return err
+			}
+		} else {
+			addAssetDetails(details, op.Line.ToAsset(), "")
+		}
+
+		e.addMuxed(source, effectType, details)
+		break
+	}
+
+	return nil
+}
+
+func (e *effectsWrapper) addAllowTrustEffects() error {
+	source := e.operation.SourceAccount()
+	op := e.operation.Operation.Body.MustAllowTrustOp()
+	asset := op.Asset.ToAsset(source.ToAccountId())
+	details := map[string]interface{}{
+		"trustor": op.Trustor.Address(),
+	}
+	addAssetDetails(details, asset, "")
+
+	switch {
+	case xdr.TrustLineFlags(op.Authorize).IsAuthorized():
+		e.addMuxed(source, effects.EffectTrustlineAuthorized, details)
+		// Forward compatibility
+		setFlags := xdr.Uint32(xdr.TrustLineFlagsAuthorizedFlag)
+		e.addTrustLineFlagsEffect(source, op.Trustor, asset, &setFlags, nil)
+	case xdr.TrustLineFlags(op.Authorize).IsAuthorizedToMaintainLiabilitiesFlag():
+		e.addMuxed(source, effects.EffectTrustlineAuthorizedToMaintainLiabilities, details)
+		// Forward compatibility
+		setFlags := xdr.Uint32(xdr.TrustLineFlagsAuthorizedToMaintainLiabilitiesFlag)
+		e.addTrustLineFlagsEffect(source, op.Trustor, asset, &setFlags, nil)
+	default:
+		e.addMuxed(source, effects.EffectTrustlineDeauthorized, details)
+		// Forward compatibility, show both as cleared
+		clearFlags := xdr.Uint32(xdr.TrustLineFlagsAuthorizedFlag | xdr.TrustLineFlagsAuthorizedToMaintainLiabilitiesFlag)
+		e.addTrustLineFlagsEffect(source, op.Trustor, asset, nil, &clearFlags)
+	}
+	return e.addLiquidityPoolRevokedEffect()
+}
+
+func (e *effectsWrapper) addAccountMergeEffects() {
+	source := e.operation.SourceAccount()
+	dest := e.operation.Operation.Body.MustDestination()
+	result, _ := e.operation.Result()
+	details := map[string]interface{}{
+		"amount":     amount.String(result.MustAccountMergeResult().MustSourceAccountBalance()),
+		"asset_type": "native",
+	}
+
+	e.addMuxed(source, effects.EffectAccountDebited, details)
+	e.addMuxed(dest, effects.EffectAccountCredited, details)
+	e.addMuxed(source, effects.EffectAccountRemoved, map[string]interface{}{})
+}
+
+func (e *effectsWrapper) addInflationEffects() {
+	result, _ := e.operation.Result()
+	for _, payout := range result.MustInflationResult().MustPayouts() {
+		e.addUnmuxed(payout.Destination, effects.EffectAccountCredited,
+			map[string]interface{}{
+				"amount":     amount.String(payout.Amount),
+				"asset_type": "native",
+			},
+		)
+	}
+}
+
+func (e *effectsWrapper) addManageDataEffects(changes []Change) {
+	op := e.operation.Operation.Body.MustManageDataOp()
+	details := map[string]interface{}{"name": op.DataName}
+	effectType := effects.EffectType(0)
+
+	for _, change := range changes {
+		if change.Type != xdr.LedgerEntryTypeData {
+			continue
+		}
+
+		if change.Post != nil {
+			raw := change.Post.Data.MustData().DataValue
+			details["value"] = base64.StdEncoding.EncodeToString(raw)
+		}
+
+		switch {
+		case change.Pre == nil && change.Post != nil:
+			effectType = effects.EffectDataCreated
+		case change.Pre != nil && change.Post == nil:
+			effectType = effects.EffectDataRemoved
+		case change.Pre != nil && change.Post != nil:
+			effectType = effects.EffectDataUpdated
+		}
+
+		break
+	}
+
+	e.addMuxed(e.operation.SourceAccount(), effectType, details)
+}
+
+func (e *effectsWrapper) addBumpSequenceEffects(changes []Change) {
+	for _, change := range changes {
+		if change.Type != xdr.LedgerEntryTypeAccount || change.Pre == nil || change.Post == nil {
+			continue
+		}
+
+		beforeAccount := change.Pre.Data.MustAccount()
+		afterAccount := change.Post.Data.MustAccount()
+
+		if beforeAccount.SeqNum != afterAccount.SeqNum {
+			details := map[string]interface{}{"new_seq": afterAccount.SeqNum}
+			e.addMuxed(e.operation.SourceAccount(), effects.EffectSequenceBumped, details)
+		}
+		break
+	}
+}
+
+func setClaimableBalanceFlagDetails(details map[string]interface{}, flags xdr.ClaimableBalanceFlags) {
+	if flags.IsClawbackEnabled() {
+		details["claimable_balance_clawback_enabled_flag"] = true
+	}
+}
+
+func (e *effectsWrapper) addCreateClaimableBalanceEffects(changes []Change) error {
+	source := e.operation.SourceAccount()
+	var cb *xdr.ClaimableBalanceEntry
+	for _, change := range changes {
+		if change.Type != xdr.LedgerEntryTypeClaimableBalance || change.Post == nil {
+			continue
+		}
+		cb = change.Post.Data.ClaimableBalance
+		if err := e.addClaimableBalanceEntryCreatedEffects(source, cb); err != nil {
+			return err
+		}
+		break
+	}
+	if cb == nil {
+		return errors.New("claimable balance entry not found")
+	}
+
+	details := map[string]interface{}{
+		"amount": amount.String(cb.Amount),
+	}
+	addAssetDetails(details, cb.Asset, "")
+	e.addMuxed(source, effects.EffectAccountDebited, details)
+
+	return nil
+}
+
+func (e *effectsWrapper) addClaimableBalanceEntryCreatedEffects(source xdr.MuxedAccount, cb *xdr.ClaimableBalanceEntry) error {
+	id, err := xdr.MarshalHex(cb.BalanceId)
+	if err != nil {
+		return err
+	}
+	details := map[string]interface{}{
+		"balance_id": id,
+		"amount":     amount.String(cb.Amount),
+		"asset":      cb.Asset.StringCanonical(),
+	}
+	setClaimableBalanceFlagDetails(details, cb.Flags())
+	e.addMuxed(source, effects.EffectClaimableBalanceCreated, details)
+
+	// EffectClaimableBalanceClaimantCreated can be generated by
+	// `create_claimable_balance` operation but also by
+	// `liquidity_pool_withdraw` operation causing a revocation.
+	// In case of `create_claimable_balance` we use `op.Claimants` to make
+	// effects backward compatible. The reason for this is that Stellar-Core
+	// changes all `rel_before` predicated to `abs_before` when tx is included
+	// in the ledger.
+	var claimants []xdr.Claimant
+	if op, ok := e.operation.Operation.Body.GetCreateClaimableBalanceOp(); ok {
+		claimants = op.Claimants
+	} else {
+		claimants = cb.Claimants
+	}
+	for _, c := range claimants {
+		cv0 := c.MustV0()
+		e.addUnmuxed(cv0.Destination, effects.EffectClaimableBalanceClaimantCreated,
+			map[string]interface{}{
+				"balance_id": id,
+				"amount":     amount.String(cb.Amount),
+				"predicate":  cv0.Predicate,
+				"asset":      cb.Asset.StringCanonical(),
+			},
+		)
+	}
+	return nil
+}
+
+func (e *effectsWrapper) addClaimClaimableBalanceEffects(changes []Change) error {
+	op := e.operation.Operation.Body.MustClaimClaimableBalanceOp()
+
+	balanceID, err := xdr.MarshalHex(op.BalanceId)
+	if err != nil {
+		return fmt.Errorf("Invalid balanceId in op: %d", e.operation.Index)
+	}
+
+	var cBalance xdr.ClaimableBalanceEntry
+	found := false
+	for _, change := range changes {
+		if change.Type != xdr.LedgerEntryTypeClaimableBalance || change.Pre == nil || change.Post != nil {
+			continue
+		}
+
+		cBalance = change.Pre.Data.MustClaimableBalance()
+		preBalanceID, err := xdr.MarshalHex(cBalance.BalanceId)
+		if err != nil {
+			return fmt.Errorf("Invalid balanceId in meta changes for op: %d", e.operation.Index)
+		}
+
+		if preBalanceID == balanceID {
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("Change not found for balanceId : %s", balanceID)
+	}
+
+	details := map[string]interface{}{
+		"amount":     amount.String(cBalance.Amount),
+		"balance_id": balanceID,
+		"asset":      cBalance.Asset.StringCanonical(),
+	}
+	setClaimableBalanceFlagDetails(details, cBalance.Flags())
+	source := e.operation.SourceAccount()
+	e.addMuxed(source, effects.EffectClaimableBalanceClaimed, details)
+
+	details = map[string]interface{}{
+		"amount": amount.String(cBalance.Amount),
+	}
+	addAssetDetails(details, cBalance.Asset, "")
+	e.addMuxed(source, effects.EffectAccountCredited, details)
+
+	return nil
+}
+
+func (e *effectsWrapper) addIngestTradeEffects(buyer xdr.MuxedAccount, claims []xdr.ClaimAtom) error {
+	for _, claim := range claims {
+		switch claim.Type {
+		case xdr.ClaimAtomTypeClaimAtomTypeLiquidityPool:
+			if err := e.addClaimLiquidityPoolTradeEffect(claim); err != nil {
+				return err
+			}
+		default:
+			e.addClaimTradeEffects(buyer, claim)
+		}
+	}
+	return nil
+}
+
+func (e *effectsWrapper) addClaimTradeEffects(buyer xdr.MuxedAccount, claim xdr.ClaimAtom) {
+	seller := claim.SellerId()
+	bd, sd := tradeDetails(buyer, seller, claim)
+
+	e.addMuxed(buyer, effects.EffectTrade, bd)
+	e.addUnmuxed(seller, effects.EffectTrade, sd)
+}
+
+func (e *effectsWrapper) addClaimLiquidityPoolTradeEffect(claim xdr.ClaimAtom) error {
+	lp, _, err := e.operation.liquidityPoolAndDelta(&claim.LiquidityPool.LiquidityPoolId)
+	if err != nil {
+		return err
+	}
+	details := map[string]interface{}{
+		"liquidity_pool": liquidityPoolDetails(lp),
+		"sold": map[string]string{
+			"asset":  claim.LiquidityPool.AssetSold.StringCanonical(),
+			"amount": amount.String(claim.LiquidityPool.AmountSold),
+		},
+		"bought": map[string]string{
+			"asset":  claim.LiquidityPool.AssetBought.StringCanonical(),
+			"amount": amount.String(claim.LiquidityPool.AmountBought),
+		},
+	}
+	e.addMuxed(e.operation.SourceAccount(), effects.EffectLiquidityPoolTrade, details)
+	return nil
+}
+
+func (e *effectsWrapper) addClawbackEffects() {
+	op := e.operation.Operation.Body.MustClawbackOp()
+	details := map[string]interface{}{
+		"amount": amount.String(op.Amount),
+	}
+	addAssetDetails(details, op.Asset, "")
+
+	// The funds will be burned, but even with that, we generated an account
+	// credited effect
+	e.addMuxed(e.operation.SourceAccount(), effects.EffectAccountCredited, details)
+	e.addMuxed(op.From, effects.EffectAccountDebited, details)
+}
+
+func (e *effectsWrapper) addClawbackClaimableBalanceEffects(changes []Change) error {
+	op := e.operation.Operation.Body.MustClawbackClaimableBalanceOp()
+	balanceID, err := xdr.MarshalHex(op.BalanceId)
+	if err != nil {
+		return errors.Wrapf(err, "Invalid balanceId in op %d", e.operation.Index)
+	}
+	source := e.operation.SourceAccount()
+	e.addMuxed(source, effects.EffectClaimableBalanceClawedBack,
+		map[string]interface{}{
+			"balance_id": balanceID,
+		},
+	)
+
+	// Generate the account credited effect (although the funds will be
+	// burned) for the asset issuer
+	for _, c := range changes {
+		if c.Type == xdr.LedgerEntryTypeClaimableBalance && c.Post == nil && c.Pre != nil {
+			cb := c.Pre.Data.ClaimableBalance
+			details := map[string]interface{}{"amount": amount.String(cb.Amount)}
+			addAssetDetails(details, cb.Asset, "")
+			e.addMuxed(source, effects.EffectAccountCredited, details)
+			break
+		}
+	}
+
+	return nil
+}
+
+func (e *effectsWrapper) addSetTrustLineFlagsEffects() error {
+	op := e.operation.Operation.Body.MustSetTrustLineFlagsOp()
+	e.addTrustLineFlagsEffect(e.operation.SourceAccount(), op.Trustor, op.Asset, &op.SetFlags, &op.ClearFlags)
+	return e.addLiquidityPoolRevokedEffect()
+}
+
+func (e *effectsWrapper) addTrustLineFlagsEffect(
+	account xdr.MuxedAccount,
+	trustor xdr.AccountId,
+	asset xdr.Asset,
+	setFlags *xdr.Uint32,
+	clearFlags *xdr.Uint32,
+) {
+	details := map[string]interface{}{
+		"trustor": trustor.Address(),
+	}
+	addAssetDetails(details, asset, "")
+
+	var flagDetailsAdded bool
+	if setFlags != nil {
+		setTrustLineFlagDetails(details, xdr.TrustLineFlags(*setFlags), true)
+		flagDetailsAdded = true
+	}
+	if clearFlags != nil {
+		setTrustLineFlagDetails(details, xdr.TrustLineFlags(*clearFlags), false)
+		flagDetailsAdded = true
+	}
+
+	if flagDetailsAdded {
+		e.addMuxed(account, effects.EffectTrustlineFlagsUpdated, details)
+	}
+}
+
+func setTrustLineFlagDetails(flagDetails map[string]interface{}, flags xdr.TrustLineFlags, setValue bool) {
+	if flags.IsAuthorized() {
+		flagDetails["authorized_flag"] = setValue
+	}
+	if flags.IsAuthorizedToMaintainLiabilitiesFlag() {
+		flagDetails["authorized_to_maintain_liabilites"] = setValue
+	}
+	if flags.IsClawbackEnabledFlag() {
+		flagDetails["clawback_enabled_flag"] = setValue
+	}
+}
+
+func (e *effectsWrapper) addLiquidityPoolRevokedEffect() error {
+	source := e.operation.SourceAccount()
+	lp, delta, err := e.operation.liquidityPoolAndDelta(nil)
+	if err == errLiquidityPoolChangeNotFound {
+		// no revocation happened
+		return nil
+	} else if err != nil {
+		return err
+	}
+	changes, err := e.operation.Changes()
+	if err != nil {
+		return err
+	}
+	assetToCBID := map[string]string{}
+	var cbs []*xdr.ClaimableBalanceEntry
+	for _, change := range changes {
+		if change.Type == xdr.LedgerEntryTypeClaimableBalance && change.Pre == nil && change.Post != nil {
+			cb := change.Post.Data.ClaimableBalance
+			id, err := xdr.MarshalHex(cb.BalanceId)
+			if err != nil {
+				return err
+			}
+			assetToCBID[cb.Asset.StringCanonical()] = id
+			cbs = append(cbs, cb)
+		}
+	}
+	if len(assetToCBID) == 0 {
+		// no claimable balances were created, and thus, no revocation
+		// happened
+		return nil
+	}
+	// Core's claimable balance metadata isn't ordered, so we order it
+	// ourselves so that effects are ordered consistently
+	sort.Slice(cbs, func(i, j int) bool {
+		return cbs[i].Asset.LessThan(cbs[j].Asset)
+	})
+	for _, cb := range cbs {
+		if err := e.addClaimableBalanceEntryCreatedEffects(source, cb); err != nil {
+			return err
+		}
+	}
+
+	reservesRevoked := make([]map[string]string, 0, 2)
+	for _, aa := range []base.AssetAmount{
+		{
+			Asset:  lp.Body.ConstantProduct.Params.AssetA.StringCanonical(),
+			Amount: amount.String(-delta.ReserveA),
+		},
+		{
+			Asset:  lp.Body.ConstantProduct.Params.AssetB.StringCanonical(),
+			Amount: amount.String(-delta.ReserveB),
+		},
+	} {
+		if cbID, ok := assetToCBID[aa.Asset]; ok {
+			reservesRevoked = append(reservesRevoked, map[string]string{
+				"asset":                aa.Asset,
+				"amount":               aa.Amount,
+				"claimable_balance_id": cbID,
+			})
+		}
+	}
+	details := map[string]interface{}{
+		"liquidity_pool":   liquidityPoolDetails(lp),
+		"reserves_revoked": reservesRevoked,
+		"shares_revoked":   amount.String(-delta.TotalPoolShares),
+	}
+	e.addMuxed(source, effects.EffectLiquidityPoolRevoked, details)
+	return nil
+}
+
+func (e *effectsWrapper) addLiquidityPoolDepositEffect() error {
+	op := e.operation.Operation.Body.MustLiquidityPoolDepositOp()
+	lp, delta, err := e.operation.liquidityPoolAndDelta(&op.LiquidityPoolId)
+	if err != nil {
+		return err
+	}
+	details := map[string]interface{}{
+		"liquidity_pool": liquidityPoolDetails(lp),
+		"reserves_deposited": []base.AssetAmount{
+			{
+				Asset:  lp.Body.ConstantProduct.Params.AssetA.StringCanonical(),
+				Amount: amount.String(delta.ReserveA),
+			},
+			{
+				Asset:  lp.Body.ConstantProduct.Params.AssetB.StringCanonical(),
+				Amount: amount.String(delta.ReserveB),
+			},
+		},
+		"shares_received": amount.String(delta.TotalPoolShares),
+	}
+	e.addMuxed(e.operation.SourceAccount(), effects.EffectLiquidityPoolDeposited, details)
+	return nil
+}
+
+func (e *effectsWrapper) addLiquidityPoolWithdrawEffect() error {
+	op := e.operation.Operation.Body.MustLiquidityPoolWithdrawOp()
+	lp, delta, err := e.operation.liquidityPoolAndDelta(&op.LiquidityPoolId)
+	if err != nil {
+		return err
+	}
+	details := map[string]interface{}{
+		"liquidity_pool": liquidityPoolDetails(lp),
+		"reserves_received": []base.AssetAmount{
+			{
+				Asset:  lp.Body.ConstantProduct.Params.AssetA.StringCanonical(),
+				Amount: amount.String(-delta.ReserveA),
+			},
+			{
+				Asset:  lp.Body.ConstantProduct.Params.AssetB.StringCanonical(),
+				Amount: amount.String(-delta.ReserveB),
+			},
+		},
+		"shares_redeemed": amount.String(-delta.TotalPoolShares),
+	}
+	e.addMuxed(e.operation.SourceAccount(), effects.EffectLiquidityPoolWithdrew, details)
+	return nil
+}
+
+func setAuthFlagDetails(flagDetails map[string]interface{}, flags xdr.AccountFlags, setValue bool) {
+	if flags.IsAuthRequired() {
+		flagDetails["auth_required_flag"] = setValue
+	}
+	if flags.IsAuthRevocable() {
+		flagDetails["auth_revocable_flag"] = setValue
+	}
+	if flags.IsAuthImmutable() {
+		flagDetails["auth_immutable_flag"] = setValue
+	}
+	if flags.IsAuthClawbackEnabled() {
+		flagDetails["auth_clawback_enabled_flag"] = setValue
+	}
+}
+
+func tradeDetails(buyer xdr.MuxedAccount, seller xdr.AccountId, claim xdr.ClaimAtom) (bd map[string]interface{}, sd map[string]interface{}) {
+	bd = map[string]interface{}{
+		"offer_id":      claim.OfferId(),
+		"seller":        seller.Address(),
+		"bought_amount": amount.String(claim.AmountSold()),
+		"sold_amount":   amount.String(claim.AmountBought()),
+	}
+	addAssetDetails(bd, claim.AssetSold(), "bought_")
+	addAssetDetails(bd, claim.AssetBought(), "sold_")
+
+	sd = map[string]interface{}{
+		"offer_id":      claim.OfferId(),
+		"bought_amount": amount.String(claim.AmountBought()),
+		"sold_amount":   amount.String(claim.AmountSold()),
+	}
+	addAccountAndMuxedAccountDetails(sd, buyer, "seller")
+	addAssetDetails(sd, claim.AssetBought(), "bought_")
+	addAssetDetails(sd, claim.AssetSold(), "sold_")
+
+	return
+}
+
+func liquidityPoolDetails(lp *xdr.LiquidityPoolEntry) map[string]interface{} {
+	return map[string]interface{}{
+		"id":               poolIDToString(lp.LiquidityPoolId),
+		"fee_bp":           uint32(lp.Body.ConstantProduct.Params.Fee),
+		"type":             "constant_product",
+		"total_trustlines": strconv.FormatInt(int64(lp.Body.ConstantProduct.PoolSharesTrustLineCount), 10),
+		"total_shares":     amount.String(lp.Body.ConstantProduct.TotalPoolShares),
+		"reserves": []base.AssetAmount{
+			{
+				Asset:  lp.Body.ConstantProduct.Params.AssetA.StringCanonical(),
+				Amount: amount.String(lp.Body.ConstantProduct.ReserveA),
+			},
+			{
+				Asset:  lp.Body.ConstantProduct.Params.AssetB.StringCanonical(),
+				Amount: amount.String(lp.Body.ConstantProduct.ReserveB),
+			},
+		},
+	}
+}
+
+func poolIDToString(id xdr.PoolId) string {
+	return xdr.Hash(id).HexString()
+}
+
+func addAccountAndMuxedAccountDetails(result map[string]interface{}, a xdr.MuxedAccount, prefix string) {
+	accountID := a.ToAccountId()
+	result[prefix] = accountID.Address()
+	if a.Type == xdr.CryptoKeyTypeKeyTypeMuxedEd25519 {
+		result[prefix+"_muxed"] = a.Address()
+		result[prefix+"_muxed_id"] = uint64(a.Med25519.Id)
+	}
+}
+
+func addLiquidityPoolAssetDetails(result map[string]interface{}, lpp xdr.LiquidityPoolParameters) error {
+	result["asset_type"] = "liquidity_pool_shares"
+	if lpp.Type != xdr.LiquidityPoolTypeLiquidityPoolConstantProduct {
+		return fmt.Errorf("unknown liquidity pool type %d", lpp.Type)
+	}
+	cp := lpp.ConstantProduct
+	poolID, err := xdr.NewPoolId(cp.AssetA, cp.AssetB, cp.Fee)
+	if err != nil {
+		return err
+	}
+	result["liquidity_pool_id"] = poolIDToString(poolID)
+	return nil
+}
+
+// addAssetDetails sets the details for `a` on `result` using keys with
+// `prefix`.
+func addAssetDetails(result map[string]interface{}, a xdr.Asset, prefix string) {
+	var assetType, code, issuer string
+	if err := a.Extract(&assetType, &code, &issuer); err != nil {
+		return
+	}
+	result[prefix+"asset_type"] = assetType
+
+	if a.Type == xdr.AssetTypeAssetTypeNative {
+		return
+	}
+
+	result[prefix+"asset_code"] = code
+	result[prefix+"asset_issuer"] = issuer
+}
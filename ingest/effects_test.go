@@ -0,0 +1,115 @@
+package ingest
+
+import (
+	"testing"
+
+	"github.com/stellar/go/protocols/horizon/effects"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPathPaymentEffects(t *testing.T) {
+	op, result := testPathPaymentStrictSend()
+	tx := testLedgerTransaction([]xdr.Operation{op}, []xdr.OperationResult{result})
+
+	operationEffects, err := tx.Effects()
+	require.NoError(t, err)
+	assert.Equal(t, []Effect{
+		{
+			Address: testDestinationAddress,
+			Order:   1,
+			Type:    effects.EffectAccountCredited,
+			Details: map[string]interface{}{
+				"amount":       "0.0000020",
+				"asset_type":   "credit_alphanum4",
+				"asset_code":   "USD",
+				"asset_issuer": testSellerAddress,
+			},
+		},
+		{
+			Address: testSourceAddress,
+			Order:   2,
+			Type:    effects.EffectAccountDebited,
+			Details: map[string]interface{}{
+				"amount":     "0.0000100",
+				"asset_type": "native",
+			},
+		},
+		{
+			Address: testSourceAddress,
+			Order:   3,
+			Type:    effects.EffectTrade,
+			Details: map[string]interface{}{
+				"offer_id":            xdr.Int64(7),
+				"seller":              testSellerAddress,
+				"bought_amount":       "0.0000020",
+				"bought_asset_type":   "credit_alphanum4",
+				"bought_asset_code":   "USD",
+				"bought_asset_issuer": testSellerAddress,
+				"sold_amount":         "0.0000100",
+				"sold_asset_type":     "native",
+			},
+		},
+		{
+			Address: testSellerAddress,
+			Order:   4,
+			Type:    effects.EffectTrade,
+			Details: map[string]interface{}{
+				"offer_id":          xdr.Int64(7),
+				"seller":            testSourceAddress,
+				"bought_amount":     "0.0000100",
+				"bought_asset_type": "native",
+				"sold_amount":       "0.0000020",
+				"sold_asset_type":   "credit_alphanum4",
+				"sold_asset_code":   "USD",
+				"sold_asset_issuer": testSellerAddress,
+			},
+		},
+	}, operationEffects)
+
+	// Failed transactions don't have effects
+	tx.Result.Result.Result.Code = xdr.TransactionResultCodeTxFailed
+	operationEffects, err = tx.Effects()
+	require.NoError(t, err)
+	assert.Empty(t, operationEffects)
+}
+
+func TestPaymentEffectsMuxed(t *testing.T) {
+	destination := xdr.MuxedAccount{
+		Type: xdr.CryptoKeyTypeKeyTypeMuxedEd25519,
+		Med25519: &xdr.MuxedAccountMed25519{
+			Id:      5,
+			Ed25519: *xdr.MustAddress(testDestinationAddress).Ed25519,
+		},
+	}
+	ops := []xdr.Operation{
+		{
+			Body: xdr.OperationBody{
+				Type: xdr.OperationTypePayment,
+				PaymentOp: &xdr.PaymentOp{
+					Destination: destination,
+					Asset:       xdr.MustNewNativeAsset(),
+					Amount:      10,
+				},
+			},
+		},
+	}
+	tx := testLedgerTransaction(ops, nil)
+
+	operationEffects, err := tx.Operations()[0].Effects()
+	require.NoError(t, err)
+	require.Len(t, operationEffects, 2)
+
+	assert.Equal(t, testDestinationAddress, operationEffects[0].Address)
+	assert.Equal(t, destination.Address(), operationEffects[0].AddressMuxed)
+	assert.Equal(t, effects.EffectAccountCredited, operationEffects[0].Type)
+
+	assert.Equal(t, testSourceAddress, operationEffects[1].Address)
+	assert.Empty(t, operationEffects[1].AddressMuxed)
+	assert.Equal(t, effects.EffectAccountDebited, operationEffects[1].Type)
+	assert.Equal(t, map[string]interface{}{
+		"amount":     "0.0000010",
+		"asset_type": "native",
+	}, operationEffects[1].Details)
+}
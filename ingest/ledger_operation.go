@@ -0,0 +1,493 @@
+package ingest
+
+import (
+	"fmt"
+
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/xdr"
+)
+
+// LedgerOperation represents a single operation within a LedgerTransaction.
+// It provides the participants, effects and trade details of the operation
+// derived the same way Horizon does.
+type LedgerOperation struct {
+	// Index is the 0-based index of the operation in the transaction.
+	Index       uint32
+	Transaction *LedgerTransaction
+	Operation   xdr.Operation
+}
+
+// PathPaymentResult contains the amounts actually sent and delivered by a
+// successful path payment.
+type PathPaymentResult struct {
+	Destination xdr.MuxedAccount
+	SendAsset   xdr.Asset
+	SendAmount  xdr.Int64
+	DestAsset   xdr.Asset
+	DestAmount  xdr.Int64
+	// Offers are offers and liquidity pools the payment traded with.
+	Offers []xdr.ClaimAtom
+}
+
+// Operations returns all operations of the transaction.
+func (t *LedgerTransaction) Operations() []LedgerOperation {
+	ops := t.Envelope.Operations()
+	operations := make([]LedgerOperation, len(ops))
+	for i, op := range ops {
+		operations[i] = LedgerOperation{
+			Index:       uint32(i),
+			Transaction: t,
+			Operation:   op,
+		}
+	}
+	return operations
+}
+
+// Successful returns true if the transaction succeeded.
+func (t *LedgerTransaction) Successful() bool {
+	return t.Result.Successful()
+}
+
+// Participants returns the accounts taking part in the transaction: the
+// source account, the fee bump account, accounts changed by fees and
+// participants of all operations. The returned slice is not sorted.
+func (t *LedgerTransaction) Participants() ([]xdr.AccountId, error) {
+	participants := []xdr.AccountId{
+		t.Envelope.SourceAccount().ToAccountId(),
+	}
+	if t.Envelope.IsFeeBump() {
+		participants = append(participants, t.Envelope.FeeBumpAccount().ToAccountId())
+	}
+
+	// Only legacy TransactionMeta.V=0 operations meta is checked to match
+	// the participants stored by Horizon.
+	if t.UnsafeMeta.Operations != nil {
+		for _, op := range *t.UnsafeMeta.Operations {
+			p, err := participantsForChanges(op.Changes)
+			if err != nil {
+				return nil, err
+			}
+			participants = append(participants, p...)
+		}
+	}
+
+	p, err := participantsForChanges(t.FeeChanges)
+	if err != nil {
+		return nil, err
+	}
+	participants = append(participants, p...)
+
+	for _, operation := range t.Operations() {
+		p, err := operation.Participants()
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not determine operation %d participants", operation.Index)
+		}
+		participants = append(participants, p...)
+	}
+
+	return dedupeParticipants(participants), nil
+}
+
+func participantsForChanges(changes xdr.LedgerEntryChanges) ([]xdr.AccountId, error) {
+	var participants []xdr.AccountId
+
+	for _, c := range changes {
+		var entryType xdr.LedgerEntryType
+		var accountID xdr.AccountId
+
+		switch c.Type {
+		case xdr.LedgerEntryChangeTypeLedgerEntryCreated:
+			entryType = c.MustCreated().Data.Type
+			if entryType == xdr.LedgerEntryTypeAccount {
+				accountID = c.MustCreated().Data.MustAccount().AccountId
+			}
+		case xdr.LedgerEntryChangeTypeLedgerEntryRemoved:
+			entryType = c.MustRemoved().Type
+			if entryType == xdr.LedgerEntryTypeAccount {
+				accountID = c.MustRemoved().MustAccount().AccountId
+			}
+		case xdr.LedgerEntryChangeTypeLedgerEntryUpdated:
+			entryType = c.MustUpdated().Data.Type
+			if entryType == xdr.LedgerEntryTypeAccount {
+				accountID = c.MustUpdated().Data.MustAccount().AccountId
+			}
+		case xdr.LedgerEntryChangeTypeLedgerEntryState:
+			entryType = c.MustState().Data.Type
+			if entryType == xdr.LedgerEntryTypeAccount {
+				accountID = c.MustState().Data.MustAccount().AccountId
+			}
+		default:
+			return nil, errors.Errorf("Unknown change type: %s", c.Type)
+		}
+
+		if entryType == xdr.LedgerEntryTypeAccount {
+			participants = append(participants, accountID)
+		}
+	}
+
+	return participants, nil
+}
+
+// SourceAccount returns the operation's source account or the transaction's
+// source account if the operation doesn't have one.
+func (o LedgerOperation) SourceAccount() xdr.MuxedAccount {
+	if o.Operation.SourceAccount != nil {
+		return *o.Operation.SourceAccount
+	}
+	return o.Transaction.Envelope.SourceAccount()
+}
+
+// Type returns the operation type.
+func (o LedgerOperation) Type() xdr.OperationType {
+	return o.Operation.Body.Type
+}
+
+// Result returns the result of the operation. It returns false if the
+// transaction doesn't contain operation results (ex. it failed before
+// operations were applied).
+func (o LedgerOperation) Result() (xdr.OperationResultTr, bool) {
+	results, ok := o.Transaction.Result.OperationResults()
+	if !ok || int(o.Index) >= len(results) {
+		return xdr.OperationResultTr{}, false
+	}
+	return results[o.Index].GetTr()
+}
+
+// Changes returns the ledger entry changes of the operation.
+func (o LedgerOperation) Changes() ([]Change, error) {
+	return o.Transaction.GetOperationChanges(o.Index)
+}
+
+// PathPaymentResult returns the amounts sent and delivered by a successful
+// path payment (strict send or strict receive). It returns false if the
+// operation is not a path payment or it failed.
+func (o LedgerOperation) PathPaymentResult() (PathPaymentResult, bool) {
+	result, ok := o.Result()
+	if !ok || !o.Transaction.Successful() {
+		return PathPaymentResult{}, false
+	}
+
+	switch o.Type() {
+	case xdr.OperationTypePathPaymentStrictReceive:
+		op := o.Operation.Body.MustPathPaymentStrictReceiveOp()
+		opResult := result.MustPathPaymentStrictReceiveResult()
+		success, ok := opResult.GetSuccess()
+		if !ok {
+			return PathPaymentResult{}, false
+		}
+		return PathPaymentResult{
+			Destination: op.Destination,
+			SendAsset:   op.SendAsset,
+			SendAmount:  opResult.SendAmount(),
+			DestAsset:   op.DestAsset,
+			DestAmount:  op.DestAmount,
+			Offers:      success.Offers,
+		}, true
+	case xdr.OperationTypePathPaymentStrictSend:
+		op := o.Operation.Body.MustPathPaymentStrictSendOp()
+		opResult := result.MustPathPaymentStrictSendResult()
+		success, ok := opResult.GetSuccess()
+		if !ok {
+			return PathPaymentResult{}, false
+		}
+		return PathPaymentResult{
+			Destination: op.Destination,
+			SendAsset:   op.SendAsset,
+			SendAmount:  op.SendAmount,
+			DestAsset:   op.DestAsset,
+			DestAmount:  opResult.DestAmount(),
+			Offers:      success.Offers,
+		}, true
+	default:
+		return PathPaymentResult{}, false
+	}
+}
+
+// ClaimAtoms returns the offers and liquidity pools a successful path payment
+// or offer operation traded with. Claims which didn't exchange any amount are
+// omitted.
+func (o LedgerOperation) ClaimAtoms() []xdr.ClaimAtom {
+	result, ok := o.Result()
+	if !ok || !o.Transaction.Successful() {
+		return nil
+	}
+
+	var claims []xdr.ClaimAtom
+	switch o.Type() {
+	case xdr.OperationTypePathPaymentStrictReceive, xdr.OperationTypePathPaymentStrictSend:
+		pathPayment, _ := o.PathPaymentResult()
+		claims = pathPayment.Offers
+	case xdr.OperationTypeManageSellOffer:
+		claims = result.MustManageSellOfferResult().MustSuccess().OffersClaimed
+	case xdr.OperationTypeManageBuyOffer:
+		claims = result.MustManageBuyOfferResult().MustSuccess().OffersClaimed
+	case xdr.OperationTypeCreatePassiveSellOffer:
+		// KNOWN ISSUE: stellar-core creates results for CreatePassiveOffer
+		// operations with the wrong result arm set.
+		if result.Type == xdr.OperationTypeManageSellOffer {
+			claims = result.MustManageSellOfferResult().MustSuccess().OffersClaimed
+		} else {
+			claims = result.MustCreatePassiveSellOfferResult().MustSuccess().OffersClaimed
+		}
+	}
+
+	var trades []xdr.ClaimAtom
+	for _, claim := range claims {
+		if claim.AmountSold() == 0 && claim.AmountBought() == 0 {
+			continue
+		}
+		trades = append(trades, claim)
+	}
+	return trades
+}
+
+// Sponsor returns the account sponsoring the ledger entry (or signer)
+// created by the operation or nil if it's not sponsored.
+func (o LedgerOperation) Sponsor() (*xdr.AccountId, error) {
+	changes, err := o.Changes()
+	if err != nil {
+		return nil, err
+	}
+	var signerKey string
+	if setOps, ok := o.Operation.Body.GetSetOptionsOp(); ok && setOps.Signer != nil {
+		signerKey = setOps.Signer.Key.Address()
+	}
+
+	for _, c := range changes {
+		// Check Signer changes
+		if signerKey != "" {
+			if sponsorAccount := signerSponsorInChange(signerKey, c); sponsorAccount != nil {
+				return sponsorAccount, nil
+			}
+		}
+
+		// Check Ledger key changes
+		if c.Pre != nil || c.Post == nil {
+			// We are only looking for entry creations denoting that a sponsor
+			// is associated to the ledger entry of the operation.
+			continue
+		}
+		if sponsorAccount := c.Post.SponsoringID(); sponsorAccount != nil {
+			return sponsorAccount, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func signerSponsorInChange(signerKey string, change Change) xdr.SponsorshipDescriptor {
+	if change.Type != xdr.LedgerEntryTypeAccount || change.Post == nil {
+		return nil
+	}
+
+	preSigners := map[string]xdr.AccountId{}
+	if change.Pre != nil {
+		account := change.Pre.Data.MustAccount()
+		preSigners = account.SponsorPerSigner()
+	}
+
+	account := change.Post.Data.MustAccount()
+	postSigners := account.SponsorPerSigner()
+
+	pre, preFound := preSigners[signerKey]
+	post, postFound := postSigners[signerKey]
+
+	if !postFound {
+		return nil
+	}
+
+	if preFound && pre.Address() == post.Address() {
+		return nil
+	}
+
+	return &post
+}
+
+// beginSponsoringOperation returns the BeginSponsoringFutureReserves
+// operation matching an EndSponsoringFutureReserves operation or false if
+// it can't be found.
+func (o LedgerOperation) beginSponsoringOperation() (LedgerOperation, bool) {
+	if !o.Transaction.Successful() {
+		// Failed transactions may not have a compliant sandwich structure
+		// we can rely on (e.g. invalid nesting or a being operation with the
+		// wrong sponsoree ID) and thus we bail out since we could return
+		// incorrect information.
+		return LedgerOperation{}, false
+	}
+	source := o.SourceAccount()
+	sponsoree := source.ToAccountId()
+	operations := o.Transaction.Envelope.Operations()
+	for i := int(o.Index) - 1; i >= 0; i-- {
+		if beginOp, ok := operations[i].Body.GetBeginSponsoringFutureReservesOp(); ok &&
+			beginOp.SponsoredId.Address() == sponsoree.Address() {
+			return LedgerOperation{
+				Index:       uint32(i),
+				Transaction: o.Transaction,
+				Operation:   operations[i],
+			}, true
+		}
+	}
+	return LedgerOperation{}, false
+}
+
+// Participants returns the accounts taking part in the operation. The
+// returned slice is not sorted.
+func (o LedgerOperation) Participants() ([]xdr.AccountId, error) {
+	source := o.SourceAccount()
+	participants := []xdr.AccountId{source.ToAccountId()}
+	op := o.Operation
+
+	switch o.Type() {
+	case xdr.OperationTypeCreateAccount:
+		participants = append(participants, op.Body.MustCreateAccountOp().Destination)
+	case xdr.OperationTypePayment:
+		participants = append(participants, op.Body.MustPaymentOp().Destination.ToAccountId())
+	case xdr.OperationTypePathPaymentStrictReceive:
+		participants = append(participants, op.Body.MustPathPaymentStrictReceiveOp().Destination.ToAccountId())
+	case xdr.OperationTypePathPaymentStrictSend:
+		participants = append(participants, op.Body.MustPathPaymentStrictSendOp().Destination.ToAccountId())
+	case xdr.OperationTypeManageBuyOffer,
+		xdr.OperationTypeManageSellOffer,
+		xdr.OperationTypeCreatePassiveSellOffer,
+		xdr.OperationTypeSetOptions,
+		xdr.OperationTypeChangeTrust,
+		xdr.OperationTypeInflation,
+		xdr.OperationTypeManageData,
+		xdr.OperationTypeBumpSequence,
+		xdr.OperationTypeClaimClaimableBalance,
+		xdr.OperationTypeClawbackClaimableBalance,
+		xdr.OperationTypeLiquidityPoolDeposit,
+		xdr.OperationTypeLiquidityPoolWithdraw:
+		// the only direct participant is the source_account
+	case xdr.OperationTypeAllowTrust:
+		participants = append(participants, op.Body.MustAllowTrustOp().Trustor)
+	case xdr.OperationTypeAccountMerge:
+		participants = append(participants, op.Body.MustDestination().ToAccountId())
+	case xdr.OperationTypeCreateClaimableBalance:
+		for _, c := range op.Body.MustCreateClaimableBalanceOp().Claimants {
+			participants = append(participants, c.MustV0().Destination)
+		}
+	case xdr.OperationTypeBeginSponsoringFutureReserves:
+		participants = append(participants, op.Body.MustBeginSponsoringFutureReservesOp().SponsoredId)
+	case xdr.OperationTypeEndSponsoringFutureReserves:
+		if beginOp, ok := o.beginSponsoringOperation(); ok {
+			beginSource := beginOp.SourceAccount()
+			participants = append(participants, beginSource.ToAccountId())
+		}
+	case xdr.OperationTypeRevokeSponsorship:
+		revokeOp := op.Body.MustRevokeSponsorshipOp()
+		switch revokeOp.Type {
+		case xdr.RevokeSponsorshipTypeRevokeSponsorshipLedgerEntry:
+			participants = append(participants, ledgerKeyParticipants(*revokeOp.LedgerKey)...)
+		case xdr.RevokeSponsorshipTypeRevokeSponsorshipSigner:
+			participants = append(participants, revokeOp.Signer.AccountId)
+			// We don't add signer as a participant because a signer can be
+			// arbitrary account. This can spam successful operations history
+			// of any account.
+		}
+	case xdr.OperationTypeClawback:
+		participants = append(participants, op.Body.MustClawbackOp().From.ToAccountId())
+	case xdr.OperationTypeSetTrustLineFlags:
+		participants = append(participants, op.Body.MustSetTrustLineFlagsOp().Trustor)
+	default:
+		return participants, fmt.Errorf("Unknown operation type: %s", op.Body.Type)
+	}
+
+	sponsor, err := o.Sponsor()
+	if err != nil {
+		return nil, err
+	}
+	if sponsor != nil {
+		participants = append(participants, *sponsor)
+	}
+
+	return dedupeParticipants(participants), nil
+}
+
+func ledgerKeyParticipants(ledgerKey xdr.LedgerKey) []xdr.AccountId {
+	switch ledgerKey.Type {
+	case xdr.LedgerEntryTypeAccount:
+		return []xdr.AccountId{ledgerKey.Account.AccountId}
+	case xdr.LedgerEntryTypeData:
+		return []xdr.AccountId{ledgerKey.Data.AccountId}
+	case xdr.LedgerEntryTypeOffer:
+		return []xdr.AccountId{ledgerKey.Offer.SellerId}
+	case xdr.LedgerEntryTypeTrustline:
+		return []xdr.AccountId{ledgerKey.TrustLine.AccountId}
+	default:
+		return nil
+	}
+}
+
+// dedupeParticipants removes duplicate ids from `in`.
+func dedupeParticipants(in []xdr.AccountId) []xdr.AccountId {
+	set := map[string]bool{}
+	var out []xdr.AccountId
+	for _, id := range in {
+		address := id.Address()
+		if set[address] {
+			continue
+		}
+		set[address] = true
+		out = append(out, id)
+	}
+	return out
+}
+
+// liquidityPoolDelta is the change of reserves and pool shares of a
+// liquidity pool caused by an operation.
+type liquidityPoolDelta struct {
+	ReserveA        xdr.Int64
+	ReserveB        xdr.Int64
+	TotalPoolShares xdr.Int64
+}
+
+var errLiquidityPoolChangeNotFound = errors.New("liquidity pool change not found")
+
+// liquidityPoolAndDelta returns the liquidity pool (lpID or any pool if nil)
+// changed by the operation and the change of its reserves.
+func (o LedgerOperation) liquidityPoolAndDelta(lpID *xdr.PoolId) (*xdr.LiquidityPoolEntry, *liquidityPoolDelta, error) {
+	changes, err := o.Changes()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, c := range changes {
+		if c.Type != xdr.LedgerEntryTypeLiquidityPool {
+			continue
+		}
+		// The delta can be caused by a full removal or full creation of the liquidity pool
+		var lp *xdr.LiquidityPoolEntry
+		var preA, preB, preShares xdr.Int64
+		if c.Pre != nil {
+			if lpID != nil && c.Pre.Data.LiquidityPool.LiquidityPoolId != *lpID {
+				continue
+			}
+			lp = c.Pre.Data.LiquidityPool
+			if lp.Body.Type != xdr.LiquidityPoolTypeLiquidityPoolConstantProduct {
+				return nil, nil, fmt.Errorf("unexpected liquity pool body type %d", lp.Body.Type)
+			}
+			cpPre := lp.Body.ConstantProduct
+			preA, preB, preShares = cpPre.ReserveA, cpPre.ReserveB, cpPre.TotalPoolShares
+		}
+		var postA, postB, postShares xdr.Int64
+		if c.Post != nil {
+			if lpID != nil && c.Post.Data.LiquidityPool.LiquidityPoolId != *lpID {
+				continue
+			}
+			lp = c.Post.Data.LiquidityPool
+			if lp.Body.Type != xdr.LiquidityPoolTypeLiquidityPoolConstantProduct {
+				return nil, nil, fmt.Errorf("unexpected liquity pool body type %d", lp.Body.Type)
+			}
+			cpPost := lp.Body.ConstantProduct
+			postA, postB, postShares = cpPost.ReserveA, cpPost.ReserveB, cpPost.TotalPoolShares
+		}
+		return lp, &liquidityPoolDelta{
+			ReserveA:        postA - preA,
+			ReserveB:        postB - preB,
+			TotalPoolShares: postShares - preShares,
+		}, nil
+	}
+
+	return nil, nil, errLiquidityPoolChangeNotFound
+}
@@ -0,0 +1,174 @@
+package ingest
+
+import (
+	"testing"
+
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testSourceAddress      = "GAHK7EEG2WWHVKDNT4CEQFZGKF2LGDSW2IVM4S5DP42RBW3K6BTODB4A"
+	testDestinationAddress = "GACAR2AEYEKITE2LKI5RMXF5MIVZ6Q7XILROGDT22O7JX4DSWFS7FDDP"
+	testOperationAddress   = "GBRPYHIL2CI3FNQ4BXLFMNDLFJUNPU2HY3ZMFSHONUCEOASW7QC7OX2H"
+	testSellerAddress      = "GC3C4AKRBQLHOJ45U4XG35ESVWRDECWO5XLDGYADO6DPR3L7KIDVUMML"
+)
+
+// testLedgerTransaction returns a successful transaction with the given
+// operations and results submitted by testSourceAddress.
+func testLedgerTransaction(ops []xdr.Operation, results []xdr.OperationResult) LedgerTransaction {
+	return LedgerTransaction{
+		Index: 1,
+		Envelope: xdr.TransactionEnvelope{
+			Type: xdr.EnvelopeTypeEnvelopeTypeTx,
+			V1: &xdr.TransactionV1Envelope{
+				Tx: xdr.Transaction{
+					SourceAccount: xdr.MustMuxedAddress(testSourceAddress),
+					Operations:    ops,
+				},
+			},
+		},
+		Result: xdr.TransactionResultPair{
+			Result: xdr.TransactionResult{
+				Result: xdr.TransactionResultResult{
+					Code:    xdr.TransactionResultCodeTxSuccess,
+					Results: &results,
+				},
+			},
+		},
+		UnsafeMeta: xdr.TransactionMeta{
+			V: 2,
+			V2: &xdr.TransactionMetaV2{
+				Operations: make([]xdr.OperationMeta, len(ops)),
+			},
+		},
+	}
+}
+
+func testPathPaymentStrictSend() (xdr.Operation, xdr.OperationResult) {
+	usd := xdr.MustNewCreditAsset("USD", testSellerAddress)
+	op := xdr.Operation{
+		Body: xdr.OperationBody{
+			Type: xdr.OperationTypePathPaymentStrictSend,
+			PathPaymentStrictSendOp: &xdr.PathPaymentStrictSendOp{
+				SendAsset:   xdr.MustNewNativeAsset(),
+				SendAmount:  100,
+				Destination: xdr.MustMuxedAddress(testDestinationAddress),
+				DestAsset:   usd,
+				DestMin:     10,
+			},
+		},
+	}
+	result := xdr.OperationResult{
+		Code: xdr.OperationResultCodeOpInner,
+		Tr: &xdr.OperationResultTr{
+			Type: xdr.OperationTypePathPaymentStrictSend,
+			PathPaymentStrictSendResult: &xdr.PathPaymentStrictSendResult{
+				Code: xdr.PathPaymentStrictSendResultCodePathPaymentStrictSendSuccess,
+				Success: &xdr.PathPaymentStrictSendResultSuccess{
+					Offers: []xdr.ClaimAtom{
+						{
+							Type: xdr.ClaimAtomTypeClaimAtomTypeOrderBook,
+							OrderBook: &xdr.ClaimOfferAtom{
+								SellerId:     xdr.MustAddress(testSellerAddress),
+								OfferId:      7,
+								AssetSold:    usd,
+								AmountSold:   20,
+								AssetBought:  xdr.MustNewNativeAsset(),
+								AmountBought: 100,
+							},
+						},
+						{
+							Type: xdr.ClaimAtomTypeClaimAtomTypeOrderBook,
+							OrderBook: &xdr.ClaimOfferAtom{
+								SellerId:    xdr.MustAddress(testSellerAddress),
+								OfferId:     8,
+								AssetSold:   usd,
+								AssetBought: xdr.MustNewNativeAsset(),
+							},
+						},
+					},
+					Last: xdr.SimplePaymentResult{
+						Destination: xdr.MustAddress(testDestinationAddress),
+						Asset:       usd,
+						Amount:      20,
+					},
+				},
+			},
+		},
+	}
+	return op, result
+}
+
+func TestLedgerOperationPathPaymentResult(t *testing.T) {
+	op, result := testPathPaymentStrictSend()
+	tx := testLedgerTransaction([]xdr.Operation{op}, []xdr.OperationResult{result})
+
+	operations := tx.Operations()
+	require.Len(t, operations, 1)
+	pathPayment, ok := operations[0].PathPaymentResult()
+	require.True(t, ok)
+	assert.Equal(t, xdr.Int64(100), pathPayment.SendAmount)
+	assert.Equal(t, xdr.Int64(20), pathPayment.DestAmount)
+	assert.True(t, pathPayment.DestAsset.Equals(xdr.MustNewCreditAsset("USD", testSellerAddress)))
+	assert.Len(t, pathPayment.Offers, 2)
+
+	// The claim which didn't exchange anything is omitted
+	claims := operations[0].ClaimAtoms()
+	require.Len(t, claims, 1)
+	assert.Equal(t, xdr.Int64(7), claims[0].OfferId())
+
+	tx.Result.Result.Result.Code = xdr.TransactionResultCodeTxFailed
+	_, ok = operations[0].PathPaymentResult()
+	assert.False(t, ok)
+	assert.Empty(t, operations[0].ClaimAtoms())
+}
+
+func TestLedgerTransactionParticipants(t *testing.T) {
+	operationSource := xdr.MustMuxedAddress(testOperationAddress)
+	ops := []xdr.Operation{
+		{
+			Body: xdr.OperationBody{
+				Type: xdr.OperationTypePayment,
+				PaymentOp: &xdr.PaymentOp{
+					Destination: xdr.MustMuxedAddress(testDestinationAddress),
+					Asset:       xdr.MustNewNativeAsset(),
+					Amount:      10,
+				},
+			},
+		},
+		{
+			SourceAccount: &operationSource,
+			Body: xdr.OperationBody{
+				Type: xdr.OperationTypeBumpSequence,
+				BumpSequenceOp: &xdr.BumpSequenceOp{
+					BumpTo: 100,
+				},
+			},
+		},
+	}
+	tx := testLedgerTransaction(ops, nil)
+
+	operations := tx.Operations()
+	require.Len(t, operations, 2)
+
+	participants, err := operations[0].Participants()
+	require.NoError(t, err)
+	assert.Equal(t, []xdr.AccountId{
+		xdr.MustAddress(testSourceAddress),
+		xdr.MustAddress(testDestinationAddress),
+	}, participants)
+
+	participants, err = operations[1].Participants()
+	require.NoError(t, err)
+	assert.Equal(t, []xdr.AccountId{xdr.MustAddress(testOperationAddress)}, participants)
+
+	participants, err = tx.Participants()
+	require.NoError(t, err)
+	assert.Equal(t, []xdr.AccountId{
+		xdr.MustAddress(testSourceAddress),
+		xdr.MustAddress(testDestinationAddress),
+		xdr.MustAddress(testOperationAddress),
+	}, participants)
+}
@@ -67,16 +67,18 @@ type bufferedLedgerMetaReader struct {
 	r       *bufio.Reader
 	c       chan metaResult
 	decoder *xdr3.Decoder
+	metrics *captiveCoreMetrics
 }
 
 // newBufferedLedgerMetaReader creates a new meta reader that will shutdown
 // when stellar-core terminates.
-func newBufferedLedgerMetaReader(reader io.Reader) *bufferedLedgerMetaReader {
-	r := bufio.NewReaderSize(reader, metaPipeBufferSize)
+func newBufferedLedgerMetaReader(reader io.Reader, metrics *captiveCoreMetrics) *bufferedLedgerMetaReader {
+	r := bufio.NewReaderSize(metrics.metaPipeReader(reader), metaPipeBufferSize)
 	return &bufferedLedgerMetaReader{
 		c:       make(chan metaResult, ledgerReadAheadBufferSize),
 		r:       r,
 		decoder: xdr3.NewDecoder(r),
+		metrics: metrics,
 	}
 }
 
@@ -129,5 +131,6 @@ func (b *bufferedLedgerMetaReader) start() {
 		}
 
 		b.c <- metaResult{meta, nil}
+		b.metrics.ledgerRead(meta.LedgerSequence(), len(b.c))
 	}
 }
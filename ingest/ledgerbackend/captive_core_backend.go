@@ -5,8 +5,10 @@ import (
 	"encoding/hex"
 	"os"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 
 	"github.com/stellar/go/historyarchive"
@@ -85,6 +87,9 @@ type CaptiveStellarCore struct {
 	// For testing
 	stellarCoreRunnerFactory func() stellarCoreRunnerInterface
 
+	// metrics is nil if CaptiveStellarCore was created without NewCaptive.
+	metrics *captiveCoreMetrics
+
 	// cachedMeta keeps that ledger data of the last fetched ledger. Updated in GetLedger().
 	cachedMeta *xdr.LedgerCloseMeta
 
@@ -132,6 +137,13 @@ type CaptiveCoreConfig struct {
 	// of DATABASE parameter in the captive-core-config-path or if absent, the db will default to sqlite
 	// and the db file will be stored at location derived from StoragePath parameter.
 	UseDB bool
+
+	// Registry is an (optional) prometheus registry. When set, metrics of the
+	// Stellar-Core subprocess (meta pipe throughput, time waiting for ledgers,
+	// catchup duration, restarts and read-ahead buffer size) are registered in it.
+	Registry *prometheus.Registry
+	// RegistryNamespace is the namespace of the registered metrics.
+	RegistryNamespace string
 }
 
 // NewCaptive returns a new CaptiveStellarCore instance.
@@ -154,13 +166,14 @@ func NewCaptive(config CaptiveCoreConfig) (*CaptiveStellarCore, error) {
 	var cancel context.CancelFunc
 	config.Context, cancel = context.WithCancel(parentCtx)
 
-	archivePool, err := historyarchive.NewArchivePool(
+	archivePool, err := historyarchive.NewFailoverArchivePool(
 		config.HistoryArchiveURLs,
 		historyarchive.ConnectOptions{
 			NetworkPassphrase:   config.NetworkPassphrase,
 			CheckpointFrequency: config.CheckpointFrequency,
 			Context:             config.Context,
 		},
+		historyarchive.FailoverArchivePoolOptions{},
 	)
 
 	if err != nil {
@@ -169,14 +182,18 @@ func NewCaptive(config CaptiveCoreConfig) (*CaptiveStellarCore, error) {
 	}
 
 	c := &CaptiveStellarCore{
-		archive:           &archivePool,
+		archive:           archivePool,
 		ledgerHashStore:   config.LedgerHashStore,
 		cancel:            cancel,
 		checkpointManager: historyarchive.NewCheckpointManager(config.CheckpointFrequency),
+		metrics:           newCaptiveCoreMetrics(config.RegistryNamespace),
+	}
+	if config.Registry != nil {
+		c.metrics.register(config.Registry)
 	}
 
 	c.stellarCoreRunnerFactory = func() stellarCoreRunnerInterface {
-		return newStellarCoreRunner(config)
+		return newStellarCoreRunner(config, c.metrics)
 	}
 	return c, nil
 }
@@ -333,7 +350,8 @@ func (c *CaptiveStellarCore) startPreparingRange(ctx context.Context, ledgerRang
 		return true, nil
 	}
 
-	if c.stellarCoreRunner != nil {
+	restarting := c.stellarCoreRunner != nil
+	if restarting {
 		if err := c.stellarCoreRunner.close(); err != nil {
 			return false, errors.Wrap(err, "error closing existing session")
 		}
@@ -355,6 +373,9 @@ func (c *CaptiveStellarCore) startPreparingRange(ctx context.Context, ledgerRang
 		return false, errors.Wrap(err, "opening subprocess")
 	}
 
+	if restarting {
+		c.metrics.restarted()
+	}
 	return false, nil
 }
 
@@ -369,6 +390,7 @@ func (c *CaptiveStellarCore) startPreparingRange(ctx context.Context, ledgerRang
 // Please note that using a BoundedRange, currently, requires a full-trust on
 // history archive. This issue is being fixed in Stellar-Core.
 func (c *CaptiveStellarCore) PrepareRange(ctx context.Context, ledgerRange Range) error {
+	startTime := time.Now()
 	if alreadyPrepared, err := c.startPreparingRange(ctx, ledgerRange); err != nil {
 		return errors.Wrap(err, "error starting prepare range")
 	} else if alreadyPrepared {
@@ -380,6 +402,7 @@ func (c *CaptiveStellarCore) PrepareRange(ctx context.Context, ledgerRange Range
 		return errors.Wrapf(err, "Error fast-forwarding to %d", ledgerRange.from)
 	}
 
+	c.metrics.observeCatchup(ledgerRange, time.Since(startTime))
 	return nil
 }
 
@@ -493,10 +516,13 @@ func (c *CaptiveStellarCore) GetLedger(ctx context.Context, sequence uint32) (xd
 
 	// Now loop along the range until we find the ledger we want.
 	for {
+		waitStart := time.Now()
 		select {
 		case <-ctx.Done():
 			return xdr.LedgerCloseMeta{}, ctx.Err()
 		case result, ok := <-c.stellarCoreRunner.getMetaPipe():
+			c.metrics.observeLedgerWait(time.Since(waitStart))
+			c.metrics.setBufferedLedgers(len(c.stellarCoreRunner.getMetaPipe()))
 			found, ledger, err := c.handleMetaPipeResult(sequence, result, ok)
 			if found || err != nil {
 				return ledger, err
@@ -577,6 +603,7 @@ func (c *CaptiveStellarCore) checkMetaPipeResult(result metaResult, ok bool) err
 			return result.err
 		} else if exited, err := c.stellarCoreRunner.getProcessExitError(); exited {
 			// Case 2 - The stellar core process exited unexpectedly
+			c.metrics.exitedUnexpectedly()
 			if err == nil {
 				return errors.Errorf("stellar core exited unexpectedly")
 			} else {
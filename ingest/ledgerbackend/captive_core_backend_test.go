@@ -7,6 +7,8 @@ import (
 	"os"
 	"testing"
 
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -660,6 +662,81 @@ func TestCaptiveGetLedger(t *testing.T) {
 	mockRunner.AssertExpectations(t)
 }
 
+func getMetricValue(metric prometheus.Metric) *dto.Metric {
+	value := &dto.Metric{}
+	err := metric.Write(value)
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
+
+func TestCaptiveMetrics(t *testing.T) {
+	metaChan := make(chan metaResult, 10)
+	for i := 64; i <= 66; i++ {
+		meta := buildLedgerCloseMeta(testLedgerHeader{sequence: uint32(i)})
+		metaChan <- metaResult{
+			LedgerCloseMeta: &meta,
+		}
+	}
+	close(metaChan)
+
+	ctx := context.Background()
+	mockRunner := &stellarCoreRunnerMock{}
+	mockRunner.On("catchup", uint32(65), uint32(66)).Return(nil).Twice()
+	mockRunner.On("getMetaPipe").Return((<-chan metaResult)(metaChan))
+	mockRunner.On("context").Return(ctx)
+	mockRunner.On("getProcessExitError").Return(true, nil)
+	mockRunner.On("close").Return(nil)
+
+	mockArchive := &historyarchive.MockArchive{}
+	mockArchive.
+		On("GetRootHAS").
+		Return(historyarchive.HistoryArchiveState{
+			CurrentLedger: uint32(200),
+		}, nil)
+
+	metrics := newCaptiveCoreMetrics("test")
+	registry := prometheus.NewRegistry()
+	metrics.register(registry)
+
+	captiveBackend := CaptiveStellarCore{
+		archive: mockArchive,
+		stellarCoreRunnerFactory: func() stellarCoreRunnerInterface {
+			return mockRunner
+		},
+		checkpointManager: historyarchive.NewCheckpointManager(64),
+		metrics:           metrics,
+	}
+
+	require.NoError(t, captiveBackend.PrepareRange(ctx, BoundedRange(65, 66)))
+	catchupDuration, err := metrics.catchupDuration.GetMetricWithLabelValues("offline")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), *getMetricValue(catchupDuration.(prometheus.Metric)).Summary.SampleCount)
+	assert.Equal(t, uint64(2), *getMetricValue(metrics.ledgerWaitDuration).Summary.SampleCount)
+	assert.Equal(t, float64(0), *getMetricValue(metrics.restarts).Counter.Value)
+
+	_, err = captiveBackend.GetLedger(ctx, 66)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), *getMetricValue(metrics.ledgerWaitDuration).Summary.SampleCount)
+
+	// Preparing a range again replaces the previous stellar-core instance
+	// which exits without streaming any ledgers.
+	assert.EqualError(t,
+		captiveBackend.PrepareRange(ctx, BoundedRange(65, 66)),
+		"Error fast-forwarding to 65: stellar core exited unexpectedly",
+	)
+	assert.Equal(t, float64(1), *getMetricValue(metrics.restarts).Counter.Value)
+	assert.Equal(t, float64(1), *getMetricValue(metrics.unexpectedExits).Counter.Value)
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	assert.Len(t, families, 8)
+
+	mockArchive.AssertExpectations(t)
+	mockRunner.AssertExpectations(t)
+}
+
 // TestCaptiveGetLedgerCacheLatestLedger test the following case:
 // 1. Prepare Unbounded range.
 // 2. GetLedger that is still not in the buffer.
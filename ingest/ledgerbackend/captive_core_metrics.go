@@ -0,0 +1,140 @@
+package ledgerbackend
+
+import (
+	"io"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// captiveCoreMetrics contains the metrics of CaptiveStellarCore and
+// stellarCoreRunner. All methods are safe to call on a nil receiver so
+// components created without metrics (ex. in tests) don't need to check it.
+type captiveCoreMetrics struct {
+	metaPipeBytes      prometheus.Counter
+	metaPipeLedgers    prometheus.Counter
+	bufferedLedgers    prometheus.Gauge
+	latestLedger       prometheus.Gauge
+	ledgerWaitDuration prometheus.Summary
+	catchupDuration    *prometheus.SummaryVec
+	restarts           prometheus.Counter
+	unexpectedExits    prometheus.Counter
+}
+
+func newCaptiveCoreMetrics(namespace string) *captiveCoreMetrics {
+	return &captiveCoreMetrics{
+		metaPipeBytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "captive_core", Name: "meta_pipe_bytes_total",
+			Help: "number of bytes read from the stellar-core meta pipe",
+		}),
+		metaPipeLedgers: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "captive_core", Name: "meta_pipe_ledgers_total",
+			Help: "number of ledgers read from the stellar-core meta pipe",
+		}),
+		bufferedLedgers: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: "captive_core", Name: "buffered_ledgers",
+			Help: "number of unmarshalled ledgers in the read-ahead buffer",
+		}),
+		latestLedger: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: "captive_core", Name: "latest_ledger",
+			Help: "sequence of the latest ledger streamed by stellar-core",
+		}),
+		ledgerWaitDuration: prometheus.NewSummary(prometheus.SummaryOpts{
+			Namespace: namespace, Subsystem: "captive_core", Name: "ledger_wait_duration_seconds",
+			Help: "time GetLedger waited for the next ledger from stellar-core, sliding window = 10m",
+		}),
+		catchupDuration: prometheus.NewSummaryVec(prometheus.SummaryOpts{
+			Namespace: namespace, Subsystem: "captive_core", Name: "catchup_duration_seconds",
+			Help: "time it took to start stellar-core and stream the first ledger of a prepared range, sliding window = 10m",
+		}, []string{"mode"}),
+		restarts: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "captive_core", Name: "restarts_total",
+			Help: "number of times a running stellar-core subprocess was replaced by a new one",
+		}),
+		unexpectedExits: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "captive_core", Name: "unexpected_exits_total",
+			Help: "number of times the stellar-core subprocess exited unexpectedly",
+		}),
+	}
+}
+
+func (m *captiveCoreMetrics) register(registry *prometheus.Registry) {
+	registry.MustRegister(
+		m.metaPipeBytes,
+		m.metaPipeLedgers,
+		m.bufferedLedgers,
+		m.latestLedger,
+		m.ledgerWaitDuration,
+		m.catchupDuration,
+		m.restarts,
+		m.unexpectedExits,
+	)
+}
+
+// metaPipeReader wraps the given meta pipe reader so the bytes read from it
+// are counted.
+func (m *captiveCoreMetrics) metaPipeReader(r io.Reader) io.Reader {
+	if m == nil {
+		return r
+	}
+	return countingReader{r: r, counter: m.metaPipeBytes}
+}
+
+func (m *captiveCoreMetrics) ledgerRead(sequence uint32, buffered int) {
+	if m == nil {
+		return
+	}
+	m.metaPipeLedgers.Inc()
+	m.latestLedger.Set(float64(sequence))
+	m.bufferedLedgers.Set(float64(buffered))
+}
+
+func (m *captiveCoreMetrics) setBufferedLedgers(buffered int) {
+	if m == nil {
+		return
+	}
+	m.bufferedLedgers.Set(float64(buffered))
+}
+
+func (m *captiveCoreMetrics) observeLedgerWait(d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.ledgerWaitDuration.Observe(d.Seconds())
+}
+
+func (m *captiveCoreMetrics) observeCatchup(ledgerRange Range, d time.Duration) {
+	if m == nil {
+		return
+	}
+	mode := "online"
+	if ledgerRange.bounded {
+		mode = "offline"
+	}
+	m.catchupDuration.With(prometheus.Labels{"mode": mode}).Observe(d.Seconds())
+}
+
+func (m *captiveCoreMetrics) restarted() {
+	if m == nil {
+		return
+	}
+	m.restarts.Inc()
+}
+
+func (m *captiveCoreMetrics) exitedUnexpectedly() {
+	if m == nil {
+		return
+	}
+	m.unexpectedExits.Inc()
+}
+
+type countingReader struct {
+	r       io.Reader
+	counter prometheus.Counter
+}
+
+func (c countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.counter.Add(float64(n))
+	return n, err
+}
@@ -21,10 +21,15 @@ const (
 	upgradeHistoryQuery      = "select ledgerseq, upgradeindex, upgrade, changes from upgradehistory where ledgerseq = ? order by upgradeindex asc"
 	orderBy                  = "order by txindex asc"
 	dbDriver                 = "postgres"
+
+	ledgerHeaderRangeQuery   = "select ledgerhash, data from ledgerheaders where ledgerseq >= ? and ledgerseq <= ? order by ledgerseq asc"
+	txHistoryRangeQuery      = "select txbody, txresult, txmeta, txindex, ledgerseq from txhistory where ledgerseq >= ? and ledgerseq <= ? order by ledgerseq asc, txindex asc"
+	txFeeHistoryRangeQuery   = "select txchanges, txindex, ledgerseq from txfeehistory where ledgerseq >= ? and ledgerseq <= ? order by ledgerseq asc, txindex asc"
+	upgradeHistoryRangeQuery = "select ledgerseq, upgradeindex, upgrade, changes from upgradehistory where ledgerseq >= ? and ledgerseq <= ? order by ledgerseq asc, upgradeindex asc"
 )
 
-// Ensure DatabaseBackend implements LedgerBackend
-var _ LedgerBackend = (*DatabaseBackend)(nil)
+// Ensure DatabaseBackend implements BatchLedgerBackend
+var _ BatchLedgerBackend = (*DatabaseBackend)(nil)
 
 // DatabaseBackend implements a database data store.
 type DatabaseBackend struct {
@@ -167,10 +172,6 @@ func (dbb *DatabaseBackend) getLedgerAfterExist(ctx context.Context, sequence ui
 // getLedgerQuery returns the LedgerCloseMeta for the given ledger sequence number.
 // The first returned value is false when the ledger does not exist in the database.
 func (dbb *DatabaseBackend) getLedgerQuery(ctx context.Context, sequence uint32) (bool, xdr.LedgerCloseMeta, error) {
-	lcm := xdr.LedgerCloseMeta{
-		V0: &xdr.LedgerCloseMetaV0{},
-	}
-
 	// Query - ledgerheader
 	var lRow ledgerHeaderHistory
 
@@ -186,26 +187,163 @@ func (dbb *DatabaseBackend) getLedgerQuery(ctx context.Context, sequence uint32)
 		}
 	}
 
-	// ...otherwise store the header
-	lcm.V0.LedgerHeader = xdr.LedgerHeaderHistoryEntry{
-		Hash:   lRow.Hash,
-		Header: lRow.Header,
-		Ext:    xdr.LedgerHeaderHistoryEntryExt{},
-	}
-
 	// Query - txhistory
 	var txhRows []txHistory
 	err = dbb.session.SelectRaw(ctx, &txhRows, txHistoryQuery+orderBy, sequence)
 	// Return errors...
 	if err != nil {
-		return false, lcm, errors.Wrap(err, "Error getting txHistory")
+		return false, xdr.LedgerCloseMeta{}, errors.Wrap(err, "Error getting txHistory")
+	}
+
+	// Query - txfeehistory
+	var txfhRows []txFeeHistory
+	err = dbb.session.SelectRaw(ctx, &txfhRows, txFeeHistoryQuery+orderBy, sequence)
+	// Return errors...
+	if err != nil {
+		return false, xdr.LedgerCloseMeta{}, errors.Wrap(err, "Error getting txFeeHistory")
 	}
 
-	// ...otherwise store the data
+	// Query - upgradehistory
+	var upgradeHistoryRows []upgradeHistory
+	err = dbb.session.SelectRaw(ctx, &upgradeHistoryRows, upgradeHistoryQuery, sequence)
+	// Return errors...
+	if err != nil {
+		return false, xdr.LedgerCloseMeta{}, errors.Wrap(err, "Error getting upgradeHistoryRows")
+	}
+
+	lcm, err := dbb.buildLedgerCloseMeta(lRow, txhRows, txfhRows, upgradeHistoryRows)
+	if err != nil {
+		return false, xdr.LedgerCloseMeta{}, err
+	}
+	return true, lcm, nil
+}
+
+// GetLedgers returns LedgerCloseMeta for all ledgers in [from, to] range
+// using a single query per stellar-core table. It blocks until the last
+// ledger in the range is available in the database.
+func (dbb *DatabaseBackend) GetLedgers(ctx context.Context, from, to uint32) ([]xdr.LedgerCloseMeta, error) {
+	if from > to {
+		return nil, errors.Errorf("invalid range: [%d, %d]", from, to)
+	}
+
+	for {
+		latest, err := dbb.GetLatestLedgerSequence(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if latest >= to {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+
+	return dbb.getLedgersQuery(ctx, from, to)
+}
+
+// getLedgersQuery returns the LedgerCloseMeta for all ledgers in the given
+// range. It returns an error if any of the ledgers does not exist in the
+// database.
+func (dbb *DatabaseBackend) getLedgersQuery(ctx context.Context, from, to uint32) ([]xdr.LedgerCloseMeta, error) {
+	var lRows []ledgerHeaderHistory
+	if err := dbb.session.SelectRaw(ctx, &lRows, ledgerHeaderRangeQuery, from, to); err != nil {
+		return nil, errors.Wrap(err, "Error getting ledger headers")
+	}
+
+	if uint32(len(lRows)) != to-from+1 {
+		return nil, errors.Errorf(
+			"requested ledgers already removed (found %d ledgers in range [%d, %d])",
+			len(lRows), from, to,
+		)
+	}
+
+	var txhRows []txHistory
+	if err := dbb.session.SelectRaw(ctx, &txhRows, txHistoryRangeQuery, from, to); err != nil {
+		return nil, errors.Wrap(err, "Error getting txHistory")
+	}
+
+	var txfhRows []txFeeHistory
+	if err := dbb.session.SelectRaw(ctx, &txfhRows, txFeeHistoryRangeQuery, from, to); err != nil {
+		return nil, errors.Wrap(err, "Error getting txFeeHistory")
+	}
+
+	var upgradeHistoryRows []upgradeHistory
+	if err := dbb.session.SelectRaw(ctx, &upgradeHistoryRows, upgradeHistoryRangeQuery, from, to); err != nil {
+		return nil, errors.Wrap(err, "Error getting upgradeHistoryRows")
+	}
+
+	// Rows of all tables are sorted by ledger sequence so they can be split
+	// into ledgers by moving forward in every table.
+	ledgers := make([]xdr.LedgerCloseMeta, 0, len(lRows))
+	var txhStart, txfhStart, upgradeStart int
+	for i, lRow := range lRows {
+		sequence := from + uint32(i)
+		if uint32(lRow.Header.LedgerSeq) != sequence {
+			return nil, errors.Errorf(
+				"unexpected ledger sequence (expected=%d actual=%d)",
+				sequence, lRow.Header.LedgerSeq,
+			)
+		}
+
+		txhEnd := txhStart
+		for txhEnd < len(txhRows) && txhRows[txhEnd].LedgerSeq == sequence {
+			txhEnd++
+		}
+		txfhEnd := txfhStart
+		for txfhEnd < len(txfhRows) && txfhRows[txfhEnd].LedgerSeq == sequence {
+			txfhEnd++
+		}
+		upgradeEnd := upgradeStart
+		for upgradeEnd < len(upgradeHistoryRows) && upgradeHistoryRows[upgradeEnd].LedgerSeq == sequence {
+			upgradeEnd++
+		}
+
+		lcm, err := dbb.buildLedgerCloseMeta(
+			lRow,
+			txhRows[txhStart:txhEnd],
+			txfhRows[txfhStart:txfhEnd],
+			upgradeHistoryRows[upgradeStart:upgradeEnd],
+		)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error building ledger %d", sequence)
+		}
+		ledgers = append(ledgers, lcm)
+
+		txhStart, txfhStart, upgradeStart = txhEnd, txfhEnd, upgradeEnd
+	}
+
+	return ledgers, nil
+}
+
+// buildLedgerCloseMeta creates a LedgerCloseMeta from rows of stellar-core
+// tables for a single ledger.
+func (dbb *DatabaseBackend) buildLedgerCloseMeta(
+	lRow ledgerHeaderHistory,
+	txhRows []txHistory,
+	txfhRows []txFeeHistory,
+	upgradeHistoryRows []upgradeHistory,
+) (xdr.LedgerCloseMeta, error) {
+	lcm := xdr.LedgerCloseMeta{
+		V0: &xdr.LedgerCloseMetaV0{},
+	}
+
+	lcm.V0.LedgerHeader = xdr.LedgerHeaderHistoryEntry{
+		Hash:   lRow.Hash,
+		Header: lRow.Header,
+		Ext:    xdr.LedgerHeaderHistoryEntryExt{},
+	}
+	// The transaction set of a ledger is linked to the previous ledger, like
+	// in the meta streamed by Stellar-Core.
+	lcm.V0.TxSet.PreviousLedgerHash = lRow.Header.PreviousLedgerHash
+
 	for i, tx := range txhRows {
 		// Sanity check index. Note that first TXIndex in a ledger is 1
 		if i != int(tx.TXIndex)-1 {
-			return false, xdr.LedgerCloseMeta{}, errors.New("transactions read from DB history table are misordered")
+			return xdr.LedgerCloseMeta{}, errors.New("transactions read from DB history table are misordered")
 		}
 
 		lcm.V0.TxSet.Txs = append(lcm.V0.TxSet.Txs, tx.TXBody)
@@ -215,36 +353,18 @@ func (dbb *DatabaseBackend) getLedgerQuery(ctx context.Context, sequence uint32)
 		})
 	}
 
-	if err = sortByHash(lcm.V0.TxSet.Txs, dbb.networkPassphrase); err != nil {
-		return false, xdr.LedgerCloseMeta{}, errors.Wrap(err, "could not sort txset")
+	if err := sortByHash(lcm.V0.TxSet.Txs, dbb.networkPassphrase); err != nil {
+		return xdr.LedgerCloseMeta{}, errors.Wrap(err, "could not sort txset")
 	}
 
-	// Query - txfeehistory
-	var txfhRows []txFeeHistory
-	err = dbb.session.SelectRaw(ctx, &txfhRows, txFeeHistoryQuery+orderBy, sequence)
-	// Return errors...
-	if err != nil {
-		return false, lcm, errors.Wrap(err, "Error getting txFeeHistory")
-	}
-
-	// ...otherwise store the data
 	for i, tx := range txfhRows {
 		// Sanity check index. Note that first TXIndex in a ledger is 1
 		if i != int(tx.TXIndex)-1 {
-			return false, xdr.LedgerCloseMeta{}, errors.New("transactions read from DB fee history table are misordered")
+			return xdr.LedgerCloseMeta{}, errors.New("transactions read from DB fee history table are misordered")
 		}
 		lcm.V0.TxProcessing[i].FeeProcessing = tx.TXChanges
 	}
 
-	// Query - upgradehistory
-	var upgradeHistoryRows []upgradeHistory
-	err = dbb.session.SelectRaw(ctx, &upgradeHistoryRows, upgradeHistoryQuery, sequence)
-	// Return errors...
-	if err != nil {
-		return false, lcm, errors.Wrap(err, "Error getting upgradeHistoryRows")
-	}
-
-	// ...otherwise store the data
 	lcm.V0.UpgradesProcessing = make([]xdr.UpgradeEntryMeta, len(upgradeHistoryRows))
 	for i, upgradeHistoryRow := range upgradeHistoryRows {
 		lcm.V0.UpgradesProcessing[i] = xdr.UpgradeEntryMeta{
@@ -253,7 +373,7 @@ func (dbb *DatabaseBackend) getLedgerQuery(ctx context.Context, sequence uint32)
 		}
 	}
 
-	return true, lcm, nil
+	return lcm, nil
 }
 
 // CreateSession returns a new db.Session that connects to the given DB settings.
@@ -0,0 +1,179 @@
+package ledgerbackend
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stellar/go/historyarchive"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/xdr"
+)
+
+type mockSession struct {
+	mock.Mock
+}
+
+func (m *mockSession) GetRaw(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	a := m.Called(ctx, dest, query, args)
+	return a.Error(0)
+}
+
+func (m *mockSession) SelectRaw(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	a := m.Called(ctx, dest, query, args)
+	return a.Error(0)
+}
+
+func (m *mockSession) Close() error {
+	return m.Called().Error(0)
+}
+
+func ledgerHeaderRow(sequence uint32) ledgerHeaderHistory {
+	return ledgerHeaderHistory{
+		Hash:   xdr.Hash{byte(sequence)},
+		Header: xdr.LedgerHeader{LedgerSeq: xdr.Uint32(sequence)},
+	}
+}
+
+func TestDatabaseBackendGetLedgers(t *testing.T) {
+	ctx := context.Background()
+	session := &mockSession{}
+	backend := &DatabaseBackend{session: session}
+	newVersion, newBaseFee := xdr.Uint32(18), xdr.Uint32(200)
+
+	session.On("SelectRaw", ctx, mock.Anything, latestLedgerSeqQuery, []interface{}(nil)).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			*args.Get(1).(*[]ledgerHeader) = []ledgerHeader{{LedgerSeq: 10}}
+		}).Once()
+	rangeArgs := []interface{}{uint32(2), uint32(4)}
+	session.On("SelectRaw", ctx, mock.Anything, ledgerHeaderRangeQuery, rangeArgs).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			*args.Get(1).(*[]ledgerHeaderHistory) = []ledgerHeaderHistory{
+				ledgerHeaderRow(2), ledgerHeaderRow(3), ledgerHeaderRow(4),
+			}
+		}).Once()
+	session.On("SelectRaw", ctx, mock.Anything, txHistoryRangeQuery, rangeArgs).Return(nil).Once()
+	session.On("SelectRaw", ctx, mock.Anything, txFeeHistoryRangeQuery, rangeArgs).Return(nil).Once()
+	session.On("SelectRaw", ctx, mock.Anything, upgradeHistoryRangeQuery, rangeArgs).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			*args.Get(1).(*[]upgradeHistory) = []upgradeHistory{
+				{LedgerSeq: 3, UpgradeIndex: 1, Upgrade: xdr.LedgerUpgrade{Type: xdr.LedgerUpgradeTypeLedgerUpgradeVersion, NewLedgerVersion: &newVersion}},
+				{LedgerSeq: 3, UpgradeIndex: 2, Upgrade: xdr.LedgerUpgrade{Type: xdr.LedgerUpgradeTypeLedgerUpgradeBaseFee, NewBaseFee: &newBaseFee}},
+			}
+		}).Once()
+
+	ledgers, err := backend.GetLedgers(ctx, 2, 4)
+	require.NoError(t, err)
+	require.Len(t, ledgers, 3)
+	for i, ledger := range ledgers {
+		assert.Equal(t, uint32(2+i), ledger.LedgerSequence())
+		assert.Equal(t, xdr.Hash{byte(2 + i)}, ledger.LedgerHash())
+	}
+	assert.Empty(t, ledgers[0].V0.UpgradesProcessing)
+	assert.Len(t, ledgers[1].V0.UpgradesProcessing, 2)
+	assert.Empty(t, ledgers[2].V0.UpgradesProcessing)
+	session.AssertExpectations(t)
+}
+
+func TestDatabaseBackendGetLedgersRemoved(t *testing.T) {
+	ctx := context.Background()
+	session := &mockSession{}
+	backend := &DatabaseBackend{session: session}
+
+	session.On("SelectRaw", ctx, mock.Anything, latestLedgerSeqQuery, []interface{}(nil)).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			*args.Get(1).(*[]ledgerHeader) = []ledgerHeader{{LedgerSeq: 10}}
+		}).Once()
+	session.On("SelectRaw", ctx, mock.Anything, ledgerHeaderRangeQuery, []interface{}{uint32(2), uint32(4)}).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			*args.Get(1).(*[]ledgerHeaderHistory) = []ledgerHeaderHistory{ledgerHeaderRow(4)}
+		}).Once()
+
+	_, err := backend.GetLedgers(ctx, 2, 4)
+	assert.EqualError(t, err, "requested ledgers already removed (found 1 ledgers in range [2, 4])")
+	session.AssertExpectations(t)
+}
+
+func TestGetLedgersFallsBackToGetLedger(t *testing.T) {
+	ctx := context.Background()
+	backend := &MockDatabaseBackend{}
+	for sequence := uint32(2); sequence <= 4; sequence++ {
+		backend.On("GetLedger", ctx, sequence).Return(testLedger(sequence), nil).Once()
+	}
+
+	ledgers, err := GetLedgers(ctx, backend, 2, 4)
+	require.NoError(t, err)
+	assert.Equal(t, []xdr.LedgerCloseMeta{testLedger(2), testLedger(3), testLedger(4)}, ledgers)
+	backend.AssertExpectations(t)
+
+	_, err = GetLedgers(ctx, backend, 4, 2)
+	assert.EqualError(t, err, "invalid range: [4, 2]")
+}
+
+func TestDatabaseBackendLedgersPassVerification(t *testing.T) {
+	ctx := context.Background()
+	session := &mockSession{}
+	backend := &DatabaseBackend{session: session, networkPassphrase: network.TestNetworkPassphrase}
+
+	tx := xdr.TransactionEnvelope{
+		Type: xdr.EnvelopeTypeEnvelopeTypeTx,
+		V1: &xdr.TransactionV1Envelope{
+			Tx: xdr.Transaction{
+				SourceAccount: xdr.MustMuxedAddress("GAAZI4TCR3TY5OJHCTJC2A4QSY6CJWJH5IAJTGKIN2ER7LBNVKOCCWN7"),
+				Fee:           100,
+				SeqNum:        1,
+			},
+		},
+	}
+	previousLedgerHash := xdr.Hash{1}
+	txSetHash, err := historyarchive.HashTxSet(&xdr.TransactionSet{
+		PreviousLedgerHash: previousLedgerHash,
+		Txs:                []xdr.TransactionEnvelope{tx},
+	})
+	require.NoError(t, err)
+	header := xdr.LedgerHeader{
+		LedgerSeq:          2,
+		PreviousLedgerHash: previousLedgerHash,
+		ScpValue:           xdr.StellarValue{TxSetHash: xdr.Hash(txSetHash)},
+	}
+	headerHash, err := historyarchive.HashXdr(&header)
+	require.NoError(t, err)
+
+	session.On("SelectRaw", ctx, mock.Anything, latestLedgerSeqQuery, []interface{}(nil)).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			*args.Get(1).(*[]ledgerHeader) = []ledgerHeader{{LedgerSeq: 10}}
+		}).Once()
+	rangeArgs := []interface{}{uint32(2), uint32(2)}
+	session.On("SelectRaw", ctx, mock.Anything, ledgerHeaderRangeQuery, rangeArgs).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			*args.Get(1).(*[]ledgerHeaderHistory) = []ledgerHeaderHistory{
+				{Hash: xdr.Hash(headerHash), Header: header},
+			}
+		}).Once()
+	session.On("SelectRaw", ctx, mock.Anything, txHistoryRangeQuery, rangeArgs).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			*args.Get(1).(*[]txHistory) = []txHistory{
+				{LedgerSeq: 2, TXIndex: 1, TXBody: tx},
+			}
+		}).Once()
+	session.On("SelectRaw", ctx, mock.Anything, txFeeHistoryRangeQuery, rangeArgs).Return(nil).Once()
+	session.On("SelectRaw", ctx, mock.Anything, upgradeHistoryRangeQuery, rangeArgs).Return(nil).Once()
+
+	ledgers, err := backend.GetLedgers(ctx, 2, 2)
+	require.NoError(t, err)
+	require.Len(t, ledgers, 1)
+	assert.Equal(t, previousLedgerHash, ledgers[0].V0.TxSet.PreviousLedgerHash)
+	assert.NoError(t, VerifyLedgerCloseMeta(ledgers[0]))
+	session.AssertExpectations(t)
+}
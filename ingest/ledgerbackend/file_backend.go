@@ -0,0 +1,305 @@
+package ledgerbackend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/stellar/go/historyarchive"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/xdr"
+)
+
+const (
+	// DefaultLedgersPerFile is the default number of ledgers stored in a
+	// single file by LedgerFileWriter. It matches the default checkpoint
+	// frequency so each file holds roughly one checkpoint worth of ledgers.
+	DefaultLedgersPerFile = 64
+
+	ledgerFilesIndexPath = ".well-known/ledger-files.json"
+)
+
+// Ensure FileBackend implements LedgerBackend
+var _ LedgerBackend = (*FileBackend)(nil)
+
+// ledgerFilesIndex describes the ledgers available in a ledger files storage.
+// It is updated by LedgerFileWriter after every file is written.
+type ledgerFilesIndex struct {
+	LedgersPerFile uint32 `json:"ledgersPerFile"`
+	OldestLedger   uint32 `json:"oldestLedger"`
+	LatestLedger   uint32 `json:"latestLedger"`
+}
+
+// LedgerFilePath returns the path of a file containing the given ledger.
+// Files are gzipped streams of framed xdr.LedgerCloseMeta objects, each file
+// containing ledgersPerFile consecutive ledgers (the first and last file can
+// contain less ledgers).
+func LedgerFilePath(sequence, ledgersPerFile uint32) string {
+	start := fileStartLedger(sequence, ledgersPerFile)
+	return path.Join(
+		"ledgers",
+		historyarchive.CheckpointPrefix(start).Path(),
+		fmt.Sprintf("ledgers-%8.8x.xdr.gz", start),
+	)
+}
+
+func fileStartLedger(sequence, ledgersPerFile uint32) uint32 {
+	return sequence - sequence%ledgersPerFile
+}
+
+func readLedgerFilesIndex(storage historyarchive.ArchiveBackend) (ledgerFilesIndex, bool, error) {
+	var index ledgerFilesIndex
+	exists, err := storage.Exists(ledgerFilesIndexPath)
+	if err != nil {
+		return index, false, errors.Wrap(err, "error checking if index exists")
+	}
+	if !exists {
+		return index, false, nil
+	}
+
+	rdr, err := storage.GetFile(ledgerFilesIndexPath)
+	if err != nil {
+		return index, false, errors.Wrap(err, "error opening index")
+	}
+	defer rdr.Close()
+
+	if err = json.NewDecoder(rdr).Decode(&index); err != nil {
+		return index, false, errors.Wrap(err, "error decoding index")
+	}
+	if index.LedgersPerFile == 0 {
+		return index, false, errors.New("invalid index: ledgersPerFile is 0")
+	}
+	return index, true, nil
+}
+
+func readLedgerFile(storage historyarchive.ArchiveBackend, pth string) ([]xdr.LedgerCloseMeta, error) {
+	rdr, err := storage.GetFile(pth)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error opening %s", pth)
+	}
+
+	stream, err := historyarchive.NewXdrGzStream(rdr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error opening gzip stream %s", pth)
+	}
+	defer stream.Close()
+
+	var ledgers []xdr.LedgerCloseMeta
+	for {
+		var ledger xdr.LedgerCloseMeta
+		if err = stream.ReadOne(&ledger); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "error reading from %s", pth)
+		}
+
+		if len(ledgers) > 0 {
+			expected := ledgers[len(ledgers)-1].LedgerSequence() + 1
+			if ledger.LedgerSequence() != expected {
+				return nil, errors.Errorf(
+					"unexpected ledger sequence in %s (expected=%d actual=%d)",
+					pth, expected, ledger.LedgerSequence(),
+				)
+			}
+		}
+		ledgers = append(ledgers, ledger)
+	}
+
+	return ledgers, nil
+}
+
+// FileBackend is a ledger backend that reads ledgers exported to files by
+// LedgerFileWriter. The files can be stored in any storage supported by
+// history archives (local directory, S3, HTTP server), see
+// historyarchive.ConnectBackend.
+//
+// Unlike CaptiveStellarCore, FileBackend does not need to replay ledgers so
+// ledgers can be read in any order and many FileBackend instances can read
+// disjoint ranges in parallel.
+//
+// When an UnboundedRange is prepared GetLedger blocks until the requested ledger
+// is exported, polling the storage every second.
+type FileBackend struct {
+	storage      historyarchive.ArchiveBackend
+	pollInterval time.Duration
+
+	lock     sync.Mutex
+	prepared *Range
+	// cachedLedgers keeps all ledgers from the most recently read file.
+	cachedLedgers []xdr.LedgerCloseMeta
+}
+
+// NewFileBackend returns a new FileBackend reading ledger files from the
+// storage at storageURL (ex. file:///data/ledgers or s3://bucket/ledgers).
+func NewFileBackend(storageURL string, opts historyarchive.ConnectOptions) (*FileBackend, error) {
+	storage, err := historyarchive.ConnectBackend(storageURL, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "error connecting to ledger files storage")
+	}
+	return NewFileBackendFromStorage(storage), nil
+}
+
+// NewFileBackendFromStorage returns a new FileBackend reading ledger files
+// from the given storage.
+func NewFileBackendFromStorage(storage historyarchive.ArchiveBackend) *FileBackend {
+	return &FileBackend{
+		storage:      storage,
+		pollInterval: time.Second,
+	}
+}
+
+// GetLatestLedgerSequence returns the sequence of the latest ledger exported
+// to the storage.
+func (f *FileBackend) GetLatestLedgerSequence(ctx context.Context) (uint32, error) {
+	index, exists, err := readLedgerFilesIndex(f.storage)
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, errors.New("no ledgers exported to storage")
+	}
+	return index.LatestLedger, nil
+}
+
+// PrepareRange checks if the given range is available in the storage. For
+// UnboundedRange it blocks until the first ledger in the range is exported.
+func (f *FileBackend) PrepareRange(ctx context.Context, ledgerRange Range) error {
+	index, exists, err := readLedgerFilesIndex(f.storage)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.New("no ledgers exported to storage")
+	}
+
+	if ledgerRange.from < index.OldestLedger {
+		return errors.Errorf(
+			"from sequence: %d is lower than the oldest exported ledger: %d",
+			ledgerRange.from,
+			index.OldestLedger,
+		)
+	}
+
+	if ledgerRange.bounded && ledgerRange.to > index.LatestLedger {
+		return errors.Errorf(
+			"to sequence: %d is greater than the latest exported ledger: %d",
+			ledgerRange.to,
+			index.LatestLedger,
+		)
+	}
+
+	f.lock.Lock()
+	f.prepared = &ledgerRange
+	f.lock.Unlock()
+
+	if _, err := f.GetLedger(ctx, ledgerRange.from); err != nil {
+		return errors.Wrapf(err, "error getting ledger %d", ledgerRange.from)
+	}
+	return nil
+}
+
+// IsPrepared returns true if a given ledgerRange is prepared.
+func (f *FileBackend) IsPrepared(ctx context.Context, ledgerRange Range) (bool, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.prepared != nil && f.prepared.Contains(ledgerRange), nil
+}
+
+// GetLedger returns the LedgerCloseMeta for the given sequence. It blocks until
+// the ledger is exported to the storage.
+func (f *FileBackend) GetLedger(ctx context.Context, sequence uint32) (xdr.LedgerCloseMeta, error) {
+	if ledger, ok, err := f.checkCachedLedger(sequence); err != nil || ok {
+		return ledger, err
+	}
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	// The lock is not held while polling the storage so other methods (ex.
+	// Close) don't block until the ledger is exported.
+	for {
+		select {
+		case <-ctx.Done():
+			return xdr.LedgerCloseMeta{}, ctx.Err()
+		case <-timer.C:
+		}
+
+		index, exists, err := readLedgerFilesIndex(f.storage)
+		if err != nil {
+			return xdr.LedgerCloseMeta{}, err
+		}
+
+		if exists && sequence < index.OldestLedger {
+			return xdr.LedgerCloseMeta{}, errors.Errorf(
+				"requested ledger %d is older than the oldest exported ledger %d",
+				sequence,
+				index.OldestLedger,
+			)
+		}
+
+		if exists && sequence <= index.LatestLedger {
+			pth := LedgerFilePath(sequence, index.LedgersPerFile)
+			ledgers, err := readLedgerFile(f.storage, pth)
+			if err != nil {
+				return xdr.LedgerCloseMeta{}, err
+			}
+
+			f.lock.Lock()
+			f.cachedLedgers = ledgers
+			f.lock.Unlock()
+
+			ledger, ok, err := f.checkCachedLedger(sequence)
+			if err != nil || ok {
+				return ledger, err
+			}
+			return xdr.LedgerCloseMeta{}, errors.Errorf("ledger %d not found in %s", sequence, pth)
+		}
+
+		timer.Reset(f.pollInterval)
+	}
+}
+
+// checkCachedLedger checks if the sequence is in the prepared range and
+// returns the ledger if it's cached.
+func (f *FileBackend) checkCachedLedger(sequence uint32) (xdr.LedgerCloseMeta, bool, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.prepared != nil && f.prepared.bounded && sequence > f.prepared.to {
+		return xdr.LedgerCloseMeta{}, false, errors.Errorf(
+			"reading past bounded range (requested sequence=%d, last ledger in range=%d)",
+			sequence,
+			f.prepared.to,
+		)
+	}
+
+	ledger, ok := f.cachedLedger(sequence)
+	return ledger, ok, nil
+}
+
+func (f *FileBackend) cachedLedger(sequence uint32) (xdr.LedgerCloseMeta, bool) {
+	if len(f.cachedLedgers) == 0 {
+		return xdr.LedgerCloseMeta{}, false
+	}
+
+	first := f.cachedLedgers[0].LedgerSequence()
+	if sequence < first || sequence-first >= uint32(len(f.cachedLedgers)) {
+		return xdr.LedgerCloseMeta{}, false
+	}
+	return f.cachedLedgers[sequence-first], true
+}
+
+// Close releases the cached ledgers.
+func (f *FileBackend) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.prepared = nil
+	f.cachedLedgers = nil
+	return nil
+}
@@ -0,0 +1,212 @@
+package ledgerbackend
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stellar/go/historyarchive"
+	"github.com/stellar/go/xdr"
+)
+
+func testLedger(sequence uint32) xdr.LedgerCloseMeta {
+	return xdr.LedgerCloseMeta{
+		V0: &xdr.LedgerCloseMetaV0{
+			LedgerHeader: xdr.LedgerHeaderHistoryEntry{
+				Header: xdr.LedgerHeader{
+					LedgerSeq: xdr.Uint32(sequence),
+				},
+			},
+		},
+	}
+}
+
+func newMockStorage(t *testing.T) historyarchive.ArchiveBackend {
+	storage, err := historyarchive.ConnectBackend("mock://test", historyarchive.ConnectOptions{})
+	require.NoError(t, err)
+	return storage
+}
+
+func writeTestLedgers(t *testing.T, writer *LedgerFileWriter, from, to uint32) {
+	for sequence := from; sequence <= to; sequence++ {
+		require.NoError(t, writer.Write(testLedger(sequence)))
+	}
+}
+
+func TestLedgerFilePath(t *testing.T) {
+	assert.Equal(t, "ledgers/00/00/00/ledgers-00000000.xdr.gz", LedgerFilePath(2, 64))
+	assert.Equal(t, "ledgers/00/00/00/ledgers-00000040.xdr.gz", LedgerFilePath(64, 64))
+	assert.Equal(t, "ledgers/00/00/00/ledgers-00000040.xdr.gz", LedgerFilePath(127, 64))
+	assert.Equal(t, "ledgers/00/00/01/ledgers-00000100.xdr.gz", LedgerFilePath(300, 256))
+}
+
+func TestFileBackendReadsWrittenLedgers(t *testing.T) {
+	ctx := context.Background()
+	storage := newMockStorage(t)
+
+	writer, err := NewLedgerFileWriter(storage, 10)
+	require.NoError(t, err)
+	writeTestLedgers(t, writer, 5, 34)
+	require.NoError(t, writer.Close())
+
+	backend := NewFileBackendFromStorage(storage)
+	latest, err := backend.GetLatestLedgerSequence(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(34), latest)
+
+	require.NoError(t, backend.PrepareRange(ctx, BoundedRange(5, 34)))
+	prepared, err := backend.IsPrepared(ctx, BoundedRange(10, 20))
+	require.NoError(t, err)
+	assert.True(t, prepared)
+
+	for sequence := uint32(5); sequence <= 34; sequence++ {
+		ledger, err := backend.GetLedger(ctx, sequence)
+		require.NoError(t, err)
+		assert.Equal(t, sequence, ledger.LedgerSequence())
+	}
+
+	// Files are random access
+	ledger, err := backend.GetLedger(ctx, 7)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(7), ledger.LedgerSequence())
+
+	_, err = backend.GetLedger(ctx, 35)
+	assert.EqualError(t, err, "reading past bounded range (requested sequence=35, last ledger in range=34)")
+}
+
+func TestFileBackendPrepareRangeOutsideExported(t *testing.T) {
+	ctx := context.Background()
+	storage := newMockStorage(t)
+	backend := NewFileBackendFromStorage(storage)
+
+	assert.EqualError(t, backend.PrepareRange(ctx, BoundedRange(5, 10)), "no ledgers exported to storage")
+
+	writer, err := NewLedgerFileWriter(storage, 10)
+	require.NoError(t, err)
+	writeTestLedgers(t, writer, 5, 14)
+	require.NoError(t, writer.Close())
+
+	assert.EqualError(t,
+		backend.PrepareRange(ctx, BoundedRange(4, 10)),
+		"from sequence: 4 is lower than the oldest exported ledger: 5",
+	)
+	assert.EqualError(t,
+		backend.PrepareRange(ctx, BoundedRange(5, 15)),
+		"to sequence: 15 is greater than the latest exported ledger: 14",
+	)
+}
+
+func TestLedgerFileWriterResumes(t *testing.T) {
+	ctx := context.Background()
+	storage := newMockStorage(t)
+
+	writer, err := NewLedgerFileWriter(storage, 10)
+	require.NoError(t, err)
+	writeTestLedgers(t, writer, 2, 14)
+	require.NoError(t, writer.Close())
+
+	_, err = NewLedgerFileWriter(storage, 20)
+	assert.EqualError(t, err, "storage contains files with 10 ledgers per file, requested 20")
+
+	writer, err = NewLedgerFileWriter(storage, 10)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(14), writer.LatestLedger())
+	assert.EqualError(t,
+		writer.Write(testLedger(16)),
+		"unexpected ledger sequence (expected=15 actual=16)",
+	)
+	writeTestLedgers(t, writer, 15, 25)
+	require.NoError(t, writer.Close())
+
+	backend := NewFileBackendFromStorage(storage)
+	for sequence := uint32(2); sequence <= 25; sequence++ {
+		ledger, err := backend.GetLedger(ctx, sequence)
+		require.NoError(t, err)
+		assert.Equal(t, sequence, ledger.LedgerSequence())
+	}
+}
+
+func TestFileBackendUnboundedWaitsForLedgers(t *testing.T) {
+	ctx := context.Background()
+	storage := newMockStorage(t)
+
+	writer, err := NewLedgerFileWriter(storage, 10)
+	require.NoError(t, err)
+	writeTestLedgers(t, writer, 2, 5)
+	require.NoError(t, writer.Flush())
+
+	backend := NewFileBackendFromStorage(storage)
+	backend.pollInterval = time.Millisecond
+	require.NoError(t, backend.PrepareRange(ctx, UnboundedRange(3)))
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err = backend.GetLedger(timeoutCtx, 6)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	writeTestLedgers(t, writer, 6, 6)
+	require.NoError(t, writer.Flush())
+
+	ledger, err := backend.GetLedger(ctx, 6)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(6), ledger.LedgerSequence())
+}
+
+func TestFileBackendWaitingDoesNotBlock(t *testing.T) {
+	ctx := context.Background()
+	storage := newMockStorage(t)
+
+	writer, err := NewLedgerFileWriter(storage, 10)
+	require.NoError(t, err)
+	writeTestLedgers(t, writer, 2, 5)
+	require.NoError(t, writer.Flush())
+
+	backend := NewFileBackendFromStorage(storage)
+	backend.pollInterval = time.Millisecond
+	require.NoError(t, backend.PrepareRange(ctx, UnboundedRange(3)))
+
+	waitCtx, cancel := context.WithCancel(ctx)
+	done := make(chan error)
+	go func() {
+		_, err := backend.GetLedger(waitCtx, 6)
+		done <- err
+	}()
+
+	// GetLedger is waiting for ledger 6, other methods must not block.
+	time.Sleep(10 * time.Millisecond)
+	prepared, err := backend.IsPrepared(ctx, UnboundedRange(3))
+	require.NoError(t, err)
+	assert.True(t, prepared)
+	latest, err := backend.GetLatestLedgerSequence(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(5), latest)
+	require.NoError(t, backend.Close())
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+}
+
+func TestExportLedgers(t *testing.T) {
+	ctx := context.Background()
+	storage := newMockStorage(t)
+
+	source := &MockDatabaseBackend{}
+	source.On("PrepareRange", ctx, BoundedRange(2, 20)).Return(nil).Once()
+	for sequence := uint32(2); sequence <= 20; sequence++ {
+		source.On("GetLedger", ctx, sequence).Return(testLedger(sequence), nil).Once()
+	}
+
+	writer, err := NewLedgerFileWriter(storage, DefaultLedgersPerFile)
+	require.NoError(t, err)
+	require.NoError(t, ExportLedgers(ctx, source, writer, BoundedRange(2, 20)))
+	require.NoError(t, writer.Close())
+	source.AssertExpectations(t)
+
+	backend := NewFileBackendFromStorage(storage)
+	latest, err := backend.GetLatestLedgerSequence(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(20), latest)
+}
@@ -0,0 +1,183 @@
+package ledgerbackend
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/stellar/go/historyarchive"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/xdr"
+)
+
+// LedgerFileWriter exports ledgers to files that can be read by FileBackend.
+// Ledgers must be written in order, without gaps. A file is written to the
+// storage every time it's complete (contains ledgersPerFile ledgers) and when
+// the writer is closed.
+//
+// If the storage already contains exported ledgers, the writer continues
+// after the latest exported ledger.
+//
+// LedgerFileWriter is not thread-safe.
+type LedgerFileWriter struct {
+	storage historyarchive.ArchiveBackend
+	index   ledgerFilesIndex
+	// ledgers contains ledgers of the current (not complete) file.
+	ledgers []xdr.LedgerCloseMeta
+	// dirty is true when ledgers contain ledgers not written to the storage.
+	dirty bool
+}
+
+// NewLedgerFileWriter creates a new LedgerFileWriter writing to the given
+// storage. ledgersPerFile must match the value used when the storage was
+// initially written.
+func NewLedgerFileWriter(storage historyarchive.ArchiveBackend, ledgersPerFile uint32) (*LedgerFileWriter, error) {
+	if ledgersPerFile == 0 {
+		return nil, errors.New("ledgersPerFile must be positive")
+	}
+
+	index, exists, err := readLedgerFilesIndex(storage)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &LedgerFileWriter{
+		storage: storage,
+		index:   ledgerFilesIndex{LedgersPerFile: ledgersPerFile},
+	}
+	if !exists {
+		return w, nil
+	}
+
+	if index.LedgersPerFile != ledgersPerFile {
+		return nil, errors.Errorf(
+			"storage contains files with %d ledgers per file, requested %d",
+			index.LedgersPerFile,
+			ledgersPerFile,
+		)
+	}
+	w.index = index
+
+	// Load the last file if it's not complete so new ledgers are appended to it.
+	if !w.isLastInFile(index.LatestLedger) {
+		w.ledgers, err = readLedgerFile(storage, LedgerFilePath(index.LatestLedger, ledgersPerFile))
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading latest ledger file")
+		}
+	}
+
+	return w, nil
+}
+
+func (w *LedgerFileWriter) isLastInFile(sequence uint32) bool {
+	return (sequence+1)%w.index.LedgersPerFile == 0
+}
+
+// LatestLedger returns the sequence of the latest written ledger or 0 if no
+// ledgers were written yet.
+func (w *LedgerFileWriter) LatestLedger() uint32 {
+	return w.index.LatestLedger
+}
+
+// Write adds a ledger to the current file. The file is written to the storage
+// when it's complete.
+func (w *LedgerFileWriter) Write(ledger xdr.LedgerCloseMeta) error {
+	sequence := ledger.LedgerSequence()
+	if w.index.LatestLedger != 0 && sequence != w.index.LatestLedger+1 {
+		return errors.Errorf(
+			"unexpected ledger sequence (expected=%d actual=%d)",
+			w.index.LatestLedger+1,
+			sequence,
+		)
+	}
+
+	if w.index.OldestLedger == 0 {
+		w.index.OldestLedger = sequence
+	}
+	w.index.LatestLedger = sequence
+	w.ledgers = append(w.ledgers, ledger)
+	w.dirty = true
+
+	if w.isLastInFile(sequence) {
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		w.ledgers = nil
+	}
+	return nil
+}
+
+// Flush writes the current file (even if it's not complete) and updates the
+// index so the written ledgers are visible to readers.
+func (w *LedgerFileWriter) Flush() error {
+	if !w.dirty {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buf)
+	for _, ledger := range w.ledgers {
+		if err := xdr.MarshalFramed(gzipWriter, ledger); err != nil {
+			return errors.Wrapf(err, "error marshaling ledger %d", ledger.LedgerSequence())
+		}
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return errors.Wrap(err, "error closing gzip writer")
+	}
+
+	pth := LedgerFilePath(w.ledgers[0].LedgerSequence(), w.index.LedgersPerFile)
+	if err := w.storage.PutFile(pth, ioutil.NopCloser(&buf)); err != nil {
+		return errors.Wrapf(err, "error writing %s", pth)
+	}
+
+	// The index is updated after the file so readers never see ledgers that
+	// are not in the storage yet.
+	indexBytes, err := json.MarshalIndent(w.index, "", "    ")
+	if err != nil {
+		return errors.Wrap(err, "error marshaling index")
+	}
+	if err := w.storage.PutFile(ledgerFilesIndexPath, ioutil.NopCloser(bytes.NewReader(indexBytes))); err != nil {
+		return errors.Wrap(err, "error writing index")
+	}
+
+	w.dirty = false
+	return nil
+}
+
+// Close writes the current file to the storage.
+func (w *LedgerFileWriter) Close() error {
+	return w.Flush()
+}
+
+// ExportLedgers reads ledgers in the given range from backend (ex.
+// CaptiveStellarCore) and writes them using writer. For UnboundedRange it
+// runs until ctx is cancelled. The writer is not closed when ExportLedgers
+// returns.
+func ExportLedgers(ctx context.Context, backend LedgerBackend, writer *LedgerFileWriter, ledgerRange Range) error {
+	if err := backend.PrepareRange(ctx, ledgerRange); err != nil {
+		return errors.Wrapf(err, "error preparing range %s", ledgerRange)
+	}
+
+	for sequence := ledgerRange.from; !ledgerRange.bounded || sequence <= ledgerRange.to; sequence++ {
+		ledger, err := backend.GetLedger(ctx, sequence)
+		if err != nil {
+			return errors.Wrapf(err, "error getting ledger %d", sequence)
+		}
+
+		if err = writer.Write(ledger); err != nil {
+			return errors.Wrapf(err, "error writing ledger %d", sequence)
+		}
+
+		if !ledgerRange.bounded {
+			// In online mode ledgers close every few seconds so make them
+			// available to readers as soon as possible.
+			if err = writer.Flush(); err != nil {
+				return errors.Wrapf(err, "error flushing ledger %d", sequence)
+			}
+		}
+	}
+
+	return nil
+}
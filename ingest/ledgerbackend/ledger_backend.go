@@ -3,6 +3,7 @@ package ledgerbackend
 import (
 	"context"
 
+	"github.com/stellar/go/support/errors"
 	"github.com/stellar/go/xdr"
 )
 
@@ -22,6 +23,43 @@ type LedgerBackend interface {
 	Close() error
 }
 
+// BatchLedgerBackend is a LedgerBackend which is able to fetch a range of
+// ledgers in a single call, which is much faster than calling GetLedger for
+// each ledger when the backend requires a round trip per call.
+type BatchLedgerBackend interface {
+	LedgerBackend
+	// GetLedgers returns all ledgers in the range [from, to] in order. It
+	// will block until all the ledgers are available.
+	GetLedgers(ctx context.Context, from, to uint32) ([]xdr.LedgerCloseMeta, error)
+}
+
+// GetLedgers returns all ledgers in the range [from, to] from the given
+// backend. If the backend implements BatchLedgerBackend the ledgers are
+// fetched using a single GetLedgers call, otherwise GetLedger is called for
+// every ledger in the range.
+func GetLedgers(ctx context.Context, backend LedgerBackend, from, to uint32) ([]xdr.LedgerCloseMeta, error) {
+	if from > to {
+		return nil, errors.Errorf("invalid range: [%d, %d]", from, to)
+	}
+
+	if batchBackend, ok := backend.(BatchLedgerBackend); ok {
+		return batchBackend.GetLedgers(ctx, from, to)
+	}
+
+	ledgers := make([]xdr.LedgerCloseMeta, 0, to-from+1)
+	for sequence := from; ; sequence++ {
+		ledger, err := backend.GetLedger(ctx, sequence)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error getting ledger %d", sequence)
+		}
+		ledgers = append(ledgers, ledger)
+		if sequence == to {
+			break
+		}
+	}
+	return ledgers, nil
+}
+
 // session is the interface needed to access a persistent database session.
 // TODO can't use this until we add Close() to the existing db.Session object
 type session interface {
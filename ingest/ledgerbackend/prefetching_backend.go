@@ -0,0 +1,357 @@
+package ledgerbackend
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/support/log"
+	"github.com/stellar/go/xdr"
+)
+
+// Ensure PrefetchingBackend implements LedgerBackend
+var _ LedgerBackend = (*PrefetchingBackend)(nil)
+
+const (
+	defaultPrefetchBufferSize = 20
+	defaultPrefetchRetryWait  = time.Second
+)
+
+// PrefetchingBackendConfig contains the parameters of a PrefetchingBackend.
+type PrefetchingBackendConfig struct {
+	// BufferSize is the maximum number of ledgers fetched ahead of the
+	// consumer. If unset, 20 ledgers are buffered.
+	BufferSize uint32
+	// NumWorkers is the number of go routines fetching ledgers from the
+	// wrapped backend. If unset, a single worker is used. Set it to more than
+	// 1 only if the wrapped backend supports concurrent GetLedger calls for
+	// any sequence (like DatabaseBackend or FileBackend). Backends streaming
+	// ledgers (like CaptiveStellarCore) require a single worker.
+	NumWorkers uint32
+	// RetryLimit is the number of times a failed GetLedger call on the
+	// wrapped backend is retried before the error is returned to the consumer.
+	RetryLimit uint32
+	// RetryWait is the time to wait between retries. If unset, 1 second is used.
+	RetryWait time.Duration
+
+	// Registry is an (optional) prometheus registry. When set, buffer metrics
+	// are registered in it.
+	Registry *prometheus.Registry
+	// RegistryNamespace is the namespace of the registered metrics.
+	RegistryNamespace string
+}
+
+type ledgerResult struct {
+	ledger xdr.LedgerCloseMeta
+	err    error
+}
+
+type prefetchTask struct {
+	sequence uint32
+	result   chan ledgerResult
+}
+
+// prefetchSession represents prefetching of a single prepared range.
+type prefetchSession struct {
+	ledgerRange Range
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+	// tasks are sent to queue in order of sequence numbers so the consumer
+	// receives ledgers in order even when fetched by many workers. The size of
+	// the queue channel limits the number of ledgers fetched ahead.
+	queue chan prefetchTask
+	// pending is a task taken from the queue but not consumed yet (because
+	// the GetLedger context was cancelled).
+	pending    *prefetchTask
+	nextLedger uint32
+}
+
+// PrefetchingBackend wraps a LedgerBackend and fetches ledgers ahead of the
+// consumer after a range is prepared. This hides the latency of backends
+// that block on every GetLedger call (like RemoteCaptiveStellarCore) while
+// the consumer is processing previous ledgers. Transient GetLedger errors are
+// retried.
+//
+// Ledgers are prefetched in order starting from the beginning of the prepared
+// range (until the end of the range for BoundedRange). Requesting a ledger
+// before the prefetched stream (or when no range is prepared) calls the
+// wrapped backend directly.
+//
+// PrefetchingBackend is not thread-safe and should not be accessed by multiple
+// go routines.
+type PrefetchingBackend struct {
+	backend LedgerBackend
+	config  PrefetchingBackendConfig
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	lock       sync.Mutex
+	session    *prefetchSession
+	cachedMeta *xdr.LedgerCloseMeta
+
+	bufferedLedgers prometheus.Gauge
+	retries         prometheus.Counter
+	waitDuration    prometheus.Summary
+}
+
+// NewPrefetchingBackend returns a new PrefetchingBackend wrapping the given
+// backend.
+func NewPrefetchingBackend(backend LedgerBackend, config PrefetchingBackendConfig) (*PrefetchingBackend, error) {
+	if config.BufferSize == 0 {
+		config.BufferSize = defaultPrefetchBufferSize
+	}
+	if config.NumWorkers == 0 {
+		config.NumWorkers = 1
+	}
+	if config.NumWorkers > config.BufferSize {
+		return nil, errors.New("NumWorkers cannot be greater than BufferSize")
+	}
+	if config.RetryWait == 0 {
+		config.RetryWait = defaultPrefetchRetryWait
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &PrefetchingBackend{
+		backend: backend,
+		config:  config,
+		ctx:     ctx,
+		cancel:  cancel,
+		bufferedLedgers: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: config.RegistryNamespace, Subsystem: "ledger_backend", Name: "prefetch_buffered_ledgers",
+			Help: "number of ledgers fetched ahead of the consumer",
+		}),
+		retries: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: config.RegistryNamespace, Subsystem: "ledger_backend", Name: "prefetch_retries_total",
+			Help: "number of retried GetLedger calls",
+		}),
+		waitDuration: prometheus.NewSummary(prometheus.SummaryOpts{
+			Namespace: config.RegistryNamespace, Subsystem: "ledger_backend", Name: "prefetch_wait_duration_seconds",
+			Help: "time the consumer waited for the next ledger, sliding window = 10m",
+		}),
+	}
+
+	if config.Registry != nil {
+		config.Registry.MustRegister(b.bufferedLedgers, b.retries, b.waitDuration)
+	}
+	return b, nil
+}
+
+// GetLatestLedgerSequence returns the sequence of the latest ledger available
+// in the wrapped backend.
+func (b *PrefetchingBackend) GetLatestLedgerSequence(ctx context.Context) (uint32, error) {
+	return b.backend.GetLatestLedgerSequence(ctx)
+}
+
+// PrepareRange prepares the given range in the wrapped backend and starts
+// prefetching ledgers from it.
+func (b *PrefetchingBackend) PrepareRange(ctx context.Context, ledgerRange Range) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.ctx.Err() != nil {
+		return errors.New("backend is closed")
+	}
+
+	if b.isPrepared(ledgerRange) {
+		return nil
+	}
+
+	b.stopSession()
+	if err := b.backend.PrepareRange(ctx, ledgerRange); err != nil {
+		return errors.Wrap(err, "error preparing range in the wrapped backend")
+	}
+	b.startSession(ledgerRange)
+	return nil
+}
+
+// IsPrepared returns true if a given ledgerRange is prepared.
+func (b *PrefetchingBackend) IsPrepared(ctx context.Context, ledgerRange Range) (bool, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.isPrepared(ledgerRange), nil
+}
+
+func (b *PrefetchingBackend) isPrepared(ledgerRange Range) bool {
+	if b.session == nil || !b.session.ledgerRange.Contains(ledgerRange) {
+		return false
+	}
+
+	cachedLedger := uint32(0)
+	if b.cachedMeta != nil {
+		cachedLedger = b.cachedMeta.LedgerSequence()
+	}
+	return b.session.nextLedger <= ledgerRange.from || cachedLedger == ledgerRange.from
+}
+
+func (b *PrefetchingBackend) startSession(ledgerRange Range) {
+	ctx, cancel := context.WithCancel(b.ctx)
+	s := &prefetchSession{
+		ledgerRange: ledgerRange,
+		cancel:      cancel,
+		queue:       make(chan prefetchTask, b.config.BufferSize-b.config.NumWorkers),
+		nextLedger:  ledgerRange.from,
+	}
+
+	tasks := make(chan prefetchTask)
+	s.wg.Add(1)
+	go b.dispatch(ctx, s, tasks)
+	for i := uint32(0); i < b.config.NumWorkers; i++ {
+		s.wg.Add(1)
+		go b.work(ctx, s, tasks)
+	}
+	b.session = s
+}
+
+func (b *PrefetchingBackend) stopSession() {
+	if b.session == nil {
+		return
+	}
+	b.session.cancel()
+	b.session.wg.Wait()
+	b.session = nil
+	b.bufferedLedgers.Set(0)
+}
+
+// dispatch creates tasks for consecutive ledgers in the session range. It
+// blocks when the queue is full.
+func (b *PrefetchingBackend) dispatch(ctx context.Context, s *prefetchSession, tasks chan<- prefetchTask) {
+	defer s.wg.Done()
+	defer close(tasks)
+	defer close(s.queue)
+
+	for sequence := s.ledgerRange.from; !s.ledgerRange.bounded || sequence <= s.ledgerRange.to; sequence++ {
+		task := prefetchTask{sequence: sequence, result: make(chan ledgerResult, 1)}
+		select {
+		case <-ctx.Done():
+			return
+		case tasks <- task:
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case s.queue <- task:
+		}
+
+		if sequence == ^uint32(0) {
+			return
+		}
+	}
+}
+
+func (b *PrefetchingBackend) work(ctx context.Context, s *prefetchSession, tasks <-chan prefetchTask) {
+	defer s.wg.Done()
+
+	for task := range tasks {
+		ledger, err := b.getLedgerWithRetries(ctx, task.sequence)
+		// result channel is buffered so this never blocks.
+		task.result <- ledgerResult{ledger: ledger, err: err}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (b *PrefetchingBackend) getLedgerWithRetries(ctx context.Context, sequence uint32) (xdr.LedgerCloseMeta, error) {
+	for attempt := uint32(0); ; attempt++ {
+		ledger, err := b.backend.GetLedger(ctx, sequence)
+		if err == nil {
+			return ledger, nil
+		}
+		if ctx.Err() != nil || attempt >= b.config.RetryLimit {
+			return xdr.LedgerCloseMeta{}, err
+		}
+
+		log.WithField("sequence", sequence).WithError(err).Warn("Error getting ledger, retrying...")
+		b.retries.Inc()
+
+		select {
+		case <-ctx.Done():
+			return xdr.LedgerCloseMeta{}, ctx.Err()
+		case <-time.After(b.config.RetryWait):
+		}
+	}
+}
+
+// GetLedger returns the given ledger. When the ledger is in the prefetched
+// stream it's returned from the buffer (blocking until it's fetched). Ledgers
+// in the stream before the requested sequence are discarded.
+func (b *PrefetchingBackend) GetLedger(ctx context.Context, sequence uint32) (xdr.LedgerCloseMeta, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.cachedMeta != nil && sequence == b.cachedMeta.LedgerSequence() {
+		return *b.cachedMeta, nil
+	}
+
+	s := b.session
+	if s == nil || sequence < s.nextLedger {
+		return b.getLedgerWithRetries(ctx, sequence)
+	}
+
+	if s.ledgerRange.bounded && sequence > s.ledgerRange.to {
+		return xdr.LedgerCloseMeta{}, errors.Errorf(
+			"reading past bounded range (requested sequence=%d, last ledger in range=%d)",
+			sequence,
+			s.ledgerRange.to,
+		)
+	}
+
+	startTime := time.Now()
+	defer func() {
+		b.waitDuration.Observe(time.Since(startTime).Seconds())
+	}()
+
+	for {
+		if s.pending == nil {
+			select {
+			case <-ctx.Done():
+				return xdr.LedgerCloseMeta{}, ctx.Err()
+			case task, ok := <-s.queue:
+				if !ok {
+					return xdr.LedgerCloseMeta{}, errors.New("prefetching stopped, call PrepareRange first")
+				}
+				s.pending = &task
+			}
+		}
+
+		var result ledgerResult
+		select {
+		case <-ctx.Done():
+			return xdr.LedgerCloseMeta{}, ctx.Err()
+		case result = <-s.pending.result:
+		}
+
+		task := s.pending
+		s.pending = nil
+		b.bufferedLedgers.Set(float64(len(s.queue)))
+
+		if result.err != nil {
+			// Workers exit on error so a new session must be prepared.
+			b.stopSession()
+			return xdr.LedgerCloseMeta{}, errors.Wrapf(result.err, "error getting ledger %d", task.sequence)
+		}
+
+		s.nextLedger = task.sequence + 1
+		if task.sequence == sequence {
+			b.cachedMeta = &result.ledger
+			return result.ledger, nil
+		}
+	}
+}
+
+// Close stops prefetching and closes the wrapped backend.
+func (b *PrefetchingBackend) Close() error {
+	b.cancel()
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.stopSession()
+	return b.backend.Close()
+}
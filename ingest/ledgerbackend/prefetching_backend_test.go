@@ -0,0 +1,142 @@
+package ledgerbackend
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stellar/go/support/errors"
+)
+
+func TestPrefetchingBackendBoundedRange(t *testing.T) {
+	ctx := context.Background()
+	wrapped := &MockDatabaseBackend{}
+	wrapped.On("PrepareRange", ctx, BoundedRange(2, 50)).Return(nil).Once()
+	for sequence := uint32(2); sequence <= 50; sequence++ {
+		wrapped.On("GetLedger", mock.Anything, sequence).Return(testLedger(sequence), nil).Once()
+	}
+	wrapped.On("Close").Return(nil).Once()
+
+	registry := prometheus.NewRegistry()
+	backend, err := NewPrefetchingBackend(wrapped, PrefetchingBackendConfig{
+		BufferSize: 10,
+		NumWorkers: 4,
+		Registry:   registry,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, backend.PrepareRange(ctx, BoundedRange(2, 50)))
+	prepared, err := backend.IsPrepared(ctx, BoundedRange(2, 50))
+	require.NoError(t, err)
+	assert.True(t, prepared)
+
+	for sequence := uint32(2); sequence <= 50; sequence++ {
+		ledger, err := backend.GetLedger(ctx, sequence)
+		require.NoError(t, err)
+		assert.Equal(t, sequence, ledger.LedgerSequence())
+	}
+
+	// The last ledger is cached
+	ledger, err := backend.GetLedger(ctx, 50)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(50), ledger.LedgerSequence())
+
+	_, err = backend.GetLedger(ctx, 51)
+	assert.EqualError(t, err, "reading past bounded range (requested sequence=51, last ledger in range=50)")
+
+	require.NoError(t, backend.Close())
+	wrapped.AssertExpectations(t)
+
+	metrics, err := registry.Gather()
+	require.NoError(t, err)
+	assert.Len(t, metrics, 3)
+}
+
+func TestPrefetchingBackendSkipsLedgers(t *testing.T) {
+	ctx := context.Background()
+	wrapped := &MockDatabaseBackend{}
+	wrapped.On("PrepareRange", ctx, UnboundedRange(2)).Return(nil).Once()
+	for sequence := uint32(2); sequence <= 20; sequence++ {
+		wrapped.On("GetLedger", mock.Anything, sequence).Return(testLedger(sequence), nil).Maybe()
+	}
+	wrapped.On("Close").Return(nil).Once()
+
+	backend, err := NewPrefetchingBackend(wrapped, PrefetchingBackendConfig{BufferSize: 5})
+	require.NoError(t, err)
+	require.NoError(t, backend.PrepareRange(ctx, UnboundedRange(2)))
+
+	ledger, err := backend.GetLedger(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(10), ledger.LedgerSequence())
+
+	// Ledgers before the prefetched stream are fetched from the wrapped backend
+	ledger, err = backend.GetLedger(ctx, 3)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(3), ledger.LedgerSequence())
+
+	ledger, err = backend.GetLedger(ctx, 11)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(11), ledger.LedgerSequence())
+
+	require.NoError(t, backend.Close())
+}
+
+func TestPrefetchingBackendRetries(t *testing.T) {
+	ctx := context.Background()
+	wrapped := &MockDatabaseBackend{}
+	wrapped.On("PrepareRange", ctx, BoundedRange(2, 3)).Return(nil).Once()
+	wrapped.On("GetLedger", mock.Anything, uint32(2)).Return(testLedger(2), errors.New("transient error")).Twice()
+	wrapped.On("GetLedger", mock.Anything, uint32(2)).Return(testLedger(2), nil).Once()
+	wrapped.On("GetLedger", mock.Anything, uint32(3)).Return(testLedger(3), nil).Once()
+
+	backend, err := NewPrefetchingBackend(wrapped, PrefetchingBackendConfig{
+		BufferSize: 1,
+		RetryLimit: 2,
+		RetryWait:  time.Millisecond,
+	})
+	require.NoError(t, err)
+	require.NoError(t, backend.PrepareRange(ctx, BoundedRange(2, 3)))
+
+	for sequence := uint32(2); sequence <= 3; sequence++ {
+		ledger, err := backend.GetLedger(ctx, sequence)
+		require.NoError(t, err)
+		assert.Equal(t, sequence, ledger.LedgerSequence())
+	}
+	wrapped.AssertExpectations(t)
+}
+
+func TestPrefetchingBackendReturnsErrorAfterRetries(t *testing.T) {
+	ctx := context.Background()
+	wrapped := &MockDatabaseBackend{}
+	wrapped.On("PrepareRange", ctx, BoundedRange(2, 3)).Return(nil).Once()
+	wrapped.On("GetLedger", mock.Anything, uint32(2)).Return(testLedger(2), errors.New("transient error")).Twice()
+
+	backend, err := NewPrefetchingBackend(wrapped, PrefetchingBackendConfig{
+		BufferSize: 1,
+		RetryLimit: 1,
+		RetryWait:  time.Millisecond,
+	})
+	require.NoError(t, err)
+	require.NoError(t, backend.PrepareRange(ctx, BoundedRange(2, 3)))
+
+	_, err = backend.GetLedger(ctx, 2)
+	assert.EqualError(t, err, "error getting ledger 2: transient error")
+
+	prepared, err := backend.IsPrepared(ctx, BoundedRange(2, 3))
+	require.NoError(t, err)
+	assert.False(t, prepared)
+	wrapped.AssertExpectations(t)
+}
+
+func TestPrefetchingBackendInvalidConfig(t *testing.T) {
+	_, err := NewPrefetchingBackend(&MockDatabaseBackend{}, PrefetchingBackendConfig{
+		BufferSize: 2,
+		NumWorkers: 3,
+	})
+	assert.EqualError(t, err, "NumWorkers cannot be greater than BufferSize")
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
@@ -34,6 +35,19 @@ type LedgerResponse struct {
 	Ledger Base64Ledger `json:"ledger"`
 }
 
+// LedgersResponse is the response for the GetLedgers command.
+type LedgersResponse struct {
+	Ledgers []Base64Ledger `json:"ledgers"`
+}
+
+// StreamLedgerResponse is a single message sent by the ledger stream command.
+// Every message contains either the next ledger or an error which terminates
+// the stream.
+type StreamLedgerResponse struct {
+	Ledger *Base64Ledger `json:"ledger,omitempty"`
+	Error  string        `json:"error,omitempty"`
+}
+
 // Base64Ledger extends xdr.LedgerCloseMeta with JSON encoding and decoding
 type Base64Ledger xdr.LedgerCloseMeta
 
@@ -60,6 +74,9 @@ func (r Base64Ledger) MarshalJSON() ([]byte, error) {
 	return json.Marshal(base64)
 }
 
+// Ensure RemoteCaptiveStellarCore implements BatchLedgerBackend
+var _ BatchLedgerBackend = RemoteCaptiveStellarCore{}
+
 // RemoteCaptiveStellarCore is an http client for interacting with a remote captive core server.
 type RemoteCaptiveStellarCore struct {
 	url                      *url.URL
@@ -260,3 +277,132 @@ func (c RemoteCaptiveStellarCore) GetLedger(ctx context.Context, sequence uint32
 		return xdr.LedgerCloseMeta(parsed.Ledger), nil
 	}
 }
+
+// StreamLedgers streams consecutive ledgers starting from the given sequence
+// in a single request and calls f for every ledger. It returns when f returns
+// an error, the context is cancelled or the stream ends (after the last ledger
+// of a bounded range or when the server returns an error).
+//
+// Call PrepareRange first to instruct the backend which ledgers to stream.
+func (c RemoteCaptiveStellarCore) StreamLedgers(ctx context.Context, from uint32, f func(xdr.LedgerCloseMeta) error) error {
+	u := *c.url
+	u.Path = path.Join(u.Path, "stream", strconv.FormatUint(uint64(from), 10))
+	request, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return errors.Wrap(err, "cannot construct http request")
+	}
+
+	// The stream is open as long as the client is reading ledgers so the
+	// request timeout of the client can't be used.
+	client := *c.client
+	client.Timeout = 0
+	response, err := client.Do(request)
+	if err != nil {
+		return errors.Wrap(err, "failed to execute request")
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		body, err := ioutil.ReadAll(response.Body)
+		if err != nil {
+			return errors.Wrap(err, "failed to read response body")
+		}
+		return errors.New(string(body))
+	}
+
+	decoder := json.NewDecoder(response.Body)
+	for {
+		var message StreamLedgerResponse
+		if err := decoder.Decode(&message); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return errors.Wrap(err, "failed to decode json payload")
+		}
+
+		if message.Error != "" {
+			return errors.New(message.Error)
+		}
+		if message.Ledger == nil {
+			return errors.New("stream message does not contain a ledger")
+		}
+		if err := f(xdr.LedgerCloseMeta(*message.Ledger)); err != nil {
+			return err
+		}
+	}
+}
+
+// GetLedgers long-polls a remote stellar core backend, until all ledgers in
+// the range [from, to] are ready, and returns them in a single response.
+//
+// The same restrictions as in GetLedger apply: PrepareRange must be called
+// first and ledgers must be requested in a non-decreasing order.
+//
+// Servers which don't support the batch endpoint (responding with 404 or 405)
+// are queried for every ledger separately using GetLedger.
+func (c RemoteCaptiveStellarCore) GetLedgers(ctx context.Context, from, to uint32) ([]xdr.LedgerCloseMeta, error) {
+	if from > to {
+		return nil, errors.Errorf("invalid range: [%d, %d]", from, to)
+	}
+
+	for {
+		u := *c.url
+		u.Path = path.Join(
+			u.Path,
+			"ledgers",
+			strconv.FormatUint(uint64(from), 10),
+			strconv.FormatUint(uint64(to), 10),
+		)
+		request, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot construct http request")
+		}
+
+		response, err := c.client.Do(request)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to execute request")
+		}
+
+		if response.StatusCode == http.StatusRequestTimeout {
+			response.Body.Close()
+			// This request timed out. Retry.
+			continue
+		}
+
+		if response.StatusCode == http.StatusNotFound ||
+			response.StatusCode == http.StatusMethodNotAllowed {
+			response.Body.Close()
+			return c.getLedgersOneByOne(ctx, from, to)
+		}
+
+		var parsed LedgersResponse
+		if err = decodeResponse(response, &parsed); err != nil {
+			return nil, err
+		}
+
+		if uint32(len(parsed.Ledgers)) != to-from+1 {
+			return nil, errors.Errorf(
+				"unexpected number of ledgers in response (expected=%d actual=%d)",
+				to-from+1,
+				len(parsed.Ledgers),
+			)
+		}
+
+		ledgers := make([]xdr.LedgerCloseMeta, len(parsed.Ledgers))
+		for i, ledger := range parsed.Ledgers {
+			ledgers[i] = xdr.LedgerCloseMeta(ledger)
+		}
+		return ledgers, nil
+	}
+}
+
+func (c RemoteCaptiveStellarCore) getLedgersOneByOne(ctx context.Context, from, to uint32) ([]xdr.LedgerCloseMeta, error) {
+	ledgers := make([]xdr.LedgerCloseMeta, 0, to-from+1)
+	for sequence := from; sequence <= to; sequence++ {
+		ledger, err := c.GetLedger(ctx, sequence)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error getting ledger %d", sequence)
+		}
+		ledgers = append(ledgers, ledger)
+	}
+	return ledgers, nil
+}
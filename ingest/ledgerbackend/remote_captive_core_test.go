@@ -5,6 +5,9 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"path"
+	"strconv"
+	"strings"
 	"sync/atomic"
 	"testing"
 
@@ -81,3 +84,91 @@ func TestGetLedgerTakesAWhile(t *testing.T) {
 	require.Equal(t, expectedLedger, ledger)
 	require.Equal(t, int64(0), atomic.LoadInt64(&encodeFailed))
 }
+
+func TestGetLedgersSucceeds(t *testing.T) {
+	var requestedPath string
+	var encodeFailed int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedPath = r.URL.Path
+		if nil != json.NewEncoder(w).Encode(LedgersResponse{
+			Ledgers: []Base64Ledger{
+				Base64Ledger(testLedger(64)),
+				Base64Ledger(testLedger(65)),
+			},
+		}) {
+			atomic.AddInt64(&encodeFailed, 1)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewRemoteCaptive(server.URL)
+	require.NoError(t, err)
+
+	ledgers, err := client.GetLedgers(context.Background(), 64, 65)
+	require.NoError(t, err)
+	require.Equal(t, "/ledgers/64/65", requestedPath)
+	require.Equal(t, []xdr.LedgerCloseMeta{testLedger(64), testLedger(65)}, ledgers)
+	require.Equal(t, int64(0), atomic.LoadInt64(&encodeFailed))
+
+	_, err = client.GetLedgers(context.Background(), 64, 66)
+	require.EqualError(t, err, "unexpected number of ledgers in response (expected=3 actual=2)")
+}
+
+func TestRemoteCaptiveCoreGetLedgersFallsBackToGetLedger(t *testing.T) {
+	var requestedPaths []string
+	var encodeFailed int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedPaths = append(requestedPaths, r.URL.Path)
+		// Servers without the batch endpoint
+		if strings.HasPrefix(r.URL.Path, "/ledgers/") {
+			http.NotFound(w, r)
+			return
+		}
+		sequence, err := strconv.ParseUint(path.Base(r.URL.Path), 10, 32)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if nil != json.NewEncoder(w).Encode(LedgerResponse{
+			Ledger: Base64Ledger(testLedger(uint32(sequence))),
+		}) {
+			atomic.AddInt64(&encodeFailed, 1)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewRemoteCaptive(server.URL)
+	require.NoError(t, err)
+
+	ledgers, err := client.GetLedgers(context.Background(), 64, 65)
+	require.NoError(t, err)
+	require.Equal(t, []string{"/ledgers/64/65", "/ledger/64", "/ledger/65"}, requestedPaths)
+	require.Equal(t, []xdr.LedgerCloseMeta{testLedger(64), testLedger(65)}, ledgers)
+	require.Equal(t, int64(0), atomic.LoadInt64(&encodeFailed))
+}
+
+func TestStreamLedgers(t *testing.T) {
+	var requestedPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedPath = r.URL.Path
+		encoder := json.NewEncoder(w)
+		for _, sequence := range []uint32{64, 65} {
+			ledger := Base64Ledger(testLedger(sequence))
+			require.NoError(t, encoder.Encode(StreamLedgerResponse{Ledger: &ledger}))
+		}
+		require.NoError(t, encoder.Encode(StreamLedgerResponse{Error: "stellar-core exited"}))
+	}))
+	defer server.Close()
+
+	client, err := NewRemoteCaptive(server.URL)
+	require.NoError(t, err)
+
+	var ledgers []xdr.LedgerCloseMeta
+	err = client.StreamLedgers(context.Background(), 64, func(ledger xdr.LedgerCloseMeta) error {
+		ledgers = append(ledgers, ledger)
+		return nil
+	})
+	require.EqualError(t, err, "stellar-core exited")
+	require.Equal(t, "/stream/64", requestedPath)
+	require.Equal(t, []xdr.LedgerCloseMeta{testLedger(64), testLedger(65)}, ledgers)
+}
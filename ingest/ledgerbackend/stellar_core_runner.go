@@ -73,7 +73,8 @@ type stellarCoreRunner struct {
 	useDB       bool
 	nonce       string
 
-	log *log.Entry
+	log     *log.Entry
+	metrics *captiveCoreMetrics
 }
 
 func createRandomHexString(n int) string {
@@ -85,7 +86,7 @@ func createRandomHexString(n int) string {
 	return string(b)
 }
 
-func newStellarCoreRunner(config CaptiveCoreConfig) *stellarCoreRunner {
+func newStellarCoreRunner(config CaptiveCoreConfig, metrics *captiveCoreMetrics) *stellarCoreRunner {
 	ctx, cancel := context.WithCancel(config.Context)
 
 	runner := &stellarCoreRunner{
@@ -98,8 +99,9 @@ func newStellarCoreRunner(config CaptiveCoreConfig) *stellarCoreRunner {
 			"captive-stellar-core-%x",
 			rand.New(rand.NewSource(time.Now().UnixNano())).Uint64(),
 		),
-		log:  config.Log,
-		toml: config.Toml,
+		log:     config.Log,
+		toml:    config.Toml,
+		metrics: metrics,
 
 		systemCaller: realSystemCaller{},
 	}
@@ -330,7 +332,7 @@ func (r *stellarCoreRunner) catchup(from, to uint32) error {
 	}
 
 	r.started = true
-	r.ledgerBuffer = newBufferedLedgerMetaReader(r.pipe.Reader)
+	r.ledgerBuffer = newBufferedLedgerMetaReader(r.pipe.Reader, r.metrics)
 	go r.ledgerBuffer.start()
 
 	if binaryWatcher, err := newFileWatcher(r); err != nil {
@@ -436,7 +438,7 @@ func (r *stellarCoreRunner) runFrom(from uint32, hash string) error {
 	}
 
 	r.started = true
-	r.ledgerBuffer = newBufferedLedgerMetaReader(r.pipe.Reader)
+	r.ledgerBuffer = newBufferedLedgerMetaReader(r.pipe.Reader, r.metrics)
 	go r.ledgerBuffer.start()
 
 	if binaryWatcher, err := newFileWatcher(r); err != nil {
@@ -25,7 +25,7 @@ func TestCloseOffline(t *testing.T) {
 		Context:            context.Background(),
 		Toml:               captiveCoreToml,
 		StoragePath:        "/tmp/captive-core",
-	})
+	}, nil)
 
 	cmdMock := simpleCommandMock()
 	cmdMock.On("Wait").Return(nil)
@@ -66,7 +66,7 @@ func TestCloseOnline(t *testing.T) {
 		Context:            context.Background(),
 		Toml:               captiveCoreToml,
 		StoragePath:        "/tmp/captive-core",
-	})
+	}, nil)
 
 	cmdMock := simpleCommandMock()
 	cmdMock.On("Wait").Return(nil)
@@ -109,7 +109,7 @@ func TestCloseOnlineWithError(t *testing.T) {
 		Context:            context.Background(),
 		Toml:               captiveCoreToml,
 		StoragePath:        "/tmp/captive-core",
-	})
+	}, nil)
 
 	cmdMock := simpleCommandMock()
 	cmdMock.On("Wait").Return(errors.New("wait error"))
@@ -163,7 +163,7 @@ func TestRunFromUseDBLedgersMatch(t *testing.T) {
 		Toml:               captiveCoreToml,
 		StoragePath:        "/tmp/captive-core",
 		UseDB:              true,
-	})
+	}, nil)
 
 	cmdMock := simpleCommandMock()
 	cmdMock.On("Wait").Return(nil)
@@ -217,7 +217,7 @@ func TestRunFromUseDBLedgersBehind(t *testing.T) {
 		Toml:               captiveCoreToml,
 		StoragePath:        "/tmp/captive-core",
 		UseDB:              true,
-	})
+	}, nil)
 
 	newDBCmdMock := simpleCommandMock()
 	newDBCmdMock.On("Run").Return(nil)
@@ -277,7 +277,7 @@ func TestRunFromUseDBLedgersInFront(t *testing.T) {
 		Toml:               captiveCoreToml,
 		StoragePath:        "/tmp/captive-core",
 		UseDB:              true,
-	})
+	}, nil)
 
 	newDBCmdMock := simpleCommandMock()
 	newDBCmdMock.On("Run").Return(nil)
@@ -0,0 +1,216 @@
+package ledgerbackend
+
+import (
+	"context"
+	"sync"
+
+	"github.com/stellar/go/historyarchive"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/xdr"
+)
+
+// Ensure VerifyingBackend implements BatchLedgerBackend
+var _ BatchLedgerBackend = (*VerifyingBackend)(nil)
+
+// VerifyingBackendConfig contains the parameters of a VerifyingBackend.
+type VerifyingBackendConfig struct {
+	// Archive is an (optional) history archive. When set, the hashes of
+	// checkpoint ledgers are compared with the ledger headers in the archive.
+	Archive historyarchive.ArchiveInterface
+	// CheckpointFrequency is the number of ledgers between checkpoints
+	// if unset, DefaultCheckpointFrequency will be used
+	CheckpointFrequency uint32
+}
+
+// VerifyingBackend wraps a LedgerBackend and verifies every ledger returned
+// by it:
+//   - the ledger hash must match the hash of the ledger header,
+//   - the transaction set hash must match the hash in the ledger header,
+//   - the previous ledger hash must match the hash of the previously returned
+//     ledger (when ledgers are requested in order),
+//   - (optionally) the hash of checkpoint ledgers must match the ledger
+//     header in the history archive.
+//
+// When a ledger doesn't pass the verification GetLedger returns an error.
+type VerifyingBackend struct {
+	backend           LedgerBackend
+	archive           historyarchive.ArchiveInterface
+	checkpointManager historyarchive.CheckpointManager
+
+	lock sync.Mutex
+	// lastSequence and lastHash describe the last verified ledger.
+	lastSequence uint32
+	lastHash     xdr.Hash
+}
+
+// NewVerifyingBackend returns a new VerifyingBackend wrapping the given
+// backend.
+func NewVerifyingBackend(backend LedgerBackend, config VerifyingBackendConfig) *VerifyingBackend {
+	return &VerifyingBackend{
+		backend:           backend,
+		archive:           config.Archive,
+		checkpointManager: historyarchive.NewCheckpointManager(config.CheckpointFrequency),
+	}
+}
+
+// VerifyLedgerCloseMeta checks if the hash of the ledger matches the hash of
+// its header and if the hash of the transaction set matches the hash in the
+// header.
+func VerifyLedgerCloseMeta(ledger xdr.LedgerCloseMeta) error {
+	var header xdr.LedgerHeaderHistoryEntry
+	var txSetHash historyarchive.Hash
+	var err error
+	switch ledger.V {
+	case 0:
+		header = ledger.V0.LedgerHeader
+		// HashTxSet sorts transactions so a copy is used to not modify the
+		// ledger. The previous ledger hash is taken from the header because
+		// not every backend sets it in the transaction set.
+		txSet := xdr.TransactionSet{
+			PreviousLedgerHash: header.Header.PreviousLedgerHash,
+			Txs:                append([]xdr.TransactionEnvelope(nil), ledger.V0.TxSet.Txs...),
+		}
+		txSetHash, err = historyarchive.HashTxSet(&txSet)
+	case 1:
+		header = ledger.V1.LedgerHeader
+		txSetHash, err = historyarchive.HashXdr(&ledger.V1.TxSet)
+	default:
+		return errors.Errorf("unsupported LedgerCloseMeta version: %d", ledger.V)
+	}
+	if err != nil {
+		return errors.Wrap(err, "error hashing transaction set")
+	}
+
+	sequence := uint32(header.Header.LedgerSeq)
+	headerHash, err := historyarchive.HashXdr(&header.Header)
+	if err != nil {
+		return errors.Wrap(err, "error hashing ledger header")
+	}
+	if xdr.Hash(headerHash) != header.Hash {
+		return errors.Errorf(
+			"invalid hash of ledger %d (expected=%s actual=%s)",
+			sequence,
+			header.Hash.HexString(),
+			xdr.Hash(headerHash).HexString(),
+		)
+	}
+
+	if xdr.Hash(txSetHash) != header.Header.ScpValue.TxSetHash {
+		return errors.Errorf(
+			"invalid transaction set hash of ledger %d (expected=%s actual=%s)",
+			sequence,
+			header.Header.ScpValue.TxSetHash.HexString(),
+			xdr.Hash(txSetHash).HexString(),
+		)
+	}
+	return nil
+}
+
+func (b *VerifyingBackend) verify(sequence uint32, ledger xdr.LedgerCloseMeta) error {
+	if ledger.LedgerSequence() != sequence {
+		return errors.Errorf(
+			"unexpected ledger sequence (expected=%d actual=%d)",
+			sequence,
+			ledger.LedgerSequence(),
+		)
+	}
+
+	if err := VerifyLedgerCloseMeta(ledger); err != nil {
+		return err
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	switch {
+	case b.lastSequence != 0 && sequence == b.lastSequence+1:
+		if ledger.PreviousLedgerHash() != b.lastHash {
+			return errors.Errorf(
+				"unexpected previous ledger hash for ledger %d (expected=%s actual=%s)",
+				sequence,
+				b.lastHash.HexString(),
+				ledger.PreviousLedgerHash().HexString(),
+			)
+		}
+	case b.lastSequence != 0 && sequence == b.lastSequence:
+		if ledger.LedgerHash() != b.lastHash {
+			return errors.Errorf(
+				"ledger %d changed (previous hash=%s current hash=%s)",
+				sequence,
+				b.lastHash.HexString(),
+				ledger.LedgerHash().HexString(),
+			)
+		}
+		return nil
+	}
+
+	if b.archive != nil && b.checkpointManager.IsCheckpoint(sequence) {
+		header, err := b.archive.GetLedgerHeader(sequence)
+		if err != nil {
+			return errors.Wrapf(err, "error getting ledger header %d from history archive", sequence)
+		}
+		if header.Hash != ledger.LedgerHash() {
+			return errors.Errorf(
+				"hash of ledger %d does not match history archive (expected=%s actual=%s)",
+				sequence,
+				header.Hash.HexString(),
+				ledger.LedgerHash().HexString(),
+			)
+		}
+	}
+
+	b.lastSequence = sequence
+	b.lastHash = ledger.LedgerHash()
+	return nil
+}
+
+// GetLatestLedgerSequence returns the sequence of the latest ledger available
+// in the wrapped backend.
+func (b *VerifyingBackend) GetLatestLedgerSequence(ctx context.Context) (uint32, error) {
+	return b.backend.GetLatestLedgerSequence(ctx)
+}
+
+// PrepareRange prepares the given range in the wrapped backend.
+func (b *VerifyingBackend) PrepareRange(ctx context.Context, ledgerRange Range) error {
+	return b.backend.PrepareRange(ctx, ledgerRange)
+}
+
+// IsPrepared returns true if a given ledgerRange is prepared in the wrapped
+// backend.
+func (b *VerifyingBackend) IsPrepared(ctx context.Context, ledgerRange Range) (bool, error) {
+	return b.backend.IsPrepared(ctx, ledgerRange)
+}
+
+// GetLedger returns the given ledger from the wrapped backend after verifying
+// it.
+func (b *VerifyingBackend) GetLedger(ctx context.Context, sequence uint32) (xdr.LedgerCloseMeta, error) {
+	ledger, err := b.backend.GetLedger(ctx, sequence)
+	if err != nil {
+		return xdr.LedgerCloseMeta{}, err
+	}
+	if err = b.verify(sequence, ledger); err != nil {
+		return xdr.LedgerCloseMeta{}, errors.Wrap(err, "ledger verification failed")
+	}
+	return ledger, nil
+}
+
+// GetLedgers returns all ledgers in the range [from, to] from the wrapped
+// backend (in a single call if it's a BatchLedgerBackend) after verifying
+// them.
+func (b *VerifyingBackend) GetLedgers(ctx context.Context, from, to uint32) ([]xdr.LedgerCloseMeta, error) {
+	ledgers, err := GetLedgers(ctx, b.backend, from, to)
+	if err != nil {
+		return nil, err
+	}
+	for i, ledger := range ledgers {
+		if err = b.verify(from+uint32(i), ledger); err != nil {
+			return nil, errors.Wrap(err, "ledger verification failed")
+		}
+	}
+	return ledgers, nil
+}
+
+// Close closes the wrapped backend.
+func (b *VerifyingBackend) Close() error {
+	return b.backend.Close()
+}
@@ -0,0 +1,170 @@
+package ledgerbackend
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stellar/go/historyarchive"
+	"github.com/stellar/go/xdr"
+)
+
+// chainedTestLedger returns a ledger with valid ledger and transaction set
+// hashes which links to the ledger with the given hash.
+func chainedTestLedger(t *testing.T, sequence uint32, previousLedgerHash xdr.Hash) xdr.LedgerCloseMeta {
+	header := xdr.LedgerHeader{
+		LedgerSeq:          xdr.Uint32(sequence),
+		PreviousLedgerHash: previousLedgerHash,
+		ScpValue: xdr.StellarValue{
+			TxSetHash: xdr.Hash(historyarchive.HashEmptyTxSet(historyarchive.Hash(previousLedgerHash))),
+		},
+	}
+	hash, err := historyarchive.HashXdr(&header)
+	require.NoError(t, err)
+
+	return xdr.LedgerCloseMeta{
+		V0: &xdr.LedgerCloseMetaV0{
+			LedgerHeader: xdr.LedgerHeaderHistoryEntry{
+				Hash:   xdr.Hash(hash),
+				Header: header,
+			},
+			TxSet: xdr.TransactionSet{
+				PreviousLedgerHash: previousLedgerHash,
+			},
+		},
+	}
+}
+
+func chainedTestLedgers(t *testing.T, from, to uint32) []xdr.LedgerCloseMeta {
+	var ledgers []xdr.LedgerCloseMeta
+	previousLedgerHash := xdr.Hash{1}
+	for sequence := from; sequence <= to; sequence++ {
+		ledger := chainedTestLedger(t, sequence, previousLedgerHash)
+		ledgers = append(ledgers, ledger)
+		previousLedgerHash = ledger.LedgerHash()
+	}
+	return ledgers
+}
+
+func TestVerifyingBackendValidChain(t *testing.T) {
+	ctx := context.Background()
+	ledgers := chainedTestLedgers(t, 2, 4)
+	wrapped := &MockDatabaseBackend{}
+	for _, ledger := range ledgers {
+		wrapped.On("GetLedger", ctx, ledger.LedgerSequence()).Return(ledger, nil)
+	}
+
+	backend := NewVerifyingBackend(wrapped, VerifyingBackendConfig{})
+	for _, expected := range ledgers {
+		ledger, err := backend.GetLedger(ctx, expected.LedgerSequence())
+		require.NoError(t, err)
+		assert.Equal(t, expected, ledger)
+	}
+
+	// The last ledger can be requested again
+	_, err := backend.GetLedger(ctx, 4)
+	require.NoError(t, err)
+
+	fetched, err := backend.GetLedgers(ctx, 2, 4)
+	require.NoError(t, err)
+	assert.Equal(t, ledgers, fetched)
+	wrapped.AssertExpectations(t)
+}
+
+func TestVerifyingBackendBrokenChain(t *testing.T) {
+	ctx := context.Background()
+	ledgers := chainedTestLedgers(t, 2, 3)
+	// Ledger 4 doesn't link to ledger 3
+	broken := chainedTestLedger(t, 4, xdr.Hash{2})
+
+	wrapped := &MockDatabaseBackend{}
+	wrapped.On("GetLedger", ctx, uint32(2)).Return(ledgers[0], nil).Once()
+	wrapped.On("GetLedger", ctx, uint32(3)).Return(ledgers[1], nil).Once()
+	wrapped.On("GetLedger", ctx, uint32(4)).Return(broken, nil).Once()
+
+	backend := NewVerifyingBackend(wrapped, VerifyingBackendConfig{})
+	_, err := backend.GetLedgers(ctx, 2, 3)
+	require.NoError(t, err)
+
+	_, err = backend.GetLedger(ctx, 4)
+	assert.EqualError(t, err,
+		"ledger verification failed: unexpected previous ledger hash for ledger 4 (expected="+
+			ledgers[1].LedgerHash().HexString()+" actual=0200000000000000000000000000000000000000000000000000000000000000)",
+	)
+	wrapped.AssertExpectations(t)
+}
+
+func TestVerifyLedgerCloseMeta(t *testing.T) {
+	ledger := chainedTestLedger(t, 2, xdr.Hash{1})
+	require.NoError(t, VerifyLedgerCloseMeta(ledger))
+
+	invalidTxSet := chainedTestLedger(t, 2, xdr.Hash{1})
+	invalidTxSet.V0.TxSet.Txs = []xdr.TransactionEnvelope{{
+		Type: xdr.EnvelopeTypeEnvelopeTypeTx,
+		V1: &xdr.TransactionV1Envelope{
+			Tx: xdr.Transaction{
+				SourceAccount: xdr.MustMuxedAddress("GBRPYHIL2CI3FNQ4BXLFMNDLFJUNPU2HY3ZMFSHONUCEOASW7QC7OX2H"),
+			},
+		},
+	}}
+	err := VerifyLedgerCloseMeta(invalidTxSet)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid transaction set hash of ledger 2")
+
+	invalidHeader := chainedTestLedger(t, 2, xdr.Hash{1})
+	invalidHeader.V0.LedgerHeader.Header.TotalCoins = 100
+	err = VerifyLedgerCloseMeta(invalidHeader)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid hash of ledger 2")
+}
+
+func TestVerifyingBackendArchiveCheck(t *testing.T) {
+	ctx := context.Background()
+	ledgers := chainedTestLedgers(t, 62, 64)
+
+	wrapped := &MockDatabaseBackend{}
+	for _, ledger := range ledgers {
+		wrapped.On("GetLedger", ctx, ledger.LedgerSequence()).Return(ledger, nil)
+	}
+
+	archive := &historyarchive.MockArchive{}
+	archive.On("GetLedgerHeader", uint32(63)).
+		Return(xdr.LedgerHeaderHistoryEntry{Hash: xdr.Hash{4}}, nil).Once()
+
+	backend := NewVerifyingBackend(wrapped, VerifyingBackendConfig{Archive: archive})
+	_, err := backend.GetLedger(ctx, 62)
+	require.NoError(t, err)
+
+	_, err = backend.GetLedger(ctx, 63)
+	assert.EqualError(t, err,
+		"ledger verification failed: hash of ledger 63 does not match history archive (expected="+
+			"0400000000000000000000000000000000000000000000000000000000000000 actual="+
+			ledgers[1].LedgerHash().HexString()+")",
+	)
+
+	archive.On("GetLedgerHeader", uint32(63)).
+		Return(ledgers[1].V0.LedgerHeader, nil).Once()
+	_, err = backend.GetLedger(ctx, 63)
+	require.NoError(t, err)
+
+	// Only checkpoint ledgers are checked
+	_, err = backend.GetLedger(ctx, 64)
+	require.NoError(t, err)
+
+	wrapped.AssertExpectations(t)
+	archive.AssertExpectations(t)
+}
+
+func TestVerifyingBackendUnexpectedSequence(t *testing.T) {
+	ctx := context.Background()
+	wrapped := &MockDatabaseBackend{}
+	wrapped.On("GetLedger", mock.Anything, uint32(3)).Return(chainedTestLedger(t, 2, xdr.Hash{1}), nil).Once()
+
+	backend := NewVerifyingBackend(wrapped, VerifyingBackendConfig{})
+	_, err := backend.GetLedger(ctx, 3)
+	assert.EqualError(t, err, "ledger verification failed: unexpected ledger sequence (expected=3 actual=2)")
+	wrapped.AssertExpectations(t)
+}
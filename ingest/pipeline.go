@@ -0,0 +1,300 @@
+package ingest
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/stellar/go/historyarchive"
+	"github.com/stellar/go/ingest/ledgerbackend"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/xdr"
+)
+
+// CursorStore stores the sequence of the last ledger processed by Pipeline.
+type CursorStore interface {
+	// GetCursor returns the last processed ledger or 0 if no ledgers were
+	// processed yet.
+	GetCursor(ctx context.Context) (uint32, error)
+	// SetCursor is called after the ledger was processed and all processors
+	// were committed.
+	SetCursor(ctx context.Context, ledger uint32) error
+}
+
+// MemoryCursorStore is a CursorStore keeping the cursor in memory.
+type MemoryCursorStore struct {
+	mutex  sync.Mutex
+	cursor uint32
+}
+
+// GetCursor returns the last processed ledger.
+func (s *MemoryCursorStore) GetCursor(ctx context.Context) (uint32, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.cursor, nil
+}
+
+// SetCursor updates the last processed ledger.
+func (s *MemoryCursorStore) SetCursor(ctx context.Context, ledger uint32) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.cursor = ledger
+	return nil
+}
+
+// FileCursorStore is a CursorStore keeping the cursor in a file so the
+// Pipeline can be resumed after restart.
+type FileCursorStore struct {
+	Path string
+}
+
+// GetCursor returns the last processed ledger. It returns 0 if the file
+// doesn't exist.
+func (s FileCursorStore) GetCursor(ctx context.Context) (uint32, error) {
+	contents, err := ioutil.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, errors.Wrap(err, "error reading cursor file")
+	}
+	cursor, err := strconv.ParseUint(strings.TrimSpace(string(contents)), 10, 32)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid cursor in %s", s.Path)
+	}
+	return uint32(cursor), nil
+}
+
+// SetCursor updates the last processed ledger. The file is replaced
+// atomically so a crash never leaves a partially written cursor.
+func (s FileCursorStore) SetCursor(ctx context.Context, ledger uint32) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(s.Path), filepath.Base(s.Path)+".tmp")
+	if err != nil {
+		return errors.Wrap(err, "error creating temp cursor file")
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.WriteString(strconv.FormatUint(uint64(ledger), 10) + "\n"); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "error writing cursor file")
+	}
+	if err = tmp.Close(); err != nil {
+		return errors.Wrap(err, "error closing cursor file")
+	}
+	if err = os.Rename(tmp.Name(), s.Path); err != nil {
+		return errors.Wrap(err, "error renaming cursor file")
+	}
+	return nil
+}
+
+// PipelineConfig configures Pipeline.
+type PipelineConfig struct {
+	NetworkPassphrase string
+	// LedgerBackend provides ledgers following the initial state.
+	LedgerBackend ledgerbackend.LedgerBackend
+	// HistoryArchive is used to initialize the state when it's built at
+	// a checkpoint ledger. It's not required when the state is built at the
+	// genesis ledger.
+	HistoryArchive historyarchive.ArchiveInterface
+	// CursorStore stores the last processed ledger. If not set, the cursor
+	// is kept in memory.
+	CursorStore CursorStore
+
+	// ChangeProcessors process the initial state and changes in every
+	// ledger.
+	ChangeProcessors []ChangeProcessor
+	// TransactionProcessors process transactions in every ledger. Note that
+	// a processor added to both lists is committed twice.
+	TransactionProcessors []LedgerTransactionProcessor
+}
+
+// Pipeline wires ledger sources to user processors. When run for the first
+// time (the cursor store is empty) it builds the initial state by passing all
+// ledger entries of a checkpoint (or the genesis ledger) to ChangeProcessors.
+// Then it passes changes and transactions of every following ledger to
+// ChangeProcessors and TransactionProcessors. After the state and after every
+// ledger all processors implementing ProcessorCommitter are committed and the
+// cursor is updated. When the cursor store is not empty the Pipeline resumes
+// after the stored ledger. If the process crashes after processors were
+// committed but before the cursor was updated the ledger will be processed
+// again so processors should be idempotent.
+//
+// Pipeline is not thread-safe.
+type Pipeline struct {
+	config                PipelineConfig
+	cursorStore           CursorStore
+	changeProcessors      *GroupChangeProcessors
+	transactionProcessors *GroupTransactionProcessors
+}
+
+// NewPipeline creates a new Pipeline instance.
+func NewPipeline(config PipelineConfig) (*Pipeline, error) {
+	if config.LedgerBackend == nil {
+		return nil, errors.New("LedgerBackend is required")
+	}
+	if config.NetworkPassphrase == "" {
+		return nil, errors.New("NetworkPassphrase is required")
+	}
+
+	cursorStore := config.CursorStore
+	if cursorStore == nil {
+		cursorStore = &MemoryCursorStore{}
+	}
+	return &Pipeline{
+		config:                config,
+		cursorStore:           cursorStore,
+		changeProcessors:      NewGroupChangeProcessors(config.ChangeProcessors...),
+		transactionProcessors: NewGroupTransactionProcessors(config.TransactionProcessors...),
+	}, nil
+}
+
+// Run processes ledgers in the given range. If the cursor store is empty,
+// ledgerRange.From() must be the genesis ledger (1) or a checkpoint ledger
+// and the state is built at this ledger. Otherwise ledgerRange.From() is
+// ignored and the pipeline resumes after the stored cursor. For unbounded
+// ranges Run returns only on error or when ctx is cancelled.
+func (p *Pipeline) Run(ctx context.Context, ledgerRange ledgerbackend.Range) error {
+	cursor, err := p.cursorStore.GetCursor(ctx)
+	if err != nil {
+		return errors.Wrap(err, "error getting cursor")
+	}
+
+	if cursor == 0 {
+		cursor = ledgerRange.From()
+		if err = p.buildState(ctx, cursor); err != nil {
+			return err
+		}
+	}
+
+	from := cursor + 1
+	if ledgerRange.Bounded() && from > ledgerRange.To() {
+		return nil
+	}
+	backendRange := ledgerbackend.UnboundedRange(from)
+	if ledgerRange.Bounded() {
+		backendRange = ledgerbackend.BoundedRange(from, ledgerRange.To())
+	}
+	if err = p.config.LedgerBackend.PrepareRange(ctx, backendRange); err != nil {
+		return errors.Wrapf(err, "error preparing range %s", backendRange)
+	}
+
+	for sequence := from; !ledgerRange.Bounded() || sequence <= ledgerRange.To(); sequence++ {
+		ledger, err := p.config.LedgerBackend.GetLedger(ctx, sequence)
+		if err != nil {
+			return errors.Wrapf(err, "error getting ledger %d", sequence)
+		}
+		if err = p.ProcessLedger(ctx, ledger); err != nil {
+			return errors.Wrapf(err, "error processing ledger %d", sequence)
+		}
+	}
+	return nil
+}
+
+func (p *Pipeline) buildState(ctx context.Context, sequence uint32) error {
+	if sequence == 1 {
+		if err := p.changeProcessors.ProcessChange(ctx, GenesisChange(p.config.NetworkPassphrase)); err != nil {
+			return errors.Wrap(err, "error processing genesis change")
+		}
+	} else {
+		if p.config.HistoryArchive == nil {
+			return errors.New("HistoryArchive is required to build state at a checkpoint ledger")
+		}
+		if !p.config.HistoryArchive.GetCheckpointManager().IsCheckpoint(sequence) {
+			return errors.Errorf("%d is not a checkpoint ledger", sequence)
+		}
+
+		reader, err := NewCheckpointChangeReader(ctx, p.config.HistoryArchive, sequence)
+		if err != nil {
+			return errors.Wrapf(err, "error creating checkpoint change reader for ledger %d", sequence)
+		}
+		defer reader.Close()
+		if err = p.processChanges(ctx, reader); err != nil {
+			return errors.Wrapf(err, "error processing checkpoint %d", sequence)
+		}
+	}
+
+	if err := p.changeProcessors.Commit(ctx); err != nil {
+		return errors.Wrapf(err, "error committing state at ledger %d", sequence)
+	}
+	if err := p.cursorStore.SetCursor(ctx, sequence); err != nil {
+		return errors.Wrap(err, "error setting cursor")
+	}
+	return nil
+}
+
+// ProcessLedger passes changes and transactions of the ledger to processors,
+// commits processors and updates the cursor. Run calls it for every ledger
+// in the range, it can be used directly when ledgers come from a different
+// source than the LedgerBackend.
+func (p *Pipeline) ProcessLedger(ctx context.Context, ledger xdr.LedgerCloseMeta) error {
+	if len(p.config.ChangeProcessors) > 0 {
+		changeReader, err := NewLedgerChangeReaderFromLedgerCloseMeta(p.config.NetworkPassphrase, ledger)
+		if err != nil {
+			return errors.Wrap(err, "error creating ledger change reader")
+		}
+		defer changeReader.Close()
+		if err = p.processChanges(ctx, changeReader); err != nil {
+			return err
+		}
+	}
+
+	if len(p.config.TransactionProcessors) > 0 {
+		txReader, err := NewLedgerTransactionReaderFromLedgerCloseMeta(p.config.NetworkPassphrase, ledger)
+		if err != nil {
+			return errors.Wrap(err, "error creating ledger transaction reader")
+		}
+		defer txReader.Close()
+		for {
+			tx, err := txReader.Read()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return errors.Wrap(err, "error reading transaction")
+			}
+			if err = p.transactionProcessors.ProcessTransaction(ctx, tx); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := p.changeProcessors.Commit(ctx); err != nil {
+		return err
+	}
+	if err := p.transactionProcessors.Commit(ctx); err != nil {
+		return err
+	}
+	if err := p.cursorStore.SetCursor(ctx, ledger.LedgerSequence()); err != nil {
+		return errors.Wrap(err, "error setting cursor")
+	}
+	return nil
+}
+
+func (p *Pipeline) processChanges(ctx context.Context, reader ChangeReader) error {
+	for {
+		change, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return errors.Wrap(err, "error reading change")
+		}
+		if err = p.changeProcessors.ProcessChange(ctx, change); err != nil {
+			return err
+		}
+	}
+}
+
+// ChangeProcessorsRunDurations returns the time spent in each change
+// processor.
+func (p *Pipeline) ChangeProcessorsRunDurations() ProcessorsRunDurations {
+	return p.changeProcessors.RunDurations()
+}
+
+// TransactionProcessorsRunDurations returns the time spent in each
+// transaction processor.
+func (p *Pipeline) TransactionProcessorsRunDurations() ProcessorsRunDurations {
+	return p.transactionProcessors.RunDurations()
+}
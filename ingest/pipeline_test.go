@@ -0,0 +1,149 @@
+package ingest
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stellar/go/historyarchive"
+	"github.com/stellar/go/ingest/ledgerbackend"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/xdr"
+)
+
+// recordingProcessor records processed changes and the number of changes
+// processed before each commit.
+type recordingProcessor struct {
+	changes      []Change
+	transactions int
+	commits      []int
+}
+
+func (p *recordingProcessor) ProcessChange(ctx context.Context, change Change) error {
+	p.changes = append(p.changes, change)
+	return nil
+}
+
+func (p *recordingProcessor) ProcessTransaction(ctx context.Context, tx LedgerTransaction) error {
+	p.transactions++
+	return nil
+}
+
+func (p *recordingProcessor) Commit(ctx context.Context) error {
+	p.commits = append(p.commits, len(p.changes))
+	return nil
+}
+
+func TestPipelineGenesis(t *testing.T) {
+	ctx := context.Background()
+	backend := &ledgerbackend.MockDatabaseBackend{}
+	var created []xdr.LedgerEntry
+	for seq := uint32(2); seq <= 4; seq++ {
+		entry := archiveLedgerAccount(keypair.MustRandom().Address(), int64(seq))
+		created = append(created, entry)
+		backend.On("GetLedger", ctx, seq).
+			Return(archiveLedgerCloseMeta(seq, xdr.Hash{}, &entry, nil, nil), nil).Once()
+	}
+	backend.On("PrepareRange", ctx, ledgerbackend.BoundedRange(2, 3)).Return(nil).Once()
+	backend.On("PrepareRange", ctx, ledgerbackend.BoundedRange(4, 4)).Return(nil).Once()
+
+	processor := &recordingProcessor{}
+	cursorStore := &MemoryCursorStore{}
+	pipeline, err := NewPipeline(PipelineConfig{
+		NetworkPassphrase:     network.TestNetworkPassphrase,
+		LedgerBackend:         backend,
+		CursorStore:           cursorStore,
+		ChangeProcessors:      []ChangeProcessor{processor},
+		TransactionProcessors: []LedgerTransactionProcessor{processor},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, pipeline.Run(ctx, ledgerbackend.BoundedRange(1, 3)))
+	cursor, err := cursorStore.GetCursor(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(3), cursor)
+	require.Len(t, processor.changes, 3)
+	assert.Equal(t, GenesisChange(network.TestNetworkPassphrase), processor.changes[0])
+	assert.Equal(t, &created[0], processor.changes[1].Post)
+	assert.Equal(t, &created[1], processor.changes[2].Post)
+	// The genesis state is committed once (no transaction processors), every
+	// ledger is committed by both groups.
+	assert.Equal(t, []int{1, 2, 2, 3, 3}, processor.commits)
+	assert.Equal(t, 0, processor.transactions)
+
+	// Resumes after the cursor, From() is ignored
+	require.NoError(t, pipeline.Run(ctx, ledgerbackend.BoundedRange(1, 4)))
+	require.Len(t, processor.changes, 4)
+	assert.Equal(t, &created[2], processor.changes[3].Post)
+
+	// Nothing to do
+	require.NoError(t, pipeline.Run(ctx, ledgerbackend.BoundedRange(1, 4)))
+	backend.AssertExpectations(t)
+}
+
+func TestPipelineCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	archive, err := historyarchive.Connect("mock://test", historyarchive.ConnectOptions{})
+	require.NoError(t, err)
+	writer, err := historyarchive.NewArchiveWriter(archive, historyarchive.NewBucketList(), 63, historyarchive.ArchiveWriterOptions{
+		NetworkPassphrase:       network.TestNetworkPassphrase,
+		SkipBucketListHashCheck: true,
+	})
+	require.NoError(t, err)
+
+	var previousHash xdr.Hash
+	for seq := uint32(64); seq <= 127; seq++ {
+		entry := archiveLedgerAccount(keypair.MustRandom().Address(), int64(seq))
+		meta := archiveLedgerCloseMeta(seq, previousHash, &entry, nil, nil)
+		previousHash = meta.LedgerHash()
+		ledger, err := NewArchiveLedgerFromLedgerCloseMeta(network.TestNetworkPassphrase, meta)
+		require.NoError(t, err)
+		require.NoError(t, writer.AddLedger(ledger))
+	}
+
+	entry := archiveLedgerAccount(keypair.MustRandom().Address(), 128)
+	backend := &ledgerbackend.MockDatabaseBackend{}
+	backend.On("PrepareRange", ctx, ledgerbackend.BoundedRange(128, 128)).Return(nil).Once()
+	backend.On("GetLedger", ctx, uint32(128)).
+		Return(archiveLedgerCloseMeta(128, previousHash, &entry, nil, nil), nil).Once()
+
+	processor := &recordingProcessor{}
+	cursorStore := FileCursorStore{Path: filepath.Join(t.TempDir(), "cursor")}
+	pipeline, err := NewPipeline(PipelineConfig{
+		NetworkPassphrase: network.TestNetworkPassphrase,
+		LedgerBackend:     backend,
+		HistoryArchive:    archive,
+		CursorStore:       cursorStore,
+		ChangeProcessors:  []ChangeProcessor{processor},
+	})
+	require.NoError(t, err)
+
+	assert.EqualError(t, pipeline.Run(ctx, ledgerbackend.BoundedRange(100, 128)), "100 is not a checkpoint ledger")
+
+	require.NoError(t, pipeline.Run(ctx, ledgerbackend.BoundedRange(127, 128)))
+	assert.Len(t, processor.changes, 65)
+	assert.Equal(t, []int{64, 65}, processor.commits)
+	cursor, err := cursorStore.GetCursor(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(128), cursor)
+	backend.AssertExpectations(t)
+}
+
+func TestFileCursorStore(t *testing.T) {
+	ctx := context.Background()
+	store := FileCursorStore{Path: filepath.Join(t.TempDir(), "cursor")}
+
+	cursor, err := store.GetCursor(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0), cursor)
+
+	require.NoError(t, store.SetCursor(ctx, 123))
+	require.NoError(t, store.SetCursor(ctx, 456))
+	cursor, err = store.GetCursor(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(456), cursor)
+}
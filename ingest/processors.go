@@ -0,0 +1,154 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/stellar/go/support/errors"
+)
+
+// ChangeProcessor processes ledger entry changes, ex. changes returned by
+// CheckpointChangeReader or LedgerChangeReader.
+type ChangeProcessor interface {
+	ProcessChange(ctx context.Context, change Change) error
+}
+
+// LedgerTransactionProcessor processes transactions returned by
+// LedgerTransactionReader.
+type LedgerTransactionProcessor interface {
+	ProcessTransaction(ctx context.Context, transaction LedgerTransaction) error
+}
+
+// ProcessorCommitter can be implemented by processors which need to flush
+// their results (ex. execute batch inserts). Commit is called by Pipeline
+// after all changes of the checkpoint state and after all changes and
+// transactions of every ledger were processed.
+type ProcessorCommitter interface {
+	Commit(ctx context.Context) error
+}
+
+// ProcessorsRunDurations contains the total time spent in each processor
+// keyed by the processor type.
+type ProcessorsRunDurations map[string]time.Duration
+
+// AddRunDuration adds the time elapsed since startTime to the processor.
+func (d ProcessorsRunDurations) AddRunDuration(name string, startTime time.Time) {
+	d[name] += time.Since(startTime)
+}
+
+// GroupChangeProcessors is a ChangeProcessor passing changes to all its
+// processors in order.
+type GroupChangeProcessors struct {
+	processors   []ChangeProcessor
+	runDurations ProcessorsRunDurations
+}
+
+// Ensure GroupChangeProcessors implements ChangeProcessor
+var _ ChangeProcessor = &GroupChangeProcessors{}
+
+// NewGroupChangeProcessors creates a new GroupChangeProcessors instance.
+func NewGroupChangeProcessors(processors ...ChangeProcessor) *GroupChangeProcessors {
+	return &GroupChangeProcessors{
+		processors:   processors,
+		runDurations: ProcessorsRunDurations{},
+	}
+}
+
+// ProcessChange passes the change to all processors. It stops on the first
+// error.
+func (g *GroupChangeProcessors) ProcessChange(ctx context.Context, change Change) error {
+	for _, p := range g.processors {
+		startTime := time.Now()
+		if err := p.ProcessChange(ctx, change); err != nil {
+			return errors.Wrapf(err, "error in %T.ProcessChange", p)
+		}
+		g.runDurations.AddRunDuration(fmt.Sprintf("%T", p), startTime)
+	}
+	return nil
+}
+
+// Commit commits all processors implementing ProcessorCommitter.
+func (g *GroupChangeProcessors) Commit(ctx context.Context) error {
+	for _, p := range g.processors {
+		if err := commitProcessor(ctx, p, g.runDurations); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunDurations returns the time spent in each processor.
+func (g *GroupChangeProcessors) RunDurations() ProcessorsRunDurations {
+	return g.runDurations
+}
+
+// Processors returns the processors of the group.
+func (g *GroupChangeProcessors) Processors() []ChangeProcessor {
+	return g.processors
+}
+
+// GroupTransactionProcessors is a LedgerTransactionProcessor passing
+// transactions to all its processors in order.
+type GroupTransactionProcessors struct {
+	processors   []LedgerTransactionProcessor
+	runDurations ProcessorsRunDurations
+}
+
+// Ensure GroupTransactionProcessors implements LedgerTransactionProcessor
+var _ LedgerTransactionProcessor = &GroupTransactionProcessors{}
+
+// NewGroupTransactionProcessors creates a new GroupTransactionProcessors
+// instance.
+func NewGroupTransactionProcessors(processors ...LedgerTransactionProcessor) *GroupTransactionProcessors {
+	return &GroupTransactionProcessors{
+		processors:   processors,
+		runDurations: ProcessorsRunDurations{},
+	}
+}
+
+// ProcessTransaction passes the transaction to all processors. It stops on
+// the first error.
+func (g *GroupTransactionProcessors) ProcessTransaction(ctx context.Context, tx LedgerTransaction) error {
+	for _, p := range g.processors {
+		startTime := time.Now()
+		if err := p.ProcessTransaction(ctx, tx); err != nil {
+			return errors.Wrapf(err, "error in %T.ProcessTransaction", p)
+		}
+		g.runDurations.AddRunDuration(fmt.Sprintf("%T", p), startTime)
+	}
+	return nil
+}
+
+// Commit commits all processors implementing ProcessorCommitter.
+func (g *GroupTransactionProcessors) Commit(ctx context.Context) error {
+	for _, p := range g.processors {
+		if err := commitProcessor(ctx, p, g.runDurations); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunDurations returns the time spent in each processor.
+func (g *GroupTransactionProcessors) RunDurations() ProcessorsRunDurations {
+	return g.runDurations
+}
+
+// Processors returns the processors of the group.
+func (g *GroupTransactionProcessors) Processors() []LedgerTransactionProcessor {
+	return g.processors
+}
+
+func commitProcessor(ctx context.Context, p interface{}, runDurations ProcessorsRunDurations) error {
+	committer, ok := p.(ProcessorCommitter)
+	if !ok {
+		return nil
+	}
+	startTime := time.Now()
+	if err := committer.Commit(ctx); err != nil {
+		return errors.Wrapf(err, "error in %T.Commit", p)
+	}
+	runDurations.AddRunDuration(fmt.Sprintf("%T", p), startTime)
+	return nil
+}
@@ -838,17 +838,47 @@ type LiquidityPoolReserve struct {
 }
 
 type AssetFilterConfig struct {
-	Whitelist    []string `json:"whitelist"`
+	Whitelist []string `json:"whitelist"`
+	// Blacklist is optional, transactions referencing a blacklisted asset
+	// are never ingested.
+	Blacklist    []string `json:"blacklist"`
 	Enabled      *bool    `json:"enabled"`
 	LastModified int64    `json:"last_modified,omitempty"`
 }
 
 type AccountFilterConfig struct {
-	Whitelist    []string `json:"whitelist"`
+	Whitelist []string `json:"whitelist"`
+	// Blacklist is optional, transactions with a blacklisted participant
+	// are never ingested.
+	Blacklist    []string `json:"blacklist"`
 	Enabled      *bool    `json:"enabled"`
 	LastModified int64    `json:"last_modified,omitempty"`
 }
 
+// TransactionFilterConfig selects transactions meeting all configured rules.
+// Empty rules are ignored.
+type TransactionFilterConfig struct {
+	// OperationTypes are operation type names, ex. "payment". A transaction
+	// must contain an operation of one of the types.
+	OperationTypes []string `json:"operation_types"`
+	// Memos are memo values as displayed in transaction resources. The
+	// transaction memo must be one of the values.
+	Memos []string `json:"memos"`
+	// MinPaymentAmount is the minimum amount of one of the create account
+	// or payment operations of the transaction, regardless of the asset.
+	MinPaymentAmount string `json:"min_payment_amount,omitempty"`
+	Enabled          *bool  `json:"enabled"`
+	LastModified     int64  `json:"last_modified,omitempty"`
+}
+
+// FilterModeConfig defines how ingestion filters are combined: "all" (a
+// transaction is ingested if it's matched by all enabled filters) or "any"
+// (a transaction is ingested if it's matched by any enabled filter). In both
+// modes a transaction excluded by a blacklist is not ingested.
+type FilterModeConfig struct {
+	Mode string `json:"mode"`
+}
+
 func (f *AccountFilterConfig) UnmarshalJSON(data []byte) error {
 	type accountFilterConfig AccountFilterConfig
 	var config = accountFilterConfig{}
@@ -888,3 +918,84 @@ func (f *AssetFilterConfig) UnmarshalJSON(data []byte) error {
 	*f = AssetFilterConfig(config)
 	return nil
 }
+
+func (f *TransactionFilterConfig) UnmarshalJSON(data []byte) error {
+	type transactionFilterConfig TransactionFilterConfig
+	var config = transactionFilterConfig{}
+
+	if err := json.Unmarshal(data, &config); err != nil {
+		return err
+	}
+
+	if config.Enabled == nil {
+		return errors.New("missing required enabled")
+	}
+
+	*f = TransactionFilterConfig(config)
+	return nil
+}
+
+// FilterBackfillStatus is the status of the reingestion of history scheduled
+// when ingestion filter rules change, so transactions matched by the new rules
+// are ingested in the retained history window.
+type FilterBackfillStatus struct {
+	// State is one of: "idle" (no backfill was scheduled), "running",
+	// "failing" (the last batch failed, it will be retried) and "completed".
+	State                string   `json:"state"`
+	NewlyMatchedAssets   []string `json:"newly_matched_assets,omitempty"`
+	NewlyMatchedAccounts []string `json:"newly_matched_accounts,omitempty"`
+	// MatchAll is true when a filter was disabled so all transactions are
+	// newly matched.
+	MatchAll        bool       `json:"match_all"`
+	FromLedger      uint32     `json:"from_ledger,omitempty"`
+	ToLedger        uint32     `json:"to_ledger,omitempty"`
+	NextLedger      uint32     `json:"next_ledger,omitempty"`
+	ProgressPercent float64    `json:"progress_percent"`
+	ScheduledAt     *time.Time `json:"scheduled_at,omitempty"`
+	FinishedAt      *time.Time `json:"finished_at,omitempty"`
+	LastError       string     `json:"last_error,omitempty"`
+}
+
+// IngestionProgress is the progress of the reingestion of ledger ranges and
+// of building the state from a history archive checkpoint.
+type IngestionProgress struct {
+	Reingest   *ReingestProgress   `json:"reingest,omitempty"`
+	BuildState *BuildStateProgress `json:"build_state,omitempty"`
+}
+
+// ReingestProgress is the progress of the reingestion of ledger ranges, using
+// one or more workers.
+type ReingestProgress struct {
+	FromLedger   uint32 `json:"from_ledger"`
+	ToLedger     uint32 `json:"to_ledger"`
+	TotalLedgers uint32 `json:"total_ledgers"`
+	// LedgersDone is the number of committed ledgers.
+	LedgersDone      uint32                   `json:"ledgers_done"`
+	ProgressPercent  float64                  `json:"progress_percent"`
+	LedgersPerSecond float64                  `json:"ledgers_per_second"`
+	ETASeconds       *float64                 `json:"eta_seconds,omitempty"`
+	Workers          []ReingestWorkerProgress `json:"workers"`
+	StartedAt        time.Time                `json:"started_at"`
+	FinishedAt       *time.Time               `json:"finished_at,omitempty"`
+}
+
+// ReingestWorkerProgress is the ledger range reingested by a worker.
+type ReingestWorkerProgress struct {
+	Worker     int       `json:"worker"`
+	FromLedger uint32    `json:"from_ledger"`
+	ToLedger   uint32    `json:"to_ledger"`
+	NextLedger uint32    `json:"next_ledger"`
+	StartedAt  time.Time `json:"started_at"`
+}
+
+// BuildStateProgress is the progress of reading ledger entries from a history
+// archive checkpoint.
+type BuildStateProgress struct {
+	CheckpointLedger uint32     `json:"checkpoint_ledger"`
+	EntriesProcessed uint64     `json:"entries_processed"`
+	EntriesPerSecond float64    `json:"entries_per_second"`
+	ProgressPercent  float64    `json:"progress_percent"`
+	ETASeconds       *float64   `json:"eta_seconds,omitempty"`
+	StartedAt        time.Time  `json:"started_at"`
+	FinishedAt       *time.Time `json:"finished_at,omitempty"`
+}
@@ -0,0 +1,437 @@
+package internal
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/stellar/go/ingest/ledgerbackend"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/support/log"
+	"github.com/stellar/go/xdr"
+)
+
+const defaultBufferSize = 128
+
+var (
+	// ErrMissingPrepareRange is returned when attempting an operation without satisfying
+	// its PrepareRange dependency
+	ErrMissingPrepareRange = errors.New("PrepareRange must be called before any other operations")
+	// ErrPrepareRangeNotReady is returned when attempting an operation before PrepareRange has finished
+	// running
+	ErrPrepareRangeNotReady = errors.New("PrepareRange operation is not yet complete")
+	// ErrLedgerNotReady is returned when the requested ledger was not streamed
+	// by Stellar-Core before the request deadline.
+	ErrLedgerNotReady = errors.New("ledger is not ready yet")
+)
+
+// rangeRequest is a single prepared range. Once the range is prepared in
+// the ledger backend the ledgers are streamed from the backend into a buffer
+// of the most recent ledgers so many clients can read them concurrently.
+type rangeRequest struct {
+	ledgerRange   ledgerbackend.Range
+	startTime     time.Time
+	readyDuration int
+	ready         bool
+	cancel        context.CancelFunc
+	done          chan struct{}
+
+	// ledgers contains the most recent ledgers streamed from the backend,
+	// ledgers[0] is the oldest one.
+	ledgers    []xdr.LedgerCloseMeta
+	nextLedger uint32
+	// readUpTo is the sequence of the first ledger not read by clients yet,
+	// ledgers before it were read (or skipped by a client waiting for a later
+	// ledger). When the buffer is full the backend is not read until the
+	// oldest ledger in the buffer is read so clients slower than the backend
+	// don't miss ledgers.
+	readUpTo uint32
+	err      error
+	// updated is closed (and replaced) every time the state of the request
+	// changes so waiting readers can check it again.
+	updated chan struct{}
+}
+
+// oldestLedger returns the sequence of the oldest ledger which can still be
+// read from the request.
+func (r *rangeRequest) oldestLedger() uint32 {
+	return r.nextLedger - uint32(len(r.ledgers))
+}
+
+// CaptiveCoreAPI shares a single ledger backend (usually Captive
+// Stellar-Core) between many clients.
+type CaptiveCoreAPI struct {
+	ctx        context.Context
+	cancel     context.CancelFunc
+	core       ledgerbackend.LedgerBackend
+	bufferSize uint32
+	log        *log.Entry
+
+	lock          sync.Mutex
+	activeRequest *rangeRequest
+}
+
+// NewCaptiveCoreAPI constructs a new CaptiveCoreAPI struct. bufferSize is the
+// number of the most recent ledgers kept in memory, if it's 0 128 ledgers are
+// kept.
+func NewCaptiveCoreAPI(core ledgerbackend.LedgerBackend, bufferSize uint32, log *log.Entry) *CaptiveCoreAPI {
+	if bufferSize == 0 {
+		bufferSize = defaultBufferSize
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &CaptiveCoreAPI{
+		ctx:        ctx,
+		cancel:     cancel,
+		core:       core,
+		bufferSize: bufferSize,
+		log:        log,
+	}
+}
+
+// Shutdown disables the PrepareRange endpoint and closes
+// the captive core process.
+func (c *CaptiveCoreAPI) Shutdown() {
+	c.lock.Lock()
+	c.cancel()
+	req := c.activeRequest
+	c.lock.Unlock()
+
+	if req != nil {
+		<-req.done
+	}
+	if err := c.core.Close(); err != nil {
+		c.log.WithError(err).Error("error closing ledger backend")
+	}
+}
+
+// isServable returns true if ledgerRange is served by the active request.
+// Ranges starting with ledgers already streamed (ex. prepared again by a
+// client polling PrepareRange) are served by the active request too instead of
+// restarting the backend.
+func (c *CaptiveCoreAPI) isServable(ledgerRange ledgerbackend.Range) bool {
+	r := c.activeRequest
+	return r != nil &&
+		r.err == nil &&
+		r.ledgerRange.Contains(ledgerRange)
+}
+
+// startPrepareRange replaces the active request with a new one. It must be
+// called with the lock held.
+func (c *CaptiveCoreAPI) startPrepareRange(ledgerRange ledgerbackend.Range) {
+	previous := c.activeRequest
+	if previous != nil {
+		previous.cancel()
+	}
+
+	ctx, cancel := context.WithCancel(c.ctx)
+	req := &rangeRequest{
+		ledgerRange: ledgerRange,
+		startTime:   time.Now(),
+		cancel:      cancel,
+		done:        make(chan struct{}),
+		nextLedger:  ledgerRange.From(),
+		readUpTo:    ledgerRange.From(),
+		updated:     make(chan struct{}),
+	}
+	c.activeRequest = req
+
+	go c.stream(ctx, req, previous)
+}
+
+// stream prepares the range of the request in the backend and reads the
+// following ledgers into the request buffer until an error occurs or the last
+// ledger in the range is read. When the buffer is full it waits for clients to
+// read the oldest ledger before reading the next one. The backend is used only
+// after the previous request has stopped using it.
+func (c *CaptiveCoreAPI) stream(ctx context.Context, req, previous *rangeRequest) {
+	defer close(req.done)
+	defer req.cancel()
+
+	if previous != nil {
+		<-previous.done
+	}
+
+	err := c.core.PrepareRange(ctx, req.ledgerRange)
+	c.lock.Lock()
+	if err != nil {
+		c.log.WithError(err).WithField("range", req.ledgerRange.String()).Error("could not prepare range")
+		c.finish(req, err)
+		c.lock.Unlock()
+		return
+	}
+	req.ready = true
+	req.readyDuration = int(time.Since(req.startTime).Seconds())
+	c.notify(req)
+	c.lock.Unlock()
+
+	for sequence := req.ledgerRange.From(); ; sequence++ {
+		if err = c.waitForReaders(ctx, req); err != nil {
+			return
+		}
+
+		ledger, err := c.core.GetLedger(ctx, sequence)
+
+		c.lock.Lock()
+		if err != nil {
+			if ctx.Err() == nil {
+				c.log.WithError(err).WithField("sequence", sequence).Error("could not get ledger")
+			}
+			c.finish(req, err)
+			c.lock.Unlock()
+			return
+		}
+
+		req.ledgers = append(req.ledgers, ledger)
+		if uint32(len(req.ledgers)) > c.bufferSize {
+			req.ledgers[0] = xdr.LedgerCloseMeta{}
+			req.ledgers = req.ledgers[1:]
+		}
+		req.nextLedger = sequence + 1
+		c.notify(req)
+		c.lock.Unlock()
+
+		if req.ledgerRange.Bounded() && sequence >= req.ledgerRange.To() {
+			return
+		}
+	}
+}
+
+// waitForReaders blocks until there is space in the buffer of the request for
+// the next ledger, which is when the buffer is not full or the oldest ledger in
+// it was read.
+func (c *CaptiveCoreAPI) waitForReaders(ctx context.Context, req *rangeRequest) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for uint32(len(req.ledgers)) >= c.bufferSize && req.oldestLedger() >= req.readUpTo {
+		updated := req.updated
+		c.lock.Unlock()
+		select {
+		case <-ctx.Done():
+			c.lock.Lock()
+			c.finish(req, ctx.Err())
+			return ctx.Err()
+		case <-updated:
+		}
+		c.lock.Lock()
+	}
+	return nil
+}
+
+// markRead records that the ledgers before sequence were read from the
+// request so they can be removed from the buffer. It must be called with the
+// lock held.
+func (c *CaptiveCoreAPI) markRead(req *rangeRequest, sequence uint32) {
+	if sequence > req.readUpTo {
+		req.readUpTo = sequence
+		c.notify(req)
+	}
+}
+
+// notify wakes up readers waiting for a change in the request. It must be
+// called with the lock held.
+func (c *CaptiveCoreAPI) notify(req *rangeRequest) {
+	close(req.updated)
+	req.updated = make(chan struct{})
+}
+
+// finish marks the request as failed. It must be called with the lock held.
+func (c *CaptiveCoreAPI) finish(req *rangeRequest, err error) {
+	req.err = err
+	c.notify(req)
+}
+
+// PrepareRange executes the PrepareRange operation on the captive core instance.
+// When the range is already prepared (or the ledgers in the range are
+// still buffered) the active range is reused so many clients reading the same
+// ledgers share a single Stellar-Core stream.
+func (c *CaptiveCoreAPI) PrepareRange(ctx context.Context, ledgerRange ledgerbackend.Range) (ledgerbackend.PrepareRangeResponse, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.ctx.Err() != nil {
+		return ledgerbackend.PrepareRangeResponse{}, errors.New("Cannot prepare range when shut down")
+	}
+
+	if r := c.activeRequest; r != nil && !r.ready && r.err != nil {
+		// Return the error once, the next call prepares the range again.
+		c.activeRequest = nil
+		return ledgerbackend.PrepareRangeResponse{}, errors.Wrap(r.err, "error preparing range")
+	}
+
+	if !c.isServable(ledgerRange) {
+		c.startPrepareRange(ledgerRange)
+	}
+
+	return ledgerbackend.PrepareRangeResponse{
+		LedgerRange:   c.activeRequest.ledgerRange,
+		StartTime:     c.activeRequest.startTime,
+		Ready:         c.activeRequest.ready,
+		ReadyDuration: c.activeRequest.readyDuration,
+	}, nil
+}
+
+// readyRequest returns the active request if it's ready. It must be called
+// with the lock held.
+func (c *CaptiveCoreAPI) readyRequest() (*rangeRequest, error) {
+	if c.activeRequest == nil {
+		return nil, ErrMissingPrepareRange
+	}
+	if !c.activeRequest.ready {
+		return nil, ErrPrepareRangeNotReady
+	}
+	return c.activeRequest, nil
+}
+
+// GetLatestLedgerSequence determines the latest ledger sequence available on the captive core instance.
+func (c *CaptiveCoreAPI) GetLatestLedgerSequence(ctx context.Context) (ledgerbackend.LatestLedgerSequenceResponse, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	req, err := c.readyRequest()
+	if err != nil {
+		return ledgerbackend.LatestLedgerSequenceResponse{}, err
+	}
+	if req.err != nil {
+		return ledgerbackend.LatestLedgerSequenceResponse{}, req.err
+	}
+	return ledgerbackend.LatestLedgerSequenceResponse{Sequence: req.nextLedger - 1}, nil
+}
+
+// getLedger returns the ledger with the given sequence, waiting until it's
+// streamed from the backend, and releases it so it can be removed from the
+// buffer.
+func (c *CaptiveCoreAPI) getLedger(ctx context.Context, sequence uint32) (xdr.LedgerCloseMeta, *rangeRequest, error) {
+	ledger, req, err := c.getRequestLedger(ctx, sequence, sequence)
+	if err != nil {
+		return xdr.LedgerCloseMeta{}, nil, err
+	}
+	c.release(req, sequence+1)
+	return ledger, req, nil
+}
+
+// getRequestLedger returns the ledger with the given sequence and the request
+// which served it, waiting until it's streamed from the backend. While
+// waiting the ledgers before keepFrom are released.
+func (c *CaptiveCoreAPI) getRequestLedger(ctx context.Context, sequence, keepFrom uint32) (xdr.LedgerCloseMeta, *rangeRequest, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	req, err := c.readyRequest()
+	if err != nil {
+		return xdr.LedgerCloseMeta{}, nil, err
+	}
+
+	for {
+		if req.ledgerRange.Bounded() && sequence > req.ledgerRange.To() {
+			return xdr.LedgerCloseMeta{}, nil, errors.Errorf(
+				"reading past bounded range (requested sequence=%d, last ledger in range=%d)",
+				sequence,
+				req.ledgerRange.To(),
+			)
+		}
+		if sequence < req.oldestLedger() {
+			return xdr.LedgerCloseMeta{}, nil, errors.Errorf(
+				"ledger %d is no longer available (oldest available ledger=%d)",
+				sequence,
+				req.oldestLedger(),
+			)
+		}
+		if sequence < req.nextLedger {
+			return req.ledgers[sequence-req.oldestLedger()], req, nil
+		}
+		if req.err != nil {
+			return xdr.LedgerCloseMeta{}, nil, req.err
+		}
+
+		// The client waiting for the ledger doesn't need earlier ledgers
+		c.markRead(req, keepFrom)
+		updated := req.updated
+		c.lock.Unlock()
+		select {
+		case <-ctx.Done():
+			c.lock.Lock()
+			return xdr.LedgerCloseMeta{}, nil, ErrLedgerNotReady
+		case <-updated:
+		}
+		c.lock.Lock()
+
+		if c.activeRequest != req {
+			return xdr.LedgerCloseMeta{}, nil, errors.New("prepared range changed, call PrepareRange first")
+		}
+	}
+}
+
+// release marks the ledgers before sequence as read so they can be removed
+// from the buffer of the request.
+func (c *CaptiveCoreAPI) release(req *rangeRequest, sequence uint32) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.markRead(req, sequence)
+}
+
+// GetLedger fetches the ledger with the given sequence number from the captive core instance.
+func (c *CaptiveCoreAPI) GetLedger(ctx context.Context, sequence uint32) (ledgerbackend.LedgerResponse, error) {
+	ledger, _, err := c.getLedger(ctx, sequence)
+	if err != nil {
+		return ledgerbackend.LedgerResponse{}, err
+	}
+	return ledgerbackend.LedgerResponse{Ledger: ledgerbackend.Base64Ledger(ledger)}, nil
+}
+
+// GetLedgers fetches all ledgers in the range [from, to] from the captive
+// core instance. The ledgers are kept in the buffer until all of them are read
+// so a client can retry a request which timed out. Ranges larger than the
+// buffer are served too but their ledgers are released as they are read to
+// make space for the following ones, so they can't be retried.
+func (c *CaptiveCoreAPI) GetLedgers(ctx context.Context, from, to uint32) (ledgerbackend.LedgersResponse, error) {
+	if from > to {
+		return ledgerbackend.LedgersResponse{}, errors.Errorf("invalid range: [%d, %d]", from, to)
+	}
+	oversized := to-from+1 > c.bufferSize
+	response := ledgerbackend.LedgersResponse{
+		Ledgers: make([]ledgerbackend.Base64Ledger, 0, to-from+1),
+	}
+	var req *rangeRequest
+	for sequence := from; sequence <= to; sequence++ {
+		var ledger xdr.LedgerCloseMeta
+		var err error
+		if oversized {
+			ledger, req, err = c.getLedger(ctx, sequence)
+		} else {
+			ledger, req, err = c.getRequestLedger(ctx, sequence, from)
+		}
+		if err != nil {
+			return ledgerbackend.LedgersResponse{}, err
+		}
+		response.Ledgers = append(response.Ledgers, ledgerbackend.Base64Ledger(ledger))
+	}
+	c.release(req, to+1)
+	return response, nil
+}
+
+// StreamLedgers calls f with consecutive ledgers starting from the given
+// sequence until f returns an error, the context is cancelled or the last
+// ledger in the prepared range is sent.
+func (c *CaptiveCoreAPI) StreamLedgers(ctx context.Context, from uint32, f func(xdr.LedgerCloseMeta) error) error {
+	var streamed *rangeRequest
+	for sequence := from; ; sequence++ {
+		ledger, req, err := c.getLedger(ctx, sequence)
+		if err != nil {
+			return err
+		}
+		if streamed == nil {
+			streamed = req
+		} else if req != streamed {
+			return errors.New("prepared range changed, call PrepareRange first")
+		}
+
+		if err = f(ledger); err != nil {
+			return err
+		}
+
+		// The range of a request doesn't change so it can be read without
+		// the lock.
+		if req.ledgerRange.Bounded() && sequence >= req.ledgerRange.To() {
+			return nil
+		}
+	}
+}
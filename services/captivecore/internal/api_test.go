@@ -0,0 +1,261 @@
+package internal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stellar/go/ingest/ledgerbackend"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/support/log"
+	"github.com/stellar/go/xdr"
+)
+
+func testLedger(sequence uint32) xdr.LedgerCloseMeta {
+	return xdr.LedgerCloseMeta{
+		V0: &xdr.LedgerCloseMetaV0{
+			LedgerHeader: xdr.LedgerHeaderHistoryEntry{
+				Header: xdr.LedgerHeader{
+					LedgerSeq: xdr.Uint32(sequence),
+				},
+			},
+		},
+	}
+}
+
+func waitForPrepareRange(t *testing.T, api *CaptiveCoreAPI, ledgerRange ledgerbackend.Range) {
+	require.Eventually(t, func() bool {
+		response, err := api.PrepareRange(context.Background(), ledgerRange)
+		return err == nil && response.Ready
+	}, time.Second, time.Millisecond)
+}
+
+func TestCaptiveCoreAPIBoundedRange(t *testing.T) {
+	core := &ledgerbackend.MockDatabaseBackend{}
+	core.On("PrepareRange", mock.Anything, ledgerbackend.BoundedRange(2, 5)).Return(nil).Once()
+	for sequence := uint32(2); sequence <= 5; sequence++ {
+		core.On("GetLedger", mock.Anything, sequence).Return(testLedger(sequence), nil).Once()
+	}
+	core.On("Close").Return(nil).Once()
+
+	api := NewCaptiveCoreAPI(core, 0, log.DefaultLogger)
+	ctx := context.Background()
+
+	_, err := api.GetLedger(ctx, 2)
+	assert.Equal(t, ErrMissingPrepareRange, err)
+
+	waitForPrepareRange(t, api, ledgerbackend.BoundedRange(2, 5))
+
+	ledger, err := api.GetLedger(ctx, 3)
+	require.NoError(t, err)
+	assert.Equal(t, testLedger(3), xdr.LedgerCloseMeta(ledger.Ledger))
+
+	ledgers, err := api.GetLedgers(ctx, 2, 5)
+	require.NoError(t, err)
+	require.Len(t, ledgers.Ledgers, 4)
+	for i, ledger := range ledgers.Ledgers {
+		assert.Equal(t, testLedger(uint32(i+2)), xdr.LedgerCloseMeta(ledger))
+	}
+
+	latest, err := api.GetLatestLedgerSequence(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(5), latest.Sequence)
+
+	_, err = api.GetLedger(ctx, 6)
+	assert.EqualError(t, err, "reading past bounded range (requested sequence=6, last ledger in range=5)")
+
+	// A range contained in the prepared one is served from the buffer.
+	response, err := api.PrepareRange(ctx, ledgerbackend.BoundedRange(3, 4))
+	require.NoError(t, err)
+	assert.True(t, response.Ready)
+	assert.Equal(t, ledgerbackend.BoundedRange(2, 5), response.LedgerRange)
+
+	var streamed []uint32
+	err = api.StreamLedgers(ctx, 4, func(ledger xdr.LedgerCloseMeta) error {
+		streamed = append(streamed, ledger.LedgerSequence())
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []uint32{4, 5}, streamed)
+
+	api.Shutdown()
+	core.AssertExpectations(t)
+}
+
+func TestCaptiveCoreAPIBufferSize(t *testing.T) {
+	core := &ledgerbackend.MockDatabaseBackend{}
+	core.On("PrepareRange", mock.Anything, ledgerbackend.BoundedRange(2, 5)).Return(nil).Once()
+	for sequence := uint32(2); sequence <= 5; sequence++ {
+		core.On("GetLedger", mock.Anything, sequence).Return(testLedger(sequence), nil).Once()
+	}
+	core.On("Close").Return(nil).Once()
+
+	api := NewCaptiveCoreAPI(core, 2, log.DefaultLogger)
+	ctx := context.Background()
+	waitForPrepareRange(t, api, ledgerbackend.BoundedRange(2, 5))
+
+	// Ledgers are not read from the backend until the buffered ones are read
+	nextLedger := func() uint32 {
+		api.lock.Lock()
+		defer api.lock.Unlock()
+		return api.activeRequest.nextLedger
+	}
+	require.Eventually(t, func() bool { return nextLedger() == 4 }, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, uint32(4), nextLedger())
+
+	_, err := api.GetLedger(ctx, 5)
+	require.NoError(t, err)
+
+	_, err = api.GetLedger(ctx, 3)
+	assert.EqualError(t, err, "ledger 3 is no longer available (oldest available ledger=4)")
+
+	// The range is still served by the active request after its first
+	// ledgers were removed from the buffer.
+	response, err := api.PrepareRange(ctx, ledgerbackend.BoundedRange(2, 5))
+	require.NoError(t, err)
+	assert.True(t, response.Ready)
+
+	api.Shutdown()
+	core.AssertExpectations(t)
+}
+
+func TestCaptiveCoreAPIGetLedgersLargerThanBuffer(t *testing.T) {
+	core := &ledgerbackend.MockDatabaseBackend{}
+	core.On("PrepareRange", mock.Anything, ledgerbackend.BoundedRange(2, 5)).Return(nil).Once()
+	for sequence := uint32(2); sequence <= 5; sequence++ {
+		core.On("GetLedger", mock.Anything, sequence).Return(testLedger(sequence), nil).Once()
+	}
+	core.On("Close").Return(nil).Once()
+
+	api := NewCaptiveCoreAPI(core, 2, log.DefaultLogger)
+	waitForPrepareRange(t, api, ledgerbackend.BoundedRange(2, 5))
+
+	ledgers, err := api.GetLedgers(context.Background(), 2, 5)
+	require.NoError(t, err)
+	require.Len(t, ledgers.Ledgers, 4)
+	for i, ledger := range ledgers.Ledgers {
+		assert.Equal(t, testLedger(uint32(i+2)), xdr.LedgerCloseMeta(ledger))
+	}
+
+	api.Shutdown()
+	core.AssertExpectations(t)
+}
+
+func TestCaptiveCoreAPIGetLedgersRetry(t *testing.T) {
+	core := &ledgerbackend.MockDatabaseBackend{}
+	core.On("PrepareRange", mock.Anything, ledgerbackend.BoundedRange(2, 9)).Return(nil).Once()
+	for sequence := uint32(2); sequence <= 4; sequence++ {
+		core.On("GetLedger", mock.Anything, sequence).Return(testLedger(sequence), nil).Once()
+	}
+	ready := make(chan struct{})
+	core.On("GetLedger", mock.Anything, uint32(5)).Run(func(args mock.Arguments) {
+		<-ready
+	}).Return(testLedger(5), nil).Once()
+	for sequence := uint32(6); sequence <= 9; sequence++ {
+		core.On("GetLedger", mock.Anything, sequence).Return(testLedger(sequence), nil).Maybe()
+	}
+	core.On("Close").Return(nil).Once()
+
+	api := NewCaptiveCoreAPI(core, 4, log.DefaultLogger)
+	waitForPrepareRange(t, api, ledgerbackend.BoundedRange(2, 9))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := api.GetLedgers(ctx, 2, 5)
+	assert.Equal(t, ErrLedgerNotReady, err)
+
+	// Ledgers of the batch which timed out are still buffered
+	close(ready)
+	ledgers, err := api.GetLedgers(context.Background(), 2, 5)
+	require.NoError(t, err)
+	require.Len(t, ledgers.Ledgers, 4)
+	for i, ledger := range ledgers.Ledgers {
+		assert.Equal(t, testLedger(uint32(i+2)), xdr.LedgerCloseMeta(ledger))
+	}
+
+	api.Shutdown()
+	core.AssertExpectations(t)
+}
+
+func TestCaptiveCoreAPILedgerNotReady(t *testing.T) {
+	core := &ledgerbackend.MockDatabaseBackend{}
+	core.On("PrepareRange", mock.Anything, ledgerbackend.UnboundedRange(2)).Return(nil).Once()
+	core.On("GetLedger", mock.Anything, uint32(2)).Return(testLedger(2), nil).Once()
+	core.On("GetLedger", mock.Anything, uint32(3)).Run(func(args mock.Arguments) {
+		<-args.Get(0).(context.Context).Done()
+	}).Return(xdr.LedgerCloseMeta{}, context.Canceled).Once()
+	core.On("Close").Return(nil).Once()
+
+	api := NewCaptiveCoreAPI(core, 0, log.DefaultLogger)
+	waitForPrepareRange(t, api, ledgerbackend.UnboundedRange(2))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := api.GetLedger(ctx, 3)
+	assert.Equal(t, ErrLedgerNotReady, err)
+
+	api.Shutdown()
+	core.AssertExpectations(t)
+}
+
+func TestCaptiveCoreAPIPrepareRangeError(t *testing.T) {
+	core := &ledgerbackend.MockDatabaseBackend{}
+	core.On("PrepareRange", mock.Anything, ledgerbackend.UnboundedRange(2)).
+		Return(errors.New("transient error")).Once()
+	core.On("PrepareRange", mock.Anything, ledgerbackend.UnboundedRange(2)).
+		Return(nil).Once()
+	core.On("GetLedger", mock.Anything, uint32(2)).Return(testLedger(2), nil).Maybe()
+	core.On("GetLedger", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		<-args.Get(0).(context.Context).Done()
+	}).Return(xdr.LedgerCloseMeta{}, context.Canceled).Maybe()
+	core.On("Close").Return(nil).Once()
+
+	api := NewCaptiveCoreAPI(core, 0, log.DefaultLogger)
+	ctx := context.Background()
+
+	var err error
+	require.Eventually(t, func() bool {
+		_, err = api.PrepareRange(ctx, ledgerbackend.UnboundedRange(2))
+		return err != nil
+	}, time.Second, time.Millisecond)
+	assert.EqualError(t, err, "error preparing range: transient error")
+
+	// The range is prepared again after the error is returned
+	waitForPrepareRange(t, api, ledgerbackend.UnboundedRange(2))
+
+	api.Shutdown()
+	core.AssertExpectations(t)
+}
+
+func TestCaptiveCoreAPIStreamLedgersRequestChanged(t *testing.T) {
+	core := &ledgerbackend.MockDatabaseBackend{}
+	core.On("PrepareRange", mock.Anything, ledgerbackend.BoundedRange(2, 3)).Return(nil).Once()
+	core.On("GetLedger", mock.Anything, uint32(2)).Return(testLedger(2), nil).Once()
+	core.On("GetLedger", mock.Anything, uint32(3)).Return(testLedger(3), nil).Maybe()
+	core.On("Close").Return(nil).Once()
+
+	api := NewCaptiveCoreAPI(core, 0, log.DefaultLogger)
+	ctx := context.Background()
+	waitForPrepareRange(t, api, ledgerbackend.BoundedRange(2, 3))
+
+	var streamed []uint32
+	err := api.StreamLedgers(ctx, 2, func(ledger xdr.LedgerCloseMeta) error {
+		streamed = append(streamed, ledger.LedgerSequence())
+		// Simulate a concurrent PrepareRange returning an error, which
+		// clears the active request.
+		api.lock.Lock()
+		api.activeRequest = nil
+		api.lock.Unlock()
+		return nil
+	})
+	assert.Equal(t, ErrMissingPrepareRange, err)
+	assert.Equal(t, []uint32{2}, streamed)
+
+	api.Shutdown()
+	core.AssertExpectations(t)
+}
@@ -0,0 +1,147 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"github.com/stellar/go/ingest/ledgerbackend"
+	supporthttp "github.com/stellar/go/support/http"
+	"github.com/stellar/go/support/log"
+	"github.com/stellar/go/support/render/httpjson"
+	"github.com/stellar/go/xdr"
+)
+
+// ledgerWaitTimeout is the maximum time a request waits for a ledger. It must
+// be lower than the request timeout of ledgerbackend.RemoteCaptiveStellarCore
+// which retries requests returning http.StatusRequestTimeout.
+const ledgerWaitTimeout = 5 * time.Second
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch err {
+	case ErrMissingPrepareRange, ErrPrepareRangeNotReady:
+		status = http.StatusBadRequest
+	case ErrLedgerNotReady:
+		status = http.StatusRequestTimeout
+	}
+	http.Error(w, err.Error(), status)
+}
+
+func parseSequence(w http.ResponseWriter, r *http.Request, param string) (uint32, bool) {
+	sequence, err := strconv.ParseUint(chi.URLParam(r, param), 10, 32)
+	if err != nil {
+		http.Error(w, "invalid "+param+": "+err.Error(), http.StatusBadRequest)
+		return 0, false
+	}
+	return uint32(sequence), true
+}
+
+// Handler returns an HTTP handler which exposes captive core operations via HTTP endpoints.
+func Handler(api *CaptiveCoreAPI) http.Handler {
+	mux := supporthttp.NewAPIMux(log.DefaultLogger)
+
+	mux.Get("/latest-sequence", func(w http.ResponseWriter, r *http.Request) {
+		response, err := api.GetLatestLedgerSequence(r.Context())
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		httpjson.Render(w, response, httpjson.JSON)
+	})
+
+	mux.Get("/ledger/{sequence}", func(w http.ResponseWriter, r *http.Request) {
+		sequence, ok := parseSequence(w, r, "sequence")
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), ledgerWaitTimeout)
+		defer cancel()
+		response, err := api.GetLedger(ctx, sequence)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		httpjson.Render(w, response, httpjson.JSON)
+	})
+
+	mux.Get("/ledgers/{from}/{to}", func(w http.ResponseWriter, r *http.Request) {
+		from, ok := parseSequence(w, r, "from")
+		if !ok {
+			return
+		}
+		to, ok := parseSequence(w, r, "to")
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), ledgerWaitTimeout)
+		defer cancel()
+		response, err := api.GetLedgers(ctx, from, to)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		httpjson.Render(w, response, httpjson.JSON)
+	})
+
+	mux.Get("/stream/{from}", func(w http.ResponseWriter, r *http.Request) {
+		from, ok := parseSequence(w, r, "from")
+		if !ok {
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+			return
+		}
+
+		encoder := json.NewEncoder(w)
+		sent := false
+		err := api.StreamLedgers(r.Context(), from, func(ledger xdr.LedgerCloseMeta) error {
+			if !sent {
+				w.Header().Set("Content-Type", "application/x-ndjson")
+				sent = true
+			}
+			message := ledgerbackend.Base64Ledger(ledger)
+			if err := encoder.Encode(ledgerbackend.StreamLedgerResponse{Ledger: &message}); err != nil {
+				return err
+			}
+			flusher.Flush()
+			return nil
+		})
+		if err == nil || r.Context().Err() != nil {
+			return
+		}
+		if !sent {
+			writeError(w, err)
+			return
+		}
+		if encodeErr := encoder.Encode(ledgerbackend.StreamLedgerResponse{Error: err.Error()}); encodeErr == nil {
+			flusher.Flush()
+		}
+	})
+
+	mux.Post("/prepare-range", func(w http.ResponseWriter, r *http.Request) {
+		var ledgerRange ledgerbackend.Range
+		if err := json.NewDecoder(r.Body).Decode(&ledgerRange); err != nil {
+			http.Error(w, "invalid range: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		response, err := api.PrepareRange(r.Context(), ledgerRange)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		httpjson.Render(w, response, httpjson.JSON)
+	})
+
+	return mux
+}
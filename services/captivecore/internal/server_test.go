@@ -0,0 +1,71 @@
+package internal
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stellar/go/ingest/ledgerbackend"
+	"github.com/stellar/go/support/log"
+	"github.com/stellar/go/xdr"
+)
+
+func TestServerWithRemoteCaptiveCore(t *testing.T) {
+	core := &ledgerbackend.MockDatabaseBackend{}
+	core.On("PrepareRange", mock.Anything, ledgerbackend.BoundedRange(2, 10)).Return(nil).Once()
+	for sequence := uint32(2); sequence <= 10; sequence++ {
+		core.On("GetLedger", mock.Anything, sequence).Return(testLedger(sequence), nil).Once()
+	}
+	core.On("Close").Return(nil).Once()
+
+	api := NewCaptiveCoreAPI(core, 0, log.DefaultLogger)
+	server := httptest.NewServer(Handler(api))
+	defer server.Close()
+
+	client, err := ledgerbackend.NewRemoteCaptive(
+		server.URL,
+		ledgerbackend.PrepareRangePollInterval(time.Millisecond),
+	)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	_, err = client.GetLedger(ctx, 2)
+	assert.EqualError(t, err, "PrepareRange must be called before any other operations\n")
+
+	require.NoError(t, client.PrepareRange(ctx, ledgerbackend.BoundedRange(2, 10)))
+
+	ledger, err := client.GetLedger(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, testLedger(2), ledger)
+
+	ledgers, err := client.GetLedgers(ctx, 3, 5)
+	require.NoError(t, err)
+	assert.Equal(t, []xdr.LedgerCloseMeta{testLedger(3), testLedger(4), testLedger(5)}, ledgers)
+
+	var streamed []xdr.LedgerCloseMeta
+	err = client.StreamLedgers(ctx, 6, func(ledger xdr.LedgerCloseMeta) error {
+		streamed = append(streamed, ledger)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []xdr.LedgerCloseMeta{
+		testLedger(6), testLedger(7), testLedger(8), testLedger(9), testLedger(10),
+	}, streamed)
+
+	latest, err := client.GetLatestLedgerSequence(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(10), latest)
+
+	err = client.StreamLedgers(ctx, 11, func(ledger xdr.LedgerCloseMeta) error {
+		return nil
+	})
+	assert.EqualError(t, err, "reading past bounded range (requested sequence=11, last ledger in range=10)\n")
+
+	api.Shutdown()
+	core.AssertExpectations(t)
+}
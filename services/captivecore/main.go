@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"go/types"
+	stdhttp "net/http"
+	"strings"
+
+	"github.com/go-chi/chi"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/stellar/go/ingest/ledgerbackend"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/services/captivecore/internal"
+	"github.com/stellar/go/support/app"
+	"github.com/stellar/go/support/config"
+	supporthttp "github.com/stellar/go/support/http"
+	supportlog "github.com/stellar/go/support/log"
+)
+
+func main() {
+	var port, adminPort int
+	var networkPassphrase, binaryPath, configPath, storagePath string
+	var historyArchiveURLs []string
+	var checkpointFrequency uint32
+	var bufferSize uint
+	var useDB bool
+	var logLevel logrus.Level
+	logger := supportlog.New()
+
+	configOpts := config.ConfigOptions{
+		{
+			Name:        "port",
+			Usage:       "Port to listen and serve on",
+			OptType:     types.Int,
+			ConfigKey:   &port,
+			FlagDefault: 8000,
+			Required:    true,
+		},
+		{
+			Name:        "admin-port",
+			Usage:       "Port to serve prometheus metrics on (/metrics), 0 to disable",
+			OptType:     types.Int,
+			ConfigKey:   &adminPort,
+			FlagDefault: 0,
+			Required:    false,
+		},
+		{
+			Name:        "network-passphrase",
+			Usage:       "Network passphrase of the Stellar network to connect to",
+			OptType:     types.String,
+			ConfigKey:   &networkPassphrase,
+			FlagDefault: network.TestNetworkPassphrase,
+			Required:    true,
+		},
+		{
+			Name:        "stellar-core-binary-path",
+			Usage:       "path to stellar core binary",
+			OptType:     types.String,
+			ConfigKey:   &binaryPath,
+			FlagDefault: "",
+			Required:    true,
+		},
+		{
+			Name:        "captive-core-config-path",
+			Usage:       "path to the captive core configuration file",
+			OptType:     types.String,
+			ConfigKey:   &configPath,
+			FlagDefault: "",
+			Required:    true,
+		},
+		{
+			Name:        "captive-core-storage-path",
+			Usage:       "storage location for Captive Core bucket data",
+			OptType:     types.String,
+			ConfigKey:   &storagePath,
+			FlagDefault: "",
+			Required:    false,
+		},
+		{
+			Name:        "captive-core-use-db",
+			Usage:       "use an external database (defined in the captive core config file) instead of RAM for ledger state",
+			OptType:     types.Bool,
+			ConfigKey:   &useDB,
+			FlagDefault: false,
+			Required:    false,
+		},
+		{
+			Name:        "history-archive-urls",
+			ConfigKey:   &historyArchiveURLs,
+			OptType:     types.String,
+			Required:    true,
+			FlagDefault: "",
+			CustomSetValue: func(co *config.ConfigOption) error {
+				stringOfUrls := viper.GetString(co.Name)
+				urlStrings := strings.Split(stringOfUrls, ",")
+				*(co.ConfigKey.(*[]string)) = urlStrings
+				return nil
+			},
+			Usage: "comma-separated list of stellar history archives to connect with",
+		},
+		{
+			Name:        "checkpoint-frequency",
+			Usage:       "establishes how many ledgers exist between checkpoints, do NOT change this unless you really know what you are doing",
+			OptType:     types.Uint32,
+			ConfigKey:   &checkpointFrequency,
+			FlagDefault: uint32(64),
+			Required:    false,
+		},
+		{
+			Name:        "buffer-size",
+			Usage:       "number of the most recent ledgers kept in memory and shared between clients",
+			OptType:     types.Uint,
+			ConfigKey:   &bufferSize,
+			FlagDefault: uint(128),
+			Required:    false,
+		},
+		{
+			Name:        "log-level",
+			ConfigKey:   &logLevel,
+			OptType:     types.String,
+			FlagDefault: "info",
+			CustomSetValue: func(co *config.ConfigOption) error {
+				ll, err := logrus.ParseLevel(viper.GetString(co.Name))
+				if err != nil {
+					return fmt.Errorf("Could not parse log-level: %v", viper.GetString(co.Name))
+				}
+				*(co.ConfigKey.(*logrus.Level)) = ll
+				return nil
+			},
+			Usage: "minimum log severity (debug, info, warn, error) to log",
+		},
+	}
+	cmd := &cobra.Command{
+		Use:   "captivecore",
+		Short: "Run the remote captive core server",
+		Long: "Run a server sharing a single Captive Stellar-Core instance between many clients " +
+			"(see ledgerbackend.RemoteCaptiveStellarCore).",
+		Run: func(_ *cobra.Command, _ []string) {
+			configOpts.Require()
+			if err := configOpts.SetValues(); err != nil {
+				logger.WithError(err).Fatal("could not parse config options")
+			}
+			logger.SetLevel(logLevel)
+
+			captiveCoreToml, err := ledgerbackend.NewCaptiveCoreTomlFromFile(configPath, ledgerbackend.CaptiveCoreTomlParams{
+				NetworkPassphrase:  networkPassphrase,
+				HistoryArchiveURLs: historyArchiveURLs,
+				UseDB:              useDB,
+				CoreBinaryPath:     binaryPath,
+			})
+			if err != nil {
+				logger.WithError(err).Fatal("Invalid captive core toml")
+			}
+
+			registry := prometheus.NewRegistry()
+			core, err := ledgerbackend.NewCaptive(ledgerbackend.CaptiveCoreConfig{
+				BinaryPath:          binaryPath,
+				NetworkPassphrase:   networkPassphrase,
+				HistoryArchiveURLs:  historyArchiveURLs,
+				CheckpointFrequency: checkpointFrequency,
+				Log:                 logger.WithField("subservice", "stellar-core"),
+				Toml:                captiveCoreToml,
+				StoragePath:         storagePath,
+				UseDB:               useDB,
+				UserAgent:           "captivecore",
+				Registry:            registry,
+				RegistryNamespace:   "captivecore",
+			})
+			if err != nil {
+				logger.WithError(err).Fatal("Could not create captive core instance")
+			}
+			api := internal.NewCaptiveCoreAPI(core, uint32(bufferSize), logger.WithField("subservice", "api"))
+
+			if adminPort != 0 {
+				go serveMetrics(logger, adminPort, registry)
+			}
+
+			supporthttp.Run(supporthttp.Config{
+				ListenAddr: fmt.Sprintf(":%d", port),
+				Handler:    internal.Handler(api),
+				OnStarting: func() {
+					logger.Infof("Starting Captive Core server %s on %v", app.Version(), port)
+				},
+				OnStopping: func() {
+					api.Shutdown()
+				},
+			})
+		},
+	}
+
+	if err := configOpts.Init(cmd); err != nil {
+		logger.WithError(err).Fatal("could not parse config options")
+	}
+
+	if err := cmd.Execute(); err != nil {
+		logger.WithError(err).Fatal("could not run")
+	}
+}
+
+func serveMetrics(logger *supportlog.Entry, port int, registry *prometheus.Registry) {
+	mux := chi.NewMux()
+	mux.Get("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP)
+
+	addr := fmt.Sprintf(":%d", port)
+	logger.Infof("Serving metrics on %s", addr)
+	if err := stdhttp.ListenAndServe(addr, mux); err != nil {
+		logger.WithError(err).Error("error serving metrics")
+	}
+}
@@ -6,15 +6,20 @@ import (
 	"fmt"
 	"go/types"
 	"log"
+	"net/http"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/stellar/go/services/horizon/internal/db2/history"
 
 	horizon "github.com/stellar/go/services/horizon/internal"
+	"github.com/stellar/go/services/horizon/internal/actions"
 	"github.com/stellar/go/services/horizon/internal/db2/schema"
 	"github.com/stellar/go/services/horizon/internal/ingest"
 	support "github.com/stellar/go/support/config"
@@ -242,6 +247,7 @@ var dbReingestCmd = &cobra.Command{
 
 var (
 	reingestForce       bool
+	reingestResume      bool
 	parallelWorkers     uint
 	parallelJobSize     uint32
 	retries             uint
@@ -275,6 +281,17 @@ func ingestRangeCmdOpts() support.ConfigOptions {
 			FlagDefault: uint32(100000),
 			Usage:       "[optional] parallel workers will run jobs processing ledger batches of the supplied size",
 		},
+		{
+			Name:        "resume",
+			ConfigKey:   &reingestResume,
+			OptType:     types.Bool,
+			Required:    false,
+			FlagDefault: false,
+			Usage: "[optional] if this flag is set, parallel workers store their jobs in the reingest_jobs table, " +
+				"so rerunning the command with --resume skips the ledgers of completed jobs and several machines " +
+				"can share the work (requires --parallel-workers > 1). Jobs are kept after the command completes, " +
+				"run without --resume to reingest their ledgers again or delete them from the reingest_jobs table",
+		},
 		{
 			Name:        "retries",
 			ConfigKey:   &retries,
@@ -398,31 +415,43 @@ func runDBReingestRange(ledgerRanges []history.LedgerRange, reingestForce bool,
 	if reingestForce && parallelWorkers > 1 {
 		return errors.New("--force is incompatible with --parallel-workers > 1")
 	}
+	if reingestResume && parallelWorkers <= 1 {
+		return errors.New("--resume requires --parallel-workers > 1")
+	}
 
 	ingestConfig := ingest.Config{
 		NetworkPassphrase:           config.NetworkPassphrase,
 		HistoryArchiveURLs:          config.HistoryArchiveURLs,
+		HistoryArchiveCache:         config.HistoryArchiveCacheOptions(),
 		CheckpointFrequency:         config.CheckpointFrequency,
 		ReingestEnabled:             true,
 		MaxReingestRetries:          int(retries),
 		ReingestRetryBackoffSeconds: int(retryBackoffSeconds),
+		ResumeReingestion:           reingestResume,
 		EnableCaptiveCore:           config.EnableCaptiveCoreIngestion,
 		CaptiveCoreBinaryPath:       config.CaptiveCoreBinaryPath,
 		CaptiveCoreConfigUseDB:      config.CaptiveCoreConfigUseDB,
 		RemoteCaptiveCoreURL:        config.RemoteCaptiveCoreURL,
+		LedgerFilesURL:              config.LedgerFilesURL,
 		CaptiveCoreToml:             config.CaptiveCoreToml,
 		CaptiveCoreStoragePath:      config.CaptiveCoreStoragePath,
 		StellarCoreCursor:           config.CursorName,
 		StellarCoreURL:              config.StellarCoreURL,
 		RoundingSlippageFilter:      config.RoundingSlippageFilter,
 		EnableIngestionFiltering:    config.EnableIngestionFiltering,
+		Progress:                    ingest.NewProgressTracker(),
+	}
+
+	if config.AdminPort != 0 {
+		adminServer := startReingestAdminServer(config.AdminPort, ingestConfig.Progress)
+		defer adminServer.Close()
 	}
 
 	if ingestConfig.HistorySession, err = db.Open("postgres", config.DatabaseURL); err != nil {
 		return fmt.Errorf("cannot open Horizon DB: %v", err)
 	}
 
-	if !config.EnableCaptiveCoreIngestion {
+	if !config.EnableCaptiveCoreIngestion && config.LedgerFilesURL == "" {
 		if config.StellarCoreDatabaseURL == "" {
 			return fmt.Errorf("flag --%s cannot be empty", horizon.StellarCoreDBURLFlagName)
 		}
@@ -467,6 +496,30 @@ the reingest command completes.`)
 	return nil
 }
 
+// startReingestAdminServer serves the reingestion progress as JSON and
+// Prometheus metrics on the admin port.
+func startReingestAdminServer(port uint, progress *ingest.ProgressTracker) *http.Server {
+	registry := prometheus.NewRegistry()
+	progress.RegisterMetrics(registry)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/ingestion/progress", actions.IngestionProgressHandler{Getter: progress}.GetProgress)
+
+	server := &http.Server{
+		Addr:        fmt.Sprintf(":%d", port),
+		Handler:     mux,
+		ReadTimeout: 5 * time.Second,
+	}
+	go func() {
+		hlog.Infof("Starting admin server on %s", server.Addr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			hlog.Warnf("error in admin server: %v", err)
+		}
+	}()
+	return server
+}
+
 var dbDetectGapsCmd = &cobra.Command{
 	Use:   "detect-gaps",
 	Short: "detects ingestion gaps in Horizon's database",
@@ -56,13 +56,17 @@ func (handler GetEffectsHandler) GetResourcePage(w HeaderWriter, r *http.Request
 		return nil, err
 	}
 
-	err = validateCursorWithinHistory(handler.LedgerState, pq)
+	qp := EffectsQuery{}
+	err = getParams(&qp, r)
 	if err != nil {
 		return nil, err
 	}
 
-	qp := EffectsQuery{}
-	err = getParams(&qp, r)
+	tables := []string{"history_effects"}
+	if qp.LiquidityPoolID != "" {
+		tables = append(tables, "history_operation_liquidity_pools")
+	}
+	err = validateCursorWithinHistory(handler.LedgerState, pq, tables...)
 	if err != nil {
 		return nil, err
 	}
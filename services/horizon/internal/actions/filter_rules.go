@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/stellar/go/amount"
 	hProtocol "github.com/stellar/go/protocols/horizon"
 	horizonContext "github.com/stellar/go/services/horizon/internal/context"
 	"github.com/stellar/go/services/horizon/internal/db2/history"
+	"github.com/stellar/go/services/horizon/internal/ingest/filters"
 	"github.com/stellar/go/support/render/problem"
 )
 
@@ -72,6 +74,7 @@ func (handler FilterConfigHandler) UpdateAccountConfig(w http.ResponseWriter, r
 	filterConfig := history.AccountFilterConfig{}
 	filterConfig.Enabled = *filterRequest.Enabled
 	filterConfig.Whitelist = filterRequest.Whitelist
+	filterConfig.Blacklist = filterRequest.Blacklist
 
 	config, err := historyQ.UpdateAccountFilterConfig(r.Context(), filterConfig)
 	if err != nil {
@@ -85,6 +88,121 @@ func (handler FilterConfigHandler) UpdateAccountConfig(w http.ResponseWriter, r
 	}
 }
 
+func (handler FilterConfigHandler) GetTransactionConfig(w http.ResponseWriter, r *http.Request) {
+	historyQ, err := horizonContext.HistoryQFromRequest(r)
+	if err != nil {
+		problem.Render(r.Context(), w, err)
+		return
+	}
+
+	config, err := historyQ.GetTransactionFilterConfig(r.Context())
+
+	if err != nil {
+		problem.Render(r.Context(), w, err)
+		return
+	}
+
+	responsePayload := handler.transactionConfigResource(config)
+	enc := json.NewEncoder(w)
+	if err = enc.Encode(responsePayload); err != nil {
+		problem.Render(r.Context(), w, err)
+	}
+}
+
+func (handler FilterConfigHandler) UpdateTransactionConfig(w http.ResponseWriter, r *http.Request) {
+	historyQ, err := horizonContext.HistoryQFromRequest(r)
+	if err != nil {
+		problem.Render(r.Context(), w, err)
+		return
+	}
+
+	filterConfig, err := handler.transactionFilterResource(r)
+	if err != nil {
+		problem.Render(r.Context(), w, err)
+		return
+	}
+
+	config, err := historyQ.UpdateTransactionFilterConfig(r.Context(), filterConfig)
+	if err != nil {
+		problem.Render(r.Context(), w, err)
+		return
+	}
+
+	responsePayload := handler.transactionConfigResource(config)
+	enc := json.NewEncoder(w)
+	if err = enc.Encode(responsePayload); err != nil {
+		problem.Render(r.Context(), w, err)
+	}
+}
+
+func (handler FilterConfigHandler) GetMode(w http.ResponseWriter, r *http.Request) {
+	historyQ, err := horizonContext.HistoryQFromRequest(r)
+	if err != nil {
+		problem.Render(r.Context(), w, err)
+		return
+	}
+
+	mode, err := historyQ.GetFilterMode(r.Context())
+	if err != nil {
+		problem.Render(r.Context(), w, err)
+		return
+	}
+
+	enc := json.NewEncoder(w)
+	if err = enc.Encode(hProtocol.FilterModeConfig{Mode: string(mode)}); err != nil {
+		problem.Render(r.Context(), w, err)
+	}
+}
+
+func (handler FilterConfigHandler) UpdateMode(w http.ResponseWriter, r *http.Request) {
+	historyQ, err := horizonContext.HistoryQFromRequest(r)
+	if err != nil {
+		problem.Render(r.Context(), w, err)
+		return
+	}
+
+	var modeRequest hProtocol.FilterModeConfig
+	dec := json.NewDecoder(r.Body)
+	if err = dec.Decode(&modeRequest); err != nil {
+		p := problem.NewProblemWithInvalidField(problem.BadRequest, "reason", fmt.Errorf("invalid json for filter mode config %v", err.Error()))
+		problem.Render(r.Context(), w, p)
+		return
+	}
+	mode := history.FilterMode(modeRequest.Mode)
+	if mode != history.FilterModeAll && mode != history.FilterModeAny {
+		p := problem.NewProblemWithInvalidField(problem.BadRequest, "mode", fmt.Errorf("mode must be %q or %q", history.FilterModeAll, history.FilterModeAny))
+		problem.Render(r.Context(), w, p)
+		return
+	}
+
+	if err = historyQ.UpdateFilterMode(r.Context(), mode); err != nil {
+		problem.Render(r.Context(), w, err)
+		return
+	}
+
+	enc := json.NewEncoder(w)
+	if err = enc.Encode(modeRequest); err != nil {
+		problem.Render(r.Context(), w, err)
+	}
+}
+
+// FilterBackfillStatusGetter returns the status of the history backfill
+// scheduled after ingestion filter rules change.
+type FilterBackfillStatusGetter interface {
+	FilterBackfillStatus() hProtocol.FilterBackfillStatus
+}
+
+type FilterBackfillHandler struct {
+	Getter FilterBackfillStatusGetter
+}
+
+func (handler FilterBackfillHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(handler.Getter.FilterBackfillStatus()); err != nil {
+		problem.Render(r.Context(), w, err)
+	}
+}
+
 func (handler FilterConfigHandler) UpdateAssetConfig(w http.ResponseWriter, r *http.Request) {
 	historyQ, err := horizonContext.HistoryQFromRequest(r)
 	if err != nil {
@@ -101,6 +219,7 @@ func (handler FilterConfigHandler) UpdateAssetConfig(w http.ResponseWriter, r *h
 	filterConfig := history.AssetFilterConfig{}
 	filterConfig.Enabled = *filterRequest.Enabled
 	filterConfig.Whitelist = filterRequest.Whitelist
+	filterConfig.Blacklist = filterRequest.Blacklist
 
 	config, err := historyQ.UpdateAssetFilterConfig(r.Context(), filterConfig)
 	if err != nil {
@@ -134,9 +253,45 @@ func (handler FilterConfigHandler) accountFilterResource(r *http.Request) (hProt
 	return filterRequest, nil
 }
 
+// transactionFilterResource decodes and validates the transaction filter
+// config from the request body.
+func (handler FilterConfigHandler) transactionFilterResource(r *http.Request) (history.TransactionFilterConfig, error) {
+	var filterRequest hProtocol.TransactionFilterConfig
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&filterRequest); err != nil {
+		p := problem.NewProblemWithInvalidField(problem.BadRequest, "reason", fmt.Errorf("invalid json for transaction filter config %v", err.Error()))
+		return history.TransactionFilterConfig{}, p
+	}
+
+	for _, name := range filterRequest.OperationTypes {
+		if _, ok := filters.OperationTypesByName[name]; !ok {
+			p := problem.NewProblemWithInvalidField(problem.BadRequest, "operation_types", fmt.Errorf("unknown operation type %q", name))
+			return history.TransactionFilterConfig{}, p
+		}
+	}
+
+	var minPaymentAmount int64
+	if filterRequest.MinPaymentAmount != "" {
+		parsed, err := amount.ParseInt64(filterRequest.MinPaymentAmount)
+		if err != nil || parsed < 0 {
+			p := problem.NewProblemWithInvalidField(problem.BadRequest, "min_payment_amount", fmt.Errorf("invalid amount %q", filterRequest.MinPaymentAmount))
+			return history.TransactionFilterConfig{}, p
+		}
+		minPaymentAmount = parsed
+	}
+
+	return history.TransactionFilterConfig{
+		Enabled:          *filterRequest.Enabled,
+		OperationTypes:   filterRequest.OperationTypes,
+		Memos:            filterRequest.Memos,
+		MinPaymentAmount: minPaymentAmount,
+	}, nil
+}
+
 func (handler FilterConfigHandler) assetConfigResource(config history.AssetFilterConfig) hProtocol.AssetFilterConfig {
 	return hProtocol.AssetFilterConfig{
 		Whitelist:    config.Whitelist,
+		Blacklist:    config.Blacklist,
 		Enabled:      &config.Enabled,
 		LastModified: config.LastModified,
 	}
@@ -145,7 +300,21 @@ func (handler FilterConfigHandler) assetConfigResource(config history.AssetFilte
 func (handler FilterConfigHandler) accountConfigResource(config history.AccountFilterConfig) hProtocol.AccountFilterConfig {
 	return hProtocol.AccountFilterConfig{
 		Whitelist:    config.Whitelist,
+		Blacklist:    config.Blacklist,
 		Enabled:      &config.Enabled,
 		LastModified: config.LastModified,
 	}
 }
+
+func (handler FilterConfigHandler) transactionConfigResource(config history.TransactionFilterConfig) hProtocol.TransactionFilterConfig {
+	resource := hProtocol.TransactionFilterConfig{
+		OperationTypes: config.OperationTypes,
+		Memos:          config.Memos,
+		Enabled:        &config.Enabled,
+		LastModified:   config.LastModified,
+	}
+	if config.MinPaymentAmount > 0 {
+		resource.MinPaymentAmount = amount.StringFromInt64(config.MinPaymentAmount)
+	}
+	return resource
+}
@@ -244,3 +244,114 @@ func TestUpdateAccountFilterConfig(t *testing.T) {
 	tt.Assert.True(filterCfgResource.LastModified > 0)
 	tt.Assert.ElementsMatch(filterCfgResource.Whitelist, []string{"4", "5", "6"})
 }
+
+func TestUpdateTransactionFilterConfig(t *testing.T) {
+	tt := test.Start(t)
+	defer tt.Finish()
+	test.ResetHorizonDB(t, tt.HorizonDB)
+
+	q := &history.Q{SessionInterface: tt.HorizonSession()}
+
+	handler := &FilterConfigHandler{}
+	recorder := httptest.NewRecorder()
+	request := makeRequest(
+		t,
+		map[string]string{},
+		map[string]string{},
+		q,
+	)
+
+	request.Body = ioutil.NopCloser(strings.NewReader(`
+	    {
+			"operation_types": ["payment", "path_payment_strict_send"],
+			"memos": ["123"],
+			"min_payment_amount": "10.5",
+			"enabled": true
+		}`))
+
+	handler.UpdateTransactionConfig(
+		recorder,
+		request,
+	)
+
+	resp := recorder.Result()
+	tt.Assert.Equal(http.StatusOK, resp.StatusCode)
+
+	raw, err := ioutil.ReadAll(resp.Body)
+	tt.Assert.NoError(err)
+
+	var filterCfgResource hProtocol.TransactionFilterConfig
+	tt.Assert.NoError(json.Unmarshal(raw, &filterCfgResource))
+
+	tt.Assert.Equal(*filterCfgResource.Enabled, true)
+	tt.Assert.True(filterCfgResource.LastModified > 0)
+	tt.Assert.ElementsMatch(filterCfgResource.OperationTypes, []string{"payment", "path_payment_strict_send"})
+	tt.Assert.Equal([]string{"123"}, filterCfgResource.Memos)
+	tt.Assert.Equal("10.5000000", filterCfgResource.MinPaymentAmount)
+
+	config, err := q.GetTransactionFilterConfig(tt.Ctx)
+	tt.Assert.NoError(err)
+	tt.Assert.Equal(int64(105000000), config.MinPaymentAmount)
+}
+
+func TestInvalidUpdateTransactionFilterConfig(t *testing.T) {
+	tt := test.Start(t)
+	defer tt.Finish()
+	test.ResetHorizonDB(t, tt.HorizonDB)
+
+	q := &history.Q{SessionInterface: tt.HorizonSession()}
+
+	handler := &FilterConfigHandler{}
+	for _, body := range []string{
+		`{"operation_types": ["payment"]}`,
+		`{"operation_types": ["pay"], "enabled": true}`,
+		`{"min_payment_amount": "-1", "enabled": true}`,
+	} {
+		recorder := httptest.NewRecorder()
+		request := makeRequest(
+			t,
+			map[string]string{},
+			map[string]string{},
+			q,
+		)
+		request.Body = ioutil.NopCloser(strings.NewReader(body))
+
+		handler.UpdateTransactionConfig(recorder, request)
+		tt.Assert.Equal(http.StatusBadRequest, recorder.Result().StatusCode, body)
+	}
+}
+
+func TestUpdateFilterMode(t *testing.T) {
+	tt := test.Start(t)
+	defer tt.Finish()
+	test.ResetHorizonDB(t, tt.HorizonDB)
+
+	q := &history.Q{SessionInterface: tt.HorizonSession()}
+
+	handler := &FilterConfigHandler{}
+	recorder := httptest.NewRecorder()
+	request := makeRequest(
+		t,
+		map[string]string{},
+		map[string]string{},
+		q,
+	)
+	request.Body = ioutil.NopCloser(strings.NewReader(`{"mode": "some"}`))
+	handler.UpdateMode(recorder, request)
+	tt.Assert.Equal(http.StatusBadRequest, recorder.Result().StatusCode)
+
+	recorder = httptest.NewRecorder()
+	request.Body = ioutil.NopCloser(strings.NewReader(`{"mode": "any"}`))
+	handler.UpdateMode(recorder, request)
+	tt.Assert.Equal(http.StatusOK, recorder.Result().StatusCode)
+
+	recorder = httptest.NewRecorder()
+	handler.GetMode(recorder, request)
+	resp := recorder.Result()
+	tt.Assert.Equal(http.StatusOK, resp.StatusCode)
+	raw, err := ioutil.ReadAll(resp.Body)
+	tt.Assert.NoError(err)
+	var modeResource hProtocol.FilterModeConfig
+	tt.Assert.NoError(json.Unmarshal(raw, &modeResource))
+	tt.Assert.Equal("any", modeResource.Mode)
+}
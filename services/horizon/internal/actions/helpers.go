@@ -540,9 +540,10 @@ func validateAssetParams(aType, code, issuer, prefix string) error {
 }
 
 // validateCursorWithinHistory compares the requested page of data against the
-// ledger state of the history database.  In the event that the cursor is
-// guaranteed to return no results, we return a 410 GONE http response.
-func validateCursorWithinHistory(ledgerState *ledger.State, pq db2.PageQuery) error {
+// ledger state of the history tables backing the page.  In the event that the
+// cursor is guaranteed to return no results, we return a 410 GONE http
+// response.
+func validateCursorWithinHistory(ledgerState *ledger.State, pq db2.PageQuery, tables ...string) error {
 	// an ascending query should never return a gone response:  An ascending query
 	// prior to known history should return results at the beginning of history,
 	// and an ascending query beyond the end of history should not error out but
@@ -567,7 +568,16 @@ func validateCursorWithinHistory(ledgerState *ledger.State, pq db2.PageQuery) er
 		return problem.MakeInvalidFieldProblem("cursor", errors.New("invalid value"))
 	}
 
-	elder := toid.New(ledgerState.CurrentStatus().HistoryElder, 0, 0)
+	// Tables can be reaped separately so the page is gone when any of the
+	// tables backing it is reaped.
+	status := ledgerState.CurrentStatus()
+	elderSequence := status.HistoryElder
+	for i, table := range tables {
+		if tableElder := status.HistoryTableElder(table); i == 0 || tableElder > elderSequence {
+			elderSequence = tableElder
+		}
+	}
+	elder := toid.New(elderSequence, 0, 0)
 
 	if cursor <= elder.ToInt64() {
 		return &hProblem.BeforeHistory
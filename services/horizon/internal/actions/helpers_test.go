@@ -15,6 +15,7 @@ import (
 	horizonContext "github.com/stellar/go/services/horizon/internal/context"
 	"github.com/stellar/go/services/horizon/internal/db2"
 	"github.com/stellar/go/services/horizon/internal/ledger"
+	hProblem "github.com/stellar/go/services/horizon/internal/render/problem"
 	"github.com/stellar/go/services/horizon/internal/test"
 	"github.com/stellar/go/support/db"
 	"github.com/stellar/go/support/errors"
@@ -137,7 +138,7 @@ func TestValidateCursorWithinHistory(t *testing.T) {
 		t.Run(fmt.Sprintf("cursor: %s", tc.cursor), func(t *testing.T) {
 			pq, err := db2.NewPageQuery(tc.cursor, false, tc.order, 10)
 			tt.NoError(err)
-			err = validateCursorWithinHistory(&ledger.State{}, pq)
+			err = validateCursorWithinHistory(&ledger.State{}, pq, "history_ledgers")
 
 			if tc.valid {
 				tt.NoError(err)
@@ -148,6 +149,33 @@ func TestValidateCursorWithinHistory(t *testing.T) {
 	}
 }
 
+func TestValidateCursorWithinTableHistory(t *testing.T) {
+	ledgerState := &ledger.State{}
+	ledgerState.SetHorizonStatus(ledger.HorizonStatus{
+		HistoryElder: 10,
+		HistoryTableElders: map[string]int32{
+			"history_effects":                100,
+			"history_operation_participants": 50,
+		},
+	})
+	cursor := func(sequence int32) db2.PageQuery {
+		pq, err := db2.NewPageQuery(toid.New(sequence, 0, 0).String(), false, "desc", 10)
+		assert.NoError(t, err)
+		return pq
+	}
+
+	assert.NoError(t, validateCursorWithinHistory(ledgerState, cursor(20), "history_ledgers"))
+	assert.NoError(t, validateCursorWithinHistory(ledgerState, cursor(20), "history_operations"))
+	// Effects are retained for fewer ledgers than ledgers
+	assert.Equal(t, &hProblem.BeforeHistory, validateCursorWithinHistory(ledgerState, cursor(20), "history_effects"))
+	assert.NoError(t, validateCursorWithinHistory(ledgerState, cursor(101), "history_effects"))
+	// The page is gone when any of the tables backing it is reaped
+	assert.Equal(t, &hProblem.BeforeHistory,
+		validateCursorWithinHistory(ledgerState, cursor(20), "history_operations", "history_operation_participants"))
+	assert.NoError(t,
+		validateCursorWithinHistory(ledgerState, cursor(51), "history_operations", "history_operation_participants"))
+}
+
 func TestActionGetLimit(t *testing.T) {
 	tt := test.Start(t)
 	defer tt.Finish()
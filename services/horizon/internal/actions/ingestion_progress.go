@@ -0,0 +1,28 @@
+package actions
+
+import (
+	"encoding/json"
+	"net/http"
+
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/support/render/problem"
+)
+
+// IngestionProgressGetter returns the progress of reingestion and of building
+// the state from a history archive checkpoint.
+type IngestionProgressGetter interface {
+	IngestionProgress() hProtocol.IngestionProgress
+}
+
+// IngestionProgressHandler serves the ingestion progress on the admin port,
+// it's documented in services/horizon/internal/httpx/static/admin_oapi.yml
+type IngestionProgressHandler struct {
+	Getter IngestionProgressGetter
+}
+
+func (handler IngestionProgressHandler) GetProgress(w http.ResponseWriter, r *http.Request) {
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(handler.Getter.IngestionProgress()); err != nil {
+		problem.Render(r.Context(), w, err)
+	}
+}
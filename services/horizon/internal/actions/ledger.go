@@ -22,7 +22,7 @@ func (handler GetLedgersHandler) GetResourcePage(w HeaderWriter, r *http.Request
 		return nil, err
 	}
 
-	err = validateCursorWithinHistory(handler.LedgerState, pq)
+	err = validateCursorWithinHistory(handler.LedgerState, pq, "history_ledgers")
 	if err != nil {
 		return nil, err
 	}
@@ -37,6 +37,20 @@ type OperationsQuery struct {
 	LedgerID                  uint32 `schema:"ledger_id" valid:"-"`
 }
 
+// historyTables returns the history tables storing the requested operations.
+func (qp OperationsQuery) historyTables() []string {
+	switch {
+	case qp.AccountID != "":
+		return []string{"history_operations", "history_operation_participants"}
+	case qp.ClaimableBalanceID != "":
+		return []string{"history_operations", "history_operation_claimable_balances"}
+	case qp.LiquidityPoolID != "":
+		return []string{"history_operations", "history_operation_liquidity_pools"}
+	default:
+		return []string{"history_operations"}
+	}
+}
+
 // Validate runs extra validations on query parameters
 func (qp OperationsQuery) Validate() error {
 	filters, err := countNonEmpty(
@@ -76,13 +90,13 @@ func (handler GetOperationsHandler) GetResourcePage(w HeaderWriter, r *http.Requ
 		return nil, err
 	}
 
-	err = validateCursorWithinHistory(handler.LedgerState, pq)
+	qp := OperationsQuery{}
+	err = getParams(&qp, r)
 	if err != nil {
 		return nil, err
 	}
 
-	qp := OperationsQuery{}
-	err = getParams(&qp, r)
+	err = validateCursorWithinHistory(handler.LedgerState, pq, qp.historyTables()...)
 	if err != nil {
 		return nil, err
 	}
@@ -144,7 +158,7 @@ type OperationQuery struct {
 // Validate runs extra validations on query parameters
 func (qp OperationQuery) Validate() error {
 	parsed := toid.Parse(int64(qp.ID))
-	if parsed.LedgerSequence < qp.LedgerState.CurrentStatus().HistoryElder {
+	if parsed.LedgerSequence < qp.LedgerState.CurrentStatus().HistoryTableElder("history_operations") {
 		return problem.BeforeHistory
 	}
 	return nil
@@ -164,7 +164,7 @@ func (handler GetTradesHandler) GetResourcePage(w HeaderWriter, r *http.Request)
 		return nil, err
 	}
 
-	err = validateCursorWithinHistory(handler.LedgerState, pq)
+	err = validateCursorWithinHistory(handler.LedgerState, pq, "history_trades")
 	if err != nil {
 		return nil, err
 	}
@@ -287,7 +287,7 @@ func (handler GetTradeAggregationsHandler) GetResource(w HeaderWriter, r *http.R
 	if err != nil {
 		return nil, err
 	}
-	err = validateCursorWithinHistory(handler.LedgerState, pq)
+	err = validateCursorWithinHistory(handler.LedgerState, pq, "history_trades_60000")
 	if err != nil {
 		return nil, err
 	}
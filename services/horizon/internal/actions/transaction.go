@@ -64,6 +64,20 @@ type TransactionsQuery struct {
 	LedgerID                  uint32 `schema:"ledger_id" valid:"-"`
 }
 
+// historyTables returns the history tables storing the requested transactions.
+func (qp TransactionsQuery) historyTables() []string {
+	switch {
+	case qp.AccountID != "":
+		return []string{"history_transactions", "history_transaction_participants"}
+	case qp.ClaimableBalanceID != "":
+		return []string{"history_transactions", "history_transaction_claimable_balances"}
+	case qp.LiquidityPoolID != "":
+		return []string{"history_transactions", "history_transaction_liquidity_pools"}
+	default:
+		return []string{"history_transactions"}
+	}
+}
+
 // Validate runs extra validations on query parameters
 func (qp TransactionsQuery) Validate() error {
 	filters, err := countNonEmpty(
@@ -101,13 +115,13 @@ func (handler GetTransactionsHandler) GetResourcePage(w HeaderWriter, r *http.Re
 		return nil, err
 	}
 
-	err = validateCursorWithinHistory(handler.LedgerState, pq)
+	qp := TransactionsQuery{}
+	err = getParams(&qp, r)
 	if err != nil {
 		return nil, err
 	}
 
-	qp := TransactionsQuery{}
-	err = getParams(&qp, r)
+	err = validateCursorWithinHistory(handler.LedgerState, pq, qp.historyTables()...)
 	if err != nil {
 		return nil, err
 	}
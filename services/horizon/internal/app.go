@@ -50,6 +50,11 @@ type App struct {
 	ticks           *time.Ticker
 	ledgerState     *ledger.State
 
+	// filterBackfiller is set only when the filter backfill is enabled
+	filterBackfiller *ingest.FilterBackfiller
+	// ingestionProgress is set only when ingestion is enabled
+	ingestionProgress *ingest.ProgressTracker
+
 	// metrics
 	prometheusRegistry *prometheus.Registry
 	buildInfoGauge     *prometheus.GaugeVec
@@ -114,6 +119,14 @@ func (a *App) Serve() error {
 		}()
 	}
 
+	if a.filterBackfiller != nil {
+		wg.Add(1)
+		go func() {
+			a.filterBackfiller.Run()
+			wg.Done()
+		}()
+	}
+
 	// configure shutdown signal handler
 	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
@@ -181,6 +194,9 @@ func (a *App) waitForDone() {
 	if a.reaper != nil {
 		a.reaper.Shutdown()
 	}
+	if a.filterBackfiller != nil {
+		a.filterBackfiller.Shutdown()
+	}
 	a.ticks.Stop()
 }
 
@@ -268,6 +284,20 @@ func (a *App) UpdateHorizonLedgerState(ctx context.Context) {
 		return
 	}
 
+	if a.reaper != nil {
+		for _, table := range a.reaper.TablesWithOwnRetention() {
+			elder, elderErr := a.HistoryQ().HistoryTableElderLedger(ctx, table)
+			if elderErr != nil {
+				logErr(elderErr, "failed to load the oldest ledger of a history table from history DB")
+				return
+			}
+			if next.HistoryTableElders == nil {
+				next.HistoryTableElders = map[string]int32{}
+			}
+			next.HistoryTableElders[table] = elder
+		}
+	}
+
 	next.ExpHistoryLatest, err = a.HistoryQ().GetLastLedgerIngestNonBlocking(ctx)
 	if err != nil {
 		logErr(err, "failed to load the oldest known exp ledger state from history DB")
@@ -502,7 +532,11 @@ func (a *App) init() error {
 	initSubmissionSystem(a)
 
 	// reaper
-	a.reaper = reap.New(a.config.HistoryRetentionCount, a.HorizonSession(), a.ledgerState)
+	a.reaper = reap.New(reap.Config{
+		RetentionCount:       a.config.HistoryRetentionCount,
+		TableRetentionCounts: a.config.HistoryRetentionCounts,
+		BatchSize:            uint32(a.config.HistoryRetentionBatchSize),
+	}, a.HorizonSession(), a.ledgerState)
 
 	// go metrics
 	initGoMetrics(a)
@@ -519,6 +553,9 @@ func (a *App) init() error {
 	// txsub.metrics
 	initTxSubMetrics(a)
 
+	// reap.metrics
+	initReapMetrics(a)
+
 	routerConfig := httpx.RouterConfig{
 		DBSession:               a.historyQ.SessionInterface,
 		TxSubmitter:             a.submitter,
@@ -548,6 +585,14 @@ func (a *App) init() error {
 		EnableIngestionFiltering: a.config.EnableIngestionFiltering,
 	}
 
+	if a.filterBackfiller != nil {
+		routerConfig.FilterBackfill = a.filterBackfiller
+	}
+
+	if a.ingestionProgress != nil {
+		routerConfig.IngestionProgress = a.ingestionProgress
+	}
+
 	if a.primaryHistoryQ != nil {
 		routerConfig.PrimaryDBSession = a.primaryHistoryQ.SessionInterface
 	}
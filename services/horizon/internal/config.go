@@ -4,6 +4,7 @@ import (
 	"net/url"
 	"time"
 
+	"github.com/stellar/go/historyarchive"
 	"github.com/stellar/go/ingest/ledgerbackend"
 
 	"github.com/sirupsen/logrus"
@@ -19,11 +20,19 @@ type Config struct {
 	Port               uint
 	AdminPort          uint
 
+	// HistoryArchiveCachePath is the (optional) directory in which files
+	// downloaded from history archives are cached.
+	HistoryArchiveCachePath string
+	// HistoryArchiveCacheSize is the maximum size of the history archive
+	// cache in MB.
+	HistoryArchiveCacheSize uint
+
 	EnableCaptiveCoreIngestion  bool
 	EnableIngestionFiltering    bool
 	UsingDefaultPubnetConfig    bool
 	CaptiveCoreBinaryPath       string
 	RemoteCaptiveCoreURL        string
+	LedgerFilesURL              string
 	CaptiveCoreConfigPath       string
 	CaptiveCoreTomlParams       ledgerbackend.CaptiveCoreTomlParams
 	CaptiveCoreToml             *ledgerbackend.CaptiveCoreToml
@@ -31,6 +40,10 @@ type Config struct {
 	CaptiveCoreReuseStoragePath bool
 	CaptiveCoreConfigUseDB      bool
 
+	// EnableIngestionFilterBackfill enables reingestion of the retained
+	// history window when ingestion filter rules change.
+	EnableIngestionFilterBackfill bool
+
 	StellarCoreDatabaseURL string
 	StellarCoreURL         string
 
@@ -79,6 +92,12 @@ type Config struct {
 	// determining a "retention duration", each ledger roughly corresponds to 10
 	// seconds of real time.
 	HistoryRetentionCount uint
+	// HistoryRetentionCounts overrides HistoryRetentionCount for individual
+	// history tables.
+	HistoryRetentionCounts map[string]uint
+	// HistoryRetentionBatchSize is the number of ledgers removed from a history
+	// table by the reaper in a single database transaction.
+	HistoryRetentionBatchSize uint
 	// StaleThreshold represents the number of ledgers a history database may be
 	// out-of-date by before horizon begins to respond with an error to history
 	// requests.
@@ -115,3 +134,12 @@ type Config struct {
 	// RoundingSlippageFilter excludes trades from /trade_aggregations with rounding slippage >x bps
 	RoundingSlippageFilter int
 }
+
+// HistoryArchiveCacheOptions returns the configuration of the on-disk history
+// archive cache.
+func (c Config) HistoryArchiveCacheOptions() historyarchive.CacheOptions {
+	return historyarchive.CacheOptions{
+		Path:    c.HistoryArchiveCachePath,
+		MaxSize: int64(c.HistoryArchiveCacheSize) * 1024 * 1024,
+	}
+}
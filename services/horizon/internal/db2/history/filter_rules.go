@@ -9,30 +9,64 @@ import (
 )
 
 const (
-	assetFilterRulesTableName   = "asset_filter_rules"
-	accountFilterRulesTableName = "account_filter_rules"
-	whitelistColumnName         = "whitelist"
-	enabledColumnName           = "enabled"
-	lastModifiedColumnName      = "last_modified"
+	assetFilterRulesTableName       = "asset_filter_rules"
+	accountFilterRulesTableName     = "account_filter_rules"
+	transactionFilterRulesTableName = "transaction_filter_rules"
+	whitelistColumnName             = "whitelist"
+	blacklistColumnName             = "blacklist"
+	enabledColumnName               = "enabled"
+	lastModifiedColumnName          = "last_modified"
+	operationTypesColumnName        = "operation_types"
+	memosColumnName                 = "memos"
+	minPaymentAmountColumnName      = "min_payment_amount"
+)
+
+// FilterMode defines how the results of ingestion filters are combined.
+type FilterMode string
+
+const (
+	// FilterModeAll ingests a transaction only if it's matched by all
+	// active filters.
+	FilterModeAll FilterMode = "all"
+	// FilterModeAny ingests a transaction if it's matched by any of the
+	// active filters.
+	FilterModeAny FilterMode = "any"
 )
 
 type AssetFilterConfig struct {
 	Enabled      bool           `db:"enabled"`
 	Whitelist    pq.StringArray `db:"whitelist"`
+	Blacklist    pq.StringArray `db:"blacklist"`
 	LastModified int64          `db:"last_modified"`
 }
 
 type AccountFilterConfig struct {
 	Enabled      bool           `db:"enabled"`
 	Whitelist    pq.StringArray `db:"whitelist"`
+	Blacklist    pq.StringArray `db:"blacklist"`
 	LastModified int64          `db:"last_modified"`
 }
 
+type TransactionFilterConfig struct {
+	Enabled bool `db:"enabled"`
+	// OperationTypes are operation type names as used in Horizon responses,
+	// ex. "payment".
+	OperationTypes pq.StringArray `db:"operation_types"`
+	Memos          pq.StringArray `db:"memos"`
+	// MinPaymentAmount is in stroops, 0 means no limit.
+	MinPaymentAmount int64 `db:"min_payment_amount"`
+	LastModified     int64 `db:"last_modified"`
+}
+
 type QFilter interface {
 	GetAccountFilterConfig(ctx context.Context) (AccountFilterConfig, error)
 	GetAssetFilterConfig(ctx context.Context) (AssetFilterConfig, error)
+	GetTransactionFilterConfig(ctx context.Context) (TransactionFilterConfig, error)
+	GetFilterMode(ctx context.Context) (FilterMode, error)
 	UpdateAssetFilterConfig(ctx context.Context, config AssetFilterConfig) (AssetFilterConfig, error)
 	UpdateAccountFilterConfig(ctx context.Context, config AccountFilterConfig) (AccountFilterConfig, error)
+	UpdateTransactionFilterConfig(ctx context.Context, config TransactionFilterConfig) (TransactionFilterConfig, error)
+	UpdateFilterMode(ctx context.Context, mode FilterMode) error
 }
 
 func (q *Q) GetAccountFilterConfig(ctx context.Context) (AccountFilterConfig, error) {
@@ -51,11 +85,33 @@ func (q *Q) GetAssetFilterConfig(ctx context.Context) (AssetFilterConfig, error)
 	return filterConfig, err
 }
 
+func (q *Q) GetTransactionFilterConfig(ctx context.Context) (TransactionFilterConfig, error) {
+	filterConfig := TransactionFilterConfig{}
+	sql := sq.Select("*").From(transactionFilterRulesTableName)
+	err := q.Get(ctx, &filterConfig, sql)
+
+	return filterConfig, err
+}
+
+// GetFilterMode returns the mode used to combine filters, FilterModeAll if
+// it was never set.
+func (q *Q) GetFilterMode(ctx context.Context) (FilterMode, error) {
+	mode, err := q.getValueFromStore(ctx, filterModeKey, false)
+	if err != nil {
+		return "", err
+	}
+	if mode == "" {
+		return FilterModeAll, nil
+	}
+	return FilterMode(mode), nil
+}
+
 func (q *Q) UpdateAssetFilterConfig(ctx context.Context, config AssetFilterConfig) (AssetFilterConfig, error) {
 	updateCols := map[string]interface{}{
 		lastModifiedColumnName: sq.Expr(`extract(epoch from now() at time zone 'utc')`),
 		enabledColumnName:      config.Enabled,
 		whitelistColumnName:    config.Whitelist,
+		blacklistColumnName:    nonNilStringArray(config.Blacklist),
 	}
 
 	sqlUpdate := sq.Update(assetFilterRulesTableName).SetMap(updateCols)
@@ -76,6 +132,7 @@ func (q *Q) UpdateAccountFilterConfig(ctx context.Context, config AccountFilterC
 		lastModifiedColumnName: sq.Expr(`extract(epoch from now() at time zone 'utc')`),
 		enabledColumnName:      config.Enabled,
 		whitelistColumnName:    config.Whitelist,
+		blacklistColumnName:    nonNilStringArray(config.Blacklist),
 	}
 
 	sqlUpdate := sq.Update(accountFilterRulesTableName).SetMap(updateCols)
@@ -91,6 +148,41 @@ func (q *Q) UpdateAccountFilterConfig(ctx context.Context, config AccountFilterC
 	return q.GetAccountFilterConfig(ctx)
 }
 
+func (q *Q) UpdateTransactionFilterConfig(ctx context.Context, config TransactionFilterConfig) (TransactionFilterConfig, error) {
+	updateCols := map[string]interface{}{
+		lastModifiedColumnName:     sq.Expr(`extract(epoch from now() at time zone 'utc')`),
+		enabledColumnName:          config.Enabled,
+		operationTypesColumnName:   nonNilStringArray(config.OperationTypes),
+		memosColumnName:            nonNilStringArray(config.Memos),
+		minPaymentAmountColumnName: config.MinPaymentAmount,
+	}
+
+	sqlUpdate := sq.Update(transactionFilterRulesTableName).SetMap(updateCols)
+
+	rowCnt, err := q.checkForError(sqlUpdate, ctx)
+	if err != nil {
+		return TransactionFilterConfig{}, err
+	}
+
+	if rowCnt < 1 {
+		return TransactionFilterConfig{}, sql.ErrNoRows
+	}
+	return q.GetTransactionFilterConfig(ctx)
+}
+
+func (q *Q) UpdateFilterMode(ctx context.Context, mode FilterMode) error {
+	return q.updateValueInStore(ctx, filterModeKey, string(mode))
+}
+
+// nonNilStringArray returns an empty array for nil so NOT NULL columns are
+// not set to NULL.
+func nonNilStringArray(array pq.StringArray) pq.StringArray {
+	if array == nil {
+		return pq.StringArray{}
+	}
+	return array
+}
+
 func (q *Q) checkForError(builder sq.Sqlizer, ctx context.Context) (int64, error) {
 	result, err := q.Exec(ctx, builder)
 	if err != nil {
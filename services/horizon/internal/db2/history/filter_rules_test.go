@@ -43,3 +43,58 @@ func TestAccountFilterConfig(t *testing.T) {
 	tt.Assert.Equal(fc1Result.Enabled, true)
 	tt.Assert.ElementsMatch(fc1Result.Whitelist, []string{"1", "2"})
 }
+
+func TestFilterBlacklists(t *testing.T) {
+	tt := test.Start(t)
+	defer tt.Finish()
+	test.ResetHorizonDB(t, tt.HorizonDB)
+	q := &Q{tt.HorizonSession()}
+
+	assetConfig, err := q.GetAssetFilterConfig(tt.Ctx)
+	tt.Assert.NoError(err)
+	tt.Assert.Len(assetConfig.Blacklist, 0)
+
+	assetConfig.Blacklist = []string{"1"}
+	assetConfig, err = q.UpdateAssetFilterConfig(tt.Ctx, assetConfig)
+	tt.Assert.NoError(err)
+	tt.Assert.Equal([]string{"1"}, []string(assetConfig.Blacklist))
+
+	accountConfig, err := q.UpdateAccountFilterConfig(tt.Ctx, AccountFilterConfig{Blacklist: []string{"2"}})
+	tt.Assert.NoError(err)
+	tt.Assert.Equal([]string{"2"}, []string(accountConfig.Blacklist))
+	tt.Assert.Len(accountConfig.Whitelist, 0)
+}
+
+func TestTransactionFilterConfig(t *testing.T) {
+	tt := test.Start(t)
+	defer tt.Finish()
+	test.ResetHorizonDB(t, tt.HorizonDB)
+	q := &Q{tt.HorizonSession()}
+
+	config, err := q.GetTransactionFilterConfig(tt.Ctx)
+	tt.Assert.NoError(err)
+	tt.Assert.False(config.Enabled)
+	tt.Assert.Len(config.OperationTypes, 0)
+	tt.Assert.Len(config.Memos, 0)
+	tt.Assert.Equal(int64(0), config.MinPaymentAmount)
+
+	config, err = q.UpdateTransactionFilterConfig(tt.Ctx, TransactionFilterConfig{
+		Enabled:          true,
+		OperationTypes:   []string{"payment"},
+		MinPaymentAmount: 1000,
+	})
+	tt.Assert.NoError(err)
+	tt.Assert.True(config.Enabled)
+	tt.Assert.Equal([]string{"payment"}, []string(config.OperationTypes))
+	tt.Assert.Len(config.Memos, 0)
+	tt.Assert.Equal(int64(1000), config.MinPaymentAmount)
+	tt.Assert.True(config.LastModified > 0)
+
+	mode, err := q.GetFilterMode(tt.Ctx)
+	tt.Assert.NoError(err)
+	tt.Assert.Equal(FilterModeAll, mode)
+	tt.Assert.NoError(q.UpdateFilterMode(tt.Ctx, FilterModeAny))
+	mode, err = q.GetFilterMode(tt.Ctx)
+	tt.Assert.NoError(err)
+	tt.Assert.Equal(FilterModeAny, mode)
+}
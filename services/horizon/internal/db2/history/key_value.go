@@ -18,6 +18,8 @@ const (
 	stateInvalid                    = "exp_state_invalid"
 	offerCompactionSequence         = "offer_compaction_sequence"
 	liquidityPoolCompactionSequence = "liquidity_pool_compaction_sequence"
+	filterBackfillState             = "filter_backfill_state"
+	filterModeKey                   = "filter_mode"
 )
 
 // GetLastLedgerIngestNonBlocking works like GetLastLedgerIngest but
@@ -181,6 +183,19 @@ func (q *Q) UpdateLiquidityPoolCompactionSequence(ctx context.Context, sequence
 	)
 }
 
+// GetFilterBackfillState returns the serialized state of the backfill of
+// history after ingestion filter rules change. Returns "" if there is no
+// value.
+func (q *Q) GetFilterBackfillState(ctx context.Context) (string, error) {
+	return q.getValueFromStore(ctx, filterBackfillState, false)
+}
+
+// UpdateFilterBackfillState updates the serialized state of the backfill of
+// history after ingestion filter rules change.
+func (q *Q) UpdateFilterBackfillState(ctx context.Context, state string) error {
+	return q.updateValueInStore(ctx, filterBackfillState, state)
+}
+
 // getValueFromStore returns a value for a given key from KV store. If value
 // is not present in the key value store "" will be returned.
 func (q *Q) getValueFromStore(ctx context.Context, key string, forUpdate bool) (string, error) {
@@ -23,6 +23,7 @@ import (
 	"github.com/stellar/go/support/db"
 	"github.com/stellar/go/support/errors"
 	strtime "github.com/stellar/go/support/time"
+	"github.com/stellar/go/toid"
 	"github.com/stellar/go/xdr"
 )
 
@@ -1020,23 +1021,27 @@ func constructReapLookupTablesQuery(table string, historyTables []tableObjectFie
 	return sb.String(), nil
 }
 
+// HistoryTableIDColumns maps history tables to columns containing the TOID
+// of the ledger, transaction or operation the row belongs to.
+var HistoryTableIDColumns = map[string]string{
+	"history_effects":                        "history_operation_id",
+	"history_ledgers":                        "id",
+	"history_operation_claimable_balances":   "history_operation_id",
+	"history_operation_participants":         "history_operation_id",
+	"history_operation_liquidity_pools":      "history_operation_id",
+	"history_operations":                     "id",
+	"history_trades":                         "history_operation_id",
+	"history_trades_60000":                   "open_ledger_toid",
+	"history_transaction_claimable_balances": "history_transaction_id",
+	"history_transaction_participants":       "history_transaction_id",
+	"history_transaction_liquidity_pools":    "history_transaction_id",
+	"history_transactions":                   "id",
+}
+
 // DeleteRangeAll deletes a range of rows from all history tables between
 // `start` and `end` (exclusive).
 func (q *Q) DeleteRangeAll(ctx context.Context, start, end int64) error {
-	for table, column := range map[string]string{
-		"history_effects":                        "history_operation_id",
-		"history_ledgers":                        "id",
-		"history_operation_claimable_balances":   "history_operation_id",
-		"history_operation_participants":         "history_operation_id",
-		"history_operation_liquidity_pools":      "history_operation_id",
-		"history_operations":                     "id",
-		"history_trades":                         "history_operation_id",
-		"history_trades_60000":                   "open_ledger_toid",
-		"history_transaction_claimable_balances": "history_transaction_id",
-		"history_transaction_participants":       "history_transaction_id",
-		"history_transaction_liquidity_pools":    "history_transaction_id",
-		"history_transactions":                   "id",
-	} {
+	for table, column := range HistoryTableIDColumns {
 		err := q.DeleteRange(ctx, start, end, table, column)
 		if err != nil {
 			return errors.Wrapf(err, "Error clearing %s", table)
@@ -1045,6 +1050,40 @@ func (q *Q) DeleteRangeAll(ctx context.Context, start, end int64) error {
 	return nil
 }
 
+// DeleteHistoryTableRange deletes rows of a single history table between
+// `start` and `end` (exclusive) TOIDs and returns the number of deleted rows.
+func (q *Q) DeleteHistoryTableRange(ctx context.Context, table string, start, end int64) (int64, error) {
+	column, ok := HistoryTableIDColumns[table]
+	if !ok {
+		return 0, errors.Errorf("unknown history table %s", table)
+	}
+	del := sq.Delete(table).Where(
+		fmt.Sprintf("%s >= ? AND %s < ?", column, column),
+		start,
+		end,
+	)
+	result, err := q.Exec(ctx, del)
+	if err != nil {
+		return 0, errors.Wrapf(err, "Error clearing %s", table)
+	}
+	return result.RowsAffected()
+}
+
+// HistoryTableElderLedger returns the oldest ledger with rows in the history
+// table or 0 if the table is empty.
+func (q *Q) HistoryTableElderLedger(ctx context.Context, table string) (int32, error) {
+	column, ok := HistoryTableIDColumns[table]
+	if !ok {
+		return 0, errors.Errorf("unknown history table %s", table)
+	}
+	var minID int64
+	err := q.GetRaw(ctx, &minID, fmt.Sprintf("SELECT COALESCE(MIN(%s), 0) FROM %s", column, table))
+	if err != nil {
+		return 0, errors.Wrapf(err, "Error getting elder ledger of %s", table)
+	}
+	return toid.Parse(minID).LedgerSequence, nil
+}
+
 // upsertRows builds and executes an upsert query that allows very fast upserts
 // to a given table. The final query is of form:
 //
@@ -21,6 +21,16 @@ func (m *MockQFilter) GetAssetFilterConfig(ctx context.Context) (AssetFilterConf
 	return a.Get(0).(AssetFilterConfig), a.Error(1)
 }
 
+func (m *MockQFilter) GetTransactionFilterConfig(ctx context.Context) (TransactionFilterConfig, error) {
+	a := m.Called(ctx)
+	return a.Get(0).(TransactionFilterConfig), a.Error(1)
+}
+
+func (m *MockQFilter) GetFilterMode(ctx context.Context) (FilterMode, error) {
+	a := m.Called(ctx)
+	return a.Get(0).(FilterMode), a.Error(1)
+}
+
 func (m *MockQFilter) UpdateAccountFilterConfig(ctx context.Context, config AccountFilterConfig) (AccountFilterConfig, error) {
 	a := m.Called(ctx, config)
 	return a.Get(0).(AccountFilterConfig), a.Error(0)
@@ -30,3 +40,13 @@ func (m *MockQFilter) UpdateAssetFilterConfig(ctx context.Context, config AssetF
 	a := m.Called(ctx, config)
 	return a.Get(0).(AssetFilterConfig), a.Error(0)
 }
+
+func (m *MockQFilter) UpdateTransactionFilterConfig(ctx context.Context, config TransactionFilterConfig) (TransactionFilterConfig, error) {
+	a := m.Called(ctx, config)
+	return a.Get(0).(TransactionFilterConfig), a.Error(1)
+}
+
+func (m *MockQFilter) UpdateFilterMode(ctx context.Context, mode FilterMode) error {
+	a := m.Called(ctx, mode)
+	return a.Error(0)
+}
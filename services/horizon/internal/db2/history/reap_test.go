@@ -17,7 +17,7 @@ func TestReapLookupTables(t *testing.T) {
 
 	db := tt.HorizonSession()
 
-	sys := reap.New(0, db, ledgerState)
+	sys := reap.New(reap.Config{}, db, ledgerState)
 
 	var (
 		prevLedgers, curLedgers                     int
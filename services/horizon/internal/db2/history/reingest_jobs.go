@@ -0,0 +1,248 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/stellar/go/support/errors"
+)
+
+// ReingestJobStatus is the status of a reingest job.
+type ReingestJobStatus string
+
+const (
+	ReingestJobPending   ReingestJobStatus = "pending"
+	ReingestJobRunning   ReingestJobStatus = "running"
+	ReingestJobCompleted ReingestJobStatus = "completed"
+	ReingestJobFailed    ReingestJobStatus = "failed"
+)
+
+// ReingestJob is a ledger range reingested by `horizon db reingest range`
+// with parallel workers. Jobs are stored in the DB so a rerun of the command
+// skips completed ranges and many machines can share the work.
+type ReingestJob struct {
+	StartLedger uint32            `db:"start_ledger"`
+	EndLedger   uint32            `db:"end_ledger"`
+	Status      ReingestJobStatus `db:"status"`
+	// IngestVersion is the ingestion version used to process the job.
+	IngestVersion int `db:"ingest_version"`
+	Attempts      int `db:"attempts"`
+	// Worker identifies the worker which processed the job last.
+	Worker    string    `db:"worker"`
+	LastError string    `db:"last_error"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+// LedgerRange returns the ledger range of the job.
+func (j ReingestJob) LedgerRange() LedgerRange {
+	return LedgerRange{StartSequence: j.StartLedger, EndSequence: j.EndLedger}
+}
+
+// QReingestJobs defines reingest job queries.
+type QReingestJobs interface {
+	LockReingestJobs(ctx context.Context) error
+	GetReingestJobs(ctx context.Context, from, to uint32) ([]ReingestJob, error)
+	InsertReingestJobs(ctx context.Context, ledgerRanges []LedgerRange) error
+	SplitReingestJob(ctx context.Context, job ReingestJob, ledgerRanges []LedgerRange, staleAfter time.Duration) error
+	ClaimReingestJob(ctx context.Context, ledgerRanges []LedgerRange, ingestVersion int, worker string, staleAfter time.Duration) (ReingestJob, bool, error)
+	HeartbeatReingestJob(ctx context.Context, job ReingestJob) error
+	CompleteReingestJob(ctx context.Context, job ReingestJob) error
+	FailReingestJob(ctx context.Context, job ReingestJob, reason string) error
+}
+
+var selectReingestJob = sq.Select(
+	"start_ledger",
+	"end_ledger",
+	"status",
+	"ingest_version",
+	"attempts",
+	"worker",
+	"last_error",
+	"updated_at",
+).From("reingest_jobs")
+
+// LockReingestJobs locks the reingest_jobs table until the end of the current
+// transaction so no other process can add jobs.
+func (q *Q) LockReingestJobs(ctx context.Context) error {
+	if q.GetTx() == nil {
+		return errors.New("cannot be called outside of a transaction")
+	}
+	_, err := q.ExecRaw(ctx, "LOCK TABLE reingest_jobs IN SHARE ROW EXCLUSIVE MODE")
+	return err
+}
+
+// GetReingestJobs returns the jobs overlapping the [from, to] ledger range,
+// ordered by start ledger.
+func (q *Q) GetReingestJobs(ctx context.Context, from, to uint32) ([]ReingestJob, error) {
+	var jobs []ReingestJob
+	sql := selectReingestJob.
+		Where("start_ledger <= ? AND end_ledger >= ?", to, from).
+		OrderBy("start_ledger ASC")
+	err := q.Select(ctx, &jobs, sql)
+	return jobs, err
+}
+
+// InsertReingestJobs inserts pending jobs for the ledger ranges. Existing jobs
+// are not modified.
+func (q *Q) InsertReingestJobs(ctx context.Context, ledgerRanges []LedgerRange) error {
+	if len(ledgerRanges) == 0 {
+		return nil
+	}
+	sql := sq.Insert("reingest_jobs").Columns("start_ledger", "end_ledger", "status")
+	for _, ledgerRange := range ledgerRanges {
+		sql = sql.Values(ledgerRange.StartSequence, ledgerRange.EndSequence, ReingestJobPending)
+	}
+	_, err := q.Exec(ctx, sql.Suffix("ON CONFLICT (start_ledger, end_ledger) DO NOTHING"))
+	return err
+}
+
+// SplitReingestJob replaces the job with jobs for the ledger ranges, which
+// must cover the job, keeping its status and attempts. Running jobs are split
+// only if they are stale so the worker processing the job is not affected.
+func (q *Q) SplitReingestJob(ctx context.Context, job ReingestJob, ledgerRanges []LedgerRange, staleAfter time.Duration) error {
+	if q.GetTx() == nil {
+		return errors.New("cannot be called outside of a transaction")
+	}
+	if len(ledgerRanges) == 0 {
+		return errors.New("no ledger ranges")
+	}
+
+	values := make([]string, 0, len(ledgerRanges))
+	args := []interface{}{job.StartLedger, job.EndLedger, ReingestJobRunning, staleAfter.Seconds()}
+	for _, ledgerRange := range ledgerRanges {
+		values = append(values, "(?::integer, ?::integer)")
+		args = append(args, ledgerRange.StartSequence, ledgerRange.EndSequence)
+	}
+	result, err := q.ExecRaw(ctx, `
+		WITH split AS (
+			DELETE FROM reingest_jobs
+			WHERE start_ledger = ? AND end_ledger = ? AND
+				NOT (status = ? AND updated_at >= NOW() - make_interval(secs => ?))
+			RETURNING status, ingest_version, attempts, worker, last_error, updated_at
+		)
+		INSERT INTO reingest_jobs
+			(start_ledger, end_ledger, status, ingest_version, attempts, worker, last_error, updated_at)
+		SELECT parts.start_ledger, parts.end_ledger, split.status, split.ingest_version,
+			split.attempts, split.worker, split.last_error, split.updated_at
+		FROM split, (VALUES `+strings.Join(values, ", ")+`) AS parts (start_ledger, end_ledger)`,
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errors.Errorf("reingest job [%d, %d] is running", job.StartLedger, job.EndLedger)
+	}
+	return nil
+}
+
+// ClaimReingestJob marks the job with the lowest start ledger contained in one
+// of the ledger ranges which needs to be processed as running by the worker
+// and returns it. A job needs to be processed when it's pending, failed,
+// completed using an older ingestion version or running without a heartbeat
+// for staleAfter (its worker likely crashed). Returns false if there are no
+// such jobs.
+func (q *Q) ClaimReingestJob(ctx context.Context, ledgerRanges []LedgerRange, ingestVersion int, worker string, staleAfter time.Duration) (ReingestJob, bool, error) {
+	if len(ledgerRanges) == 0 {
+		return ReingestJob{}, false, nil
+	}
+
+	contained := make([]string, 0, len(ledgerRanges))
+	args := []interface{}{ReingestJobRunning, ingestVersion, worker}
+	for _, ledgerRange := range ledgerRanges {
+		contained = append(contained, "(start_ledger >= ? AND end_ledger <= ?)")
+		args = append(args, ledgerRange.StartSequence, ledgerRange.EndSequence)
+	}
+	args = append(args,
+		ReingestJobPending,
+		ReingestJobFailed,
+		ReingestJobRunning,
+		staleAfter.Seconds(),
+		ReingestJobCompleted,
+		ingestVersion,
+	)
+
+	var job ReingestJob
+	err := q.GetRaw(ctx, &job, fmt.Sprintf(`
+		UPDATE reingest_jobs SET
+			status = ?,
+			ingest_version = ?,
+			attempts = attempts + 1,
+			worker = ?,
+			last_error = '',
+			updated_at = NOW()
+		WHERE (start_ledger, end_ledger) = (
+			SELECT start_ledger, end_ledger FROM reingest_jobs
+			WHERE (%s) AND (
+				status IN (?, ?) OR
+				(status = ? AND updated_at < NOW() - make_interval(secs => ?)) OR
+				(status = ? AND ingest_version < ?)
+			)
+			ORDER BY start_ledger ASC
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING start_ledger, end_ledger, status, ingest_version, attempts, worker, last_error, updated_at`,
+		strings.Join(contained, " OR "),
+	), args...)
+	if q.NoRows(err) {
+		return ReingestJob{}, false, nil
+	}
+	if err != nil {
+		return ReingestJob{}, false, err
+	}
+	return job, true, nil
+}
+
+// HeartbeatReingestJob updates the last update time of the running job so
+// other workers don't consider it stale.
+func (q *Q) HeartbeatReingestJob(ctx context.Context, job ReingestJob) error {
+	return q.updateReingestJob(ctx, job, map[string]interface{}{"status": ReingestJobRunning})
+}
+
+// CompleteReingestJob marks the job as completed.
+func (q *Q) CompleteReingestJob(ctx context.Context, job ReingestJob) error {
+	return q.updateReingestJob(ctx, job, map[string]interface{}{"status": ReingestJobCompleted})
+}
+
+// FailReingestJob marks the job as failed.
+func (q *Q) FailReingestJob(ctx context.Context, job ReingestJob, reason string) error {
+	return q.updateReingestJob(ctx, job, map[string]interface{}{
+		"status":     ReingestJobFailed,
+		"last_error": reason,
+	})
+}
+
+// updateReingestJob updates the running job only if it's still owned by the
+// worker which claimed it.
+func (q *Q) updateReingestJob(ctx context.Context, job ReingestJob, values map[string]interface{}) error {
+	sql := sq.Update("reingest_jobs").
+		SetMap(values).
+		Set("updated_at", sq.Expr("NOW()")).
+		Where(sq.Eq{
+			"start_ledger": job.StartLedger,
+			"end_ledger":   job.EndLedger,
+			"worker":       job.Worker,
+			"status":       ReingestJobRunning,
+		})
+	result, err := q.Exec(ctx, sql)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errors.Errorf("reingest job [%d, %d] is no longer owned by %s", job.StartLedger, job.EndLedger, job.Worker)
+	}
+	return nil
+}
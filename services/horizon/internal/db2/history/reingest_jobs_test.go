@@ -0,0 +1,162 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stellar/go/services/horizon/internal/test"
+)
+
+func TestReingestJobs(t *testing.T) {
+	tt := test.Start(t)
+	defer tt.Finish()
+	test.ResetHorizonDB(t, tt.HorizonDB)
+	q := &Q{tt.HorizonSession()}
+
+	tt.Assert.EqualError(q.LockReingestJobs(tt.Ctx), "cannot be called outside of a transaction")
+
+	tt.Require.NoError(q.InsertReingestJobs(tt.Ctx, []LedgerRange{
+		{StartSequence: 1, EndSequence: 64},
+		{StartSequence: 65, EndSequence: 128},
+		{StartSequence: 129, EndSequence: 192},
+	}))
+	// Existing jobs are not modified
+	tt.Require.NoError(q.InsertReingestJobs(tt.Ctx, []LedgerRange{{StartSequence: 1, EndSequence: 64}}))
+
+	jobs, err := q.GetReingestJobs(tt.Ctx, 100, 1000)
+	tt.Require.NoError(err)
+	tt.Require.Len(jobs, 2)
+	tt.Assert.Equal(LedgerRange{StartSequence: 65, EndSequence: 128}, jobs[0].LedgerRange())
+	tt.Assert.Equal(ReingestJobPending, jobs[0].Status)
+
+	first, ok, err := q.ClaimReingestJob(tt.Ctx, []LedgerRange{{StartSequence: 1, EndSequence: 192}}, 16, "worker-0", time.Hour)
+	tt.Require.NoError(err)
+	tt.Require.True(ok)
+	tt.Assert.Equal(LedgerRange{StartSequence: 1, EndSequence: 64}, first.LedgerRange())
+	tt.Assert.Equal(ReingestJobRunning, first.Status)
+	tt.Assert.Equal(16, first.IngestVersion)
+	tt.Assert.Equal(1, first.Attempts)
+	tt.Assert.Equal("worker-0", first.Worker)
+
+	second, ok, err := q.ClaimReingestJob(tt.Ctx, []LedgerRange{{StartSequence: 1, EndSequence: 192}}, 16, "worker-1", time.Hour)
+	tt.Require.NoError(err)
+	tt.Require.True(ok)
+	tt.Assert.Equal(LedgerRange{StartSequence: 65, EndSequence: 128}, second.LedgerRange())
+
+	// Only the worker claiming a job can update it
+	tt.Assert.EqualError(
+		q.CompleteReingestJob(tt.Ctx, ReingestJob{StartLedger: 1, EndLedger: 64, Worker: "worker-1"}),
+		"reingest job [1, 64] is no longer owned by worker-1",
+	)
+	tt.Require.NoError(q.HeartbeatReingestJob(tt.Ctx, first))
+	tt.Require.NoError(q.CompleteReingestJob(tt.Ctx, first))
+	tt.Require.NoError(q.FailReingestJob(tt.Ctx, second, "timeout"))
+
+	// Failed jobs are retried
+	third, ok, err := q.ClaimReingestJob(tt.Ctx, []LedgerRange{{StartSequence: 1, EndSequence: 192}}, 16, "worker-0", time.Hour)
+	tt.Require.NoError(err)
+	tt.Require.True(ok)
+	tt.Assert.Equal(LedgerRange{StartSequence: 65, EndSequence: 128}, third.LedgerRange())
+	tt.Assert.Equal(2, third.Attempts)
+	tt.Assert.Empty(third.LastError)
+
+	// Running jobs are claimed only when stale
+	fourth, ok, err := q.ClaimReingestJob(tt.Ctx, []LedgerRange{{StartSequence: 1, EndSequence: 192}}, 16, "worker-1", time.Hour)
+	tt.Require.NoError(err)
+	tt.Require.True(ok)
+	tt.Assert.Equal(LedgerRange{StartSequence: 129, EndSequence: 192}, fourth.LedgerRange())
+	_, ok, err = q.ClaimReingestJob(tt.Ctx, []LedgerRange{{StartSequence: 1, EndSequence: 192}}, 16, "worker-2", time.Hour)
+	tt.Require.NoError(err)
+	tt.Assert.False(ok)
+
+	_, err = q.ExecRaw(tt.Ctx, "UPDATE reingest_jobs SET updated_at = NOW() - interval '2 hours' WHERE start_ledger = 129")
+	tt.Require.NoError(err)
+	stale, ok, err := q.ClaimReingestJob(tt.Ctx, []LedgerRange{{StartSequence: 1, EndSequence: 192}}, 16, "worker-2", time.Hour)
+	tt.Require.NoError(err)
+	tt.Require.True(ok)
+	tt.Assert.Equal(LedgerRange{StartSequence: 129, EndSequence: 192}, stale.LedgerRange())
+	tt.Assert.EqualError(
+		q.CompleteReingestJob(tt.Ctx, fourth),
+		"reingest job [129, 192] is no longer owned by worker-1",
+	)
+
+	// Jobs completed by an older ingestion version are reingested
+	_, ok, err = q.ClaimReingestJob(tt.Ctx, []LedgerRange{{StartSequence: 1, EndSequence: 64}}, 16, "worker-0", time.Hour)
+	tt.Require.NoError(err)
+	tt.Assert.False(ok)
+	upgraded, ok, err := q.ClaimReingestJob(tt.Ctx, []LedgerRange{{StartSequence: 1, EndSequence: 64}}, 17, "worker-0", time.Hour)
+	tt.Require.NoError(err)
+	tt.Require.True(ok)
+	tt.Assert.Equal(LedgerRange{StartSequence: 1, EndSequence: 64}, upgraded.LedgerRange())
+	tt.Assert.Equal(17, upgraded.IngestVersion)
+
+	// Only jobs contained in one of the ranges are claimed
+	tt.Require.NoError(q.CompleteReingestJob(tt.Ctx, upgraded))
+	tt.Require.NoError(q.InsertReingestJobs(tt.Ctx, []LedgerRange{{StartSequence: 193, EndSequence: 256}}))
+	_, ok, err = q.ClaimReingestJob(tt.Ctx, []LedgerRange{
+		{StartSequence: 1, EndSequence: 200},
+		{StartSequence: 250, EndSequence: 300},
+	}, 17, "worker-0", time.Hour)
+	tt.Require.NoError(err)
+	tt.Assert.False(ok)
+}
+
+func TestSplitReingestJob(t *testing.T) {
+	tt := test.Start(t)
+	defer tt.Finish()
+	test.ResetHorizonDB(t, tt.HorizonDB)
+	q := &Q{tt.HorizonSession()}
+
+	tt.Require.NoError(q.InsertReingestJobs(tt.Ctx, []LedgerRange{
+		{StartSequence: 1, EndSequence: 64},
+		{StartSequence: 65, EndSequence: 128},
+	}))
+	job, ok, err := q.ClaimReingestJob(tt.Ctx, []LedgerRange{{StartSequence: 1, EndSequence: 64}}, 16, "worker-0", time.Hour)
+	tt.Require.NoError(err)
+	tt.Require.True(ok)
+	tt.Require.NoError(q.FailReingestJob(tt.Ctx, job, "timeout"))
+
+	parts := []LedgerRange{
+		{StartSequence: 1, EndSequence: 9},
+		{StartSequence: 10, EndSequence: 64},
+	}
+	tt.Assert.EqualError(q.SplitReingestJob(tt.Ctx, job, parts, time.Hour), "cannot be called outside of a transaction")
+
+	tt.Require.NoError(q.Begin())
+	tt.Require.NoError(q.SplitReingestJob(tt.Ctx, job, parts, time.Hour))
+	tt.Require.NoError(q.Commit())
+
+	jobs, err := q.GetReingestJobs(tt.Ctx, 1, 64)
+	tt.Require.NoError(err)
+	tt.Require.Len(jobs, 2)
+	for i, split := range jobs {
+		tt.Assert.Equal(parts[i], split.LedgerRange())
+		tt.Assert.Equal(ReingestJobFailed, split.Status)
+		tt.Assert.Equal(16, split.IngestVersion)
+		tt.Assert.Equal(1, split.Attempts)
+		tt.Assert.Equal("timeout", split.LastError)
+	}
+
+	// Running jobs are split only when stale
+	running, ok, err := q.ClaimReingestJob(tt.Ctx, []LedgerRange{{StartSequence: 65, EndSequence: 128}}, 16, "worker-0", time.Hour)
+	tt.Require.NoError(err)
+	tt.Require.True(ok)
+	parts = []LedgerRange{
+		{StartSequence: 65, EndSequence: 99},
+		{StartSequence: 100, EndSequence: 128},
+	}
+	tt.Require.NoError(q.Begin())
+	tt.Assert.EqualError(q.SplitReingestJob(tt.Ctx, running, parts, time.Hour), "reingest job [65, 128] is running")
+	tt.Require.NoError(q.Rollback())
+
+	_, err = q.ExecRaw(tt.Ctx, "UPDATE reingest_jobs SET updated_at = NOW() - interval '2 hours' WHERE start_ledger = 65")
+	tt.Require.NoError(err)
+	tt.Require.NoError(q.Begin())
+	tt.Require.NoError(q.SplitReingestJob(tt.Ctx, running, parts, time.Hour))
+	tt.Require.NoError(q.Commit())
+	jobs, err = q.GetReingestJobs(tt.Ctx, 65, 128)
+	tt.Require.NoError(err)
+	tt.Require.Len(jobs, 2)
+	tt.Assert.Equal(parts[0], jobs[0].LedgerRange())
+	tt.Assert.Equal(parts[1], jobs[1].LedgerRange())
+}
@@ -59,6 +59,8 @@
 // migrations/60_add_asset_id_indexes.sql (289B)
 // migrations/61_trust_lines_by_account_type_code_issuer.sql (383B)
 // migrations/62_claimable_balance_claimants.sql (1.428kB)
+// migrations/63_filter_rules_blacklists_and_transaction_filter.sql (711B)
+// migrations/64_reingest_jobs.sql (595B)
 // migrations/6_create_assets_table.sql (366B)
 // migrations/7_modify_trades_table.sql (2.303kB)
 // migrations/8_add_aggregators.sql (907B)
@@ -1312,6 +1314,46 @@ func migrations62_claimable_balance_claimantsSql() (*asset, error) {
 	return a, nil
 }
 
+var _migrations63_filter_rules_blacklists_and_transaction_filterSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xad\x92\x51\x4b\xc3\x30\x10\xc7\xdf\xf3\x29\xee\x6d\x13\x2d\xec\x7d\x4f\x75\xad\x30\x88\xad\x74\xa9\x2f\x22\xe5\x9a\xa6\x5b\x30\x4d\x4a\x92\x29\x43\xfc\xee\x66\xeb\x9c\xcc\x31\xe7\x83\x21\x24\x0f\xb9\xff\xef\xee\xfe\xb9\x28\x82\xeb\x4e\x2e\x2d\x7a\x01\x65\x4f\x48\x4c\x59\x5a\x00\x8b\x6f\x69\x0a\xc8\xb9\x59\x6b\x5f\xb5\x52\x79\x61\x2b\xbb\x56\xc2\x41\x9c\x24\x30\xcb\x69\x79\x9f\x41\xad\x90\xbf\x28\xe9\x3c\xbc\xa2\xe5\x2b\xb4\x4f\xcf\x90\xe5\x0c\xb2\x92\x52\x48\xd2\xbb\xb8\xa4\x0c\x46\xef\x1f\xa3\xe9\x31\xd6\x39\xf1\x2f\x50\x32\x2b\xd2\x98\xa5\x7b\xac\xb7\xa8\x1d\x72\x2f\x8d\x3e\x86\x8f\x09\x84\x25\x34\xd6\x4a\x34\x50\x1b\xa3\xbe\x81\x8d\x68\x71\xad\x3c\xb4\xa8\x9c\xb8\xd9\x05\x9a\x5e\x04\x37\xb6\x14\xbf\xe9\x83\xfc\xb4\x8c\x21\xae\x13\x9d\xf9\xe5\x55\xea\xaa\xc7\x4d\x27\x82\x7f\xd8\x6d\x6d\x84\x5a\x2e\x65\xb8\x4e\x72\x4f\x06\x85\x42\xe7\xab\xce\x34\xb2\x95\xdb\x32\x8f\x83\xc9\x55\x68\x37\x8a\x40\x6a\x27\xac\x07\xbf\x12\x07\x79\x23\xdd\xd0\x99\xf3\xe1\x13\xc9\x3c\x5b\xa4\x05\x83\x79\xc6\xf2\xf3\x96\x3c\xc6\xb4\x4c\x17\x30\x1e\xda\xde\xd9\xf9\x75\x4e\xc2\xde\x27\x3b\x0c\x46\x62\xde\x34\x21\x49\x91\x3f\x5c\xf2\x9a\xa3\xe3\xd8\x88\xe9\x1f\x06\x69\x87\xfb\xf9\xe9\x17\x27\xe5\x8c\xea\x13\x45\x06\x11\x91\xc7\x02\x00\x00")
+
+func migrations63_filter_rules_blacklists_and_transaction_filterSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_migrations63_filter_rules_blacklists_and_transaction_filterSql,
+		"migrations/63_filter_rules_blacklists_and_transaction_filter.sql",
+	)
+}
+
+func migrations63_filter_rules_blacklists_and_transaction_filterSql() (*asset, error) {
+	bytes, err := migrations63_filter_rules_blacklists_and_transaction_filterSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "migrations/63_filter_rules_blacklists_and_transaction_filter.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x36, 0x7c, 0x3d, 0x97, 0xdb, 0x5, 0xb1, 0xea, 0x69, 0x7e, 0x54, 0x9b, 0x99, 0x6, 0x81, 0xa, 0xe8, 0xd4, 0x9c, 0x60, 0xfc, 0xb7, 0xff, 0x85, 0x92, 0xa5, 0xda, 0x74, 0xd3, 0x4, 0xea, 0x78}}
+	return a, nil
+}
+
+var _migrations64_reingest_jobsSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x85\x52\xc1\x4e\x83\x40\x14\xbc\xef\x57\xbc\x5b\x21\xb6\x89\x9a\xe8\xa5\x27\x14\x34\x8d\x08\x0d\x42\xb4\x27\xb2\xc0\x0b\x5d\x2d\xbb\x64\xf7\xb5\x55\xbf\xde\x6d\xa9\x0d\xb5\x55\xf7\xb2\xc9\xee\xcc\xbc\xc9\xcc\x1b\x8d\xe0\xac\x11\xb5\xe6\x84\x90\xb5\x8c\xdd\x26\x81\x97\x06\x90\x7a\x37\x61\x00\x1a\x85\xac\xd1\x50\xfe\xaa\x0a\x03\x0e\x03\x7b\x0c\x71\x4d\xf9\x02\xab\x1a\x35\x08\x49\xb8\xb9\xa3\x38\x85\x28\x0b\xc3\xe1\x16\x82\xb2\xfa\x1b\x60\x35\x68\x69\x60\xc5\x75\x39\xe7\xda\xb9\xb8\x76\xf7\x00\xf0\x83\x3b\x2f\x0b\x53\x18\xb4\x56\xc6\x8e\x1f\x74\x94\x9d\x91\x15\x6a\x23\x94\x3c\xd2\xdd\xd3\xce\x3b\x38\x27\xc2\xa6\x25\xf3\x2f\x70\xad\xf4\x9b\xfd\xfe\xb6\x72\x79\x75\xd2\xcb\xce\xc4\x82\x5b\x0b\xa8\xb5\xd2\x40\xf8\x4e\xbf\x03\x97\x6d\x65\x03\xad\x72\x4e\x40\xa2\xb1\xc6\x79\xd3\xc2\x5a\xd0\x5c\x2d\xbb\x17\xf8\x54\x12\x8f\xf9\x51\xfc\xec\xb8\x9d\xc4\x34\x99\x3c\x7a\xc9\x0c\x1e\x82\x19\x38\xfd\xd0\x87\xbd\x7c\x5d\xe6\x8e\xf7\x9d\x4d\x22\x3f\x78\x39\xec\x2c\x2f\x3e\xf2\x5d\xd8\x71\xf4\xa3\xce\xec\x69\x12\xdd\x43\x41\x1a\x71\x3b\xc0\x82\x86\x07\xed\x6e\xa4\x47\xbd\xf5\xf0\xd5\x5a\x32\xe6\x27\xf1\xf4\xe4\x7a\x94\xdc\x94\xbc\xc2\x31\xfb\x02\x3d\x85\x7e\x13\x53\x02\x00\x00")
+
+func migrations64_reingest_jobsSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_migrations64_reingest_jobsSql,
+		"migrations/64_reingest_jobs.sql",
+	)
+}
+
+func migrations64_reingest_jobsSql() (*asset, error) {
+	bytes, err := migrations64_reingest_jobsSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "migrations/64_reingest_jobs.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x56, 0xe6, 0x61, 0x41, 0x95, 0x5a, 0x79, 0x7b, 0x6e, 0xd6, 0x88, 0xbd, 0xc2, 0xd5, 0x88, 0xce, 0xdb, 0xb4, 0xe0, 0x38, 0x44, 0x20, 0x48, 0x15, 0x25, 0x73, 0x10, 0x15, 0xc0, 0x5d, 0x85, 0x94}}
+	return a, nil
+}
+
 var _migrations6_create_assets_tableSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x6c\x90\x3d\x4f\xc3\x30\x18\x84\x77\xff\x8a\x1b\x1d\x91\x0e\x20\xe8\x92\xc9\x34\x16\x58\x18\xa7\xb8\x31\xa2\x53\xe5\x26\x16\x78\x80\x54\xb6\x11\xca\xbf\x47\xaa\x28\xf9\x50\xe6\x7b\xf4\xbc\xef\xdd\x6a\x85\xab\x4f\xff\x1e\x6c\x72\x30\x27\xb2\xd1\x9c\xd5\x1c\x35\xbb\x97\x1c\x1f\x3e\xa6\x2e\xf4\x07\x1b\xa3\x4b\x11\x94\x00\x80\x6f\xb1\xe3\x5a\x30\x89\xad\x16\xcf\x4c\xef\xf1\xc4\xf7\xc8\xcf\xd9\x19\x3c\xa4\xfe\xe4\xf0\xca\xf4\xe6\x91\x69\xba\xbe\xcd\xa0\xaa\x1a\xca\x48\x39\x86\x9a\xae\x1d\xa0\xeb\x9b\x65\xc8\xc7\xf8\xed\xc2\x3f\x76\xb7\x9e\x63\x46\x89\x17\xc3\xe9\xa0\xcc\x47\x3f\xe4\x13\x4b\x46\xb2\x82\x5c\xfa\x09\x55\xf2\xb7\xbf\xf8\xd8\x5f\xee\x54\x6a\x5e\xd9\xec\x84\x7a\xc0\x31\x05\xe7\x40\x27\xb6\x82\x90\xf1\x74\x65\xf7\xf3\x45\x4a\x5d\x6d\x97\xa7\x6b\x6c\x6c\x6c\xeb\x8a\xdf\x00\x00\x00\xff\xff\xfb\x53\x3e\x81\x6e\x01\x00\x00")
 
 func migrations6_create_assets_tableSqlBytes() ([]byte, error) {
@@ -1562,6 +1604,8 @@ var _bindata = map[string]func() (*asset, error){
 	"migrations/60_add_asset_id_indexes.sql":                             migrations60_add_asset_id_indexesSql,
 	"migrations/61_trust_lines_by_account_type_code_issuer.sql":          migrations61_trust_lines_by_account_type_code_issuerSql,
 	"migrations/62_claimable_balance_claimants.sql":                      migrations62_claimable_balance_claimantsSql,
+	"migrations/63_filter_rules_blacklists_and_transaction_filter.sql":   migrations63_filter_rules_blacklists_and_transaction_filterSql,
+	"migrations/64_reingest_jobs.sql":                                    migrations64_reingest_jobsSql,
 	"migrations/6_create_assets_table.sql":                               migrations6_create_assets_tableSql,
 	"migrations/7_modify_trades_table.sql":                               migrations7_modify_trades_tableSql,
 	"migrations/8_add_aggregators.sql":                                   migrations8_add_aggregatorsSql,
@@ -1672,6 +1716,8 @@ var _bintree = &bintree{nil, map[string]*bintree{
 		"60_add_asset_id_indexes.sql":                             {migrations60_add_asset_id_indexesSql, map[string]*bintree{}},
 		"61_trust_lines_by_account_type_code_issuer.sql":          {migrations61_trust_lines_by_account_type_code_issuerSql, map[string]*bintree{}},
 		"62_claimable_balance_claimants.sql":                      {migrations62_claimable_balance_claimantsSql, map[string]*bintree{}},
+		"63_filter_rules_blacklists_and_transaction_filter.sql":   {migrations63_filter_rules_blacklists_and_transaction_filterSql, map[string]*bintree{}},
+		"64_reingest_jobs.sql":                                    {migrations64_reingest_jobsSql, map[string]*bintree{}},
 		"6_create_assets_table.sql":                               {migrations6_create_assets_tableSql, map[string]*bintree{}},
 		"7_modify_trades_table.sql":                               {migrations7_modify_trades_tableSql, map[string]*bintree{}},
 		"8_add_aggregators.sql":                                   {migrations8_add_aggregatorsSql, map[string]*bintree{}},
@@ -0,0 +1,22 @@
+-- +migrate Up
+
+ALTER TABLE account_filter_rules ADD COLUMN blacklist varchar[] NOT NULL DEFAULT '{}';
+ALTER TABLE asset_filter_rules ADD COLUMN blacklist varchar[] NOT NULL DEFAULT '{}';
+
+CREATE TABLE transaction_filter_rules (
+    enabled bool NOT NULL default false,
+    operation_types varchar[] NOT NULL,
+    memos varchar[] NOT NULL,
+    min_payment_amount bigint NOT NULL default 0,
+    last_modified bigint NOT NULL
+);
+
+-- insert the default disabled state
+INSERT INTO transaction_filter_rules VALUES (false, '{}', '{}', 0, 0);
+
+-- +migrate Down
+
+DROP TABLE transaction_filter_rules cascade;
+
+ALTER TABLE account_filter_rules DROP COLUMN blacklist;
+ALTER TABLE asset_filter_rules DROP COLUMN blacklist;
@@ -0,0 +1,19 @@
+-- +migrate Up
+
+CREATE TABLE reingest_jobs (
+    start_ledger integer NOT NULL,
+    end_ledger integer NOT NULL,
+    status varchar(16) NOT NULL DEFAULT 'pending',
+    ingest_version integer NOT NULL DEFAULT 0,
+    attempts integer NOT NULL DEFAULT 0,
+    worker varchar(256) NOT NULL DEFAULT '',
+    last_error text NOT NULL DEFAULT '',
+    updated_at timestamp without time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (start_ledger, end_ledger)
+);
+
+CREATE INDEX reingest_jobs_by_status ON reingest_jobs USING btree (status, start_ledger);
+
+-- +migrate Down
+
+DROP TABLE reingest_jobs cascade;
@@ -15,6 +15,7 @@ import (
 	"github.com/stellar/go/ingest/ledgerbackend"
 	"github.com/stellar/go/network"
 	"github.com/stellar/go/services/horizon/internal/db2/schema"
+	"github.com/stellar/go/services/horizon/internal/reap"
 	apkg "github.com/stellar/go/support/app"
 	support "github.com/stellar/go/support/config"
 	"github.com/stellar/go/support/db"
@@ -33,6 +34,8 @@ const (
 	StellarCoreURLFlagName = "stellar-core-url"
 	// StellarCoreBinaryPathName is the command line flag for configuring the path to the stellar core binary
 	StellarCoreBinaryPathName = "stellar-core-binary-path"
+	// LedgerFilesURLFlagName is the command line flag for configuring the URL of the storage with exported ledger files
+	LedgerFilesURLFlagName = "ledger-files-url"
 	// captiveCoreConfigAppendPathName is the command line flag for configuring the path to the captive core additional configuration
 	// Note captiveCoreConfigAppendPathName is deprecated in favor of CaptiveCoreConfigPathName
 	captiveCoreConfigAppendPathName = "captive-core-config-append-path"
@@ -137,6 +140,14 @@ func Flags() (*Config, support.ConfigOptions) {
 			Usage:       "url to access the remote captive core server",
 			ConfigKey:   &config.RemoteCaptiveCoreURL,
 		},
+		&support.ConfigOption{
+			Name:        LedgerFilesURLFlagName,
+			OptType:     types.String,
+			FlagDefault: "",
+			Required:    false,
+			Usage:       "url of the storage (ex. file:///data/ledgers, s3://bucket/ledgers) with ledgers exported by ledgerbackend.LedgerFileWriter, when set ledgers are read from it instead of stellar-core",
+			ConfigKey:   &config.LedgerFilesURL,
+		},
 		&support.ConfigOption{
 			Name:        captiveCoreConfigAppendPathName,
 			OptType:     types.String,
@@ -209,6 +220,14 @@ func Flags() (*Config, support.ConfigOptions) {
 			Usage:       "causes Horizon to enable the experimental Ingestion Filtering and the ingestion admin HTTP endpoint at /ingestion/filter",
 			ConfigKey:   &config.EnableIngestionFiltering,
 		},
+		&support.ConfigOption{
+			Name:        "exp-enable-ingestion-filter-backfill",
+			OptType:     types.Bool,
+			FlagDefault: false,
+			Required:    false,
+			Usage:       "causes Horizon to reingest the retained history window when ingestion filter rules change so transactions matched by the new rules are added to history (all ledgers of the window are deleted from the history tables and ingested again, which can take as long as reingesting the window with the db reingest range command), the progress is available on the admin HTTP endpoint at /ingestion/filters/backfill (requires --exp-enable-ingestion-filtering and --ingest, should be enabled on a single instance)",
+			ConfigKey:   &config.EnableIngestionFilterBackfill,
+		},
 		&support.ConfigOption{
 			Name:           "captive-core-http-port",
 			OptType:        types.Uint,
@@ -274,6 +293,22 @@ func Flags() (*Config, support.ConfigOptions) {
 			},
 			Usage: "comma-separated list of stellar history archives to connect with",
 		},
+		&support.ConfigOption{
+			Name:        "history-archive-cache-path",
+			ConfigKey:   &config.HistoryArchiveCachePath,
+			OptType:     types.String,
+			Required:    false,
+			FlagDefault: "",
+			Usage:       "directory in which buckets and checkpoint files downloaded from history archives are cached between restarts, caching is disabled if empty",
+		},
+		&support.ConfigOption{
+			Name:        "history-archive-cache-size",
+			ConfigKey:   &config.HistoryArchiveCacheSize,
+			OptType:     types.Uint,
+			Required:    false,
+			FlagDefault: uint(10240),
+			Usage:       "maximum total size (in MB) of the history archive cache (shared by all archives in --history-archive-urls), the least recently used files are removed when it's exceeded",
+		},
 		&support.ConfigOption{
 			Name:        "port",
 			ConfigKey:   &config.Port,
@@ -479,6 +514,30 @@ func Flags() (*Config, support.ConfigOptions) {
 			FlagDefault: uint(0),
 			Usage:       "the minimum number of ledgers to maintain within horizon's history tables.  0 signifies an unlimited number of ledgers will be retained",
 		},
+		&support.ConfigOption{
+			Name:        "history-retention-overrides",
+			ConfigKey:   &config.HistoryRetentionCounts,
+			OptType:     types.String,
+			FlagDefault: "",
+			CustomSetValue: func(co *support.ConfigOption) error {
+				counts, err := reap.ParseRetentionCounts(viper.GetString(co.Name))
+				if err != nil {
+					return err
+				}
+				*(co.ConfigKey.(*map[string]uint)) = counts
+				return nil
+			},
+			Usage: "comma-separated list of table=count pairs overriding history-retention-count for individual history tables " +
+				"or table families (ledgers, transactions, operations, effects, trades), e.g. effects=120960,trades=0. " +
+				"0 signifies an unlimited number of ledgers will be retained",
+		},
+		&support.ConfigOption{
+			Name:        "history-retention-batch-size",
+			ConfigKey:   &config.HistoryRetentionBatchSize,
+			OptType:     types.Uint,
+			FlagDefault: uint(reap.DefaultBatchSize),
+			Usage:       "the number of ledgers removed from a history table in a single database transaction by the reaper",
+		},
 		&support.ConfigOption{
 			Name:        "history-stale-threshold",
 			ConfigKey:   &config.StaleThreshold,
@@ -616,6 +675,10 @@ func ApplyFlags(config *Config, flags support.ConfigOptions, options ApplyOption
 		config.Ingest = true
 	}
 
+	if config.EnableIngestionFilterBackfill && (!config.EnableIngestionFiltering || !config.Ingest) {
+		return fmt.Errorf("Invalid config: --exp-enable-ingestion-filter-backfill requires --exp-enable-ingestion-filtering and --ingest")
+	}
+
 	if config.Ingest {
 		// Migrations should be checked as early as possible. Apply and check
 		// only on ingesting instances which are required to have write-access
@@ -655,13 +718,13 @@ func ApplyFlags(config *Config, flags support.ConfigOptions, options ApplyOption
 			// NOTE: If both of these are set (regardless of user- or PATH-supplied
 			//       defaults for the binary path), the Remote Captive Core URL
 			//       takes precedence.
-			if binaryPath == "" && config.RemoteCaptiveCoreURL == "" {
+			if binaryPath == "" && config.RemoteCaptiveCoreURL == "" && config.LedgerFilesURL == "" {
 				return fmt.Errorf("Invalid config: captive core requires that either --%s or --remote-captive-core-url is set. %s",
 					StellarCoreBinaryPathName, captiveCoreMigrationHint)
 			}
 
 			config.CaptiveCoreTomlParams.CoreBinaryPath = binaryPath
-			if config.RemoteCaptiveCoreURL == "" && (binaryPath == "" || config.CaptiveCoreConfigPath == "") {
+			if config.RemoteCaptiveCoreURL == "" && config.LedgerFilesURL == "" && (binaryPath == "" || config.CaptiveCoreConfigPath == "") {
 				if options.RequireCaptiveCoreConfig {
 					var err error
 					errorMessage := fmt.Errorf(
@@ -707,7 +770,7 @@ func ApplyFlags(config *Config, flags support.ConfigOptions, options ApplyOption
 						return fmt.Errorf("Invalid captive core toml file %v", err)
 					}
 				}
-			} else if config.RemoteCaptiveCoreURL == "" {
+			} else if config.RemoteCaptiveCoreURL == "" && config.LedgerFilesURL == "" {
 				var err error
 				config.CaptiveCoreTomlParams.HistoryArchiveURLs = config.HistoryArchiveURLs
 				config.CaptiveCoreTomlParams.NetworkPassphrase = config.NetworkPassphrase
@@ -719,7 +782,7 @@ func ApplyFlags(config *Config, flags support.ConfigOptions, options ApplyOption
 
 			// If we don't supply an explicit core URL and we are running a local
 			// captive core process with the http port enabled, point to it.
-			if config.StellarCoreURL == "" && config.RemoteCaptiveCoreURL == "" && config.CaptiveCoreToml.HTTPPort != 0 {
+			if config.StellarCoreURL == "" && config.RemoteCaptiveCoreURL == "" && config.LedgerFilesURL == "" && config.CaptiveCoreToml.HTTPPort != 0 {
 				config.StellarCoreURL = fmt.Sprintf("http://localhost:%d", config.CaptiveCoreToml.HTTPPort)
 				viper.Set(StellarCoreURLFlagName, config.StellarCoreURL)
 			}
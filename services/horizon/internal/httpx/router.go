@@ -48,6 +48,12 @@ type RouterConfig struct {
 	FriendbotURL             *url.URL
 	HealthCheck              http.Handler
 	EnableIngestionFiltering bool
+	// FilterBackfill (optional) reports the status of the history backfill
+	// after ingestion filter rules change.
+	FilterBackfill actions.FilterBackfillStatusGetter
+	// IngestionProgress (optional) reports the progress of reingestion and
+	// state builds.
+	IngestionProgress actions.IngestionProgressGetter
 }
 
 type Router struct {
@@ -352,6 +358,9 @@ func (r *Router) addRoutes(config *RouterConfig, rateLimiter *throttled.HTTPRate
 	r.Internal.Get("/metrics", promhttp.HandlerFor(config.PrometheusRegistry, promhttp.HandlerOpts{}).ServeHTTP)
 	r.Internal.Get("/debug/pprof/heap", pprof.Index)
 	r.Internal.Get("/debug/pprof/profile", pprof.Profile)
+	if config.IngestionProgress != nil {
+		r.Internal.Get("/ingestion/progress", actions.IngestionProgressHandler{Getter: config.IngestionProgress}.GetProgress)
+	}
 	if config.EnableIngestionFiltering {
 		r.Internal.Route("/ingestion/filters", func(r chi.Router) {
 			handler := actions.FilterConfigHandler{}
@@ -359,6 +368,13 @@ func (r *Router) addRoutes(config *RouterConfig, rateLimiter *throttled.HTTPRate
 			r.With(historyMiddleware).Put("/account", handler.UpdateAccountConfig)
 			r.With(historyMiddleware).Get("/asset", handler.GetAssetConfig)
 			r.With(historyMiddleware).Get("/account", handler.GetAccountConfig)
+			r.With(historyMiddleware).Put("/transaction", handler.UpdateTransactionConfig)
+			r.With(historyMiddleware).Get("/transaction", handler.GetTransactionConfig)
+			r.With(historyMiddleware).Put("/mode", handler.UpdateMode)
+			r.With(historyMiddleware).Get("/mode", handler.GetMode)
+			if config.FilterBackfill != nil {
+				r.Get("/backfill", actions.FilterBackfillHandler{Getter: config.FilterBackfill}.GetStatus)
+			}
 		})
 	}
 }
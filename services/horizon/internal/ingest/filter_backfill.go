@@ -0,0 +1,480 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	protocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/services/horizon/internal/db2/history"
+	"github.com/stellar/go/services/horizon/internal/ingest/filters"
+	"github.com/stellar/go/support/errors"
+	logpkg "github.com/stellar/go/support/log"
+)
+
+const (
+	defaultFilterBackfillBatchSize     = 10000
+	defaultFilterBackfillCheckInterval = time.Minute
+)
+
+type filterBackfillQ interface {
+	history.QFilter
+	GetFilterBackfillState(ctx context.Context) (string, error)
+	UpdateFilterBackfillState(ctx context.Context, state string) error
+	GetLastLedgerIngestNonBlocking(ctx context.Context) (uint32, error)
+	ElderLedger(ctx context.Context, dest interface{}) error
+}
+
+// filterRules is a snapshot of the rules of the asset or account filter.
+type filterRules struct {
+	Enabled      bool     `json:"enabled"`
+	Whitelist    []string `json:"whitelist"`
+	Blacklist    []string `json:"blacklist,omitempty"`
+	LastModified int64    `json:"last_modified"`
+}
+
+// filterBackfillJob is a reingestion of the [FromLedger, ToLedger] range.
+// Ledgers before NextLedger were already reingested.
+type filterBackfillJob struct {
+	NewlyMatchedAssets   []string   `json:"newly_matched_assets"`
+	NewlyMatchedAccounts []string   `json:"newly_matched_accounts"`
+	MatchAll             bool       `json:"match_all"`
+	FromLedger           uint32     `json:"from_ledger"`
+	ToLedger             uint32     `json:"to_ledger"`
+	NextLedger           uint32     `json:"next_ledger"`
+	ScheduledAt          time.Time  `json:"scheduled_at"`
+	FinishedAt           *time.Time `json:"finished_at,omitempty"`
+	LastError            string     `json:"last_error,omitempty"`
+}
+
+func (j *filterBackfillJob) done() bool {
+	return j.NextLedger > j.ToLedger
+}
+
+// filterBackfillState is stored in the key value store so the backfill is
+// resumed after restart.
+type filterBackfillState struct {
+	// AssetFilter and AccountFilter are the rules the history was last
+	// backfilled for. Rule changes are detected by comparing them with the
+	// current rules.
+	AssetFilter       filterRules                     `json:"asset_filter"`
+	AccountFilter     filterRules                     `json:"account_filter"`
+	TransactionFilter history.TransactionFilterConfig `json:"transaction_filter"`
+	Mode              history.FilterMode              `json:"mode"`
+	Job               *filterBackfillJob              `json:"job,omitempty"`
+}
+
+// FilterBackfillConfig configures FilterBackfiller.
+type FilterBackfillConfig struct {
+	// IngestConfig is used to create ingestion systems reingesting history.
+	IngestConfig Config
+	// BatchSize is the number of ledgers reingested before the progress is
+	// saved.
+	BatchSize uint32
+	// CheckInterval is the interval between checks of filter rules.
+	CheckInterval time.Duration
+}
+
+// FilterBackfiller detects changes of ingestion filter rules and, when the new
+// rules match transactions which were previously filtered out (ex. an asset
+// was added to the whitelist), reingests the retained history window (from
+// the oldest ledger in the history tables to the ledger before the last
+// ingested one) so the history contains the transactions matched by the new
+// rules. Rules which only filter out more transactions don't trigger a
+// backfill.
+//
+// Every ledger of the window is reingested: its data is deleted from all
+// history tables and ingested again, not only the newly matched
+// transactions. On instances retaining a lot of history a backfill can take
+// as long as `horizon db reingest range` of the whole window.
+//
+// The backfill is reingested in batches using a separate ingestion system
+// (with its own ledger backend) and its progress is stored in the DB so it's
+// resumed after restart. It should be enabled on a single ingesting instance.
+type FilterBackfiller struct {
+	config        FilterBackfillConfig
+	historyQ      filterBackfillQ
+	systemFactory func(Config) (System, error)
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// modeChangedAt is the time the change of the filter mode was detected.
+	// The mode has no modification time in the DB.
+	modeChangedAt time.Time
+
+	mutex  sync.Mutex
+	status protocol.FilterBackfillStatus
+}
+
+// NewFilterBackfiller creates a new FilterBackfiller instance.
+func NewFilterBackfiller(config FilterBackfillConfig) (*FilterBackfiller, error) {
+	if !config.IngestConfig.EnableIngestionFiltering {
+		return nil, errors.New("ingestion filtering must be enabled")
+	}
+	config.IngestConfig.ReingestEnabled = true
+	return newFilterBackfiller(
+		config,
+		&history.Q{config.IngestConfig.HistorySession.Clone()},
+		NewSystem,
+	), nil
+}
+
+func newFilterBackfiller(config FilterBackfillConfig, historyQ filterBackfillQ, systemFactory func(Config) (System, error)) *FilterBackfiller {
+	if config.BatchSize == 0 {
+		config.BatchSize = defaultFilterBackfillBatchSize
+	}
+	if config.CheckInterval == 0 {
+		config.CheckInterval = defaultFilterBackfillCheckInterval
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &FilterBackfiller{
+		config:        config,
+		historyQ:      historyQ,
+		systemFactory: systemFactory,
+		ctx:           ctx,
+		cancel:        cancel,
+		status:        protocol.FilterBackfillStatus{State: "idle"},
+	}
+}
+
+// Run checks filter rules and reingests pending batches until Shutdown is
+// called.
+func (b *FilterBackfiller) Run() {
+	for {
+		pending, err := b.runOnce(b.ctx)
+		if err != nil && !isCancelledError(err) {
+			log.WithError(err).Error("Error backfilling history after filter rules change")
+		}
+
+		wait := b.config.CheckInterval
+		if pending && err == nil {
+			wait = 0
+		}
+		select {
+		case <-b.ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Shutdown stops the backfiller. The batch being reingested is retried after
+// restart.
+func (b *FilterBackfiller) Shutdown() {
+	b.cancel()
+}
+
+// FilterBackfillStatus returns the status of the current (or last) backfill.
+func (b *FilterBackfiller) FilterBackfillStatus() protocol.FilterBackfillStatus {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.status
+}
+
+// runOnce schedules a backfill if filter rules changed and reingests the next
+// batch of the scheduled backfill. It returns true if there are more batches
+// to reingest.
+func (b *FilterBackfiller) runOnce(ctx context.Context) (bool, error) {
+	state, err := b.loadState(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	assetConfig, err := b.historyQ.GetAssetFilterConfig(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "error getting asset filter config")
+	}
+	accountConfig, err := b.historyQ.GetAccountFilterConfig(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "error getting account filter config")
+	}
+	transactionConfig, err := b.historyQ.GetTransactionFilterConfig(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "error getting transaction filter config")
+	}
+	mode, err := b.historyQ.GetFilterMode(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "error getting filter mode")
+	}
+	current := filterBackfillState{
+		AssetFilter: filterRules{
+			assetConfig.Enabled, assetConfig.Whitelist, assetConfig.Blacklist, assetConfig.LastModified,
+		},
+		AccountFilter: filterRules{
+			accountConfig.Enabled, accountConfig.Whitelist, accountConfig.Blacklist, accountConfig.LastModified,
+		},
+		TransactionFilter: transactionConfig,
+		Mode:              mode,
+	}
+
+	if state == nil {
+		// Nothing to compare the rules with on the first run.
+		state = &current
+		return false, b.saveState(ctx, state)
+	}
+	if state.Mode == "" {
+		// state stored before filter modes were added
+		state.Mode = history.FilterModeAll
+	}
+
+	modeChanged := state.Mode != current.Mode
+	if !modeChanged {
+		b.modeChangedAt = time.Time{}
+	} else if b.modeChangedAt.IsZero() {
+		b.modeChangedAt = time.Now()
+	}
+
+	if modeChanged ||
+		state.AssetFilter.LastModified != current.AssetFilter.LastModified ||
+		state.AccountFilter.LastModified != current.AccountFilter.LastModified ||
+		state.TransactionFilter.LastModified != current.TransactionFilter.LastModified {
+		// Wait until the ingestion system reloads the rules, so ledgers
+		// ingested after the backfill range are ingested using the new rules.
+		lastModified := current.AssetFilter.LastModified
+		for _, modified := range []int64{
+			current.AccountFilter.LastModified,
+			current.TransactionFilter.LastModified,
+			b.modeChangedAt.Unix(),
+		} {
+			if modified > lastModified {
+				lastModified = modified
+			}
+		}
+		reloadDelay := int64(filters.GetFilterConfigCheckIntervalSeconds())
+		if time.Now().Unix() <= lastModified+reloadDelay {
+			return false, nil
+		}
+
+		if err = b.scheduleJob(ctx, state, current); err != nil {
+			return false, err
+		}
+		b.modeChangedAt = time.Time{}
+	}
+
+	if state.Job == nil || state.Job.done() {
+		return false, nil
+	}
+	return b.runBatch(ctx, state)
+}
+
+func (b *FilterBackfiller) scheduleJob(ctx context.Context, state *filterBackfillState, current filterBackfillState) error {
+	addedAssets, matchAll := newlyMatched(state.AssetFilter, current.AssetFilter)
+	addedAccounts, allAccounts := newlyMatched(state.AccountFilter, current.AccountFilter)
+	matchAll = matchAll || allAccounts
+	if state.Mode == history.FilterModeAny || current.Mode == history.FilterModeAny {
+		// When filters are combined using FilterModeAny activating a filter or
+		// changing rules of one filter can match transactions not related to
+		// the changed rules so any change requires a full backfill.
+		matchAll = true
+	} else if filters.TransactionFilterRelaxed(state.TransactionFilter, current.TransactionFilter) {
+		matchAll = true
+	}
+
+	pendingJob := state.Job
+	*state = current
+	state.Job = pendingJob
+
+	if len(addedAssets) == 0 && len(addedAccounts) == 0 && !matchAll {
+		log.Info("Filter rules changed, no transactions are newly matched")
+		return b.saveState(ctx, state)
+	}
+
+	elder, err := b.elderLedger(ctx)
+	if err != nil {
+		return err
+	}
+	latest, err := b.historyQ.GetLastLedgerIngestNonBlocking(ctx)
+	if err != nil {
+		return errors.Wrap(err, getLastIngestedErrMsg)
+	}
+	// The last ingested ledger is excluded: ReingestRange rejects ranges
+	// including it and ledgers ingested after the rules were reloaded
+	// already match the new rules.
+	if elder == 0 || latest <= elder {
+		log.Info("Filter rules changed, no history to backfill")
+		return b.saveState(ctx, state)
+	}
+
+	job := &filterBackfillJob{
+		NewlyMatchedAssets:   addedAssets,
+		NewlyMatchedAccounts: addedAccounts,
+		MatchAll:             matchAll,
+		FromLedger:           elder,
+		ToLedger:             latest - 1,
+		NextLedger:           elder,
+		ScheduledAt:          time.Now().UTC(),
+	}
+	if state.Job != nil && !state.Job.done() {
+		// The new job reingests the whole window again so it also covers
+		// the interrupted one.
+		job.NewlyMatchedAssets = mergeWhitelists(state.Job.NewlyMatchedAssets, addedAssets)
+		job.NewlyMatchedAccounts = mergeWhitelists(state.Job.NewlyMatchedAccounts, addedAccounts)
+		job.MatchAll = job.MatchAll || state.Job.MatchAll
+	}
+	state.Job = job
+
+	log.WithFields(logpkg.F{
+		"from":           job.FromLedger,
+		"to":             job.ToLedger,
+		"added_assets":   job.NewlyMatchedAssets,
+		"added_accounts": job.NewlyMatchedAccounts,
+		"match_all":      job.MatchAll,
+	}).Info("Filter rules changed, scheduled history backfill")
+	return b.saveState(ctx, state)
+}
+
+func (b *FilterBackfiller) runBatch(ctx context.Context, state *filterBackfillState) (bool, error) {
+	job := state.Job
+
+	// Skip ledgers removed by the reaper in the meantime.
+	elder, err := b.elderLedger(ctx)
+	if err != nil {
+		return false, err
+	}
+	if elder > job.ToLedger {
+		job.NextLedger = job.ToLedger + 1
+	} else if elder > job.NextLedger {
+		job.NextLedger = elder
+	}
+
+	if !job.done() {
+		to := job.NextLedger + b.config.BatchSize - 1
+		if to > job.ToLedger {
+			to = job.ToLedger
+		}
+		if err := b.reingest(job.NextLedger, to); err != nil {
+			job.LastError = err.Error()
+			if saveErr := b.saveState(ctx, state); saveErr != nil {
+				return false, saveErr
+			}
+			return false, err
+		}
+		log.WithFields(logpkg.F{"from": job.NextLedger, "to": to}).
+			Info("Backfilled history after filter rules change")
+		job.NextLedger = to + 1
+		job.LastError = ""
+	}
+
+	if job.done() {
+		finishedAt := time.Now().UTC()
+		job.FinishedAt = &finishedAt
+	}
+	if err := b.saveState(ctx, state); err != nil {
+		return false, err
+	}
+	return !job.done(), nil
+}
+
+// newlyMatched returns assets or accounts which are matched by the current
+// rules but were not matched by the previous ones (added to the whitelist or
+// removed from the blacklist). matchAll is true when the filter no longer
+// selects transactions.
+func newlyMatched(previous, current filterRules) ([]string, bool) {
+	added, matchAll := filters.WhitelistAdditions(
+		previous.Enabled, previous.Whitelist,
+		current.Enabled, current.Whitelist,
+	)
+	removed := filters.BlacklistRemovals(
+		previous.Enabled, previous.Blacklist,
+		current.Enabled, current.Blacklist,
+	)
+	return mergeWhitelists(added, removed), matchAll
+}
+
+// elderLedger returns the oldest ledger in the history tables or 0 if the
+// tables are empty.
+func (b *FilterBackfiller) elderLedger(ctx context.Context) (uint32, error) {
+	var elder int32
+	if err := b.historyQ.ElderLedger(ctx, &elder); err != nil {
+		return 0, errors.Wrap(err, "error getting elder ledger")
+	}
+	return uint32(elder), nil
+}
+
+func (b *FilterBackfiller) reingest(from, to uint32) error {
+	system, err := b.systemFactory(b.config.IngestConfig)
+	if err != nil {
+		return errors.Wrap(err, "error creating ingestion system")
+	}
+	defer system.Shutdown()
+
+	return system.ReingestRange([]history.LedgerRange{{StartSequence: from, EndSequence: to}}, false)
+}
+
+func (b *FilterBackfiller) loadState(ctx context.Context) (*filterBackfillState, error) {
+	value, err := b.historyQ.GetFilterBackfillState(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting filter backfill state")
+	}
+	if value == "" {
+		return nil, nil
+	}
+
+	var state filterBackfillState
+	if err = json.Unmarshal([]byte(value), &state); err != nil {
+		return nil, errors.Wrap(err, "error decoding filter backfill state")
+	}
+	b.updateStatus(&state)
+	return &state, nil
+}
+
+func (b *FilterBackfiller) saveState(ctx context.Context, state *filterBackfillState) error {
+	value, err := json.Marshal(state)
+	if err != nil {
+		return errors.Wrap(err, "error encoding filter backfill state")
+	}
+	if err = b.historyQ.UpdateFilterBackfillState(ctx, string(value)); err != nil {
+		return errors.Wrap(err, "error updating filter backfill state")
+	}
+	b.updateStatus(state)
+	return nil
+}
+
+func (b *FilterBackfiller) updateStatus(state *filterBackfillState) {
+	status := protocol.FilterBackfillStatus{State: "idle"}
+	if job := state.Job; job != nil {
+		scheduledAt := job.ScheduledAt
+		status = protocol.FilterBackfillStatus{
+			State:                "running",
+			NewlyMatchedAssets:   job.NewlyMatchedAssets,
+			NewlyMatchedAccounts: job.NewlyMatchedAccounts,
+			MatchAll:             job.MatchAll,
+			FromLedger:           job.FromLedger,
+			ToLedger:             job.ToLedger,
+			NextLedger:           job.NextLedger,
+			ProgressPercent: 100 * float64(job.NextLedger-job.FromLedger) /
+				float64(job.ToLedger-job.FromLedger+1),
+			ScheduledAt: &scheduledAt,
+			FinishedAt:  job.FinishedAt,
+			LastError:   job.LastError,
+		}
+		switch {
+		case job.FinishedAt != nil:
+			status.State = "completed"
+		case job.LastError != "":
+			status.State = "failing"
+		}
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.status = status
+}
+
+// mergeWhitelists returns entries of a followed by entries of b not present
+// in a.
+func mergeWhitelists(a, b []string) []string {
+	merged := append([]string{}, a...)
+	seen := map[string]bool{}
+	for _, entry := range a {
+		seen[entry] = true
+	}
+	for _, entry := range b {
+		if !seen[entry] {
+			merged = append(merged, entry)
+		}
+	}
+	return merged
+}
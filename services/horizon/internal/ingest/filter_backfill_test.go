@@ -0,0 +1,258 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stellar/go/services/horizon/internal/db2/history"
+)
+
+type fakeFilterBackfillQ struct {
+	assetConfig       history.AssetFilterConfig
+	accountConfig     history.AccountFilterConfig
+	transactionConfig history.TransactionFilterConfig
+	mode              history.FilterMode
+	state             string
+	lastIngested      uint32
+	elder             int32
+}
+
+func (q *fakeFilterBackfillQ) GetAccountFilterConfig(ctx context.Context) (history.AccountFilterConfig, error) {
+	return q.accountConfig, nil
+}
+
+func (q *fakeFilterBackfillQ) GetAssetFilterConfig(ctx context.Context) (history.AssetFilterConfig, error) {
+	return q.assetConfig, nil
+}
+
+func (q *fakeFilterBackfillQ) UpdateAssetFilterConfig(ctx context.Context, config history.AssetFilterConfig) (history.AssetFilterConfig, error) {
+	q.assetConfig = config
+	return config, nil
+}
+
+func (q *fakeFilterBackfillQ) UpdateAccountFilterConfig(ctx context.Context, config history.AccountFilterConfig) (history.AccountFilterConfig, error) {
+	q.accountConfig = config
+	return config, nil
+}
+
+func (q *fakeFilterBackfillQ) GetTransactionFilterConfig(ctx context.Context) (history.TransactionFilterConfig, error) {
+	return q.transactionConfig, nil
+}
+
+func (q *fakeFilterBackfillQ) UpdateTransactionFilterConfig(ctx context.Context, config history.TransactionFilterConfig) (history.TransactionFilterConfig, error) {
+	q.transactionConfig = config
+	return config, nil
+}
+
+func (q *fakeFilterBackfillQ) GetFilterMode(ctx context.Context) (history.FilterMode, error) {
+	if q.mode == "" {
+		return history.FilterModeAll, nil
+	}
+	return q.mode, nil
+}
+
+func (q *fakeFilterBackfillQ) UpdateFilterMode(ctx context.Context, mode history.FilterMode) error {
+	q.mode = mode
+	return nil
+}
+
+func (q *fakeFilterBackfillQ) GetFilterBackfillState(ctx context.Context) (string, error) {
+	return q.state, nil
+}
+
+func (q *fakeFilterBackfillQ) UpdateFilterBackfillState(ctx context.Context, state string) error {
+	q.state = state
+	return nil
+}
+
+func (q *fakeFilterBackfillQ) GetLastLedgerIngestNonBlocking(ctx context.Context) (uint32, error) {
+	return q.lastIngested, nil
+}
+
+func (q *fakeFilterBackfillQ) ElderLedger(ctx context.Context, dest interface{}) error {
+	*dest.(*int32) = q.elder
+	return nil
+}
+
+func TestFilterBackfiller(t *testing.T) {
+	ctx := context.Background()
+	q := &fakeFilterBackfillQ{
+		assetConfig: history.AssetFilterConfig{
+			Enabled:      true,
+			Whitelist:    []string{"USD:GCYLTPOU7IVYHHA3XKQF4YB4W4ZWHFERMOQ7K47IWANKNBFBNJJNEOG5"},
+			LastModified: 1,
+		},
+		lastIngested: 35,
+		elder:        10,
+	}
+	system := &mockSystem{}
+	backfiller := newFilterBackfiller(
+		FilterBackfillConfig{BatchSize: 10},
+		q,
+		func(Config) (System, error) { return system, nil },
+	)
+
+	// The first run stores the rules
+	pending, err := backfiller.runOnce(ctx)
+	require.NoError(t, err)
+	assert.False(t, pending)
+	assert.Equal(t, "idle", backfiller.FilterBackfillStatus().State)
+
+	// Rules modified recently are not used yet
+	q.assetConfig.Whitelist = append(q.assetConfig.Whitelist, "EUR:GCYLTPOU7IVYHHA3XKQF4YB4W4ZWHFERMOQ7K47IWANKNBFBNJJNEOG5")
+	q.assetConfig.LastModified = time.Now().Unix()
+	pending, err = backfiller.runOnce(ctx)
+	require.NoError(t, err)
+	assert.False(t, pending)
+	assert.Equal(t, "idle", backfiller.FilterBackfillStatus().State)
+
+	q.assetConfig.LastModified = time.Now().Add(-time.Hour).Unix()
+	system.On("ReingestRange", []history.LedgerRange{{StartSequence: 10, EndSequence: 19}}, false).Return(nil).Once()
+	system.On("ReingestRange", []history.LedgerRange{{StartSequence: 20, EndSequence: 29}}, false).Return(errors.New("timeout")).Once()
+	system.On("Shutdown").Return()
+	pending, err = backfiller.runOnce(ctx)
+	require.NoError(t, err)
+	assert.True(t, pending)
+	status := backfiller.FilterBackfillStatus()
+	assert.Equal(t, "running", status.State)
+	assert.Equal(t, []string{"EUR:GCYLTPOU7IVYHHA3XKQF4YB4W4ZWHFERMOQ7K47IWANKNBFBNJJNEOG5"}, status.NewlyMatchedAssets)
+	assert.Equal(t, uint32(10), status.FromLedger)
+	// The last ingested ledger can't be reingested.
+	assert.Equal(t, uint32(34), status.ToLedger)
+	assert.Equal(t, uint32(20), status.NextLedger)
+	assert.InDelta(t, 100*10/25.0, status.ProgressPercent, 0.001)
+
+	_, err = backfiller.runOnce(ctx)
+	assert.EqualError(t, err, "timeout")
+	status = backfiller.FilterBackfillStatus()
+	assert.Equal(t, "failing", status.State)
+	assert.Equal(t, "timeout", status.LastError)
+
+	// The progress is stored in the DB so a new backfiller resumes the job.
+	// Ledgers removed by the reaper in the meantime are skipped.
+	q.elder = 25
+	backfiller = newFilterBackfiller(
+		FilterBackfillConfig{BatchSize: 10},
+		q,
+		func(Config) (System, error) { return system, nil },
+	)
+	system.On("ReingestRange", []history.LedgerRange{{StartSequence: 25, EndSequence: 34}}, false).Return(nil).Once()
+	pending, err = backfiller.runOnce(ctx)
+	require.NoError(t, err)
+	assert.False(t, pending)
+	status = backfiller.FilterBackfillStatus()
+	assert.Equal(t, "completed", status.State)
+	assert.Empty(t, status.LastError)
+	assert.Equal(t, float64(100), status.ProgressPercent)
+	assert.NotNil(t, status.FinishedAt)
+	system.AssertExpectations(t)
+
+	// Removing rules doesn't schedule a backfill
+	q.assetConfig.Whitelist = q.assetConfig.Whitelist[:1]
+	q.assetConfig.LastModified = time.Now().Add(-30 * time.Minute).Unix()
+	pending, err = backfiller.runOnce(ctx)
+	require.NoError(t, err)
+	assert.False(t, pending)
+	assert.Equal(t, "completed", backfiller.FilterBackfillStatus().State)
+	system.AssertExpectations(t)
+}
+
+func TestFilterBackfillerBlacklistsAndModes(t *testing.T) {
+	ctx := context.Background()
+	q := &fakeFilterBackfillQ{
+		assetConfig: history.AssetFilterConfig{
+			Enabled:      true,
+			Blacklist:    []string{"SPAM:GCYLTPOU7IVYHHA3XKQF4YB4W4ZWHFERMOQ7K47IWANKNBFBNJJNEOG5"},
+			LastModified: 1,
+		},
+		transactionConfig: history.TransactionFilterConfig{
+			Enabled:          true,
+			MinPaymentAmount: 100,
+			LastModified:     1,
+		},
+		lastIngested: 20,
+		elder:        11,
+	}
+	system := &mockSystem{}
+	backfiller := newFilterBackfiller(
+		FilterBackfillConfig{BatchSize: 10},
+		q,
+		func(Config) (System, error) { return system, nil },
+	)
+	system.On("ReingestRange", []history.LedgerRange{{StartSequence: 11, EndSequence: 19}}, false).Return(nil)
+	system.On("Shutdown").Return()
+
+	_, err := backfiller.runOnce(ctx)
+	require.NoError(t, err)
+
+	// Stricter rules don't schedule a backfill
+	q.transactionConfig.MinPaymentAmount = 1000
+	q.transactionConfig.LastModified = time.Now().Add(-time.Hour).Unix()
+	pending, err := backfiller.runOnce(ctx)
+	require.NoError(t, err)
+	assert.False(t, pending)
+	assert.Equal(t, "idle", backfiller.FilterBackfillStatus().State)
+
+	// Assets removed from the blacklist are newly matched
+	q.assetConfig.Blacklist = nil
+	q.assetConfig.LastModified = time.Now().Add(-time.Hour).Unix()
+	_, err = backfiller.runOnce(ctx)
+	require.NoError(t, err)
+	status := backfiller.FilterBackfillStatus()
+	assert.Equal(t, "completed", status.State)
+	assert.Equal(t, []string{"SPAM:GCYLTPOU7IVYHHA3XKQF4YB4W4ZWHFERMOQ7K47IWANKNBFBNJJNEOG5"}, status.NewlyMatchedAssets)
+	assert.False(t, status.MatchAll)
+
+	// Changing the mode is applied after the ingestion system reloads the
+	// rules and requires a full backfill.
+	q.mode = history.FilterModeAny
+	pending, err = backfiller.runOnce(ctx)
+	require.NoError(t, err)
+	assert.False(t, pending)
+	assert.False(t, backfiller.FilterBackfillStatus().MatchAll)
+
+	backfiller.modeChangedAt = time.Now().Add(-time.Hour)
+	_, err = backfiller.runOnce(ctx)
+	require.NoError(t, err)
+	status = backfiller.FilterBackfillStatus()
+	assert.Equal(t, "completed", status.State)
+	assert.True(t, status.MatchAll)
+	system.AssertNumberOfCalls(t, "ReingestRange", 2)
+}
+
+func TestFilterBackfillerSkipsLastIngestedLedger(t *testing.T) {
+	ctx := context.Background()
+	q := &fakeFilterBackfillQ{
+		assetConfig: history.AssetFilterConfig{
+			Enabled:      true,
+			Whitelist:    []string{"USD:GCYLTPOU7IVYHHA3XKQF4YB4W4ZWHFERMOQ7K47IWANKNBFBNJJNEOG5"},
+			LastModified: 1,
+		},
+		lastIngested: 35,
+		elder:        35,
+	}
+	system := &mockSystem{}
+	backfiller := newFilterBackfiller(
+		FilterBackfillConfig{BatchSize: 10},
+		q,
+		func(Config) (System, error) { return system, nil },
+	)
+	_, err := backfiller.runOnce(ctx)
+	require.NoError(t, err)
+
+	// The only retained ledger is the last ingested one which can't be
+	// reingested.
+	q.assetConfig.Whitelist = append(q.assetConfig.Whitelist, "EUR:GCYLTPOU7IVYHHA3XKQF4YB4W4ZWHFERMOQ7K47IWANKNBFBNJJNEOG5")
+	q.assetConfig.LastModified = time.Now().Add(-time.Hour).Unix()
+	pending, err := backfiller.runOnce(ctx)
+	require.NoError(t, err)
+	assert.False(t, pending)
+	assert.Equal(t, "idle", backfiller.FilterBackfillStatus().State)
+	system.AssertNotCalled(t, "ReingestRange", mock.Anything, mock.Anything)
+}
@@ -11,18 +11,21 @@ import (
 
 type accountFilter struct {
 	whitelistedAccountsSet set.Set[string]
+	blacklistedAccountsSet set.Set[string]
 	lastModified           int64
 	enabled                bool
 }
 
 type AccountFilter interface {
 	processors.LedgerTransactionFilterer
+	ruleFilter
 	RefreshAccountFilter(filterConfig *history.AccountFilterConfig) error
 }
 
 func NewAccountFilter() AccountFilter {
 	return &accountFilter{
 		whitelistedAccountsSet: set.Set[string]{},
+		blacklistedAccountsSet: set.Set[string]{},
 	}
 }
 
@@ -34,6 +37,7 @@ func (filter *accountFilter) RefreshAccountFilter(filterConfig *history.AccountF
 
 		filter.enabled = filterConfig.Enabled
 		filter.whitelistedAccountsSet = listToSet(filterConfig.Whitelist)
+		filter.blacklistedAccountsSet = listToSet(filterConfig.Blacklist)
 		filter.lastModified = filterConfig.LastModified
 	}
 
@@ -41,22 +45,45 @@ func (filter *accountFilter) RefreshAccountFilter(filterConfig *history.AccountF
 }
 
 func (f *accountFilter) FilterTransaction(ctx context.Context, transaction ingest.LedgerTransaction) (bool, error) {
-	// filtering is disabled if the whitelist is empty for now, as that is the only filter rule
-	if len(f.whitelistedAccountsSet) == 0 || !f.enabled {
-		return true, nil
+	result, err := f.matchTransaction(transaction)
+	if err != nil {
+		return false, err
+	}
+	return result.include(), nil
+}
+
+// matchTransaction excludes transactions with a blacklisted participant and
+// matches transactions with a whitelisted participant.
+func (f *accountFilter) matchTransaction(transaction ingest.LedgerTransaction) (filterResult, error) {
+	hasWhitelist := len(f.whitelistedAccountsSet) > 0
+	if !f.enabled || (!hasWhitelist && len(f.blacklistedAccountsSet) == 0) {
+		return filterResultNoRules, nil
 	}
 
 	participants, err := processors.ParticipantsForTransaction(0, transaction)
 	if err != nil {
-		return false, err
+		return filterResultNotMatched, err
 	}
 
 	// NOTE: this assumes that the participant list has a small memory footprint
 	//       otherwise, we should be doing the filtering on the DB side
+	matched := false
 	for _, p := range participants {
-		if f.whitelistedAccountsSet.Contains(p.Address()) {
-			return true, nil
+		address := p.Address()
+		if f.blacklistedAccountsSet.Contains(address) {
+			return filterResultExcluded, nil
+		}
+		if f.whitelistedAccountsSet.Contains(address) {
+			matched = true
 		}
 	}
-	return false, nil
+
+	switch {
+	case !hasWhitelist:
+		return filterResultNoRules, nil
+	case matched:
+		return filterResultMatched, nil
+	default:
+		return filterResultNotMatched, nil
+	}
 }
@@ -100,6 +100,43 @@ func TestAccountFilterDoesNotAllowWhenNoMatch(t *testing.T) {
 	tt.Equal(result, false)
 }
 
+func TestAccountFilterBlacklist(t *testing.T) {
+	tt := assert.New(t)
+	ctx := context.Background()
+
+	for _, testCase := range []struct {
+		name      string
+		whitelist []string
+		blacklist []string
+		expected  bool
+	}{
+		{"blacklisted", nil, []string{"GD6WNNTW664WH7FXC5RUMUTF7P5QSURC2IT36VOQEEGFZ4UWUEQGECAL"}, false},
+		{"not blacklisted", nil, []string{"GBRPYHIL2CI3FNQ4BXLFMNDLFJUNPU2HY3ZMFSHONUCEOASW7QC7OX2H"}, true},
+		{
+			"blacklisted and whitelisted",
+			[]string{"GD6WNNTW664WH7FXC5RUMUTF7P5QSURC2IT36VOQEEGFZ4UWUEQGECAL"},
+			[]string{"GD6WNNTW664WH7FXC5RUMUTF7P5QSURC2IT36VOQEEGFZ4UWUEQGECAL"},
+			false,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			filter := NewAccountFilter()
+			tt.NoError(filter.RefreshAccountFilter(&history.AccountFilterConfig{
+				Whitelist:    testCase.whitelist,
+				Blacklist:    testCase.blacklist,
+				Enabled:      true,
+				LastModified: 1,
+			}))
+
+			result, err := filter.FilterTransaction(ctx, getAccountTestTx(t,
+				"GD6WNNTW664WH7FXC5RUMUTF7P5QSURC2IT36VOQEEGFZ4UWUEQGECAL",
+				"GBRPYHIL2CI3FNQ4BXLFMNDLFJUNPU2HY3ZMFSHONUCEOASW7QC7OX2H"))
+			tt.NoError(err)
+			tt.Equal(testCase.expected, result)
+		})
+	}
+}
+
 func getAccountTestTx(t *testing.T, accountId string, issuer string) ingest.LedgerTransaction {
 
 	var xdrAssetCode [12]byte
@@ -18,19 +18,22 @@ var (
 )
 
 type assetFilter struct {
-	canonicalAssetsLookup set.Set[string]
-	lastModified          int64
-	enabled               bool
+	canonicalAssetsLookup   set.Set[string]
+	blacklistedAssetsLookup set.Set[string]
+	lastModified            int64
+	enabled                 bool
 }
 
 type AssetFilter interface {
 	processors.LedgerTransactionFilterer
+	ruleFilter
 	RefreshAssetFilter(filterConfig *history.AssetFilterConfig) error
 }
 
 func NewAssetFilter() AssetFilter {
 	return &assetFilter{
-		canonicalAssetsLookup: set.Set[string]{},
+		canonicalAssetsLookup:   set.Set[string]{},
+		blacklistedAssetsLookup: set.Set[string]{},
 	}
 }
 
@@ -41,6 +44,7 @@ func (filter *assetFilter) RefreshAssetFilter(filterConfig *history.AssetFilterC
 		logger.Infof("New Asset Filter config detected, reloading new config %v ", *filterConfig)
 		filter.enabled = filterConfig.Enabled
 		filter.canonicalAssetsLookup = listToSet(filterConfig.Whitelist)
+		filter.blacklistedAssetsLookup = listToSet(filterConfig.Blacklist)
 		filter.lastModified = filterConfig.LastModified
 	}
 
@@ -48,89 +52,86 @@ func (filter *assetFilter) RefreshAssetFilter(filterConfig *history.AssetFilterC
 }
 
 func (f *assetFilter) FilterTransaction(ctx context.Context, transaction ingest.LedgerTransaction) (bool, error) {
-	// filtering is disabled if the whitelist is empty for now as that is the only filter rule
-	if len(f.canonicalAssetsLookup) < 1 || !f.enabled {
-		return true, nil
+	result, err := f.matchTransaction(transaction)
+	if err != nil {
+		return false, err
 	}
-
-	var operations []xdr.Operation
-
-	if txv1, v1Exists := transaction.Envelope.GetV1(); v1Exists {
-		operations = txv1.Tx.Operations
+	if !result.include() {
+		logger.Debugf("No match, dropped tx with seq %v ", transaction.Envelope.SeqNum())
+		return false, nil
 	}
+	return true, nil
+}
 
-	if txv0, v0Exists := transaction.Envelope.GetV0(); v0Exists {
-		operations = txv0.Tx.Operations
+// matchTransaction excludes transactions referencing a blacklisted asset in
+// any of their operations and matches transactions referencing a
+// whitelisted asset.
+func (f *assetFilter) matchTransaction(transaction ingest.LedgerTransaction) (filterResult, error) {
+	hasWhitelist := len(f.canonicalAssetsLookup) > 0
+	if !f.enabled || (!hasWhitelist && len(f.blacklistedAssetsLookup) == 0) {
+		return filterResultNoRules, nil
 	}
 
-	if f.filterOperationsMatchedOnRules(operations) {
-		return true, nil
+	matched := false
+	for _, asset := range operationsAssets(transaction) {
+		canonical := asset.StringCanonical()
+		if f.blacklistedAssetsLookup.Contains(canonical) {
+			return filterResultExcluded, nil
+		}
+		if f.canonicalAssetsLookup.Contains(canonical) {
+			matched = true
+		}
 	}
 
-	logger.Debugf("No match, dropped tx with seq %v ", transaction.Envelope.SeqNum())
-	return false, nil
+	switch {
+	case !hasWhitelist:
+		return filterResultNoRules, nil
+	case matched:
+		return filterResultMatched, nil
+	default:
+		return filterResultNotMatched, nil
+	}
 }
 
-func (f assetFilter) filterOperationsMatchedOnRules(operations []xdr.Operation) bool {
-	for _, operation := range operations {
+// operationsAssets returns assets referenced by operations of the transaction.
+func operationsAssets(transaction ingest.LedgerTransaction) []xdr.Asset {
+	var assets []xdr.Asset
+	for _, operation := range transaction.Envelope.Operations() {
 		switch operation.Body.Type {
 		case xdr.OperationTypeChangeTrust:
-			if f.filterChangeTrustMatched(operation) {
-				return true
+			if pool, ok := operation.Body.ChangeTrustOp.Line.GetLiquidityPool(); ok {
+				assets = append(assets, pool.ConstantProduct.AssetA, pool.ConstantProduct.AssetB)
+			} else {
+				assets = append(assets, operation.Body.ChangeTrustOp.Line.ToAsset())
 			}
 		case xdr.OperationTypeManageSellOffer:
-			if f.assetMatchedFilter(&operation.Body.ManageSellOfferOp.Buying) || f.assetMatchedFilter(&operation.Body.ManageSellOfferOp.Selling) {
-				return true
-			}
+			assets = append(assets, operation.Body.ManageSellOfferOp.Buying, operation.Body.ManageSellOfferOp.Selling)
 		case xdr.OperationTypeManageBuyOffer:
-			if f.assetMatchedFilter(&operation.Body.ManageBuyOfferOp.Buying) || f.assetMatchedFilter(&operation.Body.ManageBuyOfferOp.Selling) {
-				return true
-			}
+			assets = append(assets, operation.Body.ManageBuyOfferOp.Buying, operation.Body.ManageBuyOfferOp.Selling)
 		case xdr.OperationTypeCreateClaimableBalance:
-			if f.assetMatchedFilter(&operation.Body.CreateClaimableBalanceOp.Asset) {
-				return true
-			}
+			assets = append(assets, operation.Body.CreateClaimableBalanceOp.Asset)
 		case xdr.OperationTypeCreatePassiveSellOffer:
-			if f.assetMatchedFilter(&operation.Body.CreatePassiveSellOfferOp.Buying) || f.assetMatchedFilter(&operation.Body.CreatePassiveSellOfferOp.Selling) {
-				return true
-			}
+			assets = append(assets, operation.Body.CreatePassiveSellOfferOp.Buying, operation.Body.CreatePassiveSellOfferOp.Selling)
 		case xdr.OperationTypeClawback:
-			if f.assetMatchedFilter(&operation.Body.ClawbackOp.Asset) {
-				return true
-			}
+			assets = append(assets, operation.Body.ClawbackOp.Asset)
 		case xdr.OperationTypePayment:
-			if f.assetMatchedFilter(&operation.Body.PaymentOp.Asset) {
-				return true
-			}
+			assets = append(assets, operation.Body.PaymentOp.Asset)
 		case xdr.OperationTypePathPaymentStrictReceive:
-			if f.assetMatchedFilter(&operation.Body.PathPaymentStrictReceiveOp.DestAsset) || f.assetMatchedFilter(&operation.Body.PathPaymentStrictReceiveOp.SendAsset) {
-				return true
-			}
+			assets = append(assets, operation.Body.PathPaymentStrictReceiveOp.DestAsset, operation.Body.PathPaymentStrictReceiveOp.SendAsset)
 		case xdr.OperationTypePathPaymentStrictSend:
-			if f.assetMatchedFilter(&operation.Body.PathPaymentStrictSendOp.DestAsset) || f.assetMatchedFilter(&operation.Body.PathPaymentStrictSendOp.SendAsset) {
-				return true
+			assets = append(assets, operation.Body.PathPaymentStrictSendOp.DestAsset, operation.Body.PathPaymentStrictSendOp.SendAsset)
+		case xdr.OperationTypeSetTrustLineFlags:
+			assets = append(assets, operation.Body.SetTrustLineFlagsOp.Asset)
+		case xdr.OperationTypeAllowTrust:
+			// the asset issuer is the source account of the operation
+			source := transaction.Envelope.SourceAccount()
+			if operation.SourceAccount != nil {
+				source = *operation.SourceAccount
 			}
+			assets = append(assets, operation.Body.AllowTrustOp.Asset.ToAsset(source.ToAccountId()))
 		}
 	}
-	return false
-}
-
-func (f assetFilter) filterChangeTrustMatched(operation xdr.Operation) bool {
-	if pool, ok := operation.Body.ChangeTrustOp.Line.GetLiquidityPool(); ok {
-		if f.assetMatchedFilter(&pool.ConstantProduct.AssetA) || f.assetMatchedFilter(&pool.ConstantProduct.AssetB) {
-			return true
-		}
-	} else {
-		asset := operation.Body.ChangeTrustOp.Line.ToAsset()
-		if f.assetMatchedFilter(&asset) {
-			return true
-		}
-	}
-	return false
-}
-
-func (f *assetFilter) assetMatchedFilter(asset *xdr.Asset) bool {
-	return f.canonicalAssetsLookup.Contains(asset.StringCanonical())
+	return assets
 }
 
 func listToSet(list []string) set.Set[string] {
@@ -98,6 +98,66 @@ func TestAssetFilterDoesNotAllowV1WhenNoMatch(t *testing.T) {
 	tt.Equal(result, false)
 }
 
+func TestAssetFilterBlacklist(t *testing.T) {
+	tt := assert.New(t)
+	ctx := context.Background()
+	usdc := "USDC:GD6WNNTW664WH7FXC5RUMUTF7P5QSURC2IT36VOQEEGFZ4UWUEQGECAL"
+	usdx := "USDX:GD6WNNTW664WH7FXC5RUMUTF7P5QSURC2IT36VOQEEGFZ4UWUEQGECAL"
+
+	for _, testCase := range []struct {
+		name      string
+		whitelist []string
+		blacklist []string
+		expected  bool
+	}{
+		{"blacklisted", nil, []string{usdc}, false},
+		{"not blacklisted", nil, []string{usdx}, true},
+		{"blacklisted and whitelisted", []string{usdc}, []string{usdc}, false},
+		{"whitelisted", []string{usdc}, []string{usdx}, true},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			filter := NewAssetFilter()
+			tt.NoError(filter.RefreshAssetFilter(&history.AssetFilterConfig{
+				Whitelist:    testCase.whitelist,
+				Blacklist:    testCase.blacklist,
+				Enabled:      true,
+				LastModified: 1,
+			}))
+
+			result, err := filter.FilterTransaction(ctx, getAssetTestV1Tx(t, "GD6WNNTW664WH7FXC5RUMUTF7P5QSURC2IT36VOQEEGFZ4UWUEQGECAL"))
+			tt.NoError(err)
+			tt.Equal(testCase.expected, result)
+		})
+	}
+}
+
+func TestAssetFilterAllowTrust(t *testing.T) {
+	tt := assert.New(t)
+	ctx := context.Background()
+
+	filter := NewAssetFilter()
+	tt.NoError(filter.RefreshAssetFilter(&history.AssetFilterConfig{
+		Whitelist:    []string{"USDC:GD6WNNTW664WH7FXC5RUMUTF7P5QSURC2IT36VOQEEGFZ4UWUEQGECAL"},
+		Enabled:      true,
+		LastModified: 1,
+	}))
+
+	tx := getAssetTestV1Tx(t, "GD6WNNTW664WH7FXC5RUMUTF7P5QSURC2IT36VOQEEGFZ4UWUEQGECAL")
+	tx.Envelope.V1.Tx.SourceAccount = xdr.MustMuxedAddress("GD6WNNTW664WH7FXC5RUMUTF7P5QSURC2IT36VOQEEGFZ4UWUEQGECAL")
+	tx.Envelope.V1.Tx.Operations = []xdr.Operation{
+		{Body: xdr.OperationBody{
+			Type: xdr.OperationTypeAllowTrust,
+			AllowTrustOp: &xdr.AllowTrustOp{
+				Trustor: xdr.MustAddress("GBRPYHIL2CI3FNQ4BXLFMNDLFJUNPU2HY3ZMFSHONUCEOASW7QC7OX2H"),
+				Asset:   xdr.MustNewAssetCodeFromString("USDC"),
+			},
+		}},
+	}
+	result, err := filter.FilterTransaction(ctx, tx)
+	tt.NoError(err)
+	tt.True(result)
+}
+
 func getAssetTestV1Tx(t *testing.T, issuer string) ingest.LedgerTransaction {
 	var xdrAssetCode [12]byte
 	var xdrIssuer xdr.AccountId
@@ -5,6 +5,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/stellar/go/ingest"
 	"github.com/stellar/go/services/horizon/internal/db2/history"
 	"github.com/stellar/go/services/horizon/internal/ingest/processors"
 	"github.com/stellar/go/support/log"
@@ -39,6 +40,8 @@ var (
 type filtersCache struct {
 	assetFilter                    AssetFilter
 	accountFilter                  AccountFilter
+	transactionFilter              TransactionFilter
+	mode                           history.FilterMode
 	lastFilterConfigCheckUnixEpoch int64
 }
 
@@ -48,8 +51,10 @@ type Filters interface {
 
 func NewFilters() Filters {
 	return &filtersCache{
-		assetFilter:   NewAssetFilter(),
-		accountFilter: NewAccountFilter(),
+		assetFilter:       NewAssetFilter(),
+		accountFilter:     NewAccountFilter(),
+		transactionFilter: NewTransactionFilter(),
+		mode:              history.FilterModeAll,
 	}
 }
 
@@ -81,9 +86,128 @@ func (f *filtersCache) GetFilters(filterQ history.QFilter, ctx context.Context)
 		}
 	}
 
+	if filterConfig, err := filterQ.GetTransactionFilterConfig(ctx); err != nil {
+		LOG.Errorf("unable to refresh transaction filter config %v", err)
+	} else {
+		if err := f.transactionFilter.RefreshTransactionFilter(&filterConfig); err != nil {
+			LOG.Errorf("unable to refresh transaction filter config %v", err)
+		}
+	}
+
+	if mode, err := filterQ.GetFilterMode(ctx); err != nil {
+		LOG.Errorf("unable to refresh filter mode %v", err)
+	} else if mode != f.mode {
+		LOG.Infof("New filter mode detected, reloading new mode %v", mode)
+		f.mode = mode
+	}
+
 	return f.convertCacheToList()
 }
 
 func (f *filtersCache) convertCacheToList() []processors.LedgerTransactionFilterer {
-	return []processors.LedgerTransactionFilterer{f.assetFilter, f.accountFilter}
+	if f.mode == history.FilterModeAny {
+		return []processors.LedgerTransactionFilterer{
+			anyFilter{filters: []ruleFilter{f.assetFilter, f.accountFilter, f.transactionFilter}},
+		}
+	}
+	return []processors.LedgerTransactionFilterer{f.assetFilter, f.accountFilter, f.transactionFilter}
+}
+
+// filterResult is the result of matching a transaction against rules of a
+// single filter.
+type filterResult int
+
+const (
+	// filterResultNoRules means the filter is disabled or has no rules
+	// selecting transactions (ex. only a blacklist which the transaction
+	// didn't match).
+	filterResultNoRules filterResult = iota
+	filterResultMatched
+	filterResultNotMatched
+	// filterResultExcluded means the transaction matched the filter's
+	// blacklist, it's never ingested.
+	filterResultExcluded
+)
+
+// include returns true if the transaction should be ingested when the
+// filter is used alone.
+func (r filterResult) include() bool {
+	return r == filterResultNoRules || r == filterResultMatched
+}
+
+// ruleFilter is implemented by all filters so their results can be combined
+// depending on the filter mode.
+type ruleFilter interface {
+	matchTransaction(transaction ingest.LedgerTransaction) (filterResult, error)
+}
+
+// anyFilter is used in FilterModeAny. It ingests a transaction if it's
+// matched by any of the filters with rules selecting transactions. A
+// transaction excluded by any filter is not ingested.
+type anyFilter struct {
+	filters []ruleFilter
+}
+
+func (f anyFilter) FilterTransaction(ctx context.Context, transaction ingest.LedgerTransaction) (bool, error) {
+	matched, hasRules := false, false
+	for _, filter := range f.filters {
+		result, err := filter.matchTransaction(transaction)
+		if err != nil {
+			return false, err
+		}
+		switch result {
+		case filterResultExcluded:
+			return false, nil
+		case filterResultMatched:
+			matched, hasRules = true, true
+		case filterResultNotMatched:
+			hasRules = true
+		}
+	}
+	return matched || !hasRules, nil
+}
+
+// WhitelistAdditions returns whitelist entries which are matched by the
+// current filter rules but were not matched by the previous ones. A filter
+// which is disabled or has an empty whitelist doesn't filter out anything so
+// matchAll is true when the current filter is inactive and the previous one
+// was active. When the previous filter was inactive nothing can be newly
+// matched.
+func WhitelistAdditions(previousEnabled bool, previous []string, currentEnabled bool, current []string) (added []string, matchAll bool) {
+	previousActive := previousEnabled && len(previous) > 0
+	currentActive := currentEnabled && len(current) > 0
+	switch {
+	case !previousActive:
+		return nil, false
+	case !currentActive:
+		return nil, true
+	}
+
+	previousSet := listToSet(previous)
+	for _, entry := range current {
+		if !previousSet.Contains(entry) {
+			added = append(added, entry)
+		}
+	}
+	return added, false
+}
+
+// BlacklistRemovals returns blacklist entries which are not excluded by the
+// current filter rules but were excluded by the previous ones.
+func BlacklistRemovals(previousEnabled bool, previous []string, currentEnabled bool, current []string) []string {
+	if !previousEnabled {
+		return nil
+	}
+	if !currentEnabled {
+		return previous
+	}
+
+	currentSet := listToSet(current)
+	var removed []string
+	for _, entry := range previous {
+		if !currentSet.Contains(entry) {
+			removed = append(removed, entry)
+		}
+	}
+	return removed
 }
@@ -1,10 +1,12 @@
 package filters
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stellar/go/services/horizon/internal/db2/history"
 	"github.com/stellar/go/services/horizon/internal/test"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestItGetsFilters(t *testing.T) {
@@ -18,5 +20,126 @@ func TestItGetsFilters(t *testing.T) {
 	ingestFilters := filtersService.GetFilters(q, tt.Ctx)
 
 	// should be total of filters implemented in the system
-	tt.Assert.Len(ingestFilters, 2)
+	tt.Assert.Len(ingestFilters, 3)
+}
+
+func TestFilterModeAny(t *testing.T) {
+	ctx := context.Background()
+	q := &history.MockQFilter{}
+	q.On("GetAssetFilterConfig", ctx).Return(history.AssetFilterConfig{
+		Enabled:      true,
+		Whitelist:    []string{"USDX:GD6WNNTW664WH7FXC5RUMUTF7P5QSURC2IT36VOQEEGFZ4UWUEQGECAL"},
+		LastModified: 1,
+	}, nil).Once()
+	q.On("GetAccountFilterConfig", ctx).Return(history.AccountFilterConfig{
+		Enabled:      true,
+		Whitelist:    []string{"GD6WNNTW664WH7FXC5RUMUTF7P5QSURC2IT36VOQEEGFZ4UWUEQGECAL"},
+		LastModified: 1,
+	}, nil).Once()
+	q.On("GetTransactionFilterConfig", ctx).Return(history.TransactionFilterConfig{}, nil).Once()
+	q.On("GetFilterMode", ctx).Return(history.FilterModeAny, nil).Once()
+
+	ingestFilters := NewFilters().GetFilters(q, ctx)
+	assert.Len(t, ingestFilters, 1)
+	q.AssertExpectations(t)
+
+	// matched by the account filter only
+	tx := getAccountTestTx(t,
+		"GD6WNNTW664WH7FXC5RUMUTF7P5QSURC2IT36VOQEEGFZ4UWUEQGECAL",
+		"GBRPYHIL2CI3FNQ4BXLFMNDLFJUNPU2HY3ZMFSHONUCEOASW7QC7OX2H")
+	include, err := ingestFilters[0].FilterTransaction(ctx, tx)
+	assert.NoError(t, err)
+	assert.True(t, include)
+
+	// not matched by any filter
+	tx = getAccountTestTx(t,
+		"GBRPYHIL2CI3FNQ4BXLFMNDLFJUNPU2HY3ZMFSHONUCEOASW7QC7OX2H",
+		"GBRPYHIL2CI3FNQ4BXLFMNDLFJUNPU2HY3ZMFSHONUCEOASW7QC7OX2H")
+	include, err = ingestFilters[0].FilterTransaction(ctx, tx)
+	assert.NoError(t, err)
+	assert.False(t, include)
+}
+
+func TestAnyFilterExcludes(t *testing.T) {
+	ctx := context.Background()
+	assetFilter := NewAssetFilter()
+	assert.NoError(t, assetFilter.RefreshAssetFilter(&history.AssetFilterConfig{
+		Enabled:      true,
+		Blacklist:    []string{"USDC:GBRPYHIL2CI3FNQ4BXLFMNDLFJUNPU2HY3ZMFSHONUCEOASW7QC7OX2H"},
+		LastModified: 1,
+	}))
+	accountFilter := NewAccountFilter()
+	assert.NoError(t, accountFilter.RefreshAccountFilter(&history.AccountFilterConfig{
+		Enabled:      true,
+		Whitelist:    []string{"GD6WNNTW664WH7FXC5RUMUTF7P5QSURC2IT36VOQEEGFZ4UWUEQGECAL"},
+		LastModified: 1,
+	}))
+	filter := anyFilter{filters: []ruleFilter{assetFilter, accountFilter}}
+
+	// matched by the account filter but the asset is blacklisted
+	include, err := filter.FilterTransaction(ctx, getAccountTestTx(t,
+		"GD6WNNTW664WH7FXC5RUMUTF7P5QSURC2IT36VOQEEGFZ4UWUEQGECAL",
+		"GBRPYHIL2CI3FNQ4BXLFMNDLFJUNPU2HY3ZMFSHONUCEOASW7QC7OX2H"))
+	assert.NoError(t, err)
+	assert.False(t, include)
+
+	// no filter has rules selecting transactions
+	filter = anyFilter{filters: []ruleFilter{NewAssetFilter(), NewTransactionFilter()}}
+	include, err = filter.FilterTransaction(ctx, getAccountTestTx(t,
+		"GD6WNNTW664WH7FXC5RUMUTF7P5QSURC2IT36VOQEEGFZ4UWUEQGECAL",
+		"GBRPYHIL2CI3FNQ4BXLFMNDLFJUNPU2HY3ZMFSHONUCEOASW7QC7OX2H"))
+	assert.NoError(t, err)
+	assert.True(t, include)
+}
+
+func TestWhitelistAdditions(t *testing.T) {
+	for _, testCase := range []struct {
+		name            string
+		previousEnabled bool
+		previous        []string
+		currentEnabled  bool
+		current         []string
+		added           []string
+		matchAll        bool
+	}{
+		{"previous disabled", false, []string{"a"}, true, []string{"a", "b"}, nil, false},
+		{"previous empty", true, nil, true, []string{"a"}, nil, false},
+		{"current disabled", true, []string{"a"}, false, []string{"a"}, nil, true},
+		{"current empty", true, []string{"a"}, true, nil, nil, true},
+		{"added entries", true, []string{"a", "b"}, true, []string{"b", "c", "d"}, []string{"c", "d"}, false},
+		{"removed entries", true, []string{"a", "b"}, true, []string{"a"}, nil, false},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			added, matchAll := WhitelistAdditions(
+				testCase.previousEnabled, testCase.previous,
+				testCase.currentEnabled, testCase.current,
+			)
+			assert.Equal(t, testCase.added, added)
+			assert.Equal(t, testCase.matchAll, matchAll)
+		})
+	}
+}
+
+func TestBlacklistRemovals(t *testing.T) {
+	for _, testCase := range []struct {
+		name            string
+		previousEnabled bool
+		previous        []string
+		currentEnabled  bool
+		current         []string
+		removed         []string
+	}{
+		{"previous disabled", false, []string{"a"}, true, nil, nil},
+		{"current disabled", true, []string{"a"}, false, []string{"a"}, []string{"a"}},
+		{"removed entries", true, []string{"a", "b", "c"}, true, []string{"b", "d"}, []string{"a", "c"}},
+		{"added entries", true, []string{"a"}, true, []string{"a", "b"}, nil},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			removed := BlacklistRemovals(
+				testCase.previousEnabled, testCase.previous,
+				testCase.currentEnabled, testCase.current,
+			)
+			assert.Equal(t, testCase.removed, removed)
+		})
+	}
 }
@@ -0,0 +1,194 @@
+package filters
+
+import (
+	"context"
+	"encoding/base64"
+	"strconv"
+
+	"github.com/stellar/go/ingest"
+	"github.com/stellar/go/protocols/horizon/operations"
+	"github.com/stellar/go/services/horizon/internal/db2/history"
+	"github.com/stellar/go/services/horizon/internal/ingest/processors"
+	"github.com/stellar/go/support/collections/set"
+	"github.com/stellar/go/xdr"
+)
+
+// OperationTypesByName maps operation type names used in Horizon responses
+// (and in transaction filter rules) to operation types.
+var OperationTypesByName = func() map[string]xdr.OperationType {
+	types := make(map[string]xdr.OperationType, len(operations.TypeNames))
+	for opType, name := range operations.TypeNames {
+		types[name] = opType
+	}
+	return types
+}()
+
+type transactionFilter struct {
+	operationTypes   set.Set[xdr.OperationType]
+	memos            set.Set[string]
+	minPaymentAmount int64
+	lastModified     int64
+	enabled          bool
+}
+
+type TransactionFilter interface {
+	processors.LedgerTransactionFilterer
+	ruleFilter
+	RefreshTransactionFilter(filterConfig *history.TransactionFilterConfig) error
+}
+
+func NewTransactionFilter() TransactionFilter {
+	return &transactionFilter{
+		operationTypes: set.Set[xdr.OperationType]{},
+		memos:          set.Set[string]{},
+	}
+}
+
+func (filter *transactionFilter) RefreshTransactionFilter(filterConfig *history.TransactionFilterConfig) error {
+	// only need to re-initialize the filter config state(rules) if its cached version(in  memory)
+	// is older than the incoming config version based on lastModified epoch timestamp
+	if filterConfig.LastModified > filter.lastModified {
+		logger.Infof("New Transaction Filter config detected, reloading new config %v ", *filterConfig)
+
+		operationTypes := set.NewSet[xdr.OperationType](len(filterConfig.OperationTypes))
+		for _, name := range filterConfig.OperationTypes {
+			if opType, ok := OperationTypesByName[name]; ok {
+				operationTypes.Add(opType)
+			} else {
+				logger.Warnf("Unknown operation type %s in transaction filter config", name)
+			}
+		}
+
+		filter.enabled = filterConfig.Enabled
+		filter.operationTypes = operationTypes
+		filter.memos = listToSet(filterConfig.Memos)
+		filter.minPaymentAmount = filterConfig.MinPaymentAmount
+		filter.lastModified = filterConfig.LastModified
+	}
+
+	return nil
+}
+
+func (f *transactionFilter) FilterTransaction(ctx context.Context, transaction ingest.LedgerTransaction) (bool, error) {
+	result, err := f.matchTransaction(transaction)
+	if err != nil {
+		return false, err
+	}
+	return result.include(), nil
+}
+
+// matchTransaction matches transactions meeting all configured rules:
+// containing an operation of one of the operation types, with one of the
+// memos and containing a payment of at least the minimum amount.
+func (f *transactionFilter) matchTransaction(transaction ingest.LedgerTransaction) (filterResult, error) {
+	if !f.enabled || (len(f.operationTypes) == 0 && len(f.memos) == 0 && f.minPaymentAmount == 0) {
+		return filterResultNoRules, nil
+	}
+
+	if len(f.memos) > 0 && !f.memos.Contains(memoValue(transaction.Envelope.Memo())) {
+		return filterResultNotMatched, nil
+	}
+
+	operations := transaction.Envelope.Operations()
+	if len(f.operationTypes) > 0 {
+		found := false
+		for _, operation := range operations {
+			if f.operationTypes.Contains(operation.Body.Type) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return filterResultNotMatched, nil
+		}
+	}
+
+	if f.minPaymentAmount > 0 {
+		found := false
+		for _, operation := range operations {
+			if amount, ok := paymentAmount(operation); ok && amount >= f.minPaymentAmount {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return filterResultNotMatched, nil
+		}
+	}
+
+	return filterResultMatched, nil
+}
+
+// memoValue returns the memo as displayed in Horizon transaction resources:
+// text memos as is, id memos in decimal and hash memos base64 encoded.
+func memoValue(memo xdr.Memo) string {
+	switch memo.Type {
+	case xdr.MemoTypeMemoText:
+		return memo.MustText()
+	case xdr.MemoTypeMemoId:
+		return strconv.FormatUint(uint64(memo.MustId()), 10)
+	case xdr.MemoTypeMemoHash:
+		hash := memo.MustHash()
+		return base64.StdEncoding.EncodeToString(hash[:])
+	case xdr.MemoTypeMemoReturn:
+		hash := memo.MustRetHash()
+		return base64.StdEncoding.EncodeToString(hash[:])
+	default:
+		return ""
+	}
+}
+
+// paymentAmount returns the amount (in stroops, regardless of the asset)
+// sent by create account and payment operations.
+func paymentAmount(operation xdr.Operation) (int64, bool) {
+	switch operation.Body.Type {
+	case xdr.OperationTypeCreateAccount:
+		return int64(operation.Body.CreateAccountOp.StartingBalance), true
+	case xdr.OperationTypePayment:
+		return int64(operation.Body.PaymentOp.Amount), true
+	case xdr.OperationTypePathPaymentStrictReceive:
+		return int64(operation.Body.PathPaymentStrictReceiveOp.DestAmount), true
+	case xdr.OperationTypePathPaymentStrictSend:
+		return int64(operation.Body.PathPaymentStrictSendOp.SendAmount), true
+	default:
+		return 0, false
+	}
+}
+
+// TransactionFilterRelaxed returns true if the current transaction filter
+// rules can match transactions which were not matched by the previous ones,
+// assuming the filters are combined using history.FilterModeAll.
+func TransactionFilterRelaxed(previous, current history.TransactionFilterConfig) bool {
+	previousActive := previous.Enabled &&
+		(len(previous.OperationTypes) > 0 || len(previous.Memos) > 0 || previous.MinPaymentAmount > 0)
+	currentActive := current.Enabled &&
+		(len(current.OperationTypes) > 0 || len(current.Memos) > 0 || current.MinPaymentAmount > 0)
+	switch {
+	case !previousActive:
+		return false
+	case !currentActive:
+		return true
+	}
+
+	return listRelaxed(previous.OperationTypes, current.OperationTypes) ||
+		listRelaxed(previous.Memos, current.Memos) ||
+		current.MinPaymentAmount < previous.MinPaymentAmount
+}
+
+// listRelaxed returns true if the current list of accepted values accepts a
+// value not accepted by the previous one. An empty list accepts everything.
+func listRelaxed(previous, current []string) bool {
+	if len(previous) == 0 {
+		return false
+	}
+	if len(current) == 0 {
+		return true
+	}
+	previousSet := listToSet(previous)
+	for _, entry := range current {
+		if !previousSet.Contains(entry) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package filters
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stellar/go/services/horizon/internal/db2/history"
+	"github.com/stellar/go/xdr"
+)
+
+func TestTransactionFilter(t *testing.T) {
+	ctx := context.Background()
+
+	for _, testCase := range []struct {
+		name     string
+		config   history.TransactionFilterConfig
+		expected bool
+	}{
+		{"disabled", history.TransactionFilterConfig{OperationTypes: []string{"create_account"}}, true},
+		{"no rules", history.TransactionFilterConfig{Enabled: true}, true},
+		{"operation type matched", history.TransactionFilterConfig{Enabled: true, OperationTypes: []string{"create_account", "payment"}}, true},
+		{"operation type not matched", history.TransactionFilterConfig{Enabled: true, OperationTypes: []string{"create_account"}}, false},
+		{"memo matched", history.TransactionFilterConfig{Enabled: true, Memos: []string{"123"}}, true},
+		{"memo not matched", history.TransactionFilterConfig{Enabled: true, Memos: []string{"1234"}}, false},
+		{"payment amount matched", history.TransactionFilterConfig{Enabled: true, MinPaymentAmount: 100}, true},
+		{"payment amount not matched", history.TransactionFilterConfig{Enabled: true, MinPaymentAmount: 101}, false},
+		{
+			"all rules matched",
+			history.TransactionFilterConfig{Enabled: true, OperationTypes: []string{"payment"}, Memos: []string{"123"}, MinPaymentAmount: 50},
+			true,
+		},
+		{
+			"one rule not matched",
+			history.TransactionFilterConfig{Enabled: true, OperationTypes: []string{"payment"}, Memos: []string{"123"}, MinPaymentAmount: 500},
+			false,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			filter := NewTransactionFilter()
+			config := testCase.config
+			config.LastModified = 1
+			require.NoError(t, filter.RefreshTransactionFilter(&config))
+
+			tx := getAssetTestV1Tx(t, "GD6WNNTW664WH7FXC5RUMUTF7P5QSURC2IT36VOQEEGFZ4UWUEQGECAL")
+			tx.Envelope.V1.Tx.Memo = xdr.MemoID(123)
+			result, err := filter.FilterTransaction(ctx, tx)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expected, result)
+		})
+	}
+}
+
+func TestMemoValue(t *testing.T) {
+	hash := xdr.Hash{1, 2, 3}
+	assert.Equal(t, "", memoValue(xdr.Memo{Type: xdr.MemoTypeMemoNone}))
+	assert.Equal(t, "hello", memoValue(xdr.MemoText("hello")))
+	assert.Equal(t, "18446744073709551615", memoValue(xdr.MemoID(18446744073709551615)))
+	assert.Equal(t, "AQIDAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=", memoValue(xdr.MemoHash(hash)))
+}
+
+func TestTransactionFilterRelaxed(t *testing.T) {
+	for _, testCase := range []struct {
+		name     string
+		previous history.TransactionFilterConfig
+		current  history.TransactionFilterConfig
+		relaxed  bool
+	}{
+		{
+			"previous disabled",
+			history.TransactionFilterConfig{OperationTypes: []string{"payment"}},
+			history.TransactionFilterConfig{Enabled: true},
+			false,
+		},
+		{
+			"current disabled",
+			history.TransactionFilterConfig{Enabled: true, OperationTypes: []string{"payment"}},
+			history.TransactionFilterConfig{OperationTypes: []string{"payment"}},
+			true,
+		},
+		{
+			"operation type added",
+			history.TransactionFilterConfig{Enabled: true, OperationTypes: []string{"payment"}},
+			history.TransactionFilterConfig{Enabled: true, OperationTypes: []string{"payment", "create_account"}},
+			true,
+		},
+		{
+			"operation type removed",
+			history.TransactionFilterConfig{Enabled: true, OperationTypes: []string{"payment", "create_account"}},
+			history.TransactionFilterConfig{Enabled: true, OperationTypes: []string{"payment"}},
+			false,
+		},
+		{
+			"memo rule added",
+			history.TransactionFilterConfig{Enabled: true, OperationTypes: []string{"payment"}},
+			history.TransactionFilterConfig{Enabled: true, OperationTypes: []string{"payment"}, Memos: []string{"1"}},
+			false,
+		},
+		{
+			"memo rule removed",
+			history.TransactionFilterConfig{Enabled: true, OperationTypes: []string{"payment"}, Memos: []string{"1"}},
+			history.TransactionFilterConfig{Enabled: true, OperationTypes: []string{"payment"}},
+			true,
+		},
+		{
+			"min payment amount lowered",
+			history.TransactionFilterConfig{Enabled: true, MinPaymentAmount: 100},
+			history.TransactionFilterConfig{Enabled: true, MinPaymentAmount: 10},
+			true,
+		},
+		{
+			"min payment amount raised",
+			history.TransactionFilterConfig{Enabled: true, MinPaymentAmount: 100},
+			history.TransactionFilterConfig{Enabled: true, MinPaymentAmount: 1000},
+			false,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.relaxed, TransactionFilterRelaxed(testCase.previous, testCase.current))
+		})
+	}
+}
@@ -20,6 +20,10 @@ var (
 	defaultSleep = time.Second
 )
 
+// reingestLedgerBatchSize is the number of ledgers fetched at once during
+// reingestion when the ledger backend supports batches.
+const reingestLedgerBatchSize = 64
+
 // ErrReingestRangeConflict indicates that the reingest range overlaps with
 // horizon's most recently ingested ledger
 type ErrReingestRangeConflict struct {
@@ -357,6 +361,7 @@ func (b buildState) run(s *system) (transition, error) {
 		"sequence": b.checkpointLedger,
 	}).Info("Processing state")
 	startTime := time.Now()
+	s.config.Progress.startBuildState(b.checkpointLedger)
 
 	var stats ingest.StatsChangeProcessorResults
 	if b.checkpointLedger == 1 {
@@ -381,6 +386,7 @@ func (b buildState) run(s *system) (transition, error) {
 	if err = s.completeIngestion(s.ctx, b.checkpointLedger); err != nil {
 		return nextFailState, err
 	}
+	s.config.Progress.finishBuildState()
 
 	log.
 		WithFields(stats.Map()).
@@ -719,7 +725,7 @@ func (reingestHistoryRangeState) GetState() State {
 	return ReingestHistoryRange
 }
 
-func (h reingestHistoryRangeState) ingestRange(s *system, fromLedger, toLedger uint32) error {
+func (h reingestHistoryRangeState) ingestRange(s *system, ledgers *reingestLedgerReader, fromLedger, toLedger uint32) error {
 	if s.historyQ.GetTx() == nil {
 		return errors.New("expected transaction to be present")
 	}
@@ -740,7 +746,7 @@ func (h reingestHistoryRangeState) ingestRange(s *system, fromLedger, toLedger u
 
 	for cur := fromLedger; cur <= toLedger; cur++ {
 		var ledgerCloseMeta xdr.LedgerCloseMeta
-		ledgerCloseMeta, err = s.ledgerBackend.GetLedger(s.ctx, cur)
+		ledgerCloseMeta, err = ledgers.getLedger(s.ctx, cur)
 		if err != nil {
 			return errors.Wrap(err, "error getting ledger")
 		}
@@ -753,6 +759,46 @@ func (h reingestHistoryRangeState) ingestRange(s *system, fromLedger, toLedger u
 	return nil
 }
 
+// reingestLedgerReader returns ledgers from the ledger backend. When the
+// backend supports fetching many ledgers in a single call it fetches
+// reingestLedgerBatchSize ledgers at once to avoid paying a round trip
+// for every ledger.
+type reingestLedgerReader struct {
+	backend    ledgerbackend.LedgerBackend
+	lastLedger uint32
+	batch      []xdr.LedgerCloseMeta
+}
+
+func newReingestLedgerReader(backend ledgerbackend.LedgerBackend, lastLedger uint32) *reingestLedgerReader {
+	return &reingestLedgerReader{backend: backend, lastLedger: lastLedger}
+}
+
+func (r *reingestLedgerReader) getLedger(ctx context.Context, sequence uint32) (xdr.LedgerCloseMeta, error) {
+	batchBackend, ok := r.backend.(ledgerbackend.BatchLedgerBackend)
+	if !ok {
+		return r.backend.GetLedger(ctx, sequence)
+	}
+
+	if len(r.batch) > 0 {
+		first := r.batch[0].LedgerSequence()
+		if sequence >= first && sequence-first < uint32(len(r.batch)) {
+			return r.batch[sequence-first], nil
+		}
+	}
+
+	to := r.lastLedger
+	if to < sequence || to-sequence >= reingestLedgerBatchSize {
+		to = sequence + reingestLedgerBatchSize - 1
+	}
+
+	batch, err := batchBackend.GetLedgers(ctx, sequence, to)
+	if err != nil {
+		return xdr.LedgerCloseMeta{}, err
+	}
+	r.batch = batch
+	return r.batch[0], nil
+}
+
 func (h reingestHistoryRangeState) prepareRange(s *system) (transition, error) {
 	log.WithFields(logpkg.F{
 		"from": h.fromLedger,
@@ -787,6 +833,7 @@ func (h reingestHistoryRangeState) run(s *system) (transition, error) {
 	}
 
 	var startTime time.Time
+	ledgers := newReingestLedgerReader(s.ledgerBackend, h.toLedger)
 
 	if h.force {
 		if t, err := h.prepareRange(s); err != nil {
@@ -804,13 +851,14 @@ func (h reingestHistoryRangeState) run(s *system) (transition, error) {
 			return stop(), errors.Wrap(err, getLastIngestedErrMsg)
 		}
 
-		if err := h.ingestRange(s, h.fromLedger, h.toLedger); err != nil {
+		if err := h.ingestRange(s, ledgers, h.fromLedger, h.toLedger); err != nil {
 			return stop(), err
 		}
 
 		if err := s.historyQ.Commit(); err != nil {
 			return stop(), errors.Wrap(err, commitErrMsg)
 		}
+		s.config.Progress.ledgersReingested(s.config.reingestWorker, h.toLedger)
 	} else {
 		lastIngestedLedger, err := s.historyQ.GetLastLedgerIngestNonBlocking(s.ctx)
 		if err != nil {
@@ -837,13 +885,14 @@ func (h reingestHistoryRangeState) run(s *system) (transition, error) {
 
 				// ingest each ledger in a separate transaction to prevent deadlocks
 				// when acquiring ShareLocks from multiple parallel reingest range processes
-				if e := h.ingestRange(s, ledger, ledger); e != nil {
+				if e := h.ingestRange(s, ledgers, ledger, ledger); e != nil {
 					return e
 				}
 
 				if e := s.historyQ.Commit(); e != nil {
 					return errors.Wrap(e, commitErrMsg)
 				}
+				s.config.Progress.ledgersReingested(s.config.reingestWorker, ledger)
 
 				return nil
 			}(cur)
@@ -10,78 +10,26 @@ import (
 	"github.com/stellar/go/support/errors"
 )
 
-type processorsRunDurations map[string]time.Duration
+type processorsRunDurations = ingest.ProcessorsRunDurations
 
-func (d processorsRunDurations) AddRunDuration(name string, startTime time.Time) {
-	d[name] += time.Since(startTime)
-}
-
-type groupChangeProcessors struct {
-	processors []horizonChangeProcessor
-	processorsRunDurations
-}
+type groupChangeProcessors = ingest.GroupChangeProcessors
 
 func newGroupChangeProcessors(processors []horizonChangeProcessor) *groupChangeProcessors {
-	return &groupChangeProcessors{
-		processors:             processors,
-		processorsRunDurations: make(map[string]time.Duration),
-	}
-}
-
-func (g groupChangeProcessors) ProcessChange(ctx context.Context, change ingest.Change) error {
-	for _, p := range g.processors {
-		startTime := time.Now()
-		if err := p.ProcessChange(ctx, change); err != nil {
-			return errors.Wrapf(err, "error in %T.ProcessChange", p)
-		}
-		g.AddRunDuration(fmt.Sprintf("%T", p), startTime)
-	}
-	return nil
-}
-
-func (g groupChangeProcessors) Commit(ctx context.Context) error {
-	for _, p := range g.processors {
-		startTime := time.Now()
-		if err := p.Commit(ctx); err != nil {
-			return errors.Wrapf(err, "error in %T.Commit", p)
-		}
-		g.AddRunDuration(fmt.Sprintf("%T", p), startTime)
+	changeProcessors := make([]ingest.ChangeProcessor, len(processors))
+	for i, p := range processors {
+		changeProcessors[i] = p
 	}
-	return nil
+	return ingest.NewGroupChangeProcessors(changeProcessors...)
 }
 
-type groupTransactionProcessors struct {
-	processors []horizonTransactionProcessor
-	processorsRunDurations
-}
+type groupTransactionProcessors = ingest.GroupTransactionProcessors
 
 func newGroupTransactionProcessors(processors []horizonTransactionProcessor) *groupTransactionProcessors {
-	return &groupTransactionProcessors{
-		processors:             processors,
-		processorsRunDurations: make(map[string]time.Duration),
-	}
-}
-
-func (g groupTransactionProcessors) ProcessTransaction(ctx context.Context, tx ingest.LedgerTransaction) error {
-	for _, p := range g.processors {
-		startTime := time.Now()
-		if err := p.ProcessTransaction(ctx, tx); err != nil {
-			return errors.Wrapf(err, "error in %T.ProcessTransaction", p)
-		}
-		g.AddRunDuration(fmt.Sprintf("%T", p), startTime)
-	}
-	return nil
-}
-
-func (g groupTransactionProcessors) Commit(ctx context.Context) error {
-	for _, p := range g.processors {
-		startTime := time.Now()
-		if err := p.Commit(ctx); err != nil {
-			return errors.Wrapf(err, "error in %T.Commit", p)
-		}
-		g.AddRunDuration(fmt.Sprintf("%T", p), startTime)
+	transactionProcessors := make([]ingest.LedgerTransactionProcessor, len(processors))
+	for i, p := range processors {
+		transactionProcessors[i] = p
 	}
-	return nil
+	return ingest.NewGroupTransactionProcessors(transactionProcessors...)
 }
 
 type groupTransactionFilterers struct {
@@ -26,6 +26,9 @@ type loggingChangeReader struct {
 	frequency int
 	source    string
 	sequence  uint32
+	// progress (optional) receives the progress of reading history archive
+	// checkpoints.
+	progress *ProgressTracker
 }
 
 func newloggingChangeReader(
@@ -51,6 +54,12 @@ func (lcr *loggingChangeReader) Read() (ingest.Change, error) {
 	if err == nil {
 		lcr.entryCount++
 
+		if lcr.progress != nil && lcr.entryCount%progressReportFrequency == 0 {
+			if reader, ok := lcr.ChangeReader.(*ingest.CheckpointChangeReader); ok {
+				lcr.progress.buildStateRead(uint64(lcr.entryCount), reader.Progress())
+			}
+		}
+
 		if lcr.entryCount%lcr.frequency == 0 {
 			logger := log.WithField("processed_entries", lcr.entryCount).
 				WithField("source", lcr.source).
@@ -81,10 +81,14 @@ type Config struct {
 	CaptiveCoreToml        *ledgerbackend.CaptiveCoreToml
 	CaptiveCoreConfigUseDB bool
 	RemoteCaptiveCoreURL   string
+	LedgerFilesURL         string
 	NetworkPassphrase      string
 
 	HistorySession     db.SessionInterface
 	HistoryArchiveURLs []string
+	// HistoryArchiveCache configures the (optional) on-disk cache of files
+	// downloaded from history archives.
+	HistoryArchiveCache historyarchive.CacheOptions
 
 	DisableStateVerification     bool
 	EnableReapLookupTables       bool
@@ -93,6 +97,10 @@ type Config struct {
 	ReingestEnabled             bool
 	MaxReingestRetries          int
 	ReingestRetryBackoffSeconds int
+	// ResumeReingestion makes ParallelSystems store its jobs in the
+	// reingest_jobs table so a rerun skips the ledgers of completed jobs and
+	// many processes can share the work.
+	ResumeReingestion bool
 
 	// The checkpoint frequency will be 64 unless you are using an exotic test setup.
 	CheckpointFrequency                  uint32
@@ -102,6 +110,17 @@ type Config struct {
 	RoundingSlippageFilter int
 
 	EnableIngestionFiltering bool
+
+	// CaptiveCoreRegistry is an (optional) prometheus registry in which the
+	// metrics of a local Captive Core instance are registered.
+	CaptiveCoreRegistry *prometheus.Registry
+
+	// Progress (optional) collects the progress of reingestion and state
+	// builds. It can be shared by many systems.
+	Progress *ProgressTracker
+	// reingestWorker identifies the ParallelSystems worker using the system
+	// when reporting progress.
+	reingestWorker int
 }
 
 // LocalCaptiveCoreEnabled returns true if configured to run
@@ -110,13 +129,20 @@ func (c Config) LocalCaptiveCoreEnabled() bool {
 	// c.EnableCaptiveCore is true for both local and remote captive core
 	// and c.RemoteCaptiveCoreURL is always empty when running
 	// local captive core.
-	return c.EnableCaptiveCore && c.RemoteCaptiveCoreURL == ""
+	return c.EnableCaptiveCore && c.RemoteCaptiveCoreURL == "" && !c.LedgerFilesEnabled()
 }
 
 // RemoteCaptiveCoreEnabled returns true if configured to run
 // a remote captive core instance for ingestion.
 func (c Config) RemoteCaptiveCoreEnabled() bool {
-	return c.EnableCaptiveCore && c.RemoteCaptiveCoreURL != ""
+	return c.EnableCaptiveCore && c.RemoteCaptiveCoreURL != "" && !c.LedgerFilesEnabled()
+}
+
+// LedgerFilesEnabled returns true if configured to read ledgers from
+// files exported by ledgerbackend.LedgerFileWriter. It takes precedence
+// over both local and remote captive core.
+func (c Config) LedgerFilesEnabled() bool {
+	return c.LedgerFilesURL != ""
 }
 
 const (
@@ -211,6 +237,7 @@ type system struct {
 
 	ledgerBackend  ledgerbackend.LedgerBackend
 	historyAdapter historyArchiveAdapterInterface
+	archivePool    *historyarchive.FailoverArchivePool
 
 	stellarCoreClient stellarCoreClient
 
@@ -236,14 +263,16 @@ type system struct {
 func NewSystem(config Config) (System, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 
-	archive, err := historyarchive.NewArchivePool(
+	archive, err := historyarchive.NewFailoverArchivePool(
 		config.HistoryArchiveURLs,
 		historyarchive.ConnectOptions{
 			Context:             ctx,
 			NetworkPassphrase:   config.NetworkPassphrase,
 			CheckpointFrequency: config.CheckpointFrequency,
 			UserAgent:           fmt.Sprintf("horizon/%s golang/%s", apkg.Version(), runtime.Version()),
+			CacheConfig:         config.HistoryArchiveCache,
 		},
+		historyarchive.FailoverArchivePoolOptions{MetricsNamespace: "horizon"},
 	)
 	if err != nil {
 		cancel()
@@ -251,7 +280,19 @@ func NewSystem(config Config) (System, error) {
 	}
 
 	var ledgerBackend ledgerbackend.LedgerBackend
-	if config.RemoteCaptiveCoreEnabled() {
+	if config.LedgerFilesEnabled() {
+		ledgerBackend, err = ledgerbackend.NewFileBackend(
+			config.LedgerFilesURL,
+			historyarchive.ConnectOptions{
+				Context:   ctx,
+				UserAgent: fmt.Sprintf("horizon/%s golang/%s", apkg.Version(), runtime.Version()),
+			},
+		)
+		if err != nil {
+			cancel()
+			return nil, errors.Wrap(err, "error creating ledger files backend")
+		}
+	} else if config.RemoteCaptiveCoreEnabled() {
 		ledgerBackend, err = ledgerbackend.NewRemoteCaptive(config.RemoteCaptiveCoreURL)
 		if err != nil {
 			cancel()
@@ -272,6 +313,8 @@ func NewSystem(config Config) (System, error) {
 				Log:                 logger,
 				Context:             ctx,
 				UserAgent:           fmt.Sprintf("captivecore horizon/%s golang/%s", apkg.Version(), runtime.Version()),
+				Registry:            config.CaptiveCoreRegistry,
+				RegistryNamespace:   "horizon",
 			},
 		)
 		if err != nil {
@@ -292,6 +335,7 @@ func NewSystem(config Config) (System, error) {
 	filters := filters.NewFilters()
 
 	system := &system{
+		archivePool:                 archive,
 		cancel:                      cancel,
 		config:                      config,
 		ctx:                         ctx,
@@ -507,6 +551,9 @@ func (s *system) RegisterMetrics(registry *prometheus.Registry) {
 	registry.MustRegister(s.metrics.CaptiveCoreSupportedProtocolVersion)
 	registry.MustRegister(s.metrics.LedgerFetchDurationSummary)
 	registry.MustRegister(s.metrics.StateVerifyLedgerEntriesCount)
+	if s.archivePool != nil {
+		s.archivePool.RegisterMetrics(registry)
+	}
 }
 
 // Run starts ingestion system. Ingestion system supports distributed ingestion
@@ -604,8 +651,12 @@ func (s *system) ReingestRange(ledgerRanges []history.LedgerRange, force bool) e
 	if err := validateRanges(ledgerRanges); err != nil {
 		return err
 	}
+	if s.config.Progress.startReingest(ledgerRanges) {
+		defer s.config.Progress.finishReingest()
+	}
 	for _, cur := range ledgerRanges {
 		run := func() error {
+			s.config.Progress.startReingestRange(s.config.reingestWorker, cur)
 			return s.runStateMachine(reingestHistoryRangeState{
 				fromLedger: cur.StartSequence,
 				toLedger:   cur.EndSequence,
@@ -621,6 +672,7 @@ func (s *system) ReingestRange(ledgerRanges []history.LedgerRange, force bool) e
 		if err != nil {
 			return err
 		}
+		s.config.Progress.finishReingestRange(s.config.reingestWorker)
 	}
 	return nil
 }
@@ -810,7 +862,7 @@ func (s *system) resetStateVerificationErrors() {
 }
 
 func (s *system) updateCursor(ledgerSequence uint32) error {
-	if s.stellarCoreClient == nil || s.config.EnableCaptiveCore {
+	if s.stellarCoreClient == nil || s.config.EnableCaptiveCore || s.config.LedgerFilesEnabled() {
 		return nil
 	}
 
@@ -27,11 +27,24 @@ type ParallelSystems struct {
 	config        Config
 	workerCount   uint
 	systemFactory func(Config) (System, error)
+	// jobsQ (optional) persists the reingest jobs so a rerun skips completed
+	// ranges and many processes can share the work.
+	jobsQ reingestJobsQ
 }
 
 func NewParallelSystems(config Config, workerCount uint) (*ParallelSystems, error) {
 	// Leaving this because used in tests, will update after a code review.
-	return newParallelSystems(config, workerCount, NewSystem)
+	ps, err := newParallelSystems(config, workerCount, NewSystem)
+	if err != nil {
+		return nil, err
+	}
+	if config.ResumeReingestion {
+		if config.HistorySession == nil {
+			return nil, errors.New("resuming reingestion requires a history session")
+		}
+		ps.jobsQ = &history.Q{config.HistorySession.Clone()}
+	}
+	return ps, nil
 }
 
 // private version of NewParallel systems, allowing to inject a mock system
@@ -57,41 +70,53 @@ func (ps *ParallelSystems) Shutdown() {
 	}
 }
 
-func (ps *ParallelSystems) runReingestWorker(s System, stop <-chan struct{}, reingestJobQueue <-chan history.LedgerRange) rangeError {
-
+// runReingestWorker reingests ranges claimed from the jobs queue until the
+// queue is empty or stop is closed. Errors reingesting a range are returned
+// as rangeError.
+func (ps *ParallelSystems) runReingestWorker(s System, stop <-chan struct{}, jobs reingestJobQueue, worker string) error {
 	for {
 		select {
 		case <-stop:
-			return rangeError{}
-		case reingestRange := <-reingestJobQueue:
-			err := s.ReingestRange([]history.LedgerRange{reingestRange}, false)
-			if err != nil {
-				return rangeError{
-					err:         err,
-					ledgerRange: reingestRange,
-				}
+			return nil
+		default:
+		}
+
+		reingestRange, ok, err := jobs.claim(worker)
+		if err != nil {
+			return errors.Wrap(err, "error claiming reingest job")
+		}
+		if !ok {
+			return nil
+		}
+
+		err = s.ReingestRange([]history.LedgerRange{reingestRange}, false)
+		if releaseErr := jobs.release(worker, err); releaseErr != nil && err == nil {
+			err = errors.Wrap(releaseErr, "error updating reingest job")
+		}
+		if err != nil {
+			return rangeError{
+				err:         err,
+				ledgerRange: reingestRange,
 			}
-			log.WithFields(logpkg.F{"from": reingestRange.StartSequence, "to": reingestRange.EndSequence}).Info("successfully reingested range")
 		}
+		log.WithFields(logpkg.F{"from": reingestRange.StartSequence, "to": reingestRange.EndSequence}).Info("successfully reingested range")
 	}
 }
 
-func enqueueReingestTasks(ledgerRanges []history.LedgerRange, batchSize uint32, stop <-chan struct{}, reingestJobQueue chan<- history.LedgerRange) {
+// splitLedgerRanges splits the ledger ranges into batches of batchSize ledgers.
+func splitLedgerRanges(ledgerRanges []history.LedgerRange, batchSize uint32) []history.LedgerRange {
+	var batches []history.LedgerRange
 	for _, cur := range ledgerRanges {
-		for subRangeFrom := cur.StartSequence; subRangeFrom < cur.EndSequence; {
-			// job queuing
+		for subRangeFrom := cur.StartSequence; subRangeFrom <= cur.EndSequence; {
 			subRangeTo := subRangeFrom + (batchSize - 1) // we subtract one because both from and to are part of the batch
 			if subRangeTo > cur.EndSequence {
 				subRangeTo = cur.EndSequence
 			}
-			select {
-			case <-stop:
-				return
-			case reingestJobQueue <- history.LedgerRange{StartSequence: subRangeFrom, EndSequence: subRangeTo}:
-			}
+			batches = append(batches, history.LedgerRange{StartSequence: subRangeFrom, EndSequence: subRangeTo})
 			subRangeFrom = subRangeTo + 1
 		}
 	}
+	return batches
 }
 
 func calculateParallelLedgerBatchSize(rangeSize uint32, batchSizeSuggestion uint32, workerCount uint) uint32 {
@@ -119,9 +144,9 @@ func totalRangeSize(ledgerRanges []history.LedgerRange) uint32 {
 
 func (ps *ParallelSystems) ReingestRange(ledgerRanges []history.LedgerRange, batchSizeSuggestion uint32) error {
 	var (
-		batchSize        = calculateParallelLedgerBatchSize(totalRangeSize(ledgerRanges), batchSizeSuggestion, ps.workerCount)
-		reingestJobQueue = make(chan history.LedgerRange)
-		wg               sync.WaitGroup
+		batchSize = calculateParallelLedgerBatchSize(totalRangeSize(ledgerRanges), batchSizeSuggestion, ps.workerCount)
+		jobs      reingestJobQueue
+		wg        sync.WaitGroup
 
 		// stopOnce is used to close the stop channel once: closing a closed channel panics and it can happen in case
 		// of errors in multiple ranges.
@@ -131,12 +156,13 @@ func (ps *ParallelSystems) ReingestRange(ledgerRanges []history.LedgerRange, bat
 		lowestRangeErrMutex sync.Mutex
 		// lowestRangeErr is an error of the failed range with the lowest starting ledger sequence that is used to tell
 		// the user which range to reingest in case of errors. We use that fact that System.ReingestRange is blocking,
-		// jobs are sent to a queue (unbuffered channel) in sequence and there is a WaitGroup waiting for all the workers
-		// to exit.
-		// Because of this when we reach `wg.Wait()` all jobs previously sent to a channel are processed (either success
+		// jobs are claimed from a queue in sequence and there is a WaitGroup waiting for all the workers to exit.
+		// Because of this when we reach `wg.Wait()` all jobs previously claimed are processed (either success
 		// or failure). In case of a failure we save the range with the smallest sequence number because this is where
 		// the user needs to start again to prevent the gaps.
 		lowestRangeErr *rangeError
+		// jobsErr is an error accessing the jobs queue.
+		jobsErr error
 	)
 
 	defer ps.Shutdown()
@@ -144,20 +170,41 @@ func (ps *ParallelSystems) ReingestRange(ledgerRanges []history.LedgerRange, bat
 	if err := validateRanges(ledgerRanges); err != nil {
 		return err
 	}
+	if ps.config.Progress.startReingest(ledgerRanges) {
+		defer ps.config.Progress.finishReingest()
+	}
+
+	if ps.jobsQ != nil {
+		dbJobs, err := newDBReingestJobQueue(ps.jobsQ, ledgerRanges, batchSize)
+		if err != nil {
+			return errors.Wrap(err, "error creating reingest jobs")
+		}
+		defer dbJobs.close()
+		ps.config.Progress.ledgersSkipped(dbJobs.completedLedgers)
+		jobs = dbJobs
+	} else {
+		jobs = newMemoryReingestJobQueue(splitLedgerRanges(ledgerRanges, batchSize))
+	}
+	workerPrefix := reingestWorkerPrefix()
 
 	for i := uint(0); i < ps.workerCount; i++ {
 		wg.Add(1)
-		s, err := ps.systemFactory(ps.config)
+		workerConfig := ps.config
+		workerConfig.reingestWorker = int(i)
+		s, err := ps.systemFactory(workerConfig)
 		if err != nil {
 			return errors.Wrap(err, "error creating new system")
 		}
+		worker := fmt.Sprintf("%s-%d", workerPrefix, i)
 		go func() {
 			defer wg.Done()
-			rangeErr := ps.runReingestWorker(s, stop, reingestJobQueue)
-			if rangeErr.err != nil {
-				log.WithError(rangeErr).Error("error in reingest worker")
+			err := ps.runReingestWorker(s, stop, jobs, worker)
+			if err != nil {
+				log.WithError(err).Error("error in reingest worker")
 				lowestRangeErrMutex.Lock()
-				if lowestRangeErr == nil || lowestRangeErr.ledgerRange.StartSequence > rangeErr.ledgerRange.StartSequence {
+				if rangeErr, ok := err.(rangeError); !ok {
+					jobsErr = err
+				} else if lowestRangeErr == nil || lowestRangeErr.ledgerRange.StartSequence > rangeErr.ledgerRange.StartSequence {
 					lowestRangeErr = &rangeErr
 				}
 				lowestRangeErrMutex.Unlock()
@@ -169,17 +216,11 @@ func (ps *ParallelSystems) ReingestRange(ledgerRanges []history.LedgerRange, bat
 		}()
 	}
 
-	enqueueReingestTasks(ledgerRanges, batchSize, stop, reingestJobQueue)
-
-	stopOnce.Do(func() {
-		close(stop)
-	})
 	wg.Wait()
-	close(reingestJobQueue)
 
 	if lowestRangeErr != nil {
 		lastLedger := ledgerRanges[len(ledgerRanges)-1].EndSequence
 		return errors.Wrapf(lowestRangeErr, "job failed, recommended restart range: [%d, %d]", lowestRangeErr.ledgerRange.StartSequence, lastLedger)
 	}
-	return nil
+	return jobsErr
 }
@@ -250,13 +250,15 @@ func (s *ProcessorRunner) RunHistoryArchiveIngestion(
 		log.WithField("sequence", checkpointLedger).
 			Info("Processing entries from History Archive Snapshot")
 
-		err = processors.StreamChanges(s.ctx, changeProcessor, newloggingChangeReader(
+		loggingReader := newloggingChangeReader(
 			changeReader,
 			"historyArchive",
 			checkpointLedger,
 			logFrequency,
 			s.logMemoryStats,
-		))
+		)
+		loggingReader.progress = s.config.Progress
+		err = processors.StreamChanges(s.ctx, changeProcessor, loggingReader)
 		if err != nil {
 			return changeStats.GetResults(), errors.Wrap(err, "Error streaming changes from HAS")
 		}
@@ -354,8 +356,8 @@ func (s *ProcessorRunner) RunTransactionProcessorsOnLedger(ledger xdr.LedgerClos
 
 	transactionStats = ledgerTransactionStats.GetResults()
 	transactionStats.TransactionsFiltered = groupTransactionFilterers.droppedTransactions
-	transactionDurations = groupTransactionProcessors.processorsRunDurations
-	for key, duration := range groupFilteredOutProcessors.processorsRunDurations {
+	transactionDurations = groupTransactionProcessors.RunDurations()
+	for key, duration := range groupFilteredOutProcessors.RunDurations() {
 		transactionDurations[key] = duration
 	}
 	for key, duration := range groupTransactionFilterers.processorsRunDurations {
@@ -384,7 +386,7 @@ func (s *ProcessorRunner) RunAllProcessorsOnLedger(ledger xdr.LedgerCloseMeta) (
 	}
 
 	stats.changeStats = changeStatsProcessor.GetResults()
-	stats.changeDurations = groupChangeProcessors.processorsRunDurations
+	stats.changeDurations = groupChangeProcessors.RunDurations()
 
 	stats.transactionStats, stats.transactionDurations, stats.tradeStats, err =
 		s.RunTransactionProcessorsOnLedger(ledger)
@@ -8,13 +8,9 @@ import (
 	"github.com/stellar/go/support/errors"
 )
 
-type ChangeProcessor interface {
-	ProcessChange(ctx context.Context, change ingest.Change) error
-}
+type ChangeProcessor = ingest.ChangeProcessor
 
-type LedgerTransactionProcessor interface {
-	ProcessTransaction(ctx context.Context, transaction ingest.LedgerTransaction) error
-}
+type LedgerTransactionProcessor = ingest.LedgerTransactionProcessor
 
 type LedgerTransactionFilterer interface {
 	FilterTransaction(ctx context.Context, transaction ingest.LedgerTransaction) (bool, error)
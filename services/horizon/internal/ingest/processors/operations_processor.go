@@ -120,68 +120,17 @@ func (operation *transactionOperationWrapper) OperationType() xdr.OperationType
 	return operation.operation.Body.Type
 }
 
-func (operation *transactionOperationWrapper) getSignerSponsorInChange(signerKey string, change ingest.Change) xdr.SponsorshipDescriptor {
-	if change.Type != xdr.LedgerEntryTypeAccount || change.Post == nil {
-		return nil
-	}
-
-	preSigners := map[string]xdr.AccountId{}
-	if change.Pre != nil {
-		account := change.Pre.Data.MustAccount()
-		preSigners = account.SponsorPerSigner()
-	}
-
-	account := change.Post.Data.MustAccount()
-	postSigners := account.SponsorPerSigner()
-
-	pre, preFound := preSigners[signerKey]
-	post, postFound := postSigners[signerKey]
-
-	if !postFound {
-		return nil
-	}
-
-	if preFound {
-		formerSponsor := pre.Address()
-		newSponsor := post.Address()
-		if formerSponsor == newSponsor {
-			return nil
-		}
+// ledgerOperation returns the operation as an ingest.LedgerOperation.
+func (operation *transactionOperationWrapper) ledgerOperation() ingest.LedgerOperation {
+	return ingest.LedgerOperation{
+		Index:       operation.index,
+		Transaction: &operation.transaction,
+		Operation:   operation.operation,
 	}
-
-	return &post
 }
 
 func (operation *transactionOperationWrapper) getSponsor() (*xdr.AccountId, error) {
-	changes, err := operation.transaction.GetOperationChanges(operation.index)
-	if err != nil {
-		return nil, err
-	}
-	var signerKey string
-	if setOps, ok := operation.operation.Body.GetSetOptionsOp(); ok && setOps.Signer != nil {
-		signerKey = setOps.Signer.Key.Address()
-	}
-
-	for _, c := range changes {
-		// Check Signer changes
-		if signerKey != "" {
-			if sponsorAccount := operation.getSignerSponsorInChange(signerKey, c); sponsorAccount != nil {
-				return sponsorAccount, nil
-			}
-		}
-
-		// Check Ledger key changes
-		if c.Pre != nil || c.Post == nil {
-			// We are only looking for entry creations denoting that a sponsor
-			// is associated to the ledger entry of the operation.
-			continue
-		}
-		if sponsorAccount := c.Post.SponsoringID(); sponsorAccount != nil {
-			return sponsorAccount, nil
-		}
-	}
-
-	return nil, nil
+	return operation.ledgerOperation().Sponsor()
 }
 
 type liquidityPoolDelta struct {
@@ -722,119 +671,9 @@ func addLedgerKeyDetails(result map[string]interface{}, ledgerKey xdr.LedgerKey)
 	return nil
 }
 
-func getLedgerKeyParticipants(ledgerKey xdr.LedgerKey) []xdr.AccountId {
-	var result []xdr.AccountId
-	switch ledgerKey.Type {
-	case xdr.LedgerEntryTypeAccount:
-		result = append(result, ledgerKey.Account.AccountId)
-	case xdr.LedgerEntryTypeClaimableBalance:
-		// nothing to do
-	case xdr.LedgerEntryTypeData:
-		result = append(result, ledgerKey.Data.AccountId)
-	case xdr.LedgerEntryTypeOffer:
-		result = append(result, ledgerKey.Offer.SellerId)
-	case xdr.LedgerEntryTypeTrustline:
-		result = append(result, ledgerKey.TrustLine.AccountId)
-	}
-	return result
-}
-
 // Participants returns the accounts taking part in the operation.
 func (operation *transactionOperationWrapper) Participants() ([]xdr.AccountId, error) {
-	participants := []xdr.AccountId{}
-	participants = append(participants, operation.SourceAccount().ToAccountId())
-	op := operation.operation
-
-	switch operation.OperationType() {
-	case xdr.OperationTypeCreateAccount:
-		participants = append(participants, op.Body.MustCreateAccountOp().Destination)
-	case xdr.OperationTypePayment:
-		participants = append(participants, op.Body.MustPaymentOp().Destination.ToAccountId())
-	case xdr.OperationTypePathPaymentStrictReceive:
-		participants = append(participants, op.Body.MustPathPaymentStrictReceiveOp().Destination.ToAccountId())
-	case xdr.OperationTypePathPaymentStrictSend:
-		participants = append(participants, op.Body.MustPathPaymentStrictSendOp().Destination.ToAccountId())
-	case xdr.OperationTypeManageBuyOffer:
-		// the only direct participant is the source_account
-	case xdr.OperationTypeManageSellOffer:
-		// the only direct participant is the source_account
-	case xdr.OperationTypeCreatePassiveSellOffer:
-		// the only direct participant is the source_account
-	case xdr.OperationTypeSetOptions:
-		// the only direct participant is the source_account
-	case xdr.OperationTypeChangeTrust:
-		// the only direct participant is the source_account
-	case xdr.OperationTypeAllowTrust:
-		participants = append(participants, op.Body.MustAllowTrustOp().Trustor)
-	case xdr.OperationTypeAccountMerge:
-		participants = append(participants, op.Body.MustDestination().ToAccountId())
-	case xdr.OperationTypeInflation:
-		// the only direct participant is the source_account
-	case xdr.OperationTypeManageData:
-		// the only direct participant is the source_account
-	case xdr.OperationTypeBumpSequence:
-		// the only direct participant is the source_account
-	case xdr.OperationTypeCreateClaimableBalance:
-		for _, c := range op.Body.MustCreateClaimableBalanceOp().Claimants {
-			participants = append(participants, c.MustV0().Destination)
-		}
-	case xdr.OperationTypeClaimClaimableBalance:
-		// the only direct participant is the source_account
-	case xdr.OperationTypeBeginSponsoringFutureReserves:
-		participants = append(participants, op.Body.MustBeginSponsoringFutureReservesOp().SponsoredId)
-	case xdr.OperationTypeEndSponsoringFutureReserves:
-		beginSponsorshipOp := operation.findInitatingBeginSponsoringOp()
-		if beginSponsorshipOp != nil {
-			participants = append(participants, beginSponsorshipOp.SourceAccount().ToAccountId())
-		}
-	case xdr.OperationTypeRevokeSponsorship:
-		op := operation.operation.Body.MustRevokeSponsorshipOp()
-		switch op.Type {
-		case xdr.RevokeSponsorshipTypeRevokeSponsorshipLedgerEntry:
-			participants = append(participants, getLedgerKeyParticipants(*op.LedgerKey)...)
-		case xdr.RevokeSponsorshipTypeRevokeSponsorshipSigner:
-			participants = append(participants, op.Signer.AccountId)
-			// We don't add signer as a participant because a signer can be arbitrary account.
-			// This can spam successful operations history of any account.
-		}
-	case xdr.OperationTypeClawback:
-		op := operation.operation.Body.MustClawbackOp()
-		participants = append(participants, op.From.ToAccountId())
-	case xdr.OperationTypeClawbackClaimableBalance:
-		// the only direct participant is the source_account
-	case xdr.OperationTypeSetTrustLineFlags:
-		op := operation.operation.Body.MustSetTrustLineFlagsOp()
-		participants = append(participants, op.Trustor)
-	case xdr.OperationTypeLiquidityPoolDeposit:
-		// the only direct participant is the source_account
-	case xdr.OperationTypeLiquidityPoolWithdraw:
-		// the only direct participant is the source_account
-	default:
-		return participants, fmt.Errorf("Unknown operation type: %s", op.Body.Type)
-	}
-
-	sponsor, err := operation.getSponsor()
-	if err != nil {
-		return nil, err
-	}
-	if sponsor != nil {
-		participants = append(participants, *sponsor)
-	}
-
-	return dedupeParticipants(participants), nil
-}
-
-// dedupeParticipants remove any duplicate ids from `in`
-func dedupeParticipants(in []xdr.AccountId) (out []xdr.AccountId) {
-	set := map[string]xdr.AccountId{}
-	for _, id := range in {
-		set[id.Address()] = id
-	}
-
-	for _, id := range set {
-		out = append(out, id)
-	}
-	return
+	return operation.ledgerOperation().Participants()
 }
 
 // OperationsParticipants returns a map with all participants per operation
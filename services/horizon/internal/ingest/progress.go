@@ -0,0 +1,403 @@
+package ingest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	protocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/services/horizon/internal/db2/history"
+)
+
+// progressReportFrequency is the number of ledger entries read from a
+// history archive checkpoint between progress updates.
+const progressReportFrequency = 1000
+
+type reingestWorkerProgress struct {
+	ledgerRange history.LedgerRange
+	nextLedger  uint32
+	startedAt   time.Time
+}
+
+type reingestProgress struct {
+	fromLedger, toLedger uint32
+	totalLedgers         uint32
+	// completedLedgers is the number of ledgers in ranges completed by workers.
+	completedLedgers uint32
+	workers          map[int]*reingestWorkerProgress
+	startedAt        time.Time
+	finishedAt       *time.Time
+}
+
+type buildStateProgress struct {
+	checkpointLedger uint32
+	entriesProcessed uint64
+	progressPercent  float64
+	startedAt        time.Time
+	finishedAt       *time.Time
+}
+
+// ProgressTracker collects the progress of reingesting ledger ranges (by a
+// System or ParallelSystems) and of building the state from a history
+// archive checkpoint, and reports it as JSON and Prometheus metrics. It is
+// safe for concurrent use and can be shared by many systems using Config.
+// Methods of a nil ProgressTracker are no-ops.
+type ProgressTracker struct {
+	mutex      sync.Mutex
+	now        func() time.Time
+	reingest   *reingestProgress
+	buildState *buildStateProgress
+}
+
+// NewProgressTracker returns a new ProgressTracker.
+func NewProgressTracker() *ProgressTracker {
+	return &ProgressTracker{now: time.Now}
+}
+
+// startReingest starts tracking the reingestion of the ledger ranges. It
+// returns false, without changing the progress, if a reingestion is already
+// tracked (ex. when called by a ParallelSystems worker). Only callers
+// receiving true should call finishReingest.
+func (p *ProgressTracker) startReingest(ledgerRanges []history.LedgerRange) bool {
+	if p == nil || len(ledgerRanges) == 0 {
+		return false
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.reingest != nil && p.reingest.finishedAt == nil {
+		return false
+	}
+
+	progress := &reingestProgress{
+		fromLedger:   ledgerRanges[0].StartSequence,
+		toLedger:     ledgerRanges[0].EndSequence,
+		totalLedgers: totalRangeSize(ledgerRanges),
+		workers:      map[int]*reingestWorkerProgress{},
+		startedAt:    p.now(),
+	}
+	for _, ledgerRange := range ledgerRanges {
+		if ledgerRange.StartSequence < progress.fromLedger {
+			progress.fromLedger = ledgerRange.StartSequence
+		}
+		if ledgerRange.EndSequence > progress.toLedger {
+			progress.toLedger = ledgerRange.EndSequence
+		}
+	}
+	p.reingest = progress
+	return true
+}
+
+func (p *ProgressTracker) finishReingest() {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.reingest != nil {
+		now := p.now()
+		p.reingest.finishedAt = &now
+		p.reingest.workers = map[int]*reingestWorkerProgress{}
+	}
+}
+
+// startReingestRange is called when a worker starts (or retries) reingesting
+// a range. Ledgers committed by a previous attempt are not counted again.
+func (p *ProgressTracker) startReingestRange(worker int, ledgerRange history.LedgerRange) {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.reingest == nil || p.reingest.finishedAt != nil {
+		return
+	}
+	p.reingest.workers[worker] = &reingestWorkerProgress{
+		ledgerRange: ledgerRange,
+		nextLedger:  ledgerRange.StartSequence,
+		startedAt:   p.now(),
+	}
+}
+
+// ledgersReingested is called when a worker commits ledgers up to (and
+// including) the given sequence.
+func (p *ProgressTracker) ledgersReingested(worker int, sequence uint32) {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.reingest == nil {
+		return
+	}
+	if workerProgress, ok := p.reingest.workers[worker]; ok {
+		workerProgress.nextLedger = sequence + 1
+	}
+}
+
+// ledgersSkipped is called with the number of ledgers which don't need to be
+// reingested because they were reingested by a previous run.
+func (p *ProgressTracker) ledgersSkipped(ledgers uint32) {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.reingest != nil && p.reingest.finishedAt == nil {
+		p.reingest.completedLedgers += ledgers
+	}
+}
+
+// finishReingestRange is called when a worker successfully reingests a range.
+func (p *ProgressTracker) finishReingestRange(worker int) {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.reingest == nil {
+		return
+	}
+	if workerProgress, ok := p.reingest.workers[worker]; ok {
+		p.reingest.completedLedgers += workerProgress.ledgerRange.EndSequence - workerProgress.ledgerRange.StartSequence + 1
+		delete(p.reingest.workers, worker)
+	}
+}
+
+func (p *ProgressTracker) startBuildState(checkpointLedger uint32) {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.buildState = &buildStateProgress{
+		checkpointLedger: checkpointLedger,
+		startedAt:        p.now(),
+	}
+}
+
+// buildStateRead is called with the number of ledger entries read from the
+// checkpoint and the percentage of the checkpoint buckets read.
+func (p *ProgressTracker) buildStateRead(entries uint64, progressPercent float64) {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.buildState == nil || p.buildState.finishedAt != nil {
+		return
+	}
+	p.buildState.entriesProcessed = entries
+	p.buildState.progressPercent = progressPercent
+}
+
+func (p *ProgressTracker) finishBuildState() {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.buildState != nil && p.buildState.finishedAt == nil {
+		now := p.now()
+		p.buildState.finishedAt = &now
+		p.buildState.progressPercent = 100
+	}
+}
+
+// IngestionProgress returns the progress of the current (or last) reingestion
+// and state build.
+func (p *ProgressTracker) IngestionProgress() protocol.IngestionProgress {
+	var result protocol.IngestionProgress
+	if p == nil {
+		return result
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.reingest != nil {
+		result.Reingest = p.reingestProgress()
+	}
+	if p.buildState != nil {
+		result.BuildState = p.buildStateProgress()
+	}
+	return result
+}
+
+func (p *ProgressTracker) reingestProgress() *protocol.ReingestProgress {
+	progress := &protocol.ReingestProgress{
+		FromLedger:   p.reingest.fromLedger,
+		ToLedger:     p.reingest.toLedger,
+		TotalLedgers: p.reingest.totalLedgers,
+		LedgersDone:  p.reingest.completedLedgers,
+		Workers:      []protocol.ReingestWorkerProgress{},
+		StartedAt:    p.reingest.startedAt,
+		FinishedAt:   p.reingest.finishedAt,
+	}
+	for worker, workerProgress := range p.reingest.workers {
+		progress.LedgersDone += workerProgress.nextLedger - workerProgress.ledgerRange.StartSequence
+		progress.Workers = append(progress.Workers, protocol.ReingestWorkerProgress{
+			Worker:     worker,
+			FromLedger: workerProgress.ledgerRange.StartSequence,
+			ToLedger:   workerProgress.ledgerRange.EndSequence,
+			NextLedger: workerProgress.nextLedger,
+			StartedAt:  workerProgress.startedAt,
+		})
+	}
+	sort.Slice(progress.Workers, func(i, j int) bool {
+		return progress.Workers[i].Worker < progress.Workers[j].Worker
+	})
+
+	if progress.TotalLedgers > 0 {
+		progress.ProgressPercent = 100 * float64(progress.LedgersDone) / float64(progress.TotalLedgers)
+	}
+
+	end := p.now()
+	if p.reingest.finishedAt != nil {
+		end = *p.reingest.finishedAt
+	}
+	if elapsed := end.Sub(p.reingest.startedAt).Seconds(); elapsed > 0 {
+		progress.LedgersPerSecond = float64(progress.LedgersDone) / elapsed
+	}
+	if p.reingest.finishedAt == nil && progress.LedgersPerSecond > 0 {
+		eta := float64(progress.TotalLedgers-progress.LedgersDone) / progress.LedgersPerSecond
+		progress.ETASeconds = &eta
+	}
+	return progress
+}
+
+func (p *ProgressTracker) buildStateProgress() *protocol.BuildStateProgress {
+	progress := &protocol.BuildStateProgress{
+		CheckpointLedger: p.buildState.checkpointLedger,
+		EntriesProcessed: p.buildState.entriesProcessed,
+		ProgressPercent:  p.buildState.progressPercent,
+		StartedAt:        p.buildState.startedAt,
+		FinishedAt:       p.buildState.finishedAt,
+	}
+
+	end := p.now()
+	if p.buildState.finishedAt != nil {
+		end = *p.buildState.finishedAt
+	}
+	elapsed := end.Sub(p.buildState.startedAt).Seconds()
+	if elapsed > 0 {
+		progress.EntriesPerSecond = float64(progress.EntriesProcessed) / elapsed
+	}
+	if p.buildState.finishedAt == nil && progress.ProgressPercent > 0 {
+		eta := elapsed * (100 - progress.ProgressPercent) / progress.ProgressPercent
+		progress.ETASeconds = &eta
+	}
+	return progress
+}
+
+// RegisterMetrics registers the progress metrics in the provided registry.
+func (p *ProgressTracker) RegisterMetrics(registry *prometheus.Registry) {
+	reingest := func(value func(*protocol.ReingestProgress) float64) func() float64 {
+		return func() float64 {
+			progress := p.IngestionProgress().Reingest
+			if progress == nil {
+				return 0
+			}
+			return value(progress)
+		}
+	}
+	buildState := func(value func(*protocol.BuildStateProgress) float64) func() float64 {
+		return func() float64 {
+			progress := p.IngestionProgress().BuildState
+			if progress == nil {
+				return 0
+			}
+			return value(progress)
+		}
+	}
+
+	registry.MustRegister(
+		prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Namespace: "horizon", Subsystem: "ingest", Name: "reingest_ledgers_total",
+				Help: "number of ledgers in the ranges of the current (or last) reingestion",
+			},
+			reingest(func(progress *protocol.ReingestProgress) float64 {
+				return float64(progress.TotalLedgers)
+			}),
+		),
+		prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Namespace: "horizon", Subsystem: "ingest", Name: "reingest_ledgers_done",
+				Help: "number of reingested ledgers of the current (or last) reingestion",
+			},
+			reingest(func(progress *protocol.ReingestProgress) float64 {
+				return float64(progress.LedgersDone)
+			}),
+		),
+		prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Namespace: "horizon", Subsystem: "ingest", Name: "reingest_ledgers_per_second",
+				Help: "average reingestion throughput of the current (or last) reingestion",
+			},
+			reingest(func(progress *protocol.ReingestProgress) float64 {
+				return progress.LedgersPerSecond
+			}),
+		),
+		prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Namespace: "horizon", Subsystem: "ingest", Name: "reingest_eta_seconds",
+				Help: "estimated time left to complete the current reingestion, 0 when idle",
+			},
+			reingest(func(progress *protocol.ReingestProgress) float64 {
+				if progress.ETASeconds == nil {
+					return 0
+				}
+				return *progress.ETASeconds
+			}),
+		),
+		prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Namespace: "horizon", Subsystem: "ingest", Name: "reingest_active_workers",
+				Help: "number of workers reingesting a range",
+			},
+			reingest(func(progress *protocol.ReingestProgress) float64 {
+				return float64(len(progress.Workers))
+			}),
+		),
+		prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Namespace: "horizon", Subsystem: "ingest", Name: "build_state_entries_processed",
+				Help: "number of ledger entries read from the history archive checkpoint by the current (or last) state build",
+			},
+			buildState(func(progress *protocol.BuildStateProgress) float64 {
+				return float64(progress.EntriesProcessed)
+			}),
+		),
+		prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Namespace: "horizon", Subsystem: "ingest", Name: "build_state_progress_percent",
+				Help: "percentage of the history archive checkpoint buckets read by the current (or last) state build",
+			},
+			buildState(func(progress *protocol.BuildStateProgress) float64 {
+				return progress.ProgressPercent
+			}),
+		),
+		prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Namespace: "horizon", Subsystem: "ingest", Name: "build_state_eta_seconds",
+				Help: "estimated time left to complete the current state build, 0 when idle",
+			},
+			buildState(func(progress *protocol.BuildStateProgress) float64 {
+				if progress.ETASeconds == nil {
+					return 0
+				}
+				return *progress.ETASeconds
+			}),
+		),
+	)
+}
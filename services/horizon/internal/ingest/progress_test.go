@@ -0,0 +1,124 @@
+package ingest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stellar/go/services/horizon/internal/db2/history"
+)
+
+func TestReingestProgress(t *testing.T) {
+	now := time.Unix(1000, 0)
+	progress := NewProgressTracker()
+	progress.now = func() time.Time { return now }
+
+	assert.Nil(t, progress.IngestionProgress().Reingest)
+
+	ranges := []history.LedgerRange{
+		{StartSequence: 101, EndSequence: 200},
+		{StartSequence: 1, EndSequence: 100},
+	}
+	require.True(t, progress.startReingest(ranges))
+	// Workers of ParallelSystems don't restart the reingestion
+	assert.False(t, progress.startReingest(ranges[:1]))
+
+	progress.startReingestRange(0, history.LedgerRange{StartSequence: 1, EndSequence: 64})
+	progress.startReingestRange(1, history.LedgerRange{StartSequence: 65, EndSequence: 128})
+	progress.ledgersReingested(0, 64)
+	progress.finishReingestRange(0)
+	progress.ledgersReingested(1, 80)
+	progress.startReingestRange(0, history.LedgerRange{StartSequence: 129, EndSequence: 192})
+
+	now = now.Add(10 * time.Second)
+	reingest := progress.IngestionProgress().Reingest
+	require.NotNil(t, reingest)
+	assert.Equal(t, uint32(1), reingest.FromLedger)
+	assert.Equal(t, uint32(200), reingest.ToLedger)
+	assert.Equal(t, uint32(200), reingest.TotalLedgers)
+	assert.Equal(t, uint32(80), reingest.LedgersDone)
+	assert.Equal(t, float64(40), reingest.ProgressPercent)
+	assert.Equal(t, float64(8), reingest.LedgersPerSecond)
+	require.NotNil(t, reingest.ETASeconds)
+	assert.Equal(t, float64(15), *reingest.ETASeconds)
+	require.Len(t, reingest.Workers, 2)
+	assert.Equal(t, 0, reingest.Workers[0].Worker)
+	assert.Equal(t, uint32(129), reingest.Workers[0].NextLedger)
+	assert.Equal(t, 1, reingest.Workers[1].Worker)
+	assert.Equal(t, uint32(81), reingest.Workers[1].NextLedger)
+
+	// Retrying a range doesn't count committed ledgers twice
+	progress.startReingestRange(1, history.LedgerRange{StartSequence: 65, EndSequence: 128})
+	assert.Equal(t, uint32(64), progress.IngestionProgress().Reingest.LedgersDone)
+
+	progress.finishReingest()
+	reingest = progress.IngestionProgress().Reingest
+	assert.NotNil(t, reingest.FinishedAt)
+	assert.Nil(t, reingest.ETASeconds)
+	assert.Empty(t, reingest.Workers)
+
+	// A new reingestion can start after the previous one finished
+	assert.True(t, progress.startReingest(ranges[:1]))
+	assert.Equal(t, uint32(100), progress.IngestionProgress().Reingest.TotalLedgers)
+}
+
+func TestBuildStateProgress(t *testing.T) {
+	now := time.Unix(1000, 0)
+	progress := NewProgressTracker()
+	progress.now = func() time.Time { return now }
+
+	progress.startBuildState(127)
+	progress.buildStateRead(5000, 25)
+	now = now.Add(20 * time.Second)
+
+	buildState := progress.IngestionProgress().BuildState
+	require.NotNil(t, buildState)
+	assert.Equal(t, uint32(127), buildState.CheckpointLedger)
+	assert.Equal(t, uint64(5000), buildState.EntriesProcessed)
+	assert.Equal(t, float64(250), buildState.EntriesPerSecond)
+	require.NotNil(t, buildState.ETASeconds)
+	assert.Equal(t, float64(60), *buildState.ETASeconds)
+
+	progress.finishBuildState()
+	buildState = progress.IngestionProgress().BuildState
+	assert.Equal(t, float64(100), buildState.ProgressPercent)
+	assert.NotNil(t, buildState.FinishedAt)
+	assert.Nil(t, buildState.ETASeconds)
+}
+
+func TestNilProgressTracker(t *testing.T) {
+	var progress *ProgressTracker
+	assert.False(t, progress.startReingest([]history.LedgerRange{{StartSequence: 1, EndSequence: 2}}))
+	progress.startReingestRange(0, history.LedgerRange{StartSequence: 1, EndSequence: 2})
+	progress.ledgersReingested(0, 1)
+	progress.finishReingestRange(0)
+	progress.finishReingest()
+	progress.startBuildState(63)
+	progress.buildStateRead(1, 1)
+	progress.finishBuildState()
+	assert.Nil(t, progress.IngestionProgress().Reingest)
+}
+
+func TestProgressMetrics(t *testing.T) {
+	progress := NewProgressTracker()
+	registry := prometheus.NewRegistry()
+	progress.RegisterMetrics(registry)
+
+	progress.startReingest([]history.LedgerRange{{StartSequence: 1, EndSequence: 100}})
+	progress.startReingestRange(0, history.LedgerRange{StartSequence: 1, EndSequence: 100})
+	progress.ledgersReingested(0, 30)
+
+	metrics, err := registry.Gather()
+	require.NoError(t, err)
+	values := map[string]float64{}
+	for _, metric := range metrics {
+		values[metric.GetName()] = metric.GetMetric()[0].GetGauge().GetValue()
+	}
+	assert.Equal(t, float64(100), values["horizon_ingest_reingest_ledgers_total"])
+	assert.Equal(t, float64(30), values["horizon_ingest_reingest_ledgers_done"])
+	assert.Equal(t, float64(1), values["horizon_ingest_reingest_active_workers"])
+	assert.Equal(t, float64(0), values["horizon_ingest_build_state_progress_percent"])
+}
@@ -0,0 +1,326 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/stellar/go/services/horizon/internal/db2/history"
+	"github.com/stellar/go/support/errors"
+	logpkg "github.com/stellar/go/support/log"
+)
+
+const (
+	// reingestJobHeartbeatInterval is how often a worker updates the job it's
+	// processing.
+	reingestJobHeartbeatInterval = time.Minute
+	// reingestJobStaleAfter is the time after which a running job without a
+	// heartbeat is considered abandoned (ex. its process crashed) and can be
+	// claimed by another worker.
+	reingestJobStaleAfter = 10 * time.Minute
+)
+
+// reingestJobQueue distributes the ranges reingested by ParallelSystems
+// workers.
+type reingestJobQueue interface {
+	// claim returns the next range to be reingested by the worker or false if
+	// there are no more ranges.
+	claim(worker string) (history.LedgerRange, bool, error)
+	// release is called with the result of reingesting the range claimed by
+	// the worker.
+	release(worker string, reingestErr error) error
+}
+
+// memoryReingestJobQueue is a reingestJobQueue of ranges kept in memory.
+type memoryReingestJobQueue struct {
+	mutex  sync.Mutex
+	ranges []history.LedgerRange
+}
+
+func newMemoryReingestJobQueue(ranges []history.LedgerRange) *memoryReingestJobQueue {
+	return &memoryReingestJobQueue{ranges: ranges}
+}
+
+func (m *memoryReingestJobQueue) claim(worker string) (history.LedgerRange, bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if len(m.ranges) == 0 {
+		return history.LedgerRange{}, false, nil
+	}
+	next := m.ranges[0]
+	m.ranges = m.ranges[1:]
+	return next, true, nil
+}
+
+func (m *memoryReingestJobQueue) release(worker string, reingestErr error) error {
+	return nil
+}
+
+type reingestJobsQ interface {
+	history.QReingestJobs
+	Begin() error
+	Rollback() error
+	Commit() error
+}
+
+type runningReingestJob struct {
+	job           history.ReingestJob
+	stopHeartbeat chan struct{}
+	heartbeatDone chan struct{}
+}
+
+// dbReingestJobQueue is a reingestJobQueue of jobs stored in the Horizon DB,
+// so a rerun of the same reingestion skips completed ranges and processes
+// running on many machines can share the jobs.
+type dbReingestJobQueue struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	q      reingestJobsQ
+	// ledgerRanges are the reingested ranges, only jobs contained in one of
+	// them are claimed.
+	ledgerRanges []history.LedgerRange
+
+	// completedLedgers is the number of ledgers of the ranges in jobs
+	// completed before the queue was created.
+	completedLedgers uint32
+
+	mutex   sync.Mutex
+	running map[string]runningReingestJob
+}
+
+// newDBReingestJobQueue creates jobs, split in batches of batchSize ledgers,
+// for the parts of the ledger ranges not covered by existing jobs. Existing
+// jobs are reused even if they were created with a different batch size, jobs
+// crossing the boundaries of the ledger ranges are split first so ledgers
+// outside of the ranges are never reingested.
+func newDBReingestJobQueue(q reingestJobsQ, ledgerRanges []history.LedgerRange, batchSize uint32) (*dbReingestJobQueue, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	queue := &dbReingestJobQueue{
+		ctx:          ctx,
+		cancel:       cancel,
+		q:            q,
+		ledgerRanges: ledgerRanges,
+		running:      map[string]runningReingestJob{},
+	}
+
+	if err := queue.enqueue(ledgerRanges, batchSize); err != nil {
+		cancel()
+		return nil, err
+	}
+	return queue, nil
+}
+
+func (d *dbReingestJobQueue) enqueue(ledgerRanges []history.LedgerRange, batchSize uint32) error {
+	if err := d.q.Begin(); err != nil {
+		return errors.Wrap(err, "Error starting a transaction")
+	}
+	defer d.q.Rollback()
+
+	// Block other processes adding jobs for the same ledgers
+	if err := d.q.LockReingestJobs(d.ctx); err != nil {
+		return errors.Wrap(err, "Error locking reingest jobs")
+	}
+
+	from, to := ledgerRanges[0].StartSequence, ledgerRanges[0].EndSequence
+	for _, ledgerRange := range ledgerRanges {
+		if ledgerRange.StartSequence < from {
+			from = ledgerRange.StartSequence
+		}
+		if ledgerRange.EndSequence > to {
+			to = ledgerRange.EndSequence
+		}
+	}
+	jobs, err := d.q.GetReingestJobs(d.ctx, from, to)
+	if err != nil {
+		return errors.Wrap(err, "Error getting reingest jobs")
+	}
+
+	var existing []history.ReingestJob
+	for _, job := range jobs {
+		parts := splitAtLedgerRanges(job.LedgerRange(), ledgerRanges)
+		if len(parts) > 1 {
+			if err = d.q.SplitReingestJob(d.ctx, job, parts, reingestJobStaleAfter); err != nil {
+				return errors.Wrap(err, "Error splitting reingest job at the boundaries of the ledger ranges")
+			}
+		}
+		for _, part := range parts {
+			if overlappingLedgers(ledgerRanges, part) > 0 {
+				job.StartLedger, job.EndLedger = part.StartSequence, part.EndSequence
+				existing = append(existing, job)
+			}
+		}
+	}
+
+	newJobs := splitLedgerRanges(uncoveredLedgerRanges(ledgerRanges, existing), batchSize)
+	if err = d.q.InsertReingestJobs(d.ctx, newJobs); err != nil {
+		return errors.Wrap(err, "Error inserting reingest jobs")
+	}
+
+	if err = d.q.Commit(); err != nil {
+		return errors.Wrap(err, "Error committing reingest jobs")
+	}
+
+	completed := 0
+	for _, job := range existing {
+		if job.Status == history.ReingestJobCompleted && job.IngestVersion >= CurrentVersion {
+			completed++
+			d.completedLedgers += overlappingLedgers(ledgerRanges, job.LedgerRange())
+		}
+	}
+	log.WithFields(logpkg.F{
+		"new_jobs":       len(newJobs),
+		"existing_jobs":  len(existing),
+		"completed_jobs": completed,
+	}).Info("Reingest jobs created")
+	return nil
+}
+
+func (d *dbReingestJobQueue) claim(worker string) (history.LedgerRange, bool, error) {
+	job, ok, err := d.q.ClaimReingestJob(d.ctx, d.ledgerRanges, CurrentVersion, worker, reingestJobStaleAfter)
+	if err != nil || !ok {
+		return history.LedgerRange{}, false, err
+	}
+	if job.Attempts > 1 {
+		log.WithFields(logpkg.F{
+			"from":     job.StartLedger,
+			"to":       job.EndLedger,
+			"attempts": job.Attempts,
+		}).Info("Retrying reingest job")
+	}
+
+	running := runningReingestJob{
+		job:           job,
+		stopHeartbeat: make(chan struct{}),
+		heartbeatDone: make(chan struct{}),
+	}
+	go d.heartbeat(running)
+
+	d.mutex.Lock()
+	d.running[worker] = running
+	d.mutex.Unlock()
+	return job.LedgerRange(), true, nil
+}
+
+func (d *dbReingestJobQueue) heartbeat(running runningReingestJob) {
+	defer close(running.heartbeatDone)
+	ticker := time.NewTicker(reingestJobHeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-running.stopHeartbeat:
+			return
+		case <-d.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.q.HeartbeatReingestJob(d.ctx, running.job); err != nil {
+				log.WithError(err).Warn("Error updating reingest job")
+			}
+		}
+	}
+}
+
+func (d *dbReingestJobQueue) release(worker string, reingestErr error) error {
+	d.mutex.Lock()
+	running, ok := d.running[worker]
+	delete(d.running, worker)
+	d.mutex.Unlock()
+	if !ok {
+		return errors.Errorf("worker %s has no running job", worker)
+	}
+
+	close(running.stopHeartbeat)
+	<-running.heartbeatDone
+
+	if reingestErr != nil {
+		return d.q.FailReingestJob(d.ctx, running.job, reingestErr.Error())
+	}
+	return d.q.CompleteReingestJob(d.ctx, running.job)
+}
+
+// close stops the heartbeats of running jobs.
+func (d *dbReingestJobQueue) close() {
+	d.cancel()
+}
+
+// uncoveredLedgerRanges returns the parts of the ledger ranges not covered by
+// the jobs, which must be sorted by start ledger.
+func uncoveredLedgerRanges(ledgerRanges []history.LedgerRange, jobs []history.ReingestJob) []history.LedgerRange {
+	var result []history.LedgerRange
+	for _, ledgerRange := range ledgerRanges {
+		from := ledgerRange.StartSequence
+		for _, job := range jobs {
+			if from > ledgerRange.EndSequence {
+				break
+			}
+			if job.EndLedger < from || job.StartLedger > ledgerRange.EndSequence {
+				continue
+			}
+			if job.StartLedger > from {
+				result = append(result, history.LedgerRange{StartSequence: from, EndSequence: job.StartLedger - 1})
+			}
+			from = job.EndLedger + 1
+		}
+		if from <= ledgerRange.EndSequence {
+			result = append(result, history.LedgerRange{StartSequence: from, EndSequence: ledgerRange.EndSequence})
+		}
+	}
+	return result
+}
+
+// splitAtLedgerRanges splits the job range at the boundaries of the ledger
+// ranges it crosses, so every part is either contained in one of the ledger
+// ranges or outside of all of them.
+func splitAtLedgerRanges(job history.LedgerRange, ledgerRanges []history.LedgerRange) []history.LedgerRange {
+	var cuts []uint32
+	for _, ledgerRange := range ledgerRanges {
+		if job.StartSequence < ledgerRange.StartSequence && ledgerRange.StartSequence <= job.EndSequence {
+			cuts = append(cuts, ledgerRange.StartSequence)
+		}
+		if job.StartSequence <= ledgerRange.EndSequence && ledgerRange.EndSequence < job.EndSequence {
+			cuts = append(cuts, ledgerRange.EndSequence+1)
+		}
+	}
+	sort.Slice(cuts, func(i, j int) bool { return cuts[i] < cuts[j] })
+
+	var parts []history.LedgerRange
+	from := job.StartSequence
+	for _, cut := range cuts {
+		if cut > from {
+			parts = append(parts, history.LedgerRange{StartSequence: from, EndSequence: cut - 1})
+			from = cut
+		}
+	}
+	return append(parts, history.LedgerRange{StartSequence: from, EndSequence: job.EndSequence})
+}
+
+// overlappingLedgers returns the number of ledgers of the ledger ranges in
+// the other range.
+func overlappingLedgers(ledgerRanges []history.LedgerRange, other history.LedgerRange) uint32 {
+	var count uint32
+	for _, ledgerRange := range ledgerRanges {
+		from, to := ledgerRange.StartSequence, ledgerRange.EndSequence
+		if other.StartSequence > from {
+			from = other.StartSequence
+		}
+		if other.EndSequence < to {
+			to = other.EndSequence
+		}
+		if from <= to {
+			count += to - from + 1
+		}
+	}
+	return count
+}
+
+// reingestWorkerPrefix identifies the reingestion process in job worker names.
+func reingestWorkerPrefix() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
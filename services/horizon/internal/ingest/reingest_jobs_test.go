@@ -0,0 +1,304 @@
+package ingest
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stellar/go/services/horizon/internal/db2/history"
+	"github.com/stellar/go/support/errors"
+)
+
+// fakeReingestJobsQ keeps reingest jobs in memory, running jobs are never
+// considered stale.
+type fakeReingestJobsQ struct {
+	mutex sync.Mutex
+	jobs  []history.ReingestJob
+}
+
+func (q *fakeReingestJobsQ) Begin() error    { return nil }
+func (q *fakeReingestJobsQ) Rollback() error { return nil }
+func (q *fakeReingestJobsQ) Commit() error   { return nil }
+
+func (q *fakeReingestJobsQ) LockReingestJobs(ctx context.Context) error {
+	return nil
+}
+
+func (q *fakeReingestJobsQ) GetReingestJobs(ctx context.Context, from, to uint32) ([]history.ReingestJob, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	var result []history.ReingestJob
+	for _, job := range q.jobs {
+		if job.StartLedger <= to && job.EndLedger >= from {
+			result = append(result, job)
+		}
+	}
+	return result, nil
+}
+
+func (q *fakeReingestJobsQ) InsertReingestJobs(ctx context.Context, ledgerRanges []history.LedgerRange) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for _, ledgerRange := range ledgerRanges {
+		q.jobs = append(q.jobs, history.ReingestJob{
+			StartLedger: ledgerRange.StartSequence,
+			EndLedger:   ledgerRange.EndSequence,
+			Status:      history.ReingestJobPending,
+		})
+	}
+	sort.Slice(q.jobs, func(i, j int) bool {
+		return q.jobs[i].StartLedger < q.jobs[j].StartLedger
+	})
+	return nil
+}
+
+func (q *fakeReingestJobsQ) SplitReingestJob(ctx context.Context, job history.ReingestJob, ledgerRanges []history.LedgerRange, staleAfter time.Duration) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for i := range q.jobs {
+		if q.jobs[i].StartLedger != job.StartLedger || q.jobs[i].EndLedger != job.EndLedger {
+			continue
+		}
+		if q.jobs[i].Status == history.ReingestJobRunning {
+			return errors.Errorf("reingest job [%d, %d] is running", job.StartLedger, job.EndLedger)
+		}
+		split := q.jobs[i]
+		q.jobs = append(q.jobs[:i], q.jobs[i+1:]...)
+		for _, ledgerRange := range ledgerRanges {
+			split.StartLedger, split.EndLedger = ledgerRange.StartSequence, ledgerRange.EndSequence
+			q.jobs = append(q.jobs, split)
+		}
+		sort.Slice(q.jobs, func(i, j int) bool {
+			return q.jobs[i].StartLedger < q.jobs[j].StartLedger
+		})
+		return nil
+	}
+	return errors.New("job not found")
+}
+
+func (q *fakeReingestJobsQ) ClaimReingestJob(ctx context.Context, ledgerRanges []history.LedgerRange, ingestVersion int, worker string, staleAfter time.Duration) (history.ReingestJob, bool, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for i, job := range q.jobs {
+		contained := false
+		for _, ledgerRange := range ledgerRanges {
+			if job.StartLedger >= ledgerRange.StartSequence && job.EndLedger <= ledgerRange.EndSequence {
+				contained = true
+			}
+		}
+		if !contained {
+			continue
+		}
+		if job.Status == history.ReingestJobPending || job.Status == history.ReingestJobFailed ||
+			(job.Status == history.ReingestJobCompleted && job.IngestVersion < ingestVersion) {
+			q.jobs[i].Status = history.ReingestJobRunning
+			q.jobs[i].IngestVersion = ingestVersion
+			q.jobs[i].Attempts++
+			q.jobs[i].Worker = worker
+			return q.jobs[i], true, nil
+		}
+	}
+	return history.ReingestJob{}, false, nil
+}
+
+func (q *fakeReingestJobsQ) HeartbeatReingestJob(ctx context.Context, job history.ReingestJob) error {
+	return nil
+}
+
+func (q *fakeReingestJobsQ) CompleteReingestJob(ctx context.Context, job history.ReingestJob) error {
+	return q.update(job, history.ReingestJobCompleted, "")
+}
+
+func (q *fakeReingestJobsQ) FailReingestJob(ctx context.Context, job history.ReingestJob, reason string) error {
+	return q.update(job, history.ReingestJobFailed, reason)
+}
+
+func (q *fakeReingestJobsQ) update(job history.ReingestJob, status history.ReingestJobStatus, reason string) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for i := range q.jobs {
+		if q.jobs[i].StartLedger == job.StartLedger && q.jobs[i].EndLedger == job.EndLedger {
+			q.jobs[i].Status = status
+			q.jobs[i].LastError = reason
+			return nil
+		}
+	}
+	return errors.New("job not found")
+}
+
+func TestUncoveredLedgerRanges(t *testing.T) {
+	jobs := []history.ReingestJob{
+		{StartLedger: 1, EndLedger: 64},
+		{StartLedger: 129, EndLedger: 192},
+		{StartLedger: 193, EndLedger: 256},
+		{StartLedger: 400, EndLedger: 500},
+	}
+	assert.Equal(t, []history.LedgerRange{
+		{StartSequence: 65, EndSequence: 128},
+		{StartSequence: 257, EndSequence: 300},
+		{StartSequence: 350, EndSequence: 399},
+		{StartSequence: 501, EndSequence: 600},
+	}, uncoveredLedgerRanges([]history.LedgerRange{
+		{StartSequence: 10, EndSequence: 300},
+		{StartSequence: 350, EndSequence: 600},
+	}, jobs))
+	assert.Empty(t, uncoveredLedgerRanges([]history.LedgerRange{{StartSequence: 130, EndSequence: 250}}, jobs))
+}
+
+func TestSplitAtLedgerRanges(t *testing.T) {
+	ledgerRanges := []history.LedgerRange{
+		{StartSequence: 10, EndSequence: 300},
+		{StartSequence: 350, EndSequence: 600},
+	}
+	assert.Equal(t, []history.LedgerRange{
+		{StartSequence: 1, EndSequence: 9},
+		{StartSequence: 10, EndSequence: 64},
+	}, splitAtLedgerRanges(history.LedgerRange{StartSequence: 1, EndSequence: 64}, ledgerRanges))
+	assert.Equal(t, []history.LedgerRange{
+		{StartSequence: 257, EndSequence: 300},
+		{StartSequence: 301, EndSequence: 349},
+		{StartSequence: 350, EndSequence: 400},
+	}, splitAtLedgerRanges(history.LedgerRange{StartSequence: 257, EndSequence: 400}, ledgerRanges))
+	assert.Equal(t, []history.LedgerRange{
+		{StartSequence: 500, EndSequence: 600},
+		{StartSequence: 601, EndSequence: 700},
+	}, splitAtLedgerRanges(history.LedgerRange{StartSequence: 500, EndSequence: 700}, ledgerRanges))
+	assert.Equal(t, []history.LedgerRange{
+		{StartSequence: 1, EndSequence: 9},
+		{StartSequence: 10, EndSequence: 300},
+		{StartSequence: 301, EndSequence: 349},
+		{StartSequence: 350, EndSequence: 600},
+		{StartSequence: 601, EndSequence: 700},
+	}, splitAtLedgerRanges(history.LedgerRange{StartSequence: 1, EndSequence: 700}, ledgerRanges))
+	// Jobs contained in a range or outside of all of them are not split
+	assert.Equal(t, []history.LedgerRange{{StartSequence: 10, EndSequence: 300}},
+		splitAtLedgerRanges(history.LedgerRange{StartSequence: 10, EndSequence: 300}, ledgerRanges))
+	assert.Equal(t, []history.LedgerRange{{StartSequence: 301, EndSequence: 349}},
+		splitAtLedgerRanges(history.LedgerRange{StartSequence: 301, EndSequence: 349}, ledgerRanges))
+}
+
+func TestDBReingestJobQueueSplitsJobs(t *testing.T) {
+	jobsQ := &fakeReingestJobsQ{}
+	require.NoError(t, jobsQ.InsertReingestJobs(context.Background(), []history.LedgerRange{
+		{StartSequence: 1, EndSequence: 256},
+		{StartSequence: 257, EndSequence: 512},
+	}))
+	jobsQ.jobs[0].Status = history.ReingestJobCompleted
+	jobsQ.jobs[0].IngestVersion = CurrentVersion
+
+	// Ledgers in the gap between the ranges and after the last range are
+	// never claimed.
+	ledgerRanges := []history.LedgerRange{
+		{StartSequence: 100, EndSequence: 200},
+		{StartSequence: 300, EndSequence: 400},
+	}
+	queue, err := newDBReingestJobQueue(jobsQ, ledgerRanges, 64)
+	require.NoError(t, err)
+	defer queue.close()
+	assert.Equal(t, uint32(101), queue.completedLedgers)
+
+	ledgerRange, ok, err := queue.claim("worker")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, history.LedgerRange{StartSequence: 300, EndSequence: 400}, ledgerRange)
+	require.NoError(t, queue.release("worker", nil))
+	_, ok, err = queue.claim("worker")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	var ranges []history.LedgerRange
+	for _, job := range jobsQ.jobs {
+		ranges = append(ranges, job.LedgerRange())
+	}
+	assert.Equal(t, []history.LedgerRange{
+		{StartSequence: 1, EndSequence: 99},
+		{StartSequence: 100, EndSequence: 200},
+		{StartSequence: 201, EndSequence: 256},
+		{StartSequence: 257, EndSequence: 299},
+		{StartSequence: 300, EndSequence: 400},
+		{StartSequence: 401, EndSequence: 512},
+	}, ranges)
+	assert.Equal(t, history.ReingestJobCompleted, jobsQ.jobs[0].Status)
+	assert.Equal(t, history.ReingestJobPending, jobsQ.jobs[3].Status)
+	assert.Equal(t, history.ReingestJobPending, jobsQ.jobs[5].Status)
+
+	// Running jobs are not split
+	jobsQ.jobs[5].Status = history.ReingestJobRunning
+	_, err = newDBReingestJobQueue(jobsQ, []history.LedgerRange{{StartSequence: 401, EndSequence: 450}}, 64)
+	assert.EqualError(t, err, "Error splitting reingest job at the boundaries of the ledger ranges: reingest job [401, 512] is running")
+}
+
+func TestNewParallelSystemsResumeReingestion(t *testing.T) {
+	system, err := NewParallelSystems(Config{}, 2)
+	require.NoError(t, err)
+	assert.Nil(t, system.jobsQ)
+
+	_, err = NewParallelSystems(Config{ResumeReingestion: true}, 2)
+	assert.EqualError(t, err, "resuming reingestion requires a history session")
+}
+
+func TestParallelReingestRangeResumesJobs(t *testing.T) {
+	jobsQ := &fakeReingestJobsQ{}
+	var (
+		rangesCalled []history.LedgerRange
+		m            sync.Mutex
+	)
+	result := &mockSystem{}
+	result.On("ReingestRange", []history.LedgerRange{{StartSequence: 257, EndSequence: 512}}, false).
+		Return(errors.New("failed because of foo")).Once()
+	result.On("ReingestRange", mock.AnythingOfType("[]history.LedgerRange"), false).Run(
+		func(args mock.Arguments) {
+			m.Lock()
+			defer m.Unlock()
+			rangesCalled = append(rangesCalled, args.Get(0).([]history.LedgerRange)...)
+		}).Return(error(nil))
+	factory := func(c Config) (System, error) {
+		return result, nil
+	}
+
+	system, err := newParallelSystems(Config{}, 1, factory)
+	require.NoError(t, err)
+	system.jobsQ = jobsQ
+	err = system.ReingestRange([]history.LedgerRange{{StartSequence: 1, EndSequence: 1024}}, 256)
+	assert.EqualError(t, err, "job failed, recommended restart range: [257, 1024]: error when processing [257, 512] range: failed because of foo")
+	assert.Equal(t, []history.LedgerRange{{StartSequence: 1, EndSequence: 256}}, rangesCalled)
+	require.Len(t, jobsQ.jobs, 4)
+	assert.Equal(t, history.ReingestJobCompleted, jobsQ.jobs[0].Status)
+	assert.Equal(t, history.ReingestJobFailed, jobsQ.jobs[1].Status)
+	assert.Equal(t, "failed because of foo", jobsQ.jobs[1].LastError)
+	assert.Equal(t, history.ReingestJobPending, jobsQ.jobs[2].Status)
+
+	// A rerun with a different batch size and a larger range retries the
+	// failed job, skips completed ones and creates jobs for new ledgers.
+	rangesCalled = nil
+	progress := NewProgressTracker()
+	system, err = newParallelSystems(Config{Progress: progress}, 2, factory)
+	require.NoError(t, err)
+	system.jobsQ = jobsQ
+	err = system.ReingestRange([]history.LedgerRange{{StartSequence: 1, EndSequence: 1100}}, 64)
+	require.NoError(t, err)
+	sort.Slice(rangesCalled, func(i, j int) bool {
+		return rangesCalled[i].StartSequence < rangesCalled[j].StartSequence
+	})
+	assert.Equal(t, []history.LedgerRange{
+		{StartSequence: 257, EndSequence: 512},
+		{StartSequence: 513, EndSequence: 768},
+		{StartSequence: 769, EndSequence: 1024},
+		{StartSequence: 1025, EndSequence: 1088},
+		{StartSequence: 1089, EndSequence: 1100},
+	}, rangesCalled)
+	for _, job := range jobsQ.jobs {
+		assert.Equal(t, history.ReingestJobCompleted, job.Status)
+		assert.Equal(t, CurrentVersion, job.IngestVersion)
+	}
+	assert.Equal(t, 2, jobsQ.jobs[1].Attempts)
+	// Ledgers of jobs completed by the previous run are counted as done
+	assert.Equal(t, uint32(256), progress.IngestionProgress().Reingest.LedgersDone)
+	result.AssertExpectations(t)
+}
@@ -97,13 +97,15 @@ func initIngester(app *App) {
 		coreSession = mustNewDBSession(
 			db.CoreSubservice, app.config.StellarCoreDatabaseURL, ingest.MaxDBConnections, ingest.MaxDBConnections, app.prometheusRegistry)
 	}
-	app.ingester, err = ingest.NewSystem(ingest.Config{
+	app.ingestionProgress = ingest.NewProgressTracker()
+	ingestConfig := ingest.Config{
 		CoreSession: coreSession,
 		HistorySession: mustNewDBSession(
 			db.IngestSubservice, app.config.DatabaseURL, ingest.MaxDBConnections, ingest.MaxDBConnections, app.prometheusRegistry,
 		),
 		NetworkPassphrase:                    app.config.NetworkPassphrase,
 		HistoryArchiveURLs:                   app.config.HistoryArchiveURLs,
+		HistoryArchiveCache:                  app.config.HistoryArchiveCacheOptions(),
 		CheckpointFrequency:                  app.config.CheckpointFrequency,
 		StellarCoreURL:                       app.config.StellarCoreURL,
 		StellarCoreCursor:                    app.config.CursorName,
@@ -112,19 +114,31 @@ func initIngester(app *App) {
 		CaptiveCoreConfigUseDB:               app.config.CaptiveCoreConfigUseDB,
 		CaptiveCoreToml:                      app.config.CaptiveCoreToml,
 		RemoteCaptiveCoreURL:                 app.config.RemoteCaptiveCoreURL,
+		LedgerFilesURL:                       app.config.LedgerFilesURL,
 		EnableCaptiveCore:                    app.config.EnableCaptiveCoreIngestion,
 		DisableStateVerification:             app.config.IngestDisableStateVerification,
 		StateVerificationCheckpointFrequency: uint32(app.config.IngestStateVerificationCheckpointFrequency),
 		StateVerificationTimeout:             app.config.IngestStateVerificationTimeout,
-		EnableReapLookupTables:               app.config.HistoryRetentionCount > 0,
+		EnableReapLookupTables:               app.config.HistoryRetentionCount > 0 || len(app.config.HistoryRetentionCounts) > 0,
 		EnableExtendedLogLedgerStats:         app.config.IngestEnableExtendedLogLedgerStats,
 		RoundingSlippageFilter:               app.config.RoundingSlippageFilter,
 		EnableIngestionFiltering:             app.config.EnableIngestionFiltering,
-	})
-
+		CaptiveCoreRegistry:                  app.prometheusRegistry,
+		Progress:                             app.ingestionProgress,
+	}
+	app.ingester, err = ingest.NewSystem(ingestConfig)
 	if err != nil {
 		log.Fatal(err)
 	}
+
+	if app.config.EnableIngestionFilterBackfill {
+		app.filterBackfiller, err = ingest.NewFilterBackfiller(ingest.FilterBackfillConfig{
+			IngestConfig: ingestConfig,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
 }
 
 func initPathFinder(app *App) {
@@ -226,6 +240,7 @@ func initIngestMetrics(app *App) {
 
 	app.ingestingGauge.Inc()
 	app.ingester.RegisterMetrics(app.prometheusRegistry)
+	app.ingestionProgress.RegisterMetrics(app.prometheusRegistry)
 }
 
 func initTxSubMetrics(app *App) {
@@ -233,6 +248,10 @@ func initTxSubMetrics(app *App) {
 	app.submitter.RegisterMetrics(app.prometheusRegistry)
 }
 
+func initReapMetrics(app *App) {
+	app.reaper.RegisterMetrics(app.prometheusRegistry)
+}
+
 func initWebMetrics(app *App) {
 	app.webServer.RegisterMetrics(app.prometheusRegistry)
 }
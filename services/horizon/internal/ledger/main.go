@@ -28,6 +28,18 @@ type HorizonStatus struct {
 	HistoryLatestClosedAt time.Time `db:"history_latest_closed_at"`
 	HistoryElder          int32     `db:"history_elder"`
 	ExpHistoryLatest      uint32    `db:"exp_history_latest"`
+	// HistoryTableElders contains the oldest ledger of history tables which
+	// retain a different number of ledgers than history_ledgers.
+	HistoryTableElders map[string]int32
+}
+
+// HistoryTableElder returns the oldest ledger of the history table. It's the
+// oldest ledger in history_ledgers unless the table has its own retention.
+func (s HorizonStatus) HistoryTableElder(table string) int32 {
+	if elder, ok := s.HistoryTableElders[table]; ok && elder != 0 {
+		return elder
+	}
+	return s.HistoryElder
 }
 
 // State is an in-memory data structure which holds a snapshot of both
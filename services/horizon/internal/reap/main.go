@@ -1,38 +1,193 @@
 // Package reap contains the history reaping subsystem for horizon.  This system
 // is designed to remove data from the history database such that it does not
 // grow indefinitely.  The system can be configured with a number of ledgers to
-// maintain at a minimum.
+// maintain at a minimum, globally and for individual history tables.
 package reap
 
 import (
 	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/stellar/go/services/horizon/internal/db2/history"
 	"github.com/stellar/go/services/horizon/internal/ledger"
 	"github.com/stellar/go/support/db"
+	"github.com/stellar/go/support/errors"
 )
 
+// DefaultBatchSize is the default number of ledgers removed from a history
+// table in a single database transaction.
+const DefaultBatchSize = 10_000
+
+// tableFamilies groups history tables storing the same kind of resources so
+// retention can be configured for all of them at once.
+var tableFamilies = map[string][]string{
+	"ledgers": {"history_ledgers"},
+	"transactions": {
+		"history_transactions",
+		"history_transaction_participants",
+		"history_transaction_claimable_balances",
+		"history_transaction_liquidity_pools",
+	},
+	"operations": {
+		"history_operations",
+		"history_operation_participants",
+		"history_operation_claimable_balances",
+		"history_operation_liquidity_pools",
+	},
+	"effects": {"history_effects"},
+	"trades":  {"history_trades", "history_trades_60000"},
+}
+
+// Config configures the reaper.
+type Config struct {
+	// RetentionCount is the number of ledgers retained in history tables
+	// without a specific retention count. 0 retains all history.
+	RetentionCount uint
+	// TableRetentionCounts overrides RetentionCount for individual history
+	// tables. 0 retains all history of the table.
+	TableRetentionCounts map[string]uint
+	// BatchSize is the number of ledgers removed from a history table in a
+	// single database transaction. Defaults to DefaultBatchSize.
+	BatchSize uint32
+}
+
 // System represents the history reaping subsystem of horizon.
 type System struct {
-	HistoryQ       *history.Q
-	RetentionCount uint
-	ledgerState    *ledger.State
-	ctx            context.Context
-	cancel         context.CancelFunc
+	HistoryQ             *history.Q
+	RetentionCount       uint
+	TableRetentionCounts map[string]uint
+	BatchSize            uint32
+	ledgerState          *ledger.State
+	ctx                  context.Context
+	cancel               context.CancelFunc
+
+	deletedRowsCounter *prometheus.CounterVec
+	durationSummary    *prometheus.SummaryVec
 }
 
 // New initializes the reaper, causing it to begin polling the stellar-core
 // database for now ledgers and ingesting data into the horizon database.
-func New(retention uint, dbSession db.SessionInterface, ledgerState *ledger.State) *System {
+func New(config Config, dbSession db.SessionInterface, ledgerState *ledger.State) *System {
 	ctx, cancel := context.WithCancel(context.Background())
 
+	if config.BatchSize == 0 {
+		config.BatchSize = DefaultBatchSize
+	}
+
 	r := &System{
-		HistoryQ:       &history.Q{dbSession.Clone()},
-		RetentionCount: retention,
-		ledgerState:    ledgerState,
-		ctx:            ctx,
-		cancel:         cancel,
+		HistoryQ:             &history.Q{dbSession.Clone()},
+		RetentionCount:       config.RetentionCount,
+		TableRetentionCounts: config.TableRetentionCounts,
+		BatchSize:            config.BatchSize,
+		ledgerState:          ledgerState,
+		ctx:                  ctx,
+		cancel:               cancel,
+		deletedRowsCounter: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "horizon", Subsystem: "reap", Name: "deleted_rows_total",
+				Help: "number of rows deleted from history tables by the reaper",
+			},
+			[]string{"table"},
+		),
+		durationSummary: prometheus.NewSummaryVec(
+			prometheus.SummaryOpts{
+				Namespace: "horizon", Subsystem: "reap", Name: "table_duration_seconds",
+				Help:       "time it takes to reap a history table",
+				Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+			},
+			[]string{"table"},
+		),
 	}
 
 	return r
 }
+
+// RegisterMetrics registers the reaper metrics in the provided registry.
+func (r *System) RegisterMetrics(registry *prometheus.Registry) {
+	registry.MustRegister(r.deletedRowsCounter, r.durationSummary)
+}
+
+// ParseRetentionCounts parses a comma separated list of `name=count` pairs
+// where name is a history table or one of the table families: ledgers,
+// transactions, operations, effects and trades. Table entries take precedence
+// over family entries.
+func ParseRetentionCounts(value string) (map[string]uint, error) {
+	counts := map[string]uint{}
+	tableCounts := map[string]uint{}
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, errors.Errorf("invalid retention count %q, expected name=count", pair)
+		}
+		name := strings.TrimSpace(parts[0])
+		count, err := strconv.ParseUint(strings.TrimSpace(parts[1]), 10, 32)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid retention count for %s", name)
+		}
+
+		if tables, ok := tableFamilies[name]; ok {
+			for _, table := range tables {
+				counts[table] = uint(count)
+			}
+		} else if _, ok := history.HistoryTableIDColumns[name]; ok {
+			tableCounts[name] = uint(count)
+		} else {
+			return nil, errors.Errorf("unknown history table or table family %s", name)
+		}
+	}
+
+	for table, count := range tableCounts {
+		counts[table] = count
+	}
+	return counts, nil
+}
+
+// TablesWithOwnRetention returns the history tables retaining a different
+// number of ledgers than history_ledgers sorted by table name.
+func (r *System) TablesWithOwnRetention() []string {
+	counts := r.retentionCounts()
+	var ledgersCount uint
+	for _, retention := range counts {
+		if retention.table == "history_ledgers" {
+			ledgersCount = retention.count
+		}
+	}
+
+	var tables []string
+	for _, retention := range counts {
+		if retention.count != ledgersCount {
+			tables = append(tables, retention.table)
+		}
+	}
+	return tables
+}
+
+// retentionCounts returns the number of ledgers retained in every history
+// table sorted by table name.
+func (r *System) retentionCounts() []tableRetention {
+	var result []tableRetention
+	for table := range history.HistoryTableIDColumns {
+		count := r.RetentionCount
+		if tableCount, ok := r.TableRetentionCounts[table]; ok {
+			count = tableCount
+		}
+		result = append(result, tableRetention{table: table, count: count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].table < result[j].table
+	})
+	return result
+}
+
+type tableRetention struct {
+	table string
+	count uint
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+
 	herrors "github.com/stellar/go/services/horizon/internal/errors"
 	"github.com/stellar/go/support/errors"
 	"github.com/stellar/go/support/log"
@@ -11,28 +13,49 @@ import (
 )
 
 // DeleteUnretainedHistory removes all data associated with unretained ledgers.
+// Every history table is reaped separately, using its own retention count if
+// configured.
 func (r *System) DeleteUnretainedHistory(ctx context.Context) error {
-	// RetentionCount of 0 indicates "keep all history"
-	if r.RetentionCount == 0 {
-		return nil
+	latest := r.ledgerState.CurrentStatus()
+
+	for _, retention := range r.retentionCounts() {
+		// A retention count of 0 indicates "keep all history"
+		if retention.count == 0 {
+			continue
+		}
+
+		targetElder := (latest.HistoryLatest - int32(retention.count)) + 1
+		if err := r.reapTable(ctx, retention.table, targetElder); err != nil {
+			return errors.Wrapf(err, "Error reaping %s", retention.table)
+		}
 	}
 
-	var (
-		latest      = r.ledgerState.CurrentStatus()
-		targetElder = (latest.HistoryLatest - int32(r.RetentionCount)) + 1
-	)
+	return nil
+}
 
-	if targetElder < latest.HistoryElder {
+// reapTable removes the rows of the history table belonging to ledgers older
+// than targetElder.
+func (r *System) reapTable(ctx context.Context, table string, targetElder int32) error {
+	elder, err := r.HistoryQ.HistoryTableElderLedger(ctx, table)
+	if err != nil {
+		return err
+	}
+	if elder == 0 || targetElder <= elder {
 		return nil
 	}
 
-	err := r.clearBefore(ctx, latest.HistoryElder, targetElder)
+	startTime := time.Now()
+	deleted, err := r.clearBefore(ctx, table, elder, targetElder)
+	r.deletedRowsCounter.With(prometheus.Labels{"table": table}).Add(float64(deleted))
 	if err != nil {
 		return err
 	}
+	r.durationSummary.With(prometheus.Labels{"table": table}).Observe(time.Since(startTime).Seconds())
 
 	log.
+		WithField("table", table).
 		WithField("new_elder", targetElder).
+		WithField("deleted_rows", deleted).
 		Info("reaper succeeded")
 
 	return nil
@@ -70,49 +93,65 @@ func (r *System) runOnce(ctx context.Context) {
 	}
 }
 
-// Work backwards in 100k ledger blocks to prevent using all the CPU.
-//
-// This runs every hour, so we need to make sure it doesn't
-// run for longer than an hour.
-//
-// Current ledger at 2021-08-12 is 36,827,497, so 100k means 368 batches. At 1
-// batch/second, that seems like a reasonable balance between running well
-// under an hour, and slowing it down enough to leave some CPU for other
-// processes.
-var batchSize = int32(100_000)
+// sleep is the pause between deleting batches, leaving some CPU and IO for
+// other processes and letting other queries acquire the locks.
 var sleep = 1 * time.Second
 
-func (r *System) clearBefore(ctx context.Context, startSeq, endSeq int32) error {
-	for batchEndSeq := endSeq - 1; batchEndSeq >= startSeq; batchEndSeq -= batchSize {
-		batchStartSeq := batchEndSeq - batchSize
-		if batchStartSeq < startSeq {
-			batchStartSeq = startSeq
+// clearBefore deletes the rows of the history table belonging to ledgers in
+// [startSeq, endSeq) in batches of r.BatchSize ledgers, oldest first. Each
+// batch is deleted in a separate database transaction to avoid holding locks
+// for a long time. It returns the number of deleted rows.
+func (r *System) clearBefore(ctx context.Context, table string, startSeq, endSeq int32) (int64, error) {
+	var deleted int64
+	batchSize := int32(r.BatchSize)
+	for batchStartSeq := startSeq; batchStartSeq < endSeq; batchStartSeq += batchSize {
+		batchEndSeq := batchStartSeq + batchSize - 1
+		if batchEndSeq >= endSeq {
+			batchEndSeq = endSeq - 1
 		}
-		log.WithField("start_ledger", batchStartSeq).WithField("end_ledger", batchEndSeq).Info("reaper: clearing")
+		log.
+			WithField("table", table).
+			WithField("start_ledger", batchStartSeq).
+			WithField("end_ledger", batchEndSeq).
+			Info("reaper: clearing")
 
-		batchStart, batchEnd, err := toid.LedgerRangeInclusive(batchStartSeq, batchEndSeq)
+		count, err := r.clearBatch(ctx, table, batchStartSeq, batchEndSeq)
 		if err != nil {
-			return err
+			return deleted, err
 		}
+		deleted += count
 
-		err = r.HistoryQ.Begin()
-		if err != nil {
-			return errors.Wrap(err, "Error in begin")
+		select {
+		case <-ctx.Done():
+			return deleted, ctx.Err()
+		case <-time.After(sleep):
 		}
-		defer r.HistoryQ.Rollback()
+	}
 
-		err = r.HistoryQ.DeleteRangeAll(ctx, batchStart, batchEnd)
-		if err != nil {
-			return errors.Wrap(err, "Error in DeleteRangeAll")
-		}
+	return deleted, nil
+}
 
-		err = r.HistoryQ.Commit()
-		if err != nil {
-			return errors.Wrap(err, "Error in commit")
-		}
+func (r *System) clearBatch(ctx context.Context, table string, startSeq, endSeq int32) (int64, error) {
+	batchStart, batchEnd, err := toid.LedgerRangeInclusive(startSeq, endSeq)
+	if err != nil {
+		return 0, err
+	}
+
+	err = r.HistoryQ.Begin()
+	if err != nil {
+		return 0, errors.Wrap(err, "Error in begin")
+	}
+	defer r.HistoryQ.Rollback()
 
-		time.Sleep(sleep)
+	count, err := r.HistoryQ.DeleteHistoryTableRange(ctx, table, batchStart, batchEnd)
+	if err != nil {
+		return 0, errors.Wrap(err, "Error in DeleteHistoryTableRange")
 	}
 
-	return nil
+	err = r.HistoryQ.Commit()
+	if err != nil {
+		return 0, errors.Wrap(err, "Error in commit")
+	}
+
+	return count, nil
 }
@@ -3,6 +3,10 @@ package reap
 import (
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stellar/go/services/horizon/internal/db2/history"
 	"github.com/stellar/go/services/horizon/internal/ledger"
 	"github.com/stellar/go/services/horizon/internal/test"
 )
@@ -15,7 +19,7 @@ func TestDeleteUnretainedHistory(t *testing.T) {
 
 	db := tt.HorizonSession()
 
-	sys := New(0, db, ledgerState)
+	sys := New(Config{}, db, ledgerState)
 
 	// Disable sleeps for this.
 	sleep = 0
@@ -52,3 +56,87 @@ func TestDeleteUnretainedHistory(t *testing.T) {
 		tt.Assert.Equal(1, cur)
 	}
 }
+
+func TestDeleteUnretainedHistoryPerTable(t *testing.T) {
+	tt := test.Start(t)
+	defer tt.Finish()
+	ledgerState := &ledger.State{}
+	ledgerState.SetStatus(tt.Scenario("kahuna"))
+
+	db := tt.HorizonSession()
+
+	sys := New(Config{
+		RetentionCount: 20,
+		TableRetentionCounts: map[string]uint{
+			"history_effects":      5,
+			"history_transactions": 0,
+		},
+		BatchSize: 3,
+	}, db, ledgerState)
+	sleep = 0
+
+	var prevTransactions, cur int
+	err := db.GetRaw(tt.Ctx, &prevTransactions, `SELECT COUNT(*) FROM history_transactions`)
+	tt.Require.NoError(err)
+
+	ledgerState.SetStatus(tt.LoadLedgerStatus())
+	latest := ledgerState.CurrentStatus().HistoryLatest
+	err = sys.DeleteUnretainedHistory(tt.Ctx)
+	tt.Require.NoError(err)
+
+	err = db.GetRaw(tt.Ctx, &cur, `SELECT COUNT(*) FROM history_ledgers`)
+	tt.Require.NoError(err)
+	tt.Assert.Equal(20, cur)
+
+	err = db.GetRaw(tt.Ctx, &cur, `SELECT COUNT(*) FROM history_transactions`)
+	tt.Require.NoError(err)
+	tt.Assert.Equal(prevTransactions, cur, "Transactions deleted when retention count == 0")
+
+	// The scenario has effects in every ledger from 31 to 58.
+	elder, err := sys.HistoryQ.HistoryTableElderLedger(tt.Ctx, "history_effects")
+	tt.Require.NoError(err)
+	tt.Assert.Equal(latest-4, elder)
+}
+
+func TestTablesWithOwnRetention(t *testing.T) {
+	sys := &System{RetentionCount: 100}
+	assert.Empty(t, sys.TablesWithOwnRetention())
+
+	sys.TableRetentionCounts = map[string]uint{
+		"history_effects":                20,
+		"history_operation_participants": 20,
+		"history_trades":                 100,
+	}
+	assert.Equal(t, []string{"history_effects", "history_operation_participants"}, sys.TablesWithOwnRetention())
+
+	// Every other table has its own retention when ledgers have one
+	sys.TableRetentionCounts = map[string]uint{"history_ledgers": 0}
+	assert.Len(t, sys.TablesWithOwnRetention(), len(history.HistoryTableIDColumns)-1)
+}
+
+func TestParseRetentionCounts(t *testing.T) {
+	counts, err := ParseRetentionCounts("")
+	require.NoError(t, err)
+	assert.Empty(t, counts)
+
+	counts, err = ParseRetentionCounts("history_operations=10, operations=100,effects=50,trades=0")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]uint{
+		"history_operations":                   10,
+		"history_operation_participants":       100,
+		"history_operation_claimable_balances": 100,
+		"history_operation_liquidity_pools":    100,
+		"history_effects":                      50,
+		"history_trades":                       0,
+		"history_trades_60000":                 0,
+	}, counts)
+
+	_, err = ParseRetentionCounts("history_accounts=10")
+	assert.EqualError(t, err, "unknown history table or table family history_accounts")
+
+	_, err = ParseRetentionCounts("effects")
+	assert.EqualError(t, err, `invalid retention count "effects", expected name=count`)
+
+	_, err = ParseRetentionCounts("effects=-1")
+	assert.Error(t, err)
+}
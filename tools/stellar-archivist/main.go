@@ -5,10 +5,15 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"os/signal"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
@@ -43,15 +48,18 @@ func status(a string, opts *Options) {
 }
 
 type Options struct {
-	Low         int
-	High        uint32
-	Last        int
-	Recent      bool
-	Profile     bool
-	Debug       bool
-	Trace       bool
-	CommandOpts historyarchive.CommandOptions
-	ConnectOpts historyarchive.ConnectOptions
+	Low            int
+	High           uint32
+	Last           int
+	Recent         bool
+	Profile        bool
+	Debug          bool
+	Trace          bool
+	Follow         bool
+	FollowInterval time.Duration
+	Output         string
+	CommandOpts    historyarchive.CommandOptions
+	ConnectOpts    historyarchive.ConnectOptions
 }
 
 func (opts *Options) SetRange(srcArch *historyarchive.Archive, dstArch *historyarchive.Archive) {
@@ -94,6 +102,16 @@ func (opts *Options) SetRange(srcArch *historyarchive.Archive, dstArch *historya
 
 }
 
+// followHigh returns the ledger at which --follow stops. It's 0 (never stop)
+// unless --high was given, --recent and --last ranges end at the current
+// ledger of the source archive and are extended as it grows.
+func (opts *Options) followHigh() uint32 {
+	if opts.Recent || opts.Last != -1 || opts.High == math.MaxUint32 {
+		return 0
+	}
+	return opts.High
+}
+
 func (opts *Options) MaybeProfile() {
 	if opts.Profile {
 		go func() {
@@ -123,6 +141,10 @@ func scan(a string, opts *Options) {
 	arch := historyarchive.MustConnect(a, opts.ConnectOpts)
 	opts.SetRange(arch, nil)
 	e1 := arch.Scan(&opts.CommandOpts)
+	if opts.Output == "json" {
+		scanReport(arch, e1, opts)
+		return
+	}
 	missing, e2 := arch.ReportMissing(&opts.CommandOpts)
 	invalid, e3 := arch.ReportInvalid(&opts.CommandOpts)
 	if e1 != nil {
@@ -142,12 +164,47 @@ func scan(a string, opts *Options) {
 	}
 }
 
+// scanReport prints the scan report as JSON. Only errors which prevented the
+// scan from completing are fatal before the report is printed.
+func scanReport(arch *historyarchive.Archive, scanErr error, opts *Options) {
+	if scanErr != nil {
+		log.Error(scanErr)
+	}
+	report := arch.Report(&opts.CommandOpts)
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		log.Fatal(errors.Wrap(err, "Error encoding report"))
+	}
+	if scanErr != nil || report.HasMissing() || report.InvalidCount() > 0 {
+		os.Exit(1)
+	}
+}
+
 func mirror(src string, dst string, opts *Options) {
 	srcArch := historyarchive.MustConnect(src, opts.ConnectOpts)
 	dstArch := historyarchive.MustConnect(dst, opts.ConnectOpts)
 	opts.SetRange(srcArch, dstArch)
 	log.Printf("mirroring %v -> %v\n", src, dst)
-	e := historyarchive.Mirror(srcArch, dstArch, &opts.CommandOpts)
+	var e error
+	if opts.Follow {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		e = historyarchive.MirrorFollow(ctx, srcArch, dstArch, &opts.CommandOpts, opts.FollowInterval, opts.followHigh())
+	} else {
+		e = historyarchive.Mirror(srcArch, dstArch, &opts.CommandOpts)
+	}
+	log.Printf("transferred %d bytes from %v, %d bytes to %v\n",
+		srcArch.BytesRead(), src, dstArch.BytesWritten(), dst)
+	if e != nil {
+		log.Fatal(e)
+	}
+}
+
+func prune(a string, opts *Options) {
+	arch := historyarchive.MustConnect(a, opts.ConnectOpts)
+	opts.SetRange(arch, nil)
+	e := arch.Prune(&opts.CommandOpts)
 	if e != nil {
 		log.Fatal(e)
 	}
@@ -229,6 +286,20 @@ func main() {
 		"S3 endpoint to use",
 	)
 
+	rootCmd.PersistentFlags().StringVar(
+		&opts.ConnectOpts.GCSEndpoint,
+		"gcsendpoint",
+		"",
+		"GCS JSON API endpoint to use (ex. an emulator)",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&opts.ConnectOpts.AzureEndpoint,
+		"azureendpoint",
+		"",
+		"Azure Blob service endpoint to use (ex. an emulator)",
+	)
+
 	rootCmd.PersistentFlags().BoolVarP(
 		&opts.CommandOpts.DryRun,
 		"dryrun",
@@ -266,6 +337,34 @@ func main() {
 		"skip optional (SCP) checkpoint files",
 	)
 
+	rootCmd.PersistentFlags().StringVar(
+		&opts.CommandOpts.StateFile,
+		"state-file",
+		"",
+		"record mirror progress in the file and resume from it",
+	)
+
+	rootCmd.PersistentFlags().BoolVar(
+		&opts.Follow,
+		"follow",
+		false,
+		"keep mirroring new checkpoints as they are published (until --high if given)",
+	)
+
+	rootCmd.PersistentFlags().DurationVar(
+		&opts.FollowInterval,
+		"follow-interval",
+		time.Minute,
+		"interval between checks for new checkpoints in --follow mode",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&opts.Output,
+		"output",
+		"text",
+		"output format of scan: text or json",
+	)
+
 	rootCmd.PersistentFlags().BoolVar(
 		&opts.Profile,
 		"profile",
@@ -307,6 +406,9 @@ func main() {
 	rootCmd.AddCommand(&cobra.Command{
 		Use: "scan",
 		Run: func(cmd *cobra.Command, args []string) {
+			if opts.Output != "text" && opts.Output != "json" {
+				log.Fatalf("invalid --output %q, expected text or json", opts.Output)
+			}
 			opts.SetupLogging()
 			opts.MaybeProfile()
 			scan(firstArg(args), &opts)
@@ -333,6 +435,15 @@ func main() {
 		},
 	})
 
+	rootCmd.AddCommand(&cobra.Command{
+		Use: "prune",
+		Run: func(cmd *cobra.Command, args []string) {
+			opts.SetupLogging()
+			opts.MaybeProfile()
+			prune(firstArg(args), &opts)
+		},
+	})
+
 	rootCmd.AddCommand(&cobra.Command{
 		Use: "dumpxdr",
 		Run: func(cmd *cobra.Command, args []string) {